@@ -0,0 +1,156 @@
+package endpoints
+
+import (
+	"time"
+
+	"github.com/kataras/iris/v12"
+	"github.com/kataras/iris/v12/context"
+	"github.com/kmilodenisglez/drones.restapi/api/middlewares"
+	"github.com/kmilodenisglez/drones.restapi/repo/db"
+	"github.com/kmilodenisglez/drones.restapi/schema"
+	"github.com/kmilodenisglez/drones.restapi/schema/dto"
+	"github.com/kmilodenisglez/drones.restapi/service"
+	"github.com/kmilodenisglez/drones.restapi/service/utils"
+)
+
+// AuditHandler  endpoint handler struct for the Audit trail
+type AuditHandler struct {
+	response *utils.SvcResponse
+	service  *service.ISvcAudit
+}
+
+// defaultAuditLimit maximum number of audit entries returned when no limit is requested
+const defaultAuditLimit = 100
+
+// NewAuditHandler create and register the handler for the Audit trail, only for the administrators
+//
+// - app [*iris.Application] ~ Iris App instance
+//
+// - MdwAuthChecker [*context.Handler] ~ Authentication checker middleware
+//
+// - svcR [*utils.SvcResponse] ~ GrantIntentResponse service instance
+//
+// - svcC [utils.SvcConfig] ~ Configuration service instance
+//
+// - svcL [*utils.SvcLogger] ~ Logger service instance
+func NewAuditHandler(app *iris.Application, mdwAuthChecker *context.Handler, svcR *utils.SvcResponse, svcC *utils.SvcConfig, svcL *utils.SvcLogger) AuditHandler { // --- VARS SETUP ---
+	repoAudit := db.NewRepoAudit(svcC, svcL)
+	svc := service.NewSvcAuditReqs(&repoAudit, svcL)
+	// registering protected / guarded router
+	h := AuditHandler{svcR, &svc}
+	mdwAdminChecker := middlewares.NewAdminCheckerMiddleware(svcR)
+
+	// Simple group: v1
+	v1 := app.Party("/api/v1")
+	{
+		// registering protected / guarded router
+		guardAuditRouter := v1.Party("/audit")
+		{
+			// --- GROUP / PARTY MIDDLEWARES ---
+			guardAuditRouter.Use(*mdwAuthChecker, mdwAdminChecker)
+
+			guardAuditRouter.Get("/", h.GetAuditEntries)
+			guardAuditRouter.Get("/verify", h.VerifyAuditChain)
+		}
+	}
+	return h
+}
+
+// GetAuditEntries get audit entries
+// @Summary Get the audit trail
+// @description.markdown GetAuditEntriesDescription
+// @Tags audit
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce json,application/msgpack
+// @Param	Authorization	header	string	true 	"Insert access token" default(Bearer <Add access token here>)
+// @Param   actor           query   string  false   "username of the actor"
+// @Param   action          query   string  false   "audit action"      Enums(database.populate, database.import, database.backup, database.restore, database.migrate, database.reset, drone.register, drone.update, drone.retire, drone.load_medications, drone.dispatch, drone.deliver, drone.returning, drone.return, drone.abort, auth.login, auth.login_failed, auth.logout, user.create)
+// @Param   target          query   string  false   "target of the action (e.g. the drone serial number)"
+// @Param   from            query   string  false   "RFC3339 timestamp, inclusive"
+// @Param   to              query   string  false   "RFC3339 timestamp, inclusive"
+// @Param   limit           query   int     false   "maximum number of entries (100 by default)"
+// @Success 200 {object} []dto.AuditEntry "OK"
+// @Failure 400 {object} dto.Problem "err.query_parameter"
+// @Failure 401 {object} dto.Problem "err.unauthorized"
+// @Failure 403 {object} dto.Problem "err.forbidden"
+// @Failure 406 {object} dto.Problem "err.not_acceptable"
+// @Failure 500 {object} dto.Problem "err.database_related"
+// @Router /audit [get]
+func (h AuditHandler) GetAuditEntries(ctx iris.Context) {
+	limit := ctx.URLParamIntDefault("limit", defaultAuditLimit)
+	if limit <= 0 {
+		h.response.ResErr(dto.NewProblem(iris.StatusBadRequest, schema.ErrParamURL, schema.DetInvalidLimit), &ctx)
+		return
+	}
+
+	filter := dto.AuditFilter{
+		Actor:  ctx.URLParamTrim("actor"),
+		Action: ctx.URLParamTrim("action"),
+		Target: ctx.URLParamTrim("target"),
+		From:   ctx.URLParamTrim("from"),
+		To:     ctx.URLParamTrim("to"),
+		Limit:  limit,
+	}
+	for _, ts := range []string{filter.From, filter.To} {
+		if ts == "" {
+			continue
+		}
+		if _, err := time.Parse(time.RFC3339, ts); err != nil {
+			h.response.ResErr(dto.NewProblem(iris.StatusBadRequest, schema.ErrParamURL, err.Error()), &ctx)
+			return
+		}
+	}
+
+	entries, problem := (*h.service).GetAuditEntriesSvc(ctx.Request().Context(), &filter)
+	if problem != nil {
+		h.response.ResErr(problem, &ctx)
+		return
+	}
+	h.response.ResOKWithList(entries, utils.ListFormats{}, &ctx)
+}
+
+// VerifyAuditChain verify the audit hash chain
+// @Summary Verify the audit trail hash chain
+// @Description Recompute every hash of the audit trail and report the first broken entry, if any. Only for the administrators
+// @Tags audit
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce json
+// @Param	Authorization	header	string	true 	"Insert access token" default(Bearer <Add access token here>)
+// @Success 200 {object} dto.AuditChainStatus "OK"
+// @Failure 401 {object} dto.Problem "err.unauthorized"
+// @Failure 403 {object} dto.Problem "err.forbidden"
+// @Failure 500 {object} dto.Problem "err.database_related"
+// @Router /audit/verify [get]
+func (h AuditHandler) VerifyAuditChain(ctx iris.Context) {
+	status, problem := (*h.service).VerifyAuditChainSvc(ctx.Request().Context())
+	if problem != nil {
+		h.response.ResErr(problem, &ctx)
+		return
+	}
+	h.response.ResOKWithData(status, &ctx)
+}
+
+// region ======== LOCAL DEPENDENCIES ====================================================
+
+// recordAudit append an audit entry for a state-changing operation. The operation has already
+// been committed, so a failure recording it is logged but never returned to the client
+//
+// - svcAudit [*service.ISvcAudit] ~ Audit trail service instance
+//
+// - actor [dto.InjectedParam] ~ The user that performed the operation
+//
+// - action [string] ~ One of the dto.AuditAction* constants
+//
+// - target [string] ~ Identifier of the affected resource
+//
+// - before, after [interface] ~ State snapshots around the operation
+//
+// - ctx [*iris.Context] ~ Iris Request context
+func recordAudit(svcAudit *service.ISvcAudit, actor dto.InjectedParam, action, target string, before, after interface{}, ctx *iris.Context) {
+	// the failure is logged by the service with the request ID
+	_ = (*svcAudit).RecordSvc((*ctx).Request().Context(), actor, action, target, before, after)
+}
+
+// endregion =============================================================================
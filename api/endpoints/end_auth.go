@@ -11,6 +11,7 @@ import (
 	"github.com/kmilodenisglez/drones.restapi/schema/mapper"
 	"github.com/kmilodenisglez/drones.restapi/service"
 	"github.com/kmilodenisglez/drones.restapi/service/auth"
+	"github.com/kmilodenisglez/drones.restapi/service/metrics"
 	"github.com/kmilodenisglez/drones.restapi/service/utils"
 )
 
@@ -18,6 +19,7 @@ type HAuth struct {
 	response  *utils.SvcResponse
 	appConf   *utils.SvcConfig
 	providers map[string]bool
+	audit     *service.ISvcAudit
 }
 
 // NewAuthHandler create and register the authentication handlers for the App. For the moment, all the
@@ -30,14 +32,18 @@ type HAuth struct {
 // - svcR [*utils.SvcResponse] ~ GrantIntentResponse service instance
 //
 // - svcC [utils.SvcConfig] ~ Configuration service instance
-func NewAuthHandler(app *iris.Application, mdwAuthChecker *context.Handler, svcR *utils.SvcResponse, svcC *utils.SvcConfig) HAuth { // --- VARS SETUP ---
-	h := HAuth{svcR, svcC, make(map[string]bool)}
+//
+// - svcL [*utils.SvcLogger] ~ Logger service instance
+func NewAuthHandler(app *iris.Application, mdwAuthChecker *context.Handler, svcR *utils.SvcResponse, svcC *utils.SvcConfig, svcL *utils.SvcLogger) HAuth { // --- VARS SETUP ---
+	repoAudit := db.NewRepoAudit(svcC, svcL)
+	svcAudit := service.NewSvcAuditReqs(&repoAudit, svcL)
+	h := HAuth{svcR, svcC, make(map[string]bool), &svcAudit}
 	// filling providers
 	h.providers["drones"] = true
 
-	repoDrones := db.NewRepoDrones(svcC)
+	repoDrones := db.NewRepoDrones(svcC, svcL)
 	svcAuth := auth.NewSvcAuthentication(h.providers, &repoDrones) // instantiating authentication Service
-	svcDrones := service.NewSvcDronesReqs(&repoDrones)
+	svcDrones := service.NewSvcDronesReqs(svcC, &repoDrones, svcL)
 
 	// Simple group: v1
 	v1 := app.Party("/api/v1")
@@ -85,23 +91,25 @@ func NewAuthHandler(app *iris.Application, mdwAuthChecker *context.Handler, svcR
 // @Produce json
 // @Param 	credential 	body 	dto.UserCredIn 	true	"User Login Credential"
 // @Success 200 "OK"
-// @Failure 401 {object} dto.Problem "err.unauthorized"
-// @Failure 400 {object} dto.Problem "err.wrong_auth_provider"
-// @Failure 504 {object} dto.Problem "err.network"
-// @Failure 500 {object} dto.Problem "err.json_parse"
+// @Failure 401 {object} dto.Problem "err.authentication"
+// @Failure 400 {object} dto.Problem "err.json_parse"
+// @Failure 503 {object} dto.Problem "err.database_not_populated"
+// @Failure 500 {object} dto.Problem "err.jwt_generation"
 // @Router /auth [post]
 func (h HAuth) authIntent(ctx iris.Context, uCred *dto.UserCredIn, svcAuth *auth.SvcAuthentication, r service.ISvcDrones) {
 	// using a provider named 'drones', also injecting dependencies
 	provider := "drones"
 
-	populate := r.IsPopulateDBSvc()
+	populate := r.IsPopulateDBSvc(ctx.Request().Context())
 	if !populate {
-		h.response.ResErr(&dto.Problem{Status: iris.StatusInternalServerError, Title: schema.ErrBuntdbNotPopulated, Detail: "The database has not been populated yet"}, &ctx)
+		h.response.ResErr(&dto.Problem{Status: iris.StatusServiceUnavailable, Code: schema.ErrBuntdbNotPopulated, Detail: schema.DetDatabaseNotPopulated}, &ctx)
 		return
 	}
 
-	authGrantedData, problem := svcAuth.AuthProviders[provider].GrantIntent(uCred, nil) // requesting authorization to evote (provider) mechanisms in this case
-	if problem != nil {                                                                 // check for errors
+	authGrantedData, problem := svcAuth.AuthProviders[provider].GrantIntent(ctx.Request().Context(), uCred, nil) // requesting authorization to evote (provider) mechanisms in this case
+	if problem != nil {                                                                                          // check for errors
+		metrics.IncLogin(false)
+		recordAudit(h.audit, dto.InjectedParam{Username: uCred.Username}, dto.AuditActionLoginFailed, uCred.Username, nil, nil, &ctx)
 		h.response.ResErr(problem, &ctx)
 		return
 	}
@@ -111,10 +119,12 @@ func (h HAuth) authIntent(ctx iris.Context, uCred *dto.UserCredIn, svcAuth *auth
 	tokenData := mapper.ToAccessTokenDataV(authGrantedData)
 	accessToken, err := lib.MkAccessToken(tokenData, []byte(h.appConf.JWTSignKey), h.appConf.TkMaxAge)
 	if err != nil {
-		h.response.ResErr(&dto.Problem{Status: iris.StatusInternalServerError, Title: schema.ErrJwtGen, Detail: err.Error()}, &ctx)
+		h.response.ResErr(&dto.Problem{Status: iris.StatusInternalServerError, Code: schema.ErrJwtGen, Detail: err.Error()}, &ctx)
 		return
 	}
 
+	metrics.IncLogin(true)
+	recordAudit(h.audit, tokenData.Claims, dto.AuditActionLogin, uCred.Username, nil, nil, &ctx)
 	h.response.ResOKWithData(string(accessToken), &ctx)
 }
 
@@ -130,12 +140,15 @@ func (h HAuth) authIntent(ctx iris.Context, uCred *dto.UserCredIn, svcAuth *auth
 // @Failure 500 {object} dto.Problem "err.generic
 // @Router /auth/logout [get]
 func (h HAuth) logout(ctx iris.Context) {
+	// the claims are read before the token is invalidated
+	actor := DepObtainUserDid(ctx)
 	err := ctx.Logout()
 
 	if err != nil {
-		h.response.ResErr(&dto.Problem{Status: iris.StatusInternalServerError, Title: schema.ErrGeneric, Detail: err.Error()}, &ctx)
+		h.response.ResErr(&dto.Problem{Status: iris.StatusInternalServerError, Code: schema.ErrGeneric, Detail: err.Error()}, &ctx)
 		return
 	}
+	recordAudit(h.audit, actor, dto.AuditActionLogout, actor.Username, nil, nil, &ctx)
 
 	// so far so good
 	h.response.ResOK(&ctx)
@@ -151,7 +164,7 @@ func (h HAuth) logout(ctx iris.Context) {
 // @Failure 500 {object} dto.Problem "err.generic
 // @Router /auth/user [get]
 func (h HAuth) userGet(ctx iris.Context, params dto.InjectedParam, r db.RepoDrones) {
-	user, err := r.GetUser(params.Did, true)
+	user, err := r.GetUser(ctx.Request().Context(), params.Did, true)
 	if err != nil {
 		h.response.ResErr(dto.NewProblem(iris.StatusInternalServerError, schema.ErrBuntdb, err.Error()), &ctx)
 		return
@@ -0,0 +1,201 @@
+package endpoints
+
+import (
+	"github.com/kataras/iris/v12"
+	"github.com/kataras/iris/v12/context"
+	"github.com/kmilodenisglez/drones.restapi/api/middlewares"
+	"github.com/kmilodenisglez/drones.restapi/repo/db"
+	"github.com/kmilodenisglez/drones.restapi/schema"
+	"github.com/kmilodenisglez/drones.restapi/schema/dto"
+	"github.com/kmilodenisglez/drones.restapi/service"
+	"github.com/kmilodenisglez/drones.restapi/service/backup"
+	"github.com/kmilodenisglez/drones.restapi/service/utils"
+)
+
+// BackupHandler  endpoint handler struct for the database backups
+type BackupHandler struct {
+	response *utils.SvcResponse
+	service  *backup.ISvcBackup
+	audit    *service.ISvcAudit
+}
+
+// NewBackupHandler create and register the handler for the database snapshots, export and import, they are
+// only available to the administrators
+//
+// - app [*iris.Application] ~ Iris App instance
+//
+// - MdwAuthChecker [*context.Handler] ~ Authentication checker middleware
+//
+// - svcR [*utils.SvcResponse] ~ GrantIntentResponse service instance
+//
+// - svcC [utils.SvcConfig] ~ Configuration service instance
+//
+// - svcL [*utils.SvcLogger] ~ Logger service instance
+func NewBackupHandler(app *iris.Application, mdwAuthChecker *context.Handler, svcR *utils.SvcResponse, svcC *utils.SvcConfig, svcL *utils.SvcLogger) BackupHandler { // --- VARS SETUP ---
+	repoDrones := db.NewRepoDrones(svcC, svcL)
+	repoEventLog := db.NewRepoEventLog(svcC, svcL)
+	svc := backup.NewSvcBackupReqs(svcC, &repoDrones, &repoEventLog, svcL)
+	repoAudit := db.NewRepoAudit(svcC, svcL)
+	svcAudit := service.NewSvcAuditReqs(&repoAudit, svcL)
+	h := BackupHandler{svcR, &svc, &svcAudit}
+	mdwAdminChecker := middlewares.NewAdminCheckerMiddleware(svcR)
+
+	// Simple group: v1
+	v1 := app.Party("/api/v1")
+	{
+		// registering protected / guarded router
+		guardAdminRouter := v1.Party("/admin")
+		{
+			// --- GROUP / PARTY MIDDLEWARES ---
+			guardAdminRouter.Use(*mdwAuthChecker, mdwAdminChecker)
+
+			guardAdminRouter.Get("/backups", h.ListSnapshots)
+			guardAdminRouter.Post("/backups", h.CreateSnapshot)
+			guardAdminRouter.Post("/backups/{id:string}/restore", h.RestoreSnapshot)
+			guardAdminRouter.Get("/export", h.Export)
+			guardAdminRouter.Post("/import", h.Import)
+		}
+	}
+	return h
+}
+
+// region ======== ENDPOINT HANDLERS =====================================================
+
+// ListSnapshots list the database snapshots
+// @Summary List the database snapshots
+// @Description The snapshots of the store and event log databases, the newest first
+// @Tags admin
+// @Security ApiKeyAuth
+// @Produce json,application/msgpack
+// @Param	Authorization	header	string	true 	"Insert access token" default(Bearer <Add access token here>)
+// @Success 200 {object} []dto.Snapshot "OK"
+// @Failure 401 {object} dto.Problem "err.unauthorized"
+// @Failure 403 {object} dto.Problem "err.forbidden"
+// @Failure 406 {object} dto.Problem "err.not_acceptable"
+// @Failure 500 {object} dto.Problem "err.system_file_related"
+// @Router /admin/backups [get]
+func (h BackupHandler) ListSnapshots(ctx iris.Context) {
+	list, problem := (*h.service).ListSnapshotsSvc(ctx.Request().Context())
+	if problem != nil {
+		h.response.ResErr(problem, &ctx)
+		return
+	}
+	h.response.ResOKWithList(list, utils.ListFormats{}, &ctx)
+}
+
+// CreateSnapshot take a database snapshot
+// @Summary Take a database snapshot
+// @description.markdown CreateSnapshotDescription
+// @Tags admin
+// @Security ApiKeyAuth
+// @Produce json
+// @Param	Authorization	header	string	true 	"Insert access token" default(Bearer <Add access token here>)
+// @Success 201 {object} dto.Snapshot "Created"
+// @Failure 401 {object} dto.Problem "err.unauthorized"
+// @Failure 403 {object} dto.Problem "err.forbidden"
+// @Failure 500 {object} dto.Problem "err.database_related"
+// @Router /admin/backups [post]
+func (h BackupHandler) CreateSnapshot(ctx iris.Context) {
+	snapshot, problem := (*h.service).CreateSnapshotSvc(ctx.Request().Context())
+	if problem != nil {
+		h.response.ResErr(problem, &ctx)
+		return
+	}
+	recordAudit(h.audit, DepObtainUserDid(ctx), dto.AuditActionBackupDB, snapshot.ID, nil, snapshot, &ctx)
+	h.response.ResCreatedWithData(snapshot, &ctx)
+}
+
+// RestoreSnapshot restore a database snapshot
+// @Summary Restore a database snapshot
+// @description.markdown RestoreSnapshotDescription
+// @Tags admin
+// @Security ApiKeyAuth
+// @Produce json
+// @Param	Authorization	header	string	true 	"Insert access token" default(Bearer <Add access token here>)
+// @Param   id              path    string  true    "ID of the snapshot"     Format(string)
+// @Success 200 {object} dto.Snapshot "the snapshot taken before the restore"
+// @Failure 400 {object} dto.Problem "err.processing_param"
+// @Failure 401 {object} dto.Problem "err.unauthorized"
+// @Failure 403 {object} dto.Problem "err.forbidden"
+// @Failure 404 {object} dto.Problem "err.not_found"
+// @Failure 500 {object} dto.Problem "err.database_related"
+// @Router /admin/backups/{id}/restore [post]
+func (h BackupHandler) RestoreSnapshot(ctx iris.Context) {
+	id := ctx.Params().GetString("id")
+	previous, problem := (*h.service).RestoreSnapshotSvc(ctx.Request().Context(), id)
+	if problem != nil {
+		h.response.ResErr(problem, &ctx)
+		return
+	}
+	recordAudit(h.audit, DepObtainUserDid(ctx), dto.AuditActionRestoreDB, id, previous, nil, &ctx)
+	h.response.ResOKWithData(previous, &ctx)
+}
+
+// Export export the whole dataset
+// @Summary Export the whole dataset
+// @Description Users, drones, medications, loaded medications and event logs as versioned JSON, it can be imported with `POST /api/v1/admin/import`. The passphrases of the users are left out unless `passphrases` is true
+// @Tags admin
+// @Security ApiKeyAuth
+// @Produce json
+// @Param	Authorization	header	string	true 	"Insert access token" default(Bearer <Add access token here>)
+// @Param	passphrases		query	bool	false	"include the passphrases (SHA256 of the passwords) of the users"
+// @Success 200 {object} dto.Dataset "OK"
+// @Failure 400 {object} dto.Problem "err.processing_param"
+// @Failure 401 {object} dto.Problem "err.unauthorized"
+// @Failure 403 {object} dto.Problem "err.forbidden"
+// @Failure 500 {object} dto.Problem "err.database_related"
+// @Router /admin/export [get]
+func (h BackupHandler) Export(ctx iris.Context) {
+	passphrases, err := ctx.URLParamBool("passphrases")
+	if err != nil && ctx.URLParamExists("passphrases") {
+		h.response.ResErr(&dto.Problem{Status: iris.StatusBadRequest, Code: schema.ErrProcParam, Detail: err.Error()}, &ctx)
+		return
+	}
+	dataset, problem := (*h.service).ExportSvc(ctx.Request().Context(), passphrases)
+	if problem != nil {
+		h.response.ResErr(problem, &ctx)
+		return
+	}
+	h.response.ResOKWithData(dataset, &ctx)
+}
+
+// Import import a dataset
+// @Summary Import a dataset
+// @description.markdown ImportDatasetDescription
+// @Tags admin
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce json
+// @Param	Authorization	header	string	true 	"Insert access token" default(Bearer <Add access token here>)
+// @Param	replace			query	bool	false	"overwrite a populated database"
+// @Param	dataset			body	dto.Dataset	true	"Dataset exported by GET /admin/export"
+// @Success 204 "OK"
+// @Failure 400 {object} dto.Problem "err.invalid_data"
+// @Failure 401 {object} dto.Problem "err.unauthorized"
+// @Failure 403 {object} dto.Problem "err.forbidden"
+// @Failure 409 {object} dto.Problem "err.database_populated"
+// @Failure 500 {object} dto.Problem "err.database_related"
+// @Router /admin/import [post]
+func (h BackupHandler) Import(ctx iris.Context) {
+	replace, err := ctx.URLParamBool("replace")
+	if err != nil && ctx.URLParamExists("replace") {
+		h.response.ResErr(&dto.Problem{Status: iris.StatusBadRequest, Code: schema.ErrProcParam, Detail: err.Error()}, &ctx)
+		return
+	}
+	dataset := dto.Dataset{}
+	if err := ctx.ReadJSON(&dataset); err != nil {
+		h.response.ResErr(&dto.Problem{Status: iris.StatusBadRequest, Code: schema.ErrJsonParse, Detail: err.Error()}, &ctx)
+		return
+	}
+
+	problem := (*h.service).ImportSvc(ctx.Request().Context(), &dataset, replace)
+	if problem != nil {
+		h.response.ResErr(problem, &ctx)
+		return
+	}
+	summary := map[string]int{"users": len(dataset.Users), "drones": len(dataset.Drones), "medications": len(dataset.Medications), "logs": len(dataset.Logs)}
+	recordAudit(h.audit, DepObtainUserDid(ctx), dto.AuditActionImportDB, "database", nil, summary, &ctx)
+	h.response.ResOK(&ctx)
+}
+
+// endregion =============================================================================
@@ -1,24 +1,33 @@
 package endpoints
 
 import (
+	"encoding/csv"
 	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/asaskevich/govalidator"
 	"github.com/kataras/iris/v12"
 	"github.com/kataras/iris/v12/context"
 	"github.com/kataras/iris/v12/hero"
+	"github.com/kmilodenisglez/drones.restapi/api/middlewares"
 	"github.com/kmilodenisglez/drones.restapi/lib"
 	"github.com/kmilodenisglez/drones.restapi/repo/db"
 	"github.com/kmilodenisglez/drones.restapi/schema"
 	"github.com/kmilodenisglez/drones.restapi/schema/dto"
+	"github.com/kmilodenisglez/drones.restapi/schema/mapper"
 	"github.com/kmilodenisglez/drones.restapi/service"
 	"github.com/kmilodenisglez/drones.restapi/service/utils"
+	"google.golang.org/protobuf/proto"
 )
 
 // DronesHandler  endpoint handler struct for Drones
 type DronesHandler struct {
 	response *utils.SvcResponse
 	service  *service.ISvcDrones
+	audit    *service.ISvcAudit
 }
 
 // NewDronesHandler create and register the handler for Drones
@@ -30,45 +39,55 @@ type DronesHandler struct {
 // - svcR [*utils.SvcResponse] ~ GrantIntentResponse service instance
 //
 // - svcC [utils.SvcConfig] ~ Configuration service instance
-func NewDronesHandler(app *iris.Application, mdwAuthChecker *context.Handler, svcR *utils.SvcResponse, svcC *utils.SvcConfig) DronesHandler { // --- VARS SETUP ---
-	repoDrones := db.NewRepoDrones(svcC)
-	svc := service.NewSvcDronesReqs(&repoDrones)
+//
+// - svcL [*utils.SvcLogger] ~ Logger service instance
+func NewDronesHandler(app *iris.Application, mdwAuthChecker *context.Handler, svcR *utils.SvcResponse, svcC *utils.SvcConfig, svcL *utils.SvcLogger) DronesHandler { // --- VARS SETUP ---
+	repoDrones := db.NewRepoDrones(svcC, svcL)
+	svc := service.NewSvcDronesReqs(svcC, &repoDrones, svcL)
+	repoAudit := db.NewRepoAudit(svcC, svcL)
+	svcAudit := service.NewSvcAuditReqs(&repoAudit, svcL)
+	repoIdempotency := db.NewRepoIdempotency(svcC, svcL)
+	svcIdempotency := service.NewSvcIdempotencyReqs(&repoIdempotency, svcL)
+	mdwIdempotency := middlewares.NewIdempotencyMiddleware(&svcIdempotency, svcR, svcL)
 	// registering protected / guarded router
-	h := DronesHandler{svcR, &svc}
+	h := DronesHandler{svcR, &svc, &svcAudit}
 
 	app.Get("/status", h.StatusServer)
 
 	// Simple group: v1
 	v1 := app.Party("/api/v1")
 	{
-		// registering unprotected router
-		authRouter := v1.Party("/database") // unauthorized
-		{
-			authRouter.Post("/populate", h.PopulateDB)
-		}
-
 		// registering protected / guarded router
-		guardTxsDatabase := v1.Party("/database")
+		guardTxsRouter := v1.Party("/drones")
 		{
 			// --- GROUP / PARTY MIDDLEWARES ---
-			guardTxsDatabase.Use(*mdwAuthChecker)
+			guardTxsRouter.Use(*mdwAuthChecker)
+
+			guardTxsRouter.Get("/", h.GetDrones)
+			guardTxsRouter.Get("/{serialNumber:string}", h.GetADrone)
+			guardTxsRouter.Post("/", mdwIdempotency, h.RegisterADrone)
+			guardTxsRouter.Post("/bulk", mdwIdempotency, h.RegisterDronesBulk)
+			guardTxsRouter.Put("/{serialNumber:string}", h.UpdateADrone)
+			guardTxsRouter.Patch("/{serialNumber:string}", h.PatchADrone)
+			guardTxsRouter.Delete("/{serialNumber:string}", h.RetireADrone)
+			guardTxsRouter.Post("/{serialNumber:string}/dispatch", mdwIdempotency, h.DispatchADrone)
+			guardTxsRouter.Post("/{serialNumber:string}/delivered", mdwIdempotency, h.DeliveredADrone)
+			guardTxsRouter.Post("/{serialNumber:string}/return", mdwIdempotency, h.ReturnADrone)
+			guardTxsRouter.Post("/{serialNumber:string}/returned", mdwIdempotency, h.ReturnedADrone)
+			guardTxsRouter.Post("/{serialNumber:string}/abort", mdwIdempotency, h.AbortADrone)
+			guardTxsRouter.Get("/{serialNumber:string}/deliveries", h.GetDroneDeliveries)
 
 			// --- DEPENDENCIES ---
 			hero.Register(DepObtainUserDid)
 		}
 
 		// registering protected / guarded router
-		guardTxsRouter := v1.Party("/drones")
+		guardFleetRouter := v1.Party("/fleet")
 		{
 			// --- GROUP / PARTY MIDDLEWARES ---
-			guardTxsRouter.Use(*mdwAuthChecker)
+			guardFleetRouter.Use(*mdwAuthChecker)
 
-			guardTxsRouter.Get("/", h.GetDrones)
-			guardTxsRouter.Get("/{serialNumber:string}", h.GetADrone)
-			guardTxsRouter.Post("/", h.RegisterADrone)
-
-			// --- DEPENDENCIES ---
-			hero.Register(DepObtainUserDid)
+			guardFleetRouter.Get("/stats", h.GetFleetStats)
 		}
 
 		// registering protected / guarded router
@@ -79,7 +98,9 @@ func NewDronesHandler(app *iris.Application, mdwAuthChecker *context.Handler, sv
 
 			guardMedicationsRouter.Get("/", h.GetMedications)
 			guardMedicationsRouter.Get("/items/{serialNumber:string}", h.CheckingLoadedMedicationItems)
-			guardMedicationsRouter.Post("/items/{serialNumber:string}", h.LoadMedicationItems)
+			guardMedicationsRouter.Post("/items", mdwIdempotency, h.LoadMedicationItemsBulk)
+			guardMedicationsRouter.Post("/items/{serialNumber:string}", mdwIdempotency, h.LoadMedicationItems)
+			guardMedicationsRouter.Get("/{code:string}/deliveries", h.GetMedicationDeliveries)
 
 			// --- DEPENDENCIES ---
 			hero.Register(DepObtainUserDid)
@@ -92,59 +113,52 @@ func (h DronesHandler) StatusServer(ctx iris.Context) {
 	h.response.ResOKWithData(dto.StatusMsg{OK: true}, &ctx)
 }
 
-// PopulateDB
-// @Summary Populate the database with fake data
-// @description.markdown PopulateDbDescription
-// @Tags database
-// @Accept  json
-// @Produce json
-// @Success 204 "OK"
-// @Failure 401 {object} dto.Problem "err.unauthorized"
-// @Failure 400 {object} dto.Problem "err.processing_param"
-// @Failure 500 {object} dto.Problem "err.database_related"
-// @Failure 504 {object} dto.Problem "err.network"
-// @Router /database/populate [post]
-func (h DronesHandler) PopulateDB(ctx iris.Context) {
-	problem := (*h.service).PopulateDBSvc()
-	if problem != nil {
-		h.response.ResErr(problem, &ctx)
-		return
-	}
-	h.response.ResOK(&ctx)
-}
-
 // GetDrones get drones
 // @Summary Get drones
 // @description.markdown GetDronesDescription
 // @Tags drones
 // @Security ApiKeyAuth
 // @Accept  json
-// @Produce json
+// @Produce json,application/msgpack,application/x-protobuf,text/csv
 // @Param	Authorization	header	string	true 	"Insert access token" default(Bearer <Add access token here>)
-// @Param   state           query   int     false   "drone state"         Enums(0, 1, 2, 3, 4, 5)
+// @Param   state           query   []int   false   "drone states (repeat the parameter or separate them by commas)"  collectionFormat(multi)
+// @Param   model           query   []int   false   "drone models (repeat the parameter or separate them by commas)"  collectionFormat(multi)
+// @Param   batteryMin      query   number  false   "minimum battery capacity, inclusive"
+// @Param   batteryMax      query   number  false   "maximum battery capacity, inclusive"
+// @Param   availableWeight query   number  false   "minimum free carrying capacity (weight limit minus loaded medications)"
+// @Param   retired         query   bool    false   "list the retired drones instead of the ones in service"
+// @Param   sort            query   string  false   "sort field"          Enums(batteryCapacity, serialNumber, weightLimit, model, state)
+// @Param   order           query   string  false   "sort direction, desc by default when sorting by battery capacity"  Enums(asc, desc)
+// @Param   limit           query   int     false   "page size, all the drones by default"
+// @Param   cursor          query   string  false   "cursor of the next page (X-Next-Cursor header of the previous page)"
 // @Success 200 {object} []dto.Drone "OK"
-// @Failure 400 {object} dto.Problem "err.processing_param"
+// @Header  200 {integer} X-Total-Count "number of drones that match the filter"
+// @Header  200 {string} X-Next-Cursor "cursor of the next page, missing in the last page"
+// @Failure 400 {object} dto.Problem "err.query_parameter"
+// @Failure 406 {object} dto.Problem "err.not_acceptable"
 // @Failure 500 {object} dto.Problem "err.database_related"
 // @Failure 504 {object} dto.Problem "err.network"
 // @Router /drones [get]
 func (h DronesHandler) GetDrones(ctx iris.Context) {
-	qState, err := ctx.URLParamInt("state")
-	if err != nil && err != iris.ErrNotFound {
-		h.response.ResErr(dto.NewProblem(iris.StatusInternalServerError, schema.ErrParamURL, err.Error()), &ctx)
+	filter, err := depObtainDroneFilter(ctx)
+	if err != nil {
+		h.response.ResErr(dto.NewProblem(iris.StatusBadRequest, schema.ErrParamURL, err.Error()), &ctx)
 		return
 	}
 
-	var state = ""
-	// if no query parameter is passed then we show all drones
-	if qState != -1 {
-		state = fmt.Sprintf("\"state\":%d", qState)
-	}
-	drones, problem := (*h.service).GetDronesSvc(state)
+	page, problem := (*h.service).GetDronesSvc(ctx.Request().Context(), filter)
 	if problem != nil {
 		h.response.ResErr(problem, &ctx)
 		return
 	}
-	h.response.ResOKWithData(drones, &ctx)
+	ctx.Header("X-Total-Count", strconv.Itoa(page.Total))
+	if page.NextCursor != "" {
+		ctx.Header("X-Next-Cursor", page.NextCursor)
+	}
+	h.response.ResOKWithList(page.Items, utils.ListFormats{
+		Protobuf: func() proto.Message { return mapper.ToDroneListPb(page.Items) },
+		CSV:      func() [][]string { return mapper.ToDronesCSV(page.Items) },
+	}, &ctx)
 }
 
 // GetADrone get a drone
@@ -157,6 +171,7 @@ func (h DronesHandler) GetDrones(ctx iris.Context) {
 // @Param	Authorization	header	string	true 	"Insert access token"          default(Bearer <Add access token here>)
 // @Param   serialNumber    path    string  true    "Serial number of a drone"     Format(string)
 // @Success 200 {object} dto.Drone "OK"
+// @Header  200 {string} ETag "version of the drone, to be used in If-Match"
 // @Failure 400 {object} dto.Problem "err.processing_param"
 // @Failure 500 {object} dto.Problem "err.database_related"
 // @Failure 504 {object} dto.Problem "err.network"
@@ -165,38 +180,43 @@ func (h DronesHandler) GetADrone(ctx iris.Context) {
 	// checking the serialNumber param
 	serialNumber := ctx.Params().GetString("serialNumber")
 	if serialNumber == "" {
-		h.response.ResErr(&dto.Problem{Status: iris.StatusBadRequest, Title: schema.ErrProcParam, Detail: schema.ErrDetInvalidField}, &ctx)
+		h.response.ResErr(&dto.Problem{Status: iris.StatusBadRequest, Code: schema.ErrProcParam, Detail: schema.DetInvalidField}, &ctx)
 		return
 	}
-	drone, problem := (*h.service).GetADroneSvc(serialNumber)
+	drone, problem := (*h.service).GetADroneSvc(ctx.Request().Context(), serialNumber)
 	if problem != nil {
 		h.response.ResErr(problem, &ctx)
 		return
 	}
+	setDroneETag(ctx, drone.Version)
 	h.response.ResOKWithData(drone, &ctx)
 }
 
 // RegisterADrone registers a new drone
-// @Summary Registers a new drone, also updates a previously inserted drone
+// @Summary Registers a new drone
 // @description.markdown RegisterADroneDescription
 // @Tags drones
 // @Security ApiKeyAuth
 // @Accept  json
 // @Produce json
 // @Param	Authorization	header	string 			    true 	"Insert access token" default(Bearer <Add access token here>)
+// @Param	Idempotency-Key	header	string 			    false 	"Key of the request, its retries replay the first response (Idempotent-Replayed header)"
 // @Param	drone			body	dto.RequestDrone	true	"Drone data"
 // @Success 204 "OK"
+// @Header  204 {string} ETag "version of the drone, to be used in If-Match"
 // @Failure 401 {object} dto.Problem "err.unauthorized"
 // @Failure 400 {object} dto.Problem "err.processing_param"
+// @Failure 409 {object} dto.Problem "err.duplicate_key, err.drone_state_read_only, err.idempotency_in_progress"
 // @Failure 500 {object} dto.Problem "err.database_related"
 // @Failure 504 {object} dto.Problem "err.network"
+// @Failure 422 {object} dto.Problem "err.idempotency_key_reused"
 // @Router /drones [post]
 func (h DronesHandler) RegisterADrone(ctx iris.Context) {
 	drone := new(dto.Drone)
 
 	// unmarshalling the JSON from request's body and check
 	if err := ctx.ReadJSON(drone); err != nil {
-		h.response.ResErr(&dto.Problem{Status: iris.StatusBadRequest, Title: schema.ErrProcParam, Detail: err.Error()}, &ctx)
+		h.response.ResErr(&dto.Problem{Status: iris.StatusBadRequest, Code: schema.ErrProcParam, Detail: err.Error()}, &ctx)
 		return
 	}
 
@@ -206,18 +226,250 @@ func (h DronesHandler) RegisterADrone(ctx iris.Context) {
 	// validate drone fields
 	_, err := govalidator.ValidateStruct(drone)
 	if err != nil {
-		h.response.ResErr(&dto.Problem{Status: iris.StatusBadRequest, Title: schema.ErrValidationField, Detail: err.Error()}, &ctx)
+		h.response.ResErr(lib.ValidationProblem(err), &ctx)
 		return
 	}
 
-	problem := (*h.service).RegisterDroneSvc(drone)
+	problem := (*h.service).RegisterDroneSvc(ctx.Request().Context(), drone)
 	if problem != nil {
 		h.response.ResErr(problem, &ctx)
 		return
 	}
+	recordAudit(h.audit, DepObtainUserDid(ctx), dto.AuditActionRegisterDrone, drone.SerialNumber, nil, drone, &ctx)
+	setDroneETag(ctx, drone.Version)
 	h.response.ResOK(&ctx)
 }
 
+// RegisterDronesBulk registers a batch of drones
+// @Summary Registers a batch of drones
+// @description.markdown RegisterDronesBulkDescription
+// @Tags drones
+// @Security ApiKeyAuth
+// @Accept  json,text/csv,multipart/form-data
+// @Produce json
+// @Param	Authorization	header	string 			    true 	"Insert access token" default(Bearer <Add access token here>)
+// @Param	Idempotency-Key	header	string 			    false 	"Key of the request, its retries replay the first response (Idempotent-Replayed header)"
+// @Param   mode            query   string              false   "atomic (all or nothing, by default) or best-effort"  Enums(atomic, best-effort)
+// @Param	drones			body	[]dto.RequestDrone	true	"Drones data, or a CSV file with the serialNumber, model, batteryCapacity and state columns"
+// @Success 200 {object} dto.BulkReport "OK, all the drones were registered, every item has status 201"
+// @Success 207 {object} dto.BulkReport "some drones were not registered (best-effort), the registered items have status 201"
+// @Failure 422 {object} dto.BulkReport "no drone was registered (atomic), the items that didn't fail have status 424"
+// @Failure 401 {object} dto.Problem "err.unauthorized"
+// @Failure 400 {object} dto.Problem "err.processing_param, err.query_parameter"
+// @Failure 500 {object} dto.Problem "err.database_related"
+// @Failure 409 {object} dto.Problem "err.idempotency_in_progress"
+// @Router /drones/bulk [post]
+func (h DronesHandler) RegisterDronesBulk(ctx iris.Context) {
+	mode, problem := depObtainBulkMode(ctx)
+	if problem != nil {
+		h.response.ResErr(problem, &ctx)
+		return
+	}
+
+	drones := make([]dto.Drone, 0)
+	problem = depObtainBulkItems(ctx, &drones, func(records [][]string) (err error) {
+		drones, err = mapper.FromDronesCSV(records)
+		return err
+	})
+	if problem == nil {
+		problem = checkBulkSize(len(drones))
+	}
+	if problem != nil {
+		h.response.ResErr(problem, &ctx)
+		return
+	}
+
+	report, problem := (*h.service).RegisterDronesSvc(ctx.Request().Context(), drones, mode)
+	if problem != nil {
+		h.response.ResErr(problem, &ctx)
+		return
+	}
+	actor := DepObtainUserDid(ctx)
+	for _, item := range report.Items {
+		if item.Status == iris.StatusCreated {
+			recordAudit(h.audit, actor, dto.AuditActionRegisterDrone, item.SerialNumber, nil, &drones[item.Index], &ctx)
+		}
+	}
+	h.response.ResBulkReport(report, &ctx)
+}
+
+// UpdateADrone full replacement of a drone
+// @Summary Replaces an existing drone
+// @description.markdown UpdateADroneDescription
+// @Tags drones
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce json
+// @Param	Authorization	header	string 			    true 	"Insert access token" default(Bearer <Add access token here>)
+// @Param	If-Match		header	string 			    false 	"ETag of the drone version being replaced"
+// @Param   serialNumber    path    string              true    "Serial number of a drone"     Format(string)
+// @Param	drone			body	dto.RequestDrone	true	"Drone data"
+// @Success 200 {object} dto.Drone "OK"
+// @Header  200 {string} ETag "new version of the drone"
+// @Failure 401 {object} dto.Problem "err.unauthorized"
+// @Failure 400 {object} dto.Problem "err.processing_param"
+// @Failure 404 {object} dto.Problem "err.database_related.item_not_found"
+// @Failure 409 {object} dto.Problem "err.drone_retired, err.drone_state_read_only"
+// @Failure 412 {object} dto.Problem "err.drone_version_mismatch"
+// @Failure 500 {object} dto.Problem "err.database_related"
+// @Router /drones/{serialNumber} [put]
+func (h DronesHandler) UpdateADrone(ctx iris.Context) {
+	// checking the serialNumber param
+	serialNumber := ctx.Params().GetString("serialNumber")
+	if serialNumber == "" {
+		h.response.ResErr(&dto.Problem{Status: iris.StatusBadRequest, Code: schema.ErrProcParam, Detail: schema.DetInvalidField}, &ctx)
+		return
+	}
+	expectedVersion, err := depObtainIfMatch(ctx)
+	if err != nil {
+		h.response.ResErr(&dto.Problem{Status: iris.StatusBadRequest, Code: schema.ErrProcParam, Detail: err.Error()}, &ctx)
+		return
+	}
+
+	drone := new(dto.Drone)
+	// unmarshalling the JSON from request's body and check
+	if err := ctx.ReadJSON(drone); err != nil {
+		h.response.ResErr(&dto.Problem{Status: iris.StatusBadRequest, Code: schema.ErrProcParam, Detail: err.Error()}, &ctx)
+		return
+	}
+	// the serial number is the identity of the drone, it can't be replaced
+	if drone.SerialNumber != "" && drone.SerialNumber != serialNumber {
+		h.response.ResErr(&dto.Problem{Status: iris.StatusBadRequest, Code: schema.ErrValidationField, Detail: schema.DetSerialNumberMismatch}, &ctx)
+		return
+	}
+	drone.SerialNumber = serialNumber
+
+	// calculate drone weight limit
+	drone.WeightLimit = lib.CalculateDroneWeightLimit(drone.Model)
+
+	// validate drone fields
+	if _, err := govalidator.ValidateStruct(drone); err != nil {
+		h.response.ResErr(lib.ValidationProblem(err), &ctx)
+		return
+	}
+
+	// the previous state of the drone is kept for the audit trail
+	before, problem := (*h.service).UpdateDroneSvc(ctx.Request().Context(), drone, expectedVersion)
+	if problem != nil {
+		h.response.ResErr(problem, &ctx)
+		return
+	}
+	recordAudit(h.audit, DepObtainUserDid(ctx), dto.AuditActionUpdateDrone, serialNumber, before, drone, &ctx)
+	setDroneETag(ctx, drone.Version)
+	h.response.ResOKWithData(drone, &ctx)
+}
+
+// PatchADrone partial update of a drone
+// @Summary Partially updates an existing drone
+// @description.markdown PatchADroneDescription
+// @Tags drones
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce json
+// @Param	Authorization	header	string 			    true 	"Insert access token" default(Bearer <Add access token here>)
+// @Param	If-Match		header	string 			    false 	"ETag of the drone version being updated"
+// @Param   serialNumber    path    string              true    "Serial number of a drone"     Format(string)
+// @Param	drone			body	dto.PatchDrone		true	"Drone fields to update"
+// @Success 200 {object} dto.Drone "OK"
+// @Header  200 {string} ETag "new version of the drone"
+// @Failure 401 {object} dto.Problem "err.unauthorized"
+// @Failure 400 {object} dto.Problem "err.processing_param"
+// @Failure 409 {object} dto.Problem "err.drone_retired, err.drone_state_read_only"
+// @Failure 412 {object} dto.Problem "err.drone_version_mismatch"
+// @Failure 500 {object} dto.Problem "err.database_related"
+// @Router /drones/{serialNumber} [patch]
+func (h DronesHandler) PatchADrone(ctx iris.Context) {
+	// checking the serialNumber param
+	serialNumber := ctx.Params().GetString("serialNumber")
+	if serialNumber == "" {
+		h.response.ResErr(&dto.Problem{Status: iris.StatusBadRequest, Code: schema.ErrProcParam, Detail: schema.DetInvalidField}, &ctx)
+		return
+	}
+	expectedVersion, err := depObtainIfMatch(ctx)
+	if err != nil {
+		h.response.ResErr(&dto.Problem{Status: iris.StatusBadRequest, Code: schema.ErrProcParam, Detail: err.Error()}, &ctx)
+		return
+	}
+
+	patch := new(dto.PatchDrone)
+	// unmarshalling the JSON from request's body and check
+	if err := ctx.ReadJSON(patch); err != nil {
+		h.response.ResErr(&dto.Problem{Status: iris.StatusBadRequest, Code: schema.ErrProcParam, Detail: err.Error()}, &ctx)
+		return
+	}
+
+	// the previous state of the drone is kept for the audit trail
+	before, drone, problem := (*h.service).PatchDroneSvc(ctx.Request().Context(), serialNumber, patch, expectedVersion)
+	if problem != nil {
+		h.response.ResErr(problem, &ctx)
+		return
+	}
+	recordAudit(h.audit, DepObtainUserDid(ctx), dto.AuditActionUpdateDrone, serialNumber, before, drone, &ctx)
+	setDroneETag(ctx, drone.Version)
+	h.response.ResOKWithData(drone, &ctx)
+}
+
+// RetireADrone decommission a drone
+// @Summary Retires a drone (soft delete)
+// @description.markdown RetireADroneDescription
+// @Tags drones
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce json
+// @Param	Authorization	header	string 			    true 	"Insert access token" default(Bearer <Add access token here>)
+// @Param	If-Match		header	string 			    false 	"ETag of the drone version being retired"
+// @Param   serialNumber    path    string              true    "Serial number of a drone"     Format(string)
+// @Success 204 "OK"
+// @Failure 401 {object} dto.Problem "err.unauthorized"
+// @Failure 404 {object} dto.Problem "err.database_related.item_not_found"
+// @Failure 409 {object} dto.Problem "err.drone_not_retirable"
+// @Failure 412 {object} dto.Problem "err.drone_version_mismatch"
+// @Failure 500 {object} dto.Problem "err.database_related"
+// @Router /drones/{serialNumber} [delete]
+func (h DronesHandler) RetireADrone(ctx iris.Context) {
+	// checking the serialNumber param
+	serialNumber := ctx.Params().GetString("serialNumber")
+	if serialNumber == "" {
+		h.response.ResErr(&dto.Problem{Status: iris.StatusBadRequest, Code: schema.ErrProcParam, Detail: schema.DetInvalidField}, &ctx)
+		return
+	}
+	expectedVersion, err := depObtainIfMatch(ctx)
+	if err != nil {
+		h.response.ResErr(&dto.Problem{Status: iris.StatusBadRequest, Code: schema.ErrProcParam, Detail: err.Error()}, &ctx)
+		return
+	}
+
+	// the previous state of the drone is kept for the audit trail
+	before, drone, problem := (*h.service).RetireDroneSvc(ctx.Request().Context(), serialNumber, expectedVersion)
+	if problem != nil {
+		h.response.ResErr(problem, &ctx)
+		return
+	}
+	recordAudit(h.audit, DepObtainUserDid(ctx), dto.AuditActionRetireDrone, serialNumber, before, drone, &ctx)
+	h.response.ResDelete(&ctx)
+}
+
+// GetFleetStats get the fleet statistics
+// @Summary Get the fleet statistics
+// @description.markdown GetFleetStatsDescription
+// @Tags drones
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce json
+// @Param	Authorization	header	string	true 	"Insert access token" default(Bearer <Add access token here>)
+// @Success 200 {object} dto.FleetStats "OK"
+// @Failure 401 {object} dto.Problem "err.unauthorized"
+// @Failure 500 {object} dto.Problem "err.database_related"
+// @Router /fleet/stats [get]
+func (h DronesHandler) GetFleetStats(ctx iris.Context) {
+	stats, problem := (*h.service).GetFleetStatsSvc(ctx.Request().Context())
+	if problem != nil {
+		h.response.ResErr(problem, &ctx)
+		return
+	}
+	h.response.ResOKWithData(stats, &ctx)
+}
+
 // endregion =============================================================================
 
 // region ======== Medications ======================================================
@@ -228,20 +480,23 @@ func (h DronesHandler) RegisterADrone(ctx iris.Context) {
 // @Tags medications
 // @Security ApiKeyAuth
 // @Accept  json
-// @Produce json
+// @Produce json,application/msgpack,application/x-protobuf
 // @Param	Authorization	header	string	true 	"Insert access token" default(Bearer <Add access token here>)
 // @Success 200 {object} []dto.Medication "OK"
 // @Failure 400 {object} dto.Problem "err.processing_param"
+// @Failure 406 {object} dto.Problem "err.not_acceptable"
 // @Failure 500 {object} dto.Problem "err.database_related"
 // @Failure 504 {object} dto.Problem "err.network"
 // @Router /medications [get]
 func (h DronesHandler) GetMedications(ctx iris.Context) {
-	medications, problem := (*h.service).GetMedicationsSvc()
+	medications, problem := (*h.service).GetMedicationsSvc(ctx.Request().Context())
 	if problem != nil {
 		h.response.ResErr(problem, &ctx)
 		return
 	}
-	h.response.ResOKWithData(medications, &ctx)
+	h.response.ResOKWithList(medications, utils.ListFormats{
+		Protobuf: func() proto.Message { return mapper.ToMedicationListPb(*medications) },
+	}, &ctx)
 }
 
 // CheckingLoadedMedicationItems checking loaded medication items for a given drone
@@ -250,11 +505,12 @@ func (h DronesHandler) GetMedications(ctx iris.Context) {
 // @Tags medications
 // @Security ApiKeyAuth
 // @Accept  json
-// @Produce json
+// @Produce json,application/msgpack
 // @Param	Authorization	header	string	true 	"Insert access token" default(Bearer <Add access token here>)
 // @Param   serialNumber    path    string  true    "Serial number of a drone"     Format(string)
 // @Success 200 {object} []string "OK"
 // @Failure 400 {object} dto.Problem "err.processing_param"
+// @Failure 406 {object} dto.Problem "err.not_acceptable"
 // @Failure 500 {object} dto.Problem "err.database_related"
 // @Failure 504 {object} dto.Problem "err.network"
 // @Router /medications/items/{serialNumber} [get]
@@ -262,21 +518,21 @@ func (h DronesHandler) CheckingLoadedMedicationItems(ctx iris.Context) {
 	// checking the serialNumber param
 	serialNumber := ctx.Params().GetString("serialNumber")
 	if serialNumber == "" {
-		h.response.ResErr(&dto.Problem{Status: iris.StatusBadRequest, Title: schema.ErrProcParam, Detail: schema.ErrDetInvalidField}, &ctx)
+		h.response.ResErr(&dto.Problem{Status: iris.StatusBadRequest, Code: schema.ErrProcParam, Detail: schema.DetInvalidField}, &ctx)
 		return
 	}
 	isValid := lib.ValidateSerialNumberDrone(serialNumber)
 	if !isValid {
-		h.response.ResErr(&dto.Problem{Status: iris.StatusInternalServerError, Title: schema.ErrValidationField, Detail: "the serial number of a drone must have a 100 characters max"}, &ctx)
+		h.response.ResErr(dto.NewProblemf(iris.StatusBadRequest, schema.ErrValidationField, schema.DetSerialNumberTooLong, dto.MaxSerialNumberLength), &ctx)
 		return
 	}
 
-	medicationsIDs, problem := (*h.service).CheckingLoadedMedicationsItemsSvc(serialNumber)
+	medicationsIDs, problem := (*h.service).CheckingLoadedMedicationsItemsSvc(ctx.Request().Context(), serialNumber)
 	if problem != nil {
 		h.response.ResErr(problem, &ctx)
 		return
 	}
-	h.response.ResOKWithData(medicationsIDs, &ctx)
+	h.response.ResOKWithList(medicationsIDs, utils.ListFormats{}, &ctx)
 }
 
 
@@ -288,6 +544,7 @@ func (h DronesHandler) CheckingLoadedMedicationItems(ctx iris.Context) {
 // @Accept  json
 // @Produce json
 // @Param	Authorization	     header	    string 			true 	"Insert access token" default(Bearer <Add access token here>)
+// @Param	Idempotency-Key	header	string 			    false 	"Key of the request, its retries replay the first response (Idempotent-Replayed header)"
 // @Param   serialNumber         path       string          true    "Serial number of a drone"                                     Format(string)
 // @Param	medicationItemCodes  body	    []string		true	"Medication item codes' collection"
 // @Success 204 "OK"
@@ -295,46 +552,507 @@ func (h DronesHandler) CheckingLoadedMedicationItems(ctx iris.Context) {
 // @Failure 400 {object} dto.Problem "err.processing_param"
 // @Failure 500 {object} dto.Problem "err.database_related"
 // @Failure 504 {object} dto.Problem "err.network"
+// @Failure 409 {object} dto.Problem "err.idempotency_in_progress"
+// @Failure 422 {object} dto.Problem "err.idempotency_key_reused"
 // @Router /medications/items/{serialNumber} [post]
 func (h DronesHandler) LoadMedicationItems(ctx iris.Context) {
 	// checking the serialNumber param
 	serialNumber := ctx.Params().GetString("serialNumber")
 	if serialNumber == "" {
-		h.response.ResErr(&dto.Problem{Status: iris.StatusBadRequest, Title: schema.ErrProcParam, Detail: schema.ErrDetInvalidField}, &ctx)
+		h.response.ResErr(&dto.Problem{Status: iris.StatusBadRequest, Code: schema.ErrProcParam, Detail: schema.DetInvalidField}, &ctx)
 		return
 	}
 	isValid := lib.ValidateSerialNumberDrone(serialNumber)
 	if !isValid {
-		h.response.ResErr(&dto.Problem{Status: iris.StatusInternalServerError, Title: schema.ErrValidationField, Detail: "the serial number of a drone must have a 100 characters max"}, &ctx)
+		h.response.ResErr(dto.NewProblemf(iris.StatusBadRequest, schema.ErrValidationField, schema.DetSerialNumberTooLong, dto.MaxSerialNumberLength), &ctx)
 		return
 	}
 
 	medicationItemIDs := make([]interface{}, 0)
 	// unmarshalling the JSON from request's body and check
 	if err := ctx.ReadJSON(&medicationItemIDs); err != nil {
-		h.response.ResErr(&dto.Problem{Status: iris.StatusBadRequest, Title: schema.ErrProcParam, Detail: err.Error()}, &ctx)
+		h.response.ResErr(&dto.Problem{Status: iris.StatusBadRequest, Code: schema.ErrProcParam, Detail: err.Error()}, &ctx)
 		return
 	}
 
 	// if false, then there is at least one invalid medication item id
 	isValid = lib.ValidateStringCollection(medicationItemIDs, dto.RegexpMedicationCode)
 	if !isValid {
-		h.response.ResErr(&dto.Problem{Status: iris.StatusInternalServerError, Title: schema.ErrValidationField, Detail: "there is at least one medication item ID with invalid format"}, &ctx)
+		h.response.ResErr(&dto.Problem{Status: iris.StatusBadRequest, Code: schema.ErrValidationField, Detail: schema.DetInvalidMedicationIDs}, &ctx)
 		return
 	}
 
-	problem := (*h.service).LoadMedicationItemsADroneSvc(serialNumber, medicationItemIDs)
+	// the previously loaded items are kept for the audit trail
+	before, problem := (*h.service).LoadMedicationItemsADroneSvc(ctx.Request().Context(), serialNumber, medicationItemIDs)
 	if problem != nil {
 		h.response.ResErr(problem, &ctx)
 		return
 	}
+	recordAudit(h.audit, DepObtainUserDid(ctx), dto.AuditActionLoadMedications, serialNumber, before, lib.Unique(medicationItemIDs), &ctx)
 	h.response.ResOK(&ctx)
 }
 
+// LoadMedicationItemsBulk load a batch of drones with medication items
+// @Summary Load a batch of drones with medication items
+// @description.markdown LoadMedicationItemsBulkDescription
+// @Tags medications
+// @Security ApiKeyAuth
+// @Accept  json,text/csv,multipart/form-data
+// @Produce json
+// @Param	Authorization	header	string 			        true 	"Insert access token" default(Bearer <Add access token here>)
+// @Param	Idempotency-Key	header	string 			    false 	"Key of the request, its retries replay the first response (Idempotent-Replayed header)"
+// @Param   mode            query   string                  false   "atomic (all or nothing, by default) or best-effort"  Enums(atomic, best-effort)
+// @Param	loads			body	[]dto.LoadInstruction	true	"Load instructions, or a CSV file with a serialNumber and medicationCode record per medication"
+// @Success 200 {object} dto.BulkReport "OK, all the drones were loaded, every item has status 200"
+// @Success 207 {object} dto.BulkReport "some drones were not loaded (best-effort), the loaded items have status 200"
+// @Failure 422 {object} dto.BulkReport "no drone was loaded (atomic), the items that didn't fail have status 424"
+// @Failure 401 {object} dto.Problem "err.unauthorized"
+// @Failure 400 {object} dto.Problem "err.processing_param, err.query_parameter"
+// @Failure 500 {object} dto.Problem "err.database_related"
+// @Failure 409 {object} dto.Problem "err.idempotency_in_progress"
+// @Router /medications/items [post]
+func (h DronesHandler) LoadMedicationItemsBulk(ctx iris.Context) {
+	mode, problem := depObtainBulkMode(ctx)
+	if problem != nil {
+		h.response.ResErr(problem, &ctx)
+		return
+	}
+
+	loads := make([]dto.LoadInstruction, 0)
+	problem = depObtainBulkItems(ctx, &loads, func(records [][]string) (err error) {
+		loads, err = mapper.FromLoadInstructionsCSV(records)
+		return err
+	})
+	if problem == nil {
+		problem = checkBulkSize(len(loads))
+	}
+	if problem != nil {
+		h.response.ResErr(problem, &ctx)
+		return
+	}
+
+	// the previously loaded items are kept for the audit trail
+	report, before, problem := (*h.service).LoadMedicationItemsDronesSvc(ctx.Request().Context(), loads, mode)
+	if problem != nil {
+		h.response.ResErr(problem, &ctx)
+		return
+	}
+	actor := DepObtainUserDid(ctx)
+	for _, item := range report.Items {
+		if item.Status == iris.StatusOK {
+			recordAudit(h.audit, actor, dto.AuditActionLoadMedications, item.SerialNumber, before[item.Index], lib.UniqueStrings(loads[item.Index].MedicationCodes), &ctx)
+		}
+	}
+	h.response.ResBulkReport(report, &ctx)
+}
+
 // endregion ======== Medications ======================================================
 
+// region ======== Deliveries ======================================================
+
+// DispatchADrone send a loaded drone to deliver its medications
+// @Summary Dispatches a loaded drone
+// @description.markdown DispatchADroneDescription
+// @Tags deliveries
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce json
+// @Param	Authorization	header	string 			    true 	"Insert access token" default(Bearer <Add access token here>)
+// @Param	If-Match		header	string 			    false 	"ETag of the drone version being moved"
+// @Param	Idempotency-Key	header	string 			    false 	"Key of the request, its retries replay the first response (Idempotent-Replayed header)"
+// @Param   serialNumber    path    string              true    "Serial number of a drone"     Format(string)
+// @Success 200 {object} dto.DroneDelivery "OK"
+// @Header  200 {string} ETag "new version of the drone"
+// @Failure 401 {object} dto.Problem "err.unauthorized"
+// @Failure 404 {object} dto.Problem "err.database_related.item_not_found"
+// @Failure 409 {object} dto.Problem "err.drone_invalid_transition, err.drone_not_loaded"
+// @Failure 412 {object} dto.Problem "err.drone_version_mismatch"
+// @Failure 500 {object} dto.Problem "err.database_related"
+// @Router /drones/{serialNumber}/dispatch [post]
+func (h DronesHandler) DispatchADrone(ctx iris.Context) {
+	h.advanceADrone(ctx, dto.DeliveryActionDispatch, dto.AuditActionDispatchDrone)
+}
+
+// DeliveredADrone the drone delivered its medications, they are unloaded
+// @Summary Marks the medications of a drone as delivered
+// @description.markdown DeliveredADroneDescription
+// @Tags deliveries
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce json
+// @Param	Authorization	header	string 			    true 	"Insert access token" default(Bearer <Add access token here>)
+// @Param	If-Match		header	string 			    false 	"ETag of the drone version being moved"
+// @Param	Idempotency-Key	header	string 			    false 	"Key of the request, its retries replay the first response (Idempotent-Replayed header)"
+// @Param   serialNumber    path    string              true    "Serial number of a drone"     Format(string)
+// @Success 200 {object} dto.DroneDelivery "OK"
+// @Header  200 {string} ETag "new version of the drone"
+// @Failure 401 {object} dto.Problem "err.unauthorized"
+// @Failure 404 {object} dto.Problem "err.database_related.item_not_found"
+// @Failure 409 {object} dto.Problem "err.drone_invalid_transition"
+// @Failure 412 {object} dto.Problem "err.drone_version_mismatch"
+// @Failure 500 {object} dto.Problem "err.database_related"
+// @Router /drones/{serialNumber}/delivered [post]
+func (h DronesHandler) DeliveredADrone(ctx iris.Context) {
+	h.advanceADrone(ctx, dto.DeliveryActionDelivered, dto.AuditActionDeliverDrone)
+}
+
+// ReturnADrone the drone heads back after delivering its medications
+// @Summary Marks a drone as returning
+// @description.markdown ReturnADroneDescription
+// @Tags deliveries
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce json
+// @Param	Authorization	header	string 			    true 	"Insert access token" default(Bearer <Add access token here>)
+// @Param	If-Match		header	string 			    false 	"ETag of the drone version being moved"
+// @Param	Idempotency-Key	header	string 			    false 	"Key of the request, its retries replay the first response (Idempotent-Replayed header)"
+// @Param   serialNumber    path    string              true    "Serial number of a drone"     Format(string)
+// @Success 200 {object} dto.DroneDelivery "OK"
+// @Header  200 {string} ETag "new version of the drone"
+// @Failure 401 {object} dto.Problem "err.unauthorized"
+// @Failure 404 {object} dto.Problem "err.database_related.item_not_found"
+// @Failure 409 {object} dto.Problem "err.drone_invalid_transition"
+// @Failure 412 {object} dto.Problem "err.drone_version_mismatch"
+// @Failure 500 {object} dto.Problem "err.database_related"
+// @Router /drones/{serialNumber}/return [post]
+func (h DronesHandler) ReturnADrone(ctx iris.Context) {
+	h.advanceADrone(ctx, dto.DeliveryActionReturn, dto.AuditActionReturningDrone)
+}
+
+// ReturnedADrone the drone is back from its trip and available again
+// @Summary Marks a drone as returned
+// @description.markdown ReturnedADroneDescription
+// @Tags deliveries
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce json
+// @Param	Authorization	header	string 			    true 	"Insert access token" default(Bearer <Add access token here>)
+// @Param	If-Match		header	string 			    false 	"ETag of the drone version being moved"
+// @Param	Idempotency-Key	header	string 			    false 	"Key of the request, its retries replay the first response (Idempotent-Replayed header)"
+// @Param   serialNumber    path    string              true    "Serial number of a drone"     Format(string)
+// @Success 200 {object} dto.DroneDelivery "OK"
+// @Header  200 {string} ETag "new version of the drone"
+// @Failure 401 {object} dto.Problem "err.unauthorized"
+// @Failure 404 {object} dto.Problem "err.database_related.item_not_found"
+// @Failure 409 {object} dto.Problem "err.drone_invalid_transition"
+// @Failure 412 {object} dto.Problem "err.drone_version_mismatch"
+// @Failure 500 {object} dto.Problem "err.database_related"
+// @Router /drones/{serialNumber}/returned [post]
+func (h DronesHandler) ReturnedADrone(ctx iris.Context) {
+	h.advanceADrone(ctx, dto.DeliveryActionReturned, dto.AuditActionReturnDrone)
+}
+
+// AbortADrone the drone turns back with its medications
+// @Summary Aborts the delivery of a drone
+// @description.markdown AbortADroneDescription
+// @Tags deliveries
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce json
+// @Param	Authorization	header	string 			    true 	"Insert access token" default(Bearer <Add access token here>)
+// @Param	If-Match		header	string 			    false 	"ETag of the drone version being moved"
+// @Param	Idempotency-Key	header	string 			    false 	"Key of the request, its retries replay the first response (Idempotent-Replayed header)"
+// @Param   serialNumber    path    string              true    "Serial number of a drone"     Format(string)
+// @Success 200 {object} dto.DroneDelivery "OK"
+// @Header  200 {string} ETag "new version of the drone"
+// @Failure 401 {object} dto.Problem "err.unauthorized"
+// @Failure 404 {object} dto.Problem "err.database_related.item_not_found"
+// @Failure 409 {object} dto.Problem "err.drone_invalid_transition"
+// @Failure 412 {object} dto.Problem "err.drone_version_mismatch"
+// @Failure 500 {object} dto.Problem "err.database_related"
+// @Router /drones/{serialNumber}/abort [post]
+func (h DronesHandler) AbortADrone(ctx iris.Context) {
+	h.advanceADrone(ctx, dto.DeliveryActionAbort, dto.AuditActionAbortDrone)
+}
+
+// GetDroneDeliveries delivery history of a drone
+// @Summary Get the deliveries of a drone
+// @description.markdown GetDeliveriesDescription
+// @Tags deliveries
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce json,application/msgpack,text/csv
+// @Param	Authorization	header	string	true 	"Insert access token" default(Bearer <Add access token here>)
+// @Param   serialNumber    path    string  true    "Serial number of a drone"     Format(string)
+// @Param   from            query   string  false   "RFC3339 timestamp of the dispatch, inclusive"
+// @Param   to              query   string  false   "RFC3339 timestamp of the dispatch, inclusive"
+// @Success 200 {object} []dto.Delivery "OK"
+// @Failure 400 {object} dto.Problem "err.query_parameter"
+// @Failure 401 {object} dto.Problem "err.unauthorized"
+// @Failure 404 {object} dto.Problem "err.database_related.item_not_found"
+// @Failure 406 {object} dto.Problem "err.not_acceptable"
+// @Failure 500 {object} dto.Problem "err.database_related"
+// @Router /drones/{serialNumber}/deliveries [get]
+func (h DronesHandler) GetDroneDeliveries(ctx iris.Context) {
+	// checking the serialNumber param
+	serialNumber := ctx.Params().GetString("serialNumber")
+	if serialNumber == "" {
+		h.response.ResErr(&dto.Problem{Status: iris.StatusBadRequest, Code: schema.ErrProcParam, Detail: schema.DetInvalidField}, &ctx)
+		return
+	}
+	h.getDeliveries(ctx, &dto.DeliveryFilter{SerialNumber: serialNumber})
+}
+
+// GetMedicationDeliveries delivery history of a medication, where it went
+// @Summary Get the deliveries of a medication
+// @description.markdown GetDeliveriesDescription
+// @Tags deliveries
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce json,application/msgpack,text/csv
+// @Param	Authorization	header	string	true 	"Insert access token" default(Bearer <Add access token here>)
+// @Param   code            path    string  true    "Code of a medication"     Format(string)
+// @Param   from            query   string  false   "RFC3339 timestamp of the dispatch, inclusive"
+// @Param   to              query   string  false   "RFC3339 timestamp of the dispatch, inclusive"
+// @Success 200 {object} []dto.Delivery "OK"
+// @Failure 400 {object} dto.Problem "err.query_parameter"
+// @Failure 401 {object} dto.Problem "err.unauthorized"
+// @Failure 406 {object} dto.Problem "err.not_acceptable"
+// @Failure 500 {object} dto.Problem "err.database_related"
+// @Router /medications/{code}/deliveries [get]
+func (h DronesHandler) GetMedicationDeliveries(ctx iris.Context) {
+	// checking the code param
+	code := ctx.Params().GetString("code")
+	if code == "" {
+		h.response.ResErr(&dto.Problem{Status: iris.StatusBadRequest, Code: schema.ErrProcParam, Detail: schema.DetInvalidField}, &ctx)
+		return
+	}
+	h.getDeliveries(ctx, &dto.DeliveryFilter{MedicationCode: code})
+}
+
+// getDeliveries answer with the deliveries of a filter, narrowed by the time range of the query
+func (h DronesHandler) getDeliveries(ctx iris.Context, filter *dto.DeliveryFilter) {
+	if err := depObtainDeliveryRange(ctx, filter); err != nil {
+		h.response.ResErr(dto.NewProblem(iris.StatusBadRequest, schema.ErrParamURL, err.Error()), &ctx)
+		return
+	}
+
+	deliveries, problem := (*h.service).GetDeliveriesSvc(ctx.Request().Context(), filter)
+	if problem != nil {
+		h.response.ResErr(problem, &ctx)
+		return
+	}
+	h.response.ResOKWithList(deliveries, utils.ListFormats{
+		CSV: func() [][]string { return mapper.ToDeliveriesCSV(*deliveries) },
+	}, &ctx)
+}
+
+// advanceADrone apply a lifecycle action to the drone of the path and answer with the drone and its delivery
+func (h DronesHandler) advanceADrone(ctx iris.Context, action, auditAction string) {
+	// checking the serialNumber param
+	serialNumber := ctx.Params().GetString("serialNumber")
+	if serialNumber == "" {
+		h.response.ResErr(&dto.Problem{Status: iris.StatusBadRequest, Code: schema.ErrProcParam, Detail: schema.DetInvalidField}, &ctx)
+		return
+	}
+	expectedVersion, err := depObtainIfMatch(ctx)
+	if err != nil {
+		h.response.ResErr(&dto.Problem{Status: iris.StatusBadRequest, Code: schema.ErrProcParam, Detail: err.Error()}, &ctx)
+		return
+	}
+
+	// the previous state of the drone is kept for the audit trail
+	before, result, problem := (*h.service).AdvanceDroneSvc(ctx.Request().Context(), serialNumber, action, expectedVersion)
+	if problem != nil {
+		h.response.ResErr(problem, &ctx)
+		return
+	}
+	recordAudit(h.audit, DepObtainUserDid(ctx), auditAction, serialNumber, before, &result.Drone, &ctx)
+	setDroneETag(ctx, result.Drone.Version)
+	h.response.ResOKWithData(result, &ctx)
+}
+
+// endregion ======== Deliveries ======================================================
+
 // region ======== LOCAL DEPENDENCIES ====================================================
 
+// depObtainDroneFilter build the typed filter of the drone list from the query parameters
+func depObtainDroneFilter(ctx iris.Context) (*dto.DroneFilter, error) {
+	filter := dto.DroneFilter{SortBy: dto.DroneSortBattery, Desc: true}
+
+	for _, v := range splitURLParamSlice(ctx, "state") {
+		state, err := strconv.ParseUint(v, 10, 32)
+		if err != nil || dto.DroneState(state).String() == "unknown" {
+			return nil, fmt.Errorf("unknown drone state '%s'", v)
+		}
+		filter.States = append(filter.States, dto.DroneState(state))
+	}
+	for _, v := range splitURLParamSlice(ctx, "model") {
+		model, err := strconv.ParseUint(v, 10, 32)
+		if err != nil || dto.DroneModel(model).String() == "unknown" {
+			return nil, fmt.Errorf("unknown drone model '%s'", v)
+		}
+		filter.Models = append(filter.Models, dto.DroneModel(model))
+	}
+
+	var err error
+	if filter.BatteryMin, err = urlParamFloat64Ptr(ctx, "batteryMin"); err != nil {
+		return nil, err
+	}
+	if filter.BatteryMax, err = urlParamFloat64Ptr(ctx, "batteryMax"); err != nil {
+		return nil, err
+	}
+	if filter.MinAvailableWeight, err = urlParamFloat64Ptr(ctx, "availableWeight"); err != nil {
+		return nil, err
+	}
+	if filter.BatteryMin != nil && filter.BatteryMax != nil && *filter.BatteryMin > *filter.BatteryMax {
+		return nil, fmt.Errorf("batteryMin can't be greater than batteryMax")
+	}
+
+	if ctx.URLParamExists("retired") {
+		if filter.Retired, err = ctx.URLParamBool("retired"); err != nil {
+			return nil, fmt.Errorf("retired must be a boolean")
+		}
+	}
+
+	if sortBy := ctx.URLParamTrim("sort"); sortBy != "" {
+		if !lib.Contains([]string{dto.DroneSortBattery, dto.DroneSortSerialNumber, dto.DroneSortWeightLimit, dto.DroneSortModel, dto.DroneSortState}, sortBy) {
+			return nil, fmt.Errorf("the drones can't be sorted by '%s'", sortBy)
+		}
+		filter.SortBy = sortBy
+		// only the battery capacity is sorted descending by default
+		filter.Desc = sortBy == dto.DroneSortBattery
+	}
+	switch ctx.URLParamTrim("order") {
+	case "":
+	case "asc":
+		filter.Desc = false
+	case "desc":
+		filter.Desc = true
+	default:
+		return nil, fmt.Errorf("order must be 'asc' or 'desc'")
+	}
+
+	filter.Limit = ctx.URLParamIntDefault("limit", 0)
+	if filter.Limit < 0 || (ctx.URLParamExists("limit") && filter.Limit == 0) {
+		return nil, fmt.Errorf("limit must be a positive integer")
+	}
+	filter.Cursor = ctx.URLParamTrim("cursor")
+
+	return &filter, nil
+}
+
+// splitURLParamSlice values of a repeated query parameter, each of them may also be a comma separated list
+func splitURLParamSlice(ctx iris.Context, name string) []string {
+	values := make([]string, 0)
+	for _, param := range ctx.URLParamSlice(name) {
+		for _, v := range strings.Split(param, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+	return values
+}
+
+// urlParamFloat64Ptr optional float query parameter, nil if it is missing
+func urlParamFloat64Ptr(ctx iris.Context, name string) (*float64, error) {
+	if !ctx.URLParamExists(name) {
+		return nil, nil
+	}
+	v, err := ctx.URLParamFloat64(name)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a number", name)
+	}
+	return &v, nil
+}
+
+// depObtainIfMatch get the drone version expected by the client from the If-Match header.
+// It returns nil when the header is missing or is "*", so the write is unconditional
+func depObtainIfMatch(ctx iris.Context) (*uint64, error) {
+	ifMatch := strings.TrimSpace(ctx.GetHeader("If-Match"))
+	if ifMatch == "" || ifMatch == "*" {
+		return nil, nil
+	}
+	ifMatch = strings.Trim(strings.TrimPrefix(ifMatch, "W/"), "\"")
+	version, err := strconv.ParseUint(ifMatch, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid If-Match header, expected the ETag of the drone")
+	}
+	return &version, nil
+}
+
+// depObtainBulkMode the mode of a bulk request, atomic if the mode query parameter is missing
+func depObtainBulkMode(ctx iris.Context) (string, *dto.Problem) {
+	mode := ctx.URLParamDefault("mode", dto.BulkModeAtomic)
+	if mode != dto.BulkModeAtomic && mode != dto.BulkModeBestEffort {
+		return "", dto.NewProblem(iris.StatusBadRequest, schema.ErrParamURL, schema.DetBulkInvalidMode)
+	}
+	return mode, nil
+}
+
+// depObtainBulkItems read the items of a bulk request: a JSON array, a CSV body (text/csv) or a CSV file
+// uploaded as the "file" field of a multipart form
+//
+// - items [interface{}] ~ Pointer to the slice of the JSON items
+//
+// - fromCSV [func] ~ Convert the CSV records to the items
+func depObtainBulkItems(ctx iris.Context, items interface{}, fromCSV func(records [][]string) error) *dto.Problem {
+	var body io.Reader
+	switch ctx.GetContentTypeRequested() {
+	case utils.ContentCSVHeaderValue:
+		body = ctx.Request().Body
+	case context.ContentFormMultipartHeaderValue:
+		file, _, err := ctx.FormFile("file")
+		if err != nil {
+			return dto.NewProblem(iris.StatusBadRequest, schema.ErrProcParam, err.Error())
+		}
+		defer file.Close()
+		body = file
+	default:
+		if err := ctx.ReadJSON(items); err != nil {
+			return dto.NewProblem(iris.StatusBadRequest, schema.ErrProcParam, err.Error())
+		}
+		return nil
+	}
+
+	records, err := csv.NewReader(body).ReadAll()
+	if err == nil {
+		err = fromCSV(records)
+	}
+	if err != nil {
+		return dto.NewProblemf(iris.StatusBadRequest, schema.ErrProcParam, schema.DetBulkInvalidCSV, err.Error())
+	}
+	return nil
+}
+
+// checkBulkSize a problem if a bulk request has no items or more than dto.MaxBulkItems
+func checkBulkSize(size int) *dto.Problem {
+	if size == 0 {
+		return dto.NewProblem(iris.StatusBadRequest, schema.ErrProcParam, schema.DetBulkEmpty)
+	}
+	if size > dto.MaxBulkItems {
+		return dto.NewProblemf(iris.StatusBadRequest, schema.ErrProcParam, schema.DetBulkTooLarge, size, dto.MaxBulkItems)
+	}
+	return nil
+}
+
+// depObtainDeliveryRange read the from and to RFC3339 timestamps of the query into the filter
+func depObtainDeliveryRange(ctx iris.Context, filter *dto.DeliveryFilter) error {
+	filter.From, filter.To = ctx.URLParamTrim("from"), ctx.URLParamTrim("to")
+	var from, to time.Time
+	var err error
+	if filter.From != "" {
+		if from, err = time.Parse(time.RFC3339, filter.From); err != nil {
+			return err
+		}
+	}
+	if filter.To != "" {
+		if to, err = time.Parse(time.RFC3339, filter.To); err != nil {
+			return err
+		}
+	}
+	if filter.From != "" && filter.To != "" && from.After(to) {
+		return fmt.Errorf("from can't be after to")
+	}
+	return nil
+}
+
+// setDroneETag set the drone version as a strong ETag of the response
+func setDroneETag(ctx iris.Context, version uint64) {
+	ctx.Header("ETag", fmt.Sprintf("\"%d\"", version))
+}
+
 // DepObtainUserDid this tries to get the user DID store in the previously generated auth Bearer token.
 func DepObtainUserDid(ctx iris.Context) dto.InjectedParam {
 	tkData := ctx.Values().Get("iris.jwt.claims").(*dto.AccessTokenData)
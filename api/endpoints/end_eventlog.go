@@ -3,8 +3,10 @@ package endpoints
 import (
 	"github.com/kataras/iris/v12"
 	"github.com/kataras/iris/v12/context"
+	"github.com/kmilodenisglez/drones.restapi/schema/mapper"
 	"github.com/kmilodenisglez/drones.restapi/service/cron"
 	"github.com/kmilodenisglez/drones.restapi/service/utils"
+	"google.golang.org/protobuf/proto"
 )
 
 // EventLogHandler  endpoint handler struct for EventLog
@@ -22,8 +24,10 @@ type EventLogHandler struct {
 // - svcR [*utils.SvcResponse] ~ GrantIntentResponse service instance
 //
 // - svcC [utils.SvcConfig] ~ Configuration service instance
-func NewEventLogHandler(app *iris.Application, mdwAuthChecker *context.Handler, svcR *utils.SvcResponse, svcC *utils.SvcConfig) EventLogHandler { // --- VARS SETUP ---
-	svc := cron.NewSvcRepoEventLog(svcC)
+//
+// - svcL [*utils.SvcLogger] ~ Logger service instance
+func NewEventLogHandler(app *iris.Application, mdwAuthChecker *context.Handler, svcR *utils.SvcResponse, svcC *utils.SvcConfig, svcL *utils.SvcLogger) EventLogHandler { // --- VARS SETUP ---
+	svc := cron.NewSvcRepoEventLog(svcC, svcL)
 	// registering protected / guarded router
 	h := EventLogHandler{svcR, &svc}
 
@@ -47,20 +51,24 @@ func NewEventLogHandler(app *iris.Application, mdwAuthChecker *context.Handler,
 // @Tags logs
 // @Security ApiKeyAuth
 // @Accept  json
-// @Produce json
+// @Produce json,application/msgpack,application/x-protobuf,text/csv
 // @Param	Authorization	header	string	true 	"Insert access token" default(Bearer <Add access token here>)
 // @Success 200 {object} []dto.LogEvent "OK"
 // @Failure 400 {object} dto.Problem "err.processing_param"
+// @Failure 406 {object} dto.Problem "err.not_acceptable"
 // @Failure 500 {object} dto.Problem "err.database_related"
 // @Failure 504 {object} dto.Problem "err.network"
 // @Router /logs [get]
 func (h EventLogHandler) GetEventLog(ctx iris.Context) {
-	logs, problem := (*h.service).GetEventLogs()
+	logs, problem := (*h.service).GetEventLogs(ctx.Request().Context())
 	if problem != nil {
 		h.response.ResErr(problem, &ctx)
 		return
 	}
-	h.response.ResOKWithData(logs, &ctx)
+	h.response.ResOKWithList(logs, utils.ListFormats{
+		Protobuf: func() proto.Message { return mapper.ToLogEventListPb(*logs) },
+		CSV:      func() [][]string { return mapper.ToLogEventsCSV(*logs) },
+	}, &ctx)
 }
 
 // region ======== LOCAL DEPENDENCIES ====================================================
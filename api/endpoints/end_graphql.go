@@ -0,0 +1,86 @@
+package endpoints
+
+import (
+	"github.com/kataras/iris/v12"
+	"github.com/kataras/iris/v12/context"
+	"github.com/kmilodenisglez/drones.restapi/api/gql"
+	"github.com/kmilodenisglez/drones.restapi/schema"
+	"github.com/kmilodenisglez/drones.restapi/schema/dto"
+	"github.com/kmilodenisglez/drones.restapi/service/i18n"
+	"github.com/kmilodenisglez/drones.restapi/service/utils"
+)
+
+// GraphQLHandler  endpoint handler struct for GraphQL
+type GraphQLHandler struct {
+	response *utils.SvcResponse
+	executor *gql.Executor
+}
+
+// NewGraphQLHandler create and register the handler for GraphQL
+//
+// - app [*iris.Application] ~ Iris App instance
+//
+// - MdwAuthChecker [*context.Handler] ~ Authentication checker middleware
+//
+// - svcR [*utils.SvcResponse] ~ GrantIntentResponse service instance
+//
+// - svcC [utils.SvcConfig] ~ Configuration service instance
+//
+// - svcL [*utils.SvcLogger] ~ Logger service instance
+func NewGraphQLHandler(app *iris.Application, mdwAuthChecker *context.Handler, svcR *utils.SvcResponse, svcC *utils.SvcConfig, svcL *utils.SvcLogger) (GraphQLHandler, error) { // --- VARS SETUP ---
+	executor, err := gql.NewExecutor(svcC, svcL)
+	if err != nil {
+		return GraphQLHandler{}, err
+	}
+	// registering protected / guarded router
+	h := GraphQLHandler{svcR, executor}
+
+	// Simple group: v1
+	v1 := app.Party("/api/v1")
+	{
+		// registering protected / guarded router
+		guardGraphQLRouter := v1.Party("/graphql")
+		{
+			// --- GROUP / PARTY MIDDLEWARES ---
+			guardGraphQLRouter.Use(*mdwAuthChecker)
+			guardGraphQLRouter.Post("/", h.Query)
+		}
+	}
+	return h, nil
+}
+
+// Query execute a GraphQL query or mutation
+// @Summary Execute a GraphQL query or mutation
+// @description.markdown GraphQLDescription
+// @Tags graphql
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce json
+// @Param	Authorization	header	string 			    true 	"Insert access token" default(Bearer <Add access token here>)
+// @Param	request			body	dto.GraphQLRequest	true	"Query, operation name and variables"
+// @Success 200 {object} dto.GraphQLResponse "OK, the errors of the query are in the response"
+// @Failure 400 {object} dto.Problem "err.processing_param"
+// @Failure 401 {object} dto.Problem "err.unauthorized"
+// @Router /graphql [post]
+func (h GraphQLHandler) Query(ctx iris.Context) {
+	req := new(dto.GraphQLRequest)
+	// unmarshalling the JSON from request's body and check
+	if err := ctx.ReadJSON(req); err != nil {
+		h.response.ResErr(&dto.Problem{Status: iris.StatusBadRequest, Code: schema.ErrProcParam, Detail: err.Error()}, &ctx)
+		return
+	}
+	if req.Query == "" {
+		h.response.ResErr(&dto.Problem{Status: iris.StatusBadRequest, Code: schema.ErrProcParam, Detail: schema.DetInvalidField}, &ctx)
+		return
+	}
+
+	lang := i18n.DefaultLanguage
+	if locale := ctx.GetLocale(); locale != nil {
+		lang = locale.Language()
+	}
+	result := h.executor.Exec(ctx.Request().Context(), req, DepObtainUserDid(ctx), lang)
+	if result.HasErrors() {
+		ctx.Header("Content-Language", lang)
+	}
+	h.response.ResOKWithData(result, &ctx)
+}
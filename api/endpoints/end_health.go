@@ -0,0 +1,68 @@
+package endpoints
+
+import (
+	"github.com/kataras/iris/v12"
+	"github.com/kmilodenisglez/drones.restapi/repo/db"
+	"github.com/kmilodenisglez/drones.restapi/schema/dto"
+	"github.com/kmilodenisglez/drones.restapi/service"
+	"github.com/kmilodenisglez/drones.restapi/service/cron"
+	"github.com/kmilodenisglez/drones.restapi/service/utils"
+)
+
+// HealthHandler  endpoint handler struct for the Health probes
+type HealthHandler struct {
+	response *utils.SvcResponse
+	service  *service.ISvcHealth
+}
+
+// NewHealthHandler create and register the handler for the liveness and readiness probes, both are unauthenticated
+//
+// - app [*iris.Application] ~ Iris App instance
+//
+// - svcR [*utils.SvcResponse] ~ GrantIntentResponse service instance
+//
+// - svcC [utils.SvcConfig] ~ Configuration service instance
+//
+// - svcL [*utils.SvcLogger] ~ Logger service instance
+//
+// - cronJob [*cron.ISvcEventLog] ~ The cron job service started by the app, its scheduler is checked
+func NewHealthHandler(app *iris.Application, svcR *utils.SvcResponse, svcC *utils.SvcConfig, svcL *utils.SvcLogger, cronJob *cron.ISvcEventLog) HealthHandler { // --- VARS SETUP ---
+	repoDrones := db.NewRepoDrones(svcC, svcL)
+	repoEventLog := db.NewRepoEventLog(svcC, svcL)
+	repoAudit := db.NewRepoAudit(svcC, svcL)
+	svc := service.NewSvcHealthReqs(svcC, &repoDrones, &repoEventLog, &repoAudit, cronJob, svcL)
+	h := HealthHandler{svcR, &svc}
+
+	app.Get("/healthz", h.Liveness)
+	app.Get("/readyz", h.Readiness)
+
+	return h
+}
+
+// Liveness liveness probe
+// @Summary Liveness probe
+// @Description The server process is up and serving requests
+// @Tags health
+// @Produce json
+// @Success 200 {object} dto.HealthReport "OK"
+// @Router /healthz [get]
+func (h HealthHandler) Liveness(ctx iris.Context) {
+	h.response.ResOKWithData((*h.service).LivenessSvc(), &ctx)
+}
+
+// Readiness readiness probe
+// @Summary Readiness probe
+// @description.markdown ReadinessDescription
+// @Tags health
+// @Produce json
+// @Success 200 {object} dto.HealthReport "OK"
+// @Failure 503 {object} dto.HealthReport "at least one check failed"
+// @Router /readyz [get]
+func (h HealthHandler) Readiness(ctx iris.Context) {
+	report := (*h.service).ReadinessSvc(ctx.Request().Context())
+	status := iris.StatusOK
+	if report.Status == dto.HealthFail {
+		status = iris.StatusServiceUnavailable
+	}
+	h.response.ResWithDataStatus(status, report, &ctx)
+}
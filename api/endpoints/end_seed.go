@@ -0,0 +1,158 @@
+package endpoints
+
+import (
+	"github.com/kataras/iris/v12"
+	"github.com/kataras/iris/v12/context"
+	"github.com/kmilodenisglez/drones.restapi/api/middlewares"
+	"github.com/kmilodenisglez/drones.restapi/repo/db"
+	"github.com/kmilodenisglez/drones.restapi/schema"
+	"github.com/kmilodenisglez/drones.restapi/schema/dto"
+	"github.com/kmilodenisglez/drones.restapi/service"
+	"github.com/kmilodenisglez/drones.restapi/service/seed"
+	"github.com/kmilodenisglez/drones.restapi/service/utils"
+)
+
+// SeedHandler  endpoint handler struct for the database seeding
+type SeedHandler struct {
+	response *utils.SvcResponse
+	service  *seed.ISvcSeed
+	audit    *service.ISvcAudit
+}
+
+// NewSeedHandler create and register the handler for the database populate, for the authenticated users, and
+// the reset, only for the administrators. An empty database has no users to log in with, so in development
+// mode it is populated without a token
+//
+// - app [*iris.Application] ~ Iris App instance
+//
+// - MdwAuthChecker [*context.Handler] ~ Authentication checker middleware
+//
+// - svcR [*utils.SvcResponse] ~ GrantIntentResponse service instance
+//
+// - svcC [utils.SvcConfig] ~ Configuration service instance
+//
+// - svcL [*utils.SvcLogger] ~ Logger service instance
+func NewSeedHandler(app *iris.Application, mdwAuthChecker *context.Handler, svcR *utils.SvcResponse, svcC *utils.SvcConfig, svcL *utils.SvcLogger) SeedHandler { // --- VARS SETUP ---
+	repoDrones := db.NewRepoDrones(svcC, svcL)
+	repoEventLog := db.NewRepoEventLog(svcC, svcL)
+	svc := seed.NewSvcSeedReqs(svcC, &repoDrones, &repoEventLog, svcL)
+	repoAudit := db.NewRepoAudit(svcC, svcL)
+	svcAudit := service.NewSvcAuditReqs(&repoAudit, svcL)
+	h := SeedHandler{svcR, &svc, &svcAudit}
+	mdwAdminChecker := middlewares.NewAdminCheckerMiddleware(svcR)
+
+	// Simple group: v1
+	v1 := app.Party("/api/v1")
+	{
+		// registering protected / guarded router
+		guardDatabaseRouter := v1.Party("/database")
+		{
+			// --- GROUP / PARTY MIDDLEWARES ---
+			guardDatabaseRouter.Use(func(ctx iris.Context) {
+				if svcC.DevMode && !repoDrones.IsPopulated(ctx.Request().Context()) {
+					ctx.Next()
+					return
+				}
+				(*mdwAuthChecker)(ctx)
+			})
+
+			guardDatabaseRouter.Post("/populate", h.PopulateDB)
+		}
+
+		// registering protected / guarded router
+		guardAdminRouter := v1.Party("/admin")
+		{
+			// --- GROUP / PARTY MIDDLEWARES ---
+			guardAdminRouter.Use(*mdwAuthChecker, mdwAdminChecker)
+
+			guardAdminRouter.Post("/database/reset", h.ResetDB)
+		}
+	}
+	return h
+}
+
+// region ======== ENDPOINT HANDLERS =====================================================
+
+// PopulateDB
+// @Summary Populate the database with the seed data
+// @description.markdown PopulateDbDescription
+// @Tags database
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce json
+// @Param	Authorization	header	string	false 	"Insert access token, not needed to populate an empty database in development mode" default(Bearer <Add access token here>)
+// @Param	seed			query	int		false	"seed of the generated data, the same seed generates the same data"
+// @Success 200 {object} dto.SeedReport "OK"
+// @Failure 400 {object} dto.Problem "err.invalid_data"
+// @Failure 401 {object} dto.Problem "err.unauthorized"
+// @Failure 403 {object} dto.Problem "err.seed_disabled"
+// @Failure 409 {object} dto.Problem "err.database_populated"
+// @Failure 500 {object} dto.Problem "err.database_related"
+// @Router /database/populate [post]
+func (h SeedHandler) PopulateDB(ctx iris.Context) {
+	options, ok := h.seedOptions(ctx)
+	if !ok {
+		return
+	}
+	report, problem := (*h.service).PopulateSvc(ctx.Request().Context(), options)
+	if problem != nil {
+		h.response.ResErr(problem, &ctx)
+		return
+	}
+	// the empty database is populated without a token, the entry is recorded for the anonymous actor
+	actor := dto.InjectedParam{}
+	if ctx.Values().Get("iris.jwt.claims") != nil {
+		actor = DepObtainUserDid(ctx)
+	}
+	recordAudit(h.audit, actor, dto.AuditActionPopulateDB, "database", dto.ConfigDB{IsPopulated: false}, report, &ctx)
+	h.response.ResOKWithData(report, &ctx)
+}
+
+// ResetDB wipe the database and seed it again
+// @Summary Wipe the database and seed it again
+// @description.markdown ResetDbDescription
+// @Tags admin
+// @Security ApiKeyAuth
+// @Produce json
+// @Param	Authorization	header	string	true 	"Insert access token" default(Bearer <Add access token here>)
+// @Param	seed			query	int		false	"seed of the generated data, the same seed generates the same data"
+// @Success 200 {object} dto.SeedReport "OK"
+// @Failure 400 {object} dto.Problem "err.invalid_data"
+// @Failure 401 {object} dto.Problem "err.unauthorized"
+// @Failure 403 {object} dto.Problem "err.seed_disabled, err.forbidden"
+// @Failure 500 {object} dto.Problem "err.database_related"
+// @Router /admin/database/reset [post]
+func (h SeedHandler) ResetDB(ctx iris.Context) {
+	options, ok := h.seedOptions(ctx)
+	if !ok {
+		return
+	}
+	report, problem := (*h.service).ResetSvc(ctx.Request().Context(), options)
+	if problem != nil {
+		h.response.ResErr(problem, &ctx)
+		return
+	}
+	recordAudit(h.audit, DepObtainUserDid(ctx), dto.AuditActionResetDB, "database", nil, report, &ctx)
+	h.response.ResOKWithData(report, &ctx)
+}
+
+// endregion =============================================================================
+
+// region ======== PRIVATE AUX ===========================================================
+
+// seedOptions the seed query parameter, the fixture file is only taken from the settings. On error the
+// problem has been sent
+func (h SeedHandler) seedOptions(ctx iris.Context) (*dto.SeedOptions, bool) {
+	options := dto.SeedOptions{}
+	if ctx.URLParamExists("seed") {
+		seed, err := ctx.URLParamInt64("seed")
+		if err != nil || seed == 0 {
+			h.response.ResErr(&dto.Problem{Status: iris.StatusBadRequest, Code: schema.ErrProcParam, Detail: schema.DetInvalidSeed}, &ctx)
+			return nil, false
+		}
+		options.Seed = seed
+	}
+	return &options, true
+}
+
+// endregion =============================================================================
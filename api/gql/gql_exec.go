@@ -0,0 +1,270 @@
+package gql
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"github.com/kmilodenisglez/drones.restapi/lib"
+	"github.com/kmilodenisglez/drones.restapi/repo/db"
+	"github.com/kmilodenisglez/drones.restapi/schema"
+	"github.com/kmilodenisglez/drones.restapi/schema/dto"
+	"github.com/kmilodenisglez/drones.restapi/service"
+	"github.com/kmilodenisglez/drones.restapi/service/cron"
+	"github.com/kmilodenisglez/drones.restapi/service/i18n"
+	"github.com/kmilodenisglez/drones.restapi/service/utils"
+)
+
+// region ======== TYPES =================================================================
+
+// Executor runs the GraphQL requests against the drones, medications and event logs services
+type Executor struct {
+	schema    graphql.Schema
+	resolvers *resolvers
+	appConf   *utils.SvcConfig
+}
+
+// requestKey context key of the state of a request
+type requestKey struct{}
+
+// requestState the user and the language of a request, and the data read once per request
+type requestState struct {
+	actor dto.InjectedParam
+	lang  string
+	debug bool
+
+	medicationsOnce sync.Once
+	medications     []dto.Medication
+	medicationsErr  *dto.Problem
+
+	logsOnce sync.Once
+	logs     []dto.LogEvent
+	logsErr  *dto.Problem
+}
+
+// endregion =============================================================================
+
+// NewExecutor create the GraphQL schema over the drones, medications and event logs services
+//
+// - svcC [*utils.SvcConfig] ~ Configuration service instance
+//
+// - svcL [*utils.SvcLogger] ~ Logger service instance
+func NewExecutor(svcC *utils.SvcConfig, svcL *utils.SvcLogger) (*Executor, error) {
+	repoDrones := db.NewRepoDrones(svcC, svcL)
+	svcDrones := service.NewSvcDronesReqs(svcC, &repoDrones, svcL)
+	repoAudit := db.NewRepoAudit(svcC, svcL)
+	svcAudit := service.NewSvcAuditReqs(&repoAudit, svcL)
+	svcEventLog := cron.NewSvcRepoEventLog(svcC, svcL)
+
+	r := &resolvers{drones: &svcDrones, eventLog: &svcEventLog, audit: &svcAudit}
+	s, err := newSchema(r)
+	if err != nil {
+		return nil, err
+	}
+	return &Executor{schema: s, resolvers: r, appConf: svcC}, nil
+}
+
+// Exec parse and validate the request, check its depth and complexity against the limits of the
+// configuration and execute it. The errors are returned in the result, as GraphQL does
+//
+// - ctx [context.Context] ~ Context of the request, it carries the request ID
+//
+// - req [*dto.GraphQLRequest] ~ Query, operation name and variables
+//
+// - actor [dto.InjectedParam] ~ User of the access token, recorded in the audit trail by the mutations
+//
+// - lang [string] ~ Language of the error messages
+func (e *Executor) Exec(ctx context.Context, req *dto.GraphQLRequest, actor dto.InjectedParam, lang string) *graphql.Result {
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"})})
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+	if validation := graphql.ValidateDocument(&e.schema, doc, nil); !validation.IsValid {
+		return &graphql.Result{Errors: validation.Errors}
+	}
+
+	limits := queryLimits{
+		maxDepth:      e.appConf.GraphQLMaxDepth,
+		maxComplexity: e.appConf.GraphQLMaxComplexity,
+		listSize:      defaultListSize,
+	}
+	if err := limits.check(&e.schema, doc, req.OperationName, req.Variables); err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+
+	state := &requestState{actor: actor, lang: lang, debug: e.appConf.Reloadable().Debug}
+	return graphql.Execute(graphql.ExecuteParams{
+		Schema:        e.schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       context.WithValue(ctx, requestKey{}, state),
+	})
+}
+
+// region ======== ERRORS ================================================================
+
+// ProblemError a problem of the service layer as a GraphQL error. The message is the detail translated to
+// the language of the request, hidden for the internal errors unless the server runs in debug mode, and
+// the problem code, its status and the invalid fields are sent in the extensions
+type ProblemError struct {
+	problem dto.Problem
+}
+
+func (e ProblemError) Error() string {
+	if e.problem.Detail != "" {
+		return e.problem.Detail
+	}
+	return e.problem.Title
+}
+
+// Extensions the code, the status and the invalid fields of the problem
+func (e ProblemError) Extensions() map[string]interface{} {
+	ext := map[string]interface{}{"code": e.problem.Code, "status": e.problem.Status}
+	if len(e.problem.Errors) > 0 {
+		ext["errors"] = e.problem.Errors
+	}
+	return ext
+}
+
+// problemError the GraphQL error of a problem, in the language of the request
+func problemError(ctx context.Context, problem *dto.Problem) error {
+	state := stateOf(ctx)
+	problemType := schema.ProblemTypeOf(problem.Code)
+	localized := utils.Localize(*problem, state.lang)
+	localized.Status = uint(problemType.Status)
+	if problemType.Internal && !state.debug {
+		localized.Detail = ""
+	}
+	return ProblemError{problem: localized}
+}
+
+// endregion =============================================================================
+
+// region ======== PRIVATE AUX ===========================================================
+
+// stateOf the state of the request, an empty one outside Exec
+func stateOf(ctx context.Context) *requestState {
+	if state, ok := ctx.Value(requestKey{}).(*requestState); ok {
+		return state
+	}
+	return &requestState{lang: i18n.DefaultLanguage}
+}
+
+// actorOf the user of the request, taken from its access token
+func actorOf(ctx context.Context) dto.InjectedParam {
+	return stateOf(ctx).actor
+}
+
+// medicationCatalogue all the medications, read once per request
+func (r *resolvers) medicationCatalogue(ctx context.Context) ([]dto.Medication, *dto.Problem) {
+	state := stateOf(ctx)
+	state.medicationsOnce.Do(func() {
+		medications, problem := (*r.drones).GetMedicationsSvc(ctx)
+		if problem != nil {
+			state.medicationsErr = problem
+			return
+		}
+		state.medications = *medications
+	})
+	return state.medications, state.medicationsErr
+}
+
+// eventLogs the last event logs, read once per request
+func (r *resolvers) eventLogs(ctx context.Context) ([]dto.LogEvent, *dto.Problem) {
+	state := stateOf(ctx)
+	state.logsOnce.Do(func() {
+		logs, problem := (*r.eventLog).GetEventLogs(ctx)
+		if problem != nil {
+			state.logsErr = problem
+			return
+		}
+		state.logs = *logs
+	})
+	return state.logs, state.logsErr
+}
+
+// droneFilter the typed filter of the drone list, with the same rules of the query parameters of GET /drones
+func droneFilter(args map[string]interface{}) (*dto.DroneFilter, *dto.Problem) {
+	invalid := func(format string, a ...interface{}) (*dto.DroneFilter, *dto.Problem) {
+		return nil, dto.NewProblem(http.StatusBadRequest, schema.ErrParamURL, fmt.Sprintf(format, a...))
+	}
+	filter := dto.DroneFilter{SortBy: dto.DroneSortBattery, Desc: true}
+
+	if states, ok := args["state"].([]interface{}); ok {
+		for _, s := range states {
+			filter.States = append(filter.States, s.(dto.DroneState))
+		}
+	}
+	if models, ok := args["model"].([]interface{}); ok {
+		for _, m := range models {
+			filter.Models = append(filter.Models, m.(dto.DroneModel))
+		}
+	}
+	filter.BatteryMin = float64Arg(args, "batteryMin")
+	filter.BatteryMax = float64Arg(args, "batteryMax")
+	filter.MinAvailableWeight = float64Arg(args, "availableWeight")
+	if filter.BatteryMin != nil && filter.BatteryMax != nil && *filter.BatteryMin > *filter.BatteryMax {
+		return invalid("batteryMin can't be greater than batteryMax")
+	}
+	filter.Retired, _ = args["retired"].(bool)
+
+	if sortBy, ok := args["sort"].(string); ok && sortBy != "" {
+		if !lib.Contains([]string{dto.DroneSortBattery, dto.DroneSortSerialNumber, dto.DroneSortWeightLimit, dto.DroneSortModel, dto.DroneSortState}, sortBy) {
+			return invalid("the drones can't be sorted by '%s'", sortBy)
+		}
+		filter.SortBy = sortBy
+		// only the battery capacity is sorted descending by default
+		filter.Desc = sortBy == dto.DroneSortBattery
+	}
+	order, _ := args["order"].(string)
+	switch order {
+	case "":
+	case "asc":
+		filter.Desc = false
+	case "desc":
+		filter.Desc = true
+	default:
+		return invalid("order must be 'asc' or 'desc'")
+	}
+
+	// a page without limit has the size its complexity was estimated with, and no page is larger than maxListSize
+	filter.Limit = defaultListSize
+	if limit, ok := args["limit"].(int); ok {
+		if limit < 1 {
+			return invalid("limit must be a positive integer")
+		}
+		filter.Limit = limit
+	}
+	if filter.Limit > maxListSize {
+		filter.Limit = maxListSize
+	}
+	filter.Cursor, _ = args["cursor"].(string)
+	return &filter, nil
+}
+
+// float64Arg optional float argument, nil if it is missing
+func float64Arg(args map[string]interface{}, name string) *float64 {
+	if v, ok := args[name].(float64); ok {
+		return &v
+	}
+	return nil
+}
+
+// validSerialNumber a problem if the serial number of a drone is missing or too long
+func validSerialNumber(serialNumber string) *dto.Problem {
+	if serialNumber == "" {
+		return dto.NewProblem(http.StatusBadRequest, schema.ErrProcParam, schema.DetInvalidField)
+	}
+	if !lib.ValidateSerialNumberDrone(serialNumber) {
+		return dto.NewProblemf(http.StatusBadRequest, schema.ErrValidationField, schema.DetSerialNumberTooLong, dto.MaxSerialNumberLength)
+	}
+	return nil
+}
+
+// endregion =============================================================================
@@ -0,0 +1,203 @@
+package gql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// defaultListSize the size of a list without limit argument: the page size of the drones and, for the
+// complexity of a query, the estimated size of the other lists
+const defaultListSize = 10
+
+// maxListSize the largest page of drones, a greater limit argument is cut to it
+const maxListSize = 100
+
+// queryLimits the maximum depth and complexity of a query, checked before it is executed.
+//
+// The depth is the deepest nesting of fields. The complexity is the number of fields the query may resolve:
+// every field costs 1 plus the complexity of its subfields, and the subfields of a list are multiplied by
+// its size, the limit argument of the field (or of its parent, e.g. drones(limit: 5) { items { ... } }) up
+// to maxListSize, or defaultListSize. The introspection fields (__schema, __type, __typename) are free
+type queryLimits struct {
+	maxDepth      int
+	maxComplexity int
+	listSize      int
+}
+
+// queryCost the depth and complexity of a selection set
+type queryCost struct {
+	depth      int
+	complexity int
+}
+
+// check compute the depth and complexity of the operation and fail if it exceeds the limits. The
+// document must have been validated, so its fragments have no cycles
+//
+// - s [*graphql.Schema] ~ Schema of the document
+//
+// - doc [*ast.Document] ~ Parsed request
+//
+// - operationName [string] ~ Operation to execute, it may be empty if the document has only one
+//
+// - variables [map[string]interface{}] ~ Variables of the request, the limit arguments may use them
+func (l queryLimits) check(s *graphql.Schema, doc *ast.Document, operationName string, variables map[string]interface{}) error {
+	var operation *ast.OperationDefinition
+	fragments := make(map[string]*ast.FragmentDefinition)
+	for _, def := range doc.Definitions {
+		switch def := def.(type) {
+		case *ast.OperationDefinition:
+			if operationName == "" || (def.Name != nil && def.Name.Value == operationName) {
+				operation = def
+			}
+		case *ast.FragmentDefinition:
+			fragments[def.Name.Value] = def
+		}
+	}
+	if operation == nil {
+		// the executor reports the unknown operation
+		return nil
+	}
+
+	root := s.QueryType()
+	if operation.Operation == ast.OperationTypeMutation {
+		root = s.MutationType()
+	}
+	w := costWalker{schema: s, fragments: fragments, variables: variables, listSize: l.listSize}
+	cost := w.selectionSet(operation.SelectionSet, root, 0)
+	if cost.depth > l.maxDepth {
+		return fmt.Errorf("the query is too deep, %d levels of fields and the maximum is %d", cost.depth, l.maxDepth)
+	}
+	if cost.complexity > l.maxComplexity {
+		return fmt.Errorf("the query is too complex, it may resolve %d fields and the maximum is %d", cost.complexity, l.maxComplexity)
+	}
+	return nil
+}
+
+// costWalker walks the selection sets of a query with the types of the schema
+type costWalker struct {
+	schema    *graphql.Schema
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+	listSize  int
+}
+
+// selectionSet the cost of the selections on a parent type
+//
+// - set [*ast.SelectionSet] ~ Selections, nil for a leaf field
+//
+// - parent [graphql.Type] ~ Type of the selections
+//
+// - pendingLimit [int] ~ Limit argument of the parent field, it is the size of the next list
+func (w costWalker) selectionSet(set *ast.SelectionSet, parent graphql.Type, pendingLimit int) queryCost {
+	cost := queryCost{}
+	if set == nil {
+		return cost
+	}
+	for _, selection := range set.Selections {
+		var c queryCost
+		switch selection := selection.(type) {
+		case *ast.Field:
+			c = w.field(selection, parent, pendingLimit)
+		case *ast.InlineFragment:
+			c = w.selectionSet(selection.SelectionSet, w.typeCondition(selection.TypeCondition, parent), pendingLimit)
+		case *ast.FragmentSpread:
+			if fragment, ok := w.fragments[selection.Name.Value]; ok {
+				c = w.selectionSet(fragment.SelectionSet, w.typeCondition(fragment.TypeCondition, parent), pendingLimit)
+			}
+		}
+		cost.complexity += c.complexity
+		if c.depth > cost.depth {
+			cost.depth = c.depth
+		}
+	}
+	return cost
+}
+
+// field the cost of a field and its subfields
+func (w costWalker) field(field *ast.Field, parent graphql.Type, pendingLimit int) queryCost {
+	if strings.HasPrefix(field.Name.Value, "__") {
+		return queryCost{}
+	}
+	object, ok := parent.(*graphql.Object)
+	if !ok {
+		return queryCost{depth: 1, complexity: 1}
+	}
+	def, ok := object.Fields()[field.Name.Value]
+	if !ok {
+		return queryCost{depth: 1, complexity: 1}
+	}
+
+	fieldType, isList := unwrap(def.Type)
+	multiplier, childLimit := 1, 0
+	limit := w.limitArgument(field)
+	switch {
+	case isList && limit > 0:
+		multiplier = limit
+	case isList && pendingLimit > 0:
+		multiplier = pendingLimit
+	case isList:
+		multiplier = w.listSize
+	default:
+		childLimit = limit
+	}
+
+	children := w.selectionSet(field.SelectionSet, fieldType, childLimit)
+	return queryCost{depth: 1 + children.depth, complexity: 1 + multiplier*children.complexity}
+}
+
+// limitArgument the value of the limit argument of a field capped to maxListSize, 0 if it is missing
+func (w costWalker) limitArgument(field *ast.Field) int {
+	limit := 0
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != "limit" {
+			continue
+		}
+		switch value := arg.Value.(type) {
+		case *ast.IntValue:
+			limit, _ = strconv.Atoi(value.Value)
+		case *ast.Variable:
+			// the variables are decoded from JSON
+			switch v := w.variables[value.Name.Value].(type) {
+			case float64:
+				limit = int(v)
+			case int:
+				limit = v
+			}
+		}
+	}
+	if limit > maxListSize {
+		return maxListSize
+	}
+	return limit
+}
+
+// typeCondition the type of a fragment, the parent type if it has no condition
+func (w costWalker) typeCondition(condition *ast.Named, parent graphql.Type) graphql.Type {
+	if condition == nil {
+		return parent
+	}
+	if t := w.schema.Type(condition.Name.Value); t != nil {
+		return t
+	}
+	return parent
+}
+
+// unwrap the named type of a field type, and whether it is a list
+func unwrap(t graphql.Type) (graphql.Type, bool) {
+	isList := false
+	for {
+		switch wrapped := t.(type) {
+		case *graphql.NonNull:
+			t = wrapped.OfType
+		case *graphql.List:
+			isList = true
+			t = wrapped.OfType
+		default:
+			return t, isList
+		}
+	}
+}
@@ -0,0 +1,341 @@
+package gql
+
+import (
+	"net/http"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/graphql-go/graphql"
+	"github.com/kmilodenisglez/drones.restapi/lib"
+	"github.com/kmilodenisglez/drones.restapi/schema"
+	"github.com/kmilodenisglez/drones.restapi/schema/dto"
+	"github.com/kmilodenisglez/drones.restapi/service"
+	"github.com/kmilodenisglez/drones.restapi/service/cron"
+)
+
+// resolvers the services behind the fields of the schema
+type resolvers struct {
+	drones   *service.ISvcDrones
+	eventLog *cron.ISvcEventLog
+	audit    *service.ISvcAudit
+}
+
+// BatteryLevel model of the battery history of a drone, one per event log
+type BatteryLevel struct {
+	Created         string  `json:"created"`
+	BatteryCapacity float64 `json:"batteryCapacity"`
+}
+
+// newSchema the types, queries and mutations of the GraphQL API
+func newSchema(r *resolvers) (graphql.Schema, error) {
+	// region ======== TYPES =================================================================
+	droneStateValues := graphql.EnumValueConfigMap{}
+	for s := dto.IDLE; s <= dto.RETURNING; s++ {
+		droneStateValues[s.String()] = &graphql.EnumValueConfig{Value: s}
+	}
+	droneState := graphql.NewEnum(graphql.EnumConfig{Name: "DroneState", Values: droneStateValues})
+
+	droneModelValues := graphql.EnumValueConfigMap{}
+	for m := dto.Lightweight; m <= dto.Heavyweight; m++ {
+		droneModelValues[m.String()] = &graphql.EnumValueConfig{Value: m}
+	}
+	droneModel := graphql.NewEnum(graphql.EnumConfig{Name: "DroneModel", Values: droneModelValues})
+
+	medication := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Medication",
+		Fields: graphql.Fields{
+			"name":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"weight": &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+			"code":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"image":  &graphql.Field{Type: graphql.NewNonNull(graphql.String), Description: "base64 encoded image"},
+		},
+	})
+
+	batteryLevel := graphql.NewObject(graphql.ObjectConfig{
+		Name: "BatteryLevel",
+		Fields: graphql.Fields{
+			"created":         &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"batteryCapacity": &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+		},
+	})
+
+	drone := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Drone",
+		Fields: graphql.Fields{
+			"serialNumber":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"model":           &graphql.Field{Type: graphql.NewNonNull(droneModel)},
+			"weightLimit":     &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+			"batteryCapacity": &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+			"state":           &graphql.Field{Type: graphql.NewNonNull(droneState)},
+			"version":         &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"retired":         &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"retiredAt":       &graphql.Field{Type: graphql.String},
+			"loadedMedications": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(medication))),
+				Description: "the medications loaded in the drone",
+				Resolve:     r.loadedMedications,
+			},
+			"batteryHistory": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(batteryLevel))),
+				Description: "the battery level of the drone in the last event logs, the newest first",
+				Resolve:     r.batteryHistory,
+			},
+		},
+	})
+
+	dronePage := graphql.NewObject(graphql.ObjectConfig{
+		Name: "DronePage",
+		Fields: graphql.Fields{
+			"items":      &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(drone)))},
+			"total":      &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Description: "number of drones that match the filter"},
+			"nextCursor": &graphql.Field{Type: graphql.String, Description: "cursor of the next page, null in the last page"},
+		},
+	})
+
+	droneBatteryLevel := graphql.NewObject(graphql.ObjectConfig{
+		Name: "DroneBatteryLevel",
+		Fields: graphql.Fields{
+			"serialNumber":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"batteryCapacity": &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+		},
+	})
+
+	logEvent := graphql.NewObject(graphql.ObjectConfig{
+		Name: "LogEvent",
+		Fields: graphql.Fields{
+			"created":             &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"uuid":                &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"dronesBatteryLevels": &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(droneBatteryLevel)))},
+		},
+	})
+
+	droneInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name:        "DroneInput",
+		Description: "a new drone, the weight limit is calculated from its model",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"serialNumber":    &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"model":           &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(droneModel)},
+			"batteryCapacity": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.Float)},
+			"state":           &graphql.InputObjectFieldConfig{Type: droneState, DefaultValue: dto.IDLE, Description: "only IDLE, the state changes by loading the drone and by its lifecycle actions"},
+		},
+	})
+	// endregion =============================================================================
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"drone": &graphql.Field{
+				Type:        drone,
+				Description: "a drone by its serial number, like GET /drones/{serialNumber}",
+				Args: graphql.FieldConfigArgument{
+					"serialNumber": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: r.drone,
+			},
+			"drones": &graphql.Field{
+				Type:        graphql.NewNonNull(dronePage),
+				Description: "a page of the drones that match the filter, like GET /drones",
+				Args: graphql.FieldConfigArgument{
+					"state":           &graphql.ArgumentConfig{Type: graphql.NewList(graphql.NewNonNull(droneState))},
+					"model":           &graphql.ArgumentConfig{Type: graphql.NewList(graphql.NewNonNull(droneModel))},
+					"batteryMin":      &graphql.ArgumentConfig{Type: graphql.Float},
+					"batteryMax":      &graphql.ArgumentConfig{Type: graphql.Float},
+					"availableWeight": &graphql.ArgumentConfig{Type: graphql.Float, Description: "minimum free carrying capacity"},
+					"retired":         &graphql.ArgumentConfig{Type: graphql.Boolean, DefaultValue: false},
+					"sort":            &graphql.ArgumentConfig{Type: graphql.String, Description: "batteryCapacity (default), serialNumber, weightLimit, model or state"},
+					"order":           &graphql.ArgumentConfig{Type: graphql.String, Description: "asc or desc"},
+					"limit":           &graphql.ArgumentConfig{Type: graphql.Int, Description: "page size, 10 by default and 100 at most"},
+					"cursor":          &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Resolve: r.dronesPage,
+			},
+			"medications": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(medication))),
+				Description: "all the medications, like GET /medications",
+				Resolve:     r.medications,
+			},
+			"logs": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(logEvent))),
+				Description: "the last battery level event logs, the newest first, like GET /logs",
+				Resolve:     r.logs,
+			},
+		},
+	})
+
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"registerDrone": &graphql.Field{
+				Type:        graphql.NewNonNull(drone),
+				Description: "registers a new drone, like POST /drones",
+				Args: graphql.FieldConfigArgument{
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(droneInput)},
+				},
+				Resolve: r.registerDrone,
+			},
+			"loadMedications": &graphql.Field{
+				Type:        graphql.NewNonNull(drone),
+				Description: "load a drone with medication items, like POST /medications/items/{serialNumber}",
+				Args: graphql.FieldConfigArgument{
+					"serialNumber": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"codes":        &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String)))},
+				},
+				Resolve: r.loadMedications,
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query, Mutation: mutation})
+}
+
+// region ======== QUERIES ===============================================================
+
+func (r *resolvers) drone(p graphql.ResolveParams) (interface{}, error) {
+	serialNumber := p.Args["serialNumber"].(string)
+	if problem := validSerialNumber(serialNumber); problem != nil {
+		return nil, problemError(p.Context, problem)
+	}
+	drone, problem := (*r.drones).GetADroneSvc(p.Context, serialNumber)
+	if problem != nil {
+		// a missing drone is null, like the fields of GraphQL
+		if problem.Code == schema.ErrBuntdbItemNotFound {
+			return nil, nil
+		}
+		return nil, problemError(p.Context, problem)
+	}
+	return drone, nil
+}
+
+func (r *resolvers) dronesPage(p graphql.ResolveParams) (interface{}, error) {
+	filter, problem := droneFilter(p.Args)
+	if problem != nil {
+		return nil, problemError(p.Context, problem)
+	}
+	page, problem := (*r.drones).GetDronesSvc(p.Context, filter)
+	if problem != nil {
+		return nil, problemError(p.Context, problem)
+	}
+	var nextCursor interface{}
+	if page.NextCursor != "" {
+		nextCursor = page.NextCursor
+	}
+	items := make([]*dto.Drone, len(page.Items))
+	for i := range page.Items {
+		items[i] = &page.Items[i]
+	}
+	return map[string]interface{}{"items": items, "total": page.Total, "nextCursor": nextCursor}, nil
+}
+
+func (r *resolvers) medications(p graphql.ResolveParams) (interface{}, error) {
+	medications, problem := r.medicationCatalogue(p.Context)
+	if problem != nil {
+		return nil, problemError(p.Context, problem)
+	}
+	return medications, nil
+}
+
+func (r *resolvers) logs(p graphql.ResolveParams) (interface{}, error) {
+	logs, problem := r.eventLogs(p.Context)
+	if problem != nil {
+		return nil, problemError(p.Context, problem)
+	}
+	return logs, nil
+}
+
+// loadedMedications the details of the medications loaded in the drone. The catalogue of medications is
+// read once per request, however many drones are resolved
+func (r *resolvers) loadedMedications(p graphql.ResolveParams) (interface{}, error) {
+	drone := p.Source.(*dto.Drone)
+	codes, problem := (*r.drones).CheckingLoadedMedicationsItemsSvc(p.Context, drone.SerialNumber)
+	if problem != nil {
+		return nil, problemError(p.Context, problem)
+	}
+	medications, problem := r.medicationCatalogue(p.Context)
+	if problem != nil {
+		return nil, problemError(p.Context, problem)
+	}
+	byCode := make(map[string]dto.Medication, len(medications))
+	for _, m := range medications {
+		byCode[m.Code] = m
+	}
+	loaded := make([]dto.Medication, 0, len(*codes))
+	for _, code := range *codes {
+		if m, ok := byCode[code]; ok {
+			loaded = append(loaded, m)
+		}
+	}
+	return loaded, nil
+}
+
+// batteryHistory the battery level of the drone in the last event logs. The event logs are read once per
+// request, however many drones are resolved
+func (r *resolvers) batteryHistory(p graphql.ResolveParams) (interface{}, error) {
+	drone := p.Source.(*dto.Drone)
+	logs, problem := r.eventLogs(p.Context)
+	if problem != nil {
+		return nil, problemError(p.Context, problem)
+	}
+	history := make([]BatteryLevel, 0, len(logs))
+	for _, l := range logs {
+		for _, level := range l.DronesBatteryLevels {
+			if level.SerialNumber == drone.SerialNumber {
+				history = append(history, BatteryLevel{Created: l.Created, BatteryCapacity: level.BatteryCapacity})
+			}
+		}
+	}
+	return history, nil
+}
+
+// endregion =============================================================================
+
+// region ======== MUTATIONS =============================================================
+
+func (r *resolvers) registerDrone(p graphql.ResolveParams) (interface{}, error) {
+	input := p.Args["input"].(map[string]interface{})
+	drone := &dto.Drone{
+		SerialNumber:    input["serialNumber"].(string),
+		Model:           input["model"].(dto.DroneModel),
+		BatteryCapacity: input["batteryCapacity"].(float64),
+		State:           input["state"].(dto.DroneState),
+	}
+	// calculate drone weight limit
+	drone.WeightLimit = lib.CalculateDroneWeightLimit(drone.Model)
+
+	// validate drone fields
+	if _, err := govalidator.ValidateStruct(drone); err != nil {
+		return nil, problemError(p.Context, lib.ValidationProblem(err))
+	}
+
+	if problem := (*r.drones).RegisterDroneSvc(p.Context, drone); problem != nil {
+		return nil, problemError(p.Context, problem)
+	}
+	// the failure is logged by the service with the request ID
+	_ = (*r.audit).RecordSvc(p.Context, actorOf(p.Context), dto.AuditActionRegisterDrone, drone.SerialNumber, nil, drone)
+	return drone, nil
+}
+
+func (r *resolvers) loadMedications(p graphql.ResolveParams) (interface{}, error) {
+	serialNumber := p.Args["serialNumber"].(string)
+	if problem := validSerialNumber(serialNumber); problem != nil {
+		return nil, problemError(p.Context, problem)
+	}
+	medicationItemIDs := p.Args["codes"].([]interface{})
+	// if false, then there is at least one invalid medication item id
+	if !lib.ValidateStringCollection(medicationItemIDs, dto.RegexpMedicationCode) {
+		return nil, problemError(p.Context, dto.NewProblem(http.StatusBadRequest, schema.ErrValidationField, schema.DetInvalidMedicationIDs))
+	}
+
+	// the previously loaded items are kept for the audit trail
+	before, problem := (*r.drones).LoadMedicationItemsADroneSvc(p.Context, serialNumber, medicationItemIDs)
+	if problem != nil {
+		return nil, problemError(p.Context, problem)
+	}
+	_ = (*r.audit).RecordSvc(p.Context, actorOf(p.Context), dto.AuditActionLoadMedications, serialNumber, before, lib.Unique(medicationItemIDs))
+
+	drone, problem := (*r.drones).GetADroneSvc(p.Context, serialNumber)
+	if problem != nil {
+		return nil, problemError(p.Context, problem)
+	}
+	return drone, nil
+}
+
+// endregion =============================================================================
@@ -1,20 +1,49 @@
 package middlewares
 
 import (
+	"github.com/kataras/iris/v12"
 	"github.com/kataras/iris/v12/context"
 	"github.com/kataras/iris/v12/middleware/jwt"
+	"github.com/kmilodenisglez/drones.restapi/schema"
 	"github.com/kmilodenisglez/drones.restapi/schema/dto"
+	"github.com/kmilodenisglez/drones.restapi/service/utils"
 )
 
-// NewAuthCheckerMiddleware Bearer Authentication token verification middleware
-func NewAuthCheckerMiddleware(sigKey []byte) context.Handler {
-	checker := jwt.NewVerifier(jwt.HS256, sigKey)
-	checker.WithDefaultBlocklist() // Enable server-side token block feature (even before its expiration time):
-	// checker.WithDecryption()
+// NewAuthVerifier the access token verifier, shared by the REST middleware and the gRPC interceptors so a
+// token invalidated by a logout is refused by both
+func NewAuthVerifier(sigKey []byte) *jwt.Verifier {
+	verifier := jwt.NewVerifier(jwt.HS256, sigKey)
+	verifier.WithDefaultBlocklist() // Enable server-side token block feature (even before its expiration time):
+	// verifier.WithDecryption()
+	return verifier
+}
+
+// NewAuthCheckerMiddleware Bearer Authentication token verification middleware, a missing or invalid
+// token is answered with an err.unauthorized problem
+func NewAuthCheckerMiddleware(verifier *jwt.Verifier, svcR *utils.SvcResponse) context.Handler {
+	verifier.ErrorHandler = func(ctx iris.Context, err error) {
+		svcR.ResErr(dto.NewProblem(iris.StatusUnauthorized, schema.ErrUnauthorized, err.Error()), &ctx)
+	}
 
-	return checker.Verify(func() interface{} {
+	return verifier.Verify(func() interface{} {
 		// We can add login here
 
 		return new(dto.AccessTokenData)
 	})
 }
+
+// NewAdminCheckerMiddleware refuse with an err.forbidden problem the access tokens without the dto.ScopeAdmin
+// scope, it goes after the authentication checker middleware
+func NewAdminCheckerMiddleware(svcR *utils.SvcResponse) context.Handler {
+	return func(ctx iris.Context) {
+		if claims, ok := ctx.Values().Get("iris.jwt.claims").(*dto.AccessTokenData); ok {
+			for _, scope := range claims.Scope {
+				if scope == dto.ScopeAdmin {
+					ctx.Next()
+					return
+				}
+			}
+		}
+		svcR.ResErr(dto.NewProblem(iris.StatusForbidden, schema.ErrForbidden, schema.DetAdminRequired), &ctx)
+	}
+}
@@ -0,0 +1,114 @@
+package middlewares
+
+import (
+	"bytes"
+	"io"
+	"time"
+
+	"github.com/kataras/iris/v12"
+	"github.com/kataras/iris/v12/context"
+	"github.com/kmilodenisglez/drones.restapi/schema"
+	"github.com/kmilodenisglez/drones.restapi/schema/dto"
+	"github.com/kmilodenisglez/drones.restapi/service"
+	"github.com/kmilodenisglez/drones.restapi/service/utils"
+)
+
+// replayedHeaders the response headers stored with the body of an idempotent response
+var replayedHeaders = []string{"Content-Type", "Content-Language", "ETag", "Location"}
+
+// NewIdempotencyMiddleware Idempotency-Key support of a mutating route, it must run after the auth checker.
+// The first response of a key and user is stored and replayed, with the Idempotent-Replayed header, to the
+// retries of the request; the same key with a different request (method, path or body) is refused. The
+// key stays reserved until the response is stored; the server errors are not stored, their key is
+// released so the request can be retried. A request without the header is handled as usual
+//
+// - svcIdempotency [*service.ISvcIdempotency] ~ Idempotency-Key service instance
+//
+// - svcR [*utils.SvcResponse] ~ Response service instance
+//
+// - svcLog [*utils.SvcLogger] ~ Logger service instance
+func NewIdempotencyMiddleware(svcIdempotency *service.ISvcIdempotency, svcR *utils.SvcResponse, svcLog *utils.SvcLogger) context.Handler {
+	return func(ctx iris.Context) {
+		key := ctx.GetHeader(dto.IdempotencyKeyHeader)
+		if key == "" {
+			ctx.Next()
+			return
+		}
+
+		// the body is read to fingerprint the request and restored for the handler
+		body, err := io.ReadAll(ctx.Request().Body)
+		if err != nil {
+			svcR.ResErr(dto.NewProblem(iris.StatusBadRequest, schema.ErrProcParam, err.Error()), &ctx)
+			return
+		}
+		ctx.Request().Body = io.NopCloser(bytes.NewReader(body))
+
+		reqCtx := ctx.Request().Context()
+		username := ctx.Values().Get("iris.jwt.claims").(*dto.AccessTokenData).Claims.Username
+		fingerprint := service.IdempotencyFingerprint(ctx.Method(), ctx.Request().URL.RequestURI(), body)
+
+		stored, problem := (*svcIdempotency).BeginSvc(reqCtx, username, key, fingerprint)
+		if problem != nil {
+			svcR.ResErr(problem, &ctx)
+			return
+		}
+		if stored != nil {
+			replayIdempotentResponse(ctx, stored)
+			return
+		}
+
+		release := func() {
+			if problem := (*svcIdempotency).ReleaseSvc(reqCtx, username, key); problem != nil {
+				svcLog.Errorf(reqCtx, "the idempotency key %q can't be released: %s", key, problem.Detail)
+			}
+		}
+		// the reservation is stored with the configured TTL and it is released if the handler panics. If the
+		// handler outlasts the TTL, buntdb deletes the reservation: a retry reserves the key again and runs
+		// the request a second time, and the response stored last is the one replayed afterwards
+		handled := false
+		defer func() {
+			if !handled {
+				release()
+			}
+		}()
+
+		ctx.Record()
+		ctx.Next()
+		handled = true
+
+		status := ctx.GetStatusCode()
+		if status >= iris.StatusInternalServerError {
+			release()
+			return
+		}
+
+		response := dto.IdempotentResponse{
+			Fingerprint: fingerprint,
+			Status:      status,
+			Headers:     make(map[string]string),
+			Body:        ctx.Recorder().Body(),
+			Created:     time.Now().UTC().Format(time.RFC3339Nano),
+		}
+		for _, name := range replayedHeaders {
+			if value := ctx.ResponseWriter().Header().Get(name); value != "" {
+				response.Headers[name] = value
+			}
+		}
+		if problem := (*svcIdempotency).CompleteSvc(reqCtx, username, key, &response); problem != nil {
+			svcLog.Errorf(reqCtx, "the response of the idempotency key %q can't be stored: %s", key, problem.Detail)
+		}
+	}
+}
+
+// replayIdempotentResponse write the stored response of a key
+func replayIdempotentResponse(ctx iris.Context, stored *dto.IdempotentResponse) {
+	for name, value := range stored.Headers {
+		ctx.Header(name, value)
+	}
+	ctx.Header(dto.IdempotencyReplayedHeader, "true")
+	ctx.StatusCode(stored.Status)
+	if len(stored.Body) > 0 {
+		_, _ = ctx.Write(stored.Body)
+	}
+	ctx.StopExecution()
+}
@@ -0,0 +1,45 @@
+package middlewares
+
+import (
+	"time"
+
+	"github.com/kataras/golog"
+	"github.com/kataras/iris/v12/context"
+	"github.com/kataras/iris/v12/middleware/requestid"
+	"github.com/kmilodenisglez/drones.restapi/lib"
+	"github.com/kmilodenisglez/drones.restapi/service/utils"
+)
+
+// NewRequestIDMiddleware assign an ID to every request. The X-Request-Id header of the client is reused
+// if present, otherwise a new UUID is generated. The ID is sent back in the X-Request-Id response header
+// and is stored in the request context, so the services and repositories can log it
+func NewRequestIDMiddleware() context.Handler {
+	return func(ctx *context.Context) {
+		id := requestid.Get(ctx)
+		if id == "" {
+			id = requestid.DefaultGenerator(ctx) // reuses the client header, also sets the response header
+			ctx.SetID(id)
+		}
+		ctx.ResetRequest(ctx.Request().WithContext(lib.WithRequestID(ctx.Request().Context(), id)))
+		ctx.Next()
+	}
+}
+
+// NewAccessLogMiddleware log one structured line per request (method, path, status, latency and client IP),
+// tied to the rest of the log lines of the request by its ID. It replaces the Iris logger.New() middleware
+//
+// - svcLog [*utils.SvcLogger] ~ Logger service instance
+func NewAccessLogMiddleware(svcLog *utils.SvcLogger) context.Handler {
+	return func(ctx *context.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		svcLog.InfoFields(ctx.Request().Context(), "request completed", golog.Fields{
+			"method":  ctx.Method(),
+			"path":    ctx.Path(),
+			"status":  ctx.GetStatusCode(),
+			"latency": time.Since(start).String(),
+			"ip":      ctx.RemoteAddr(),
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/kmilodenisglez/drones.restapi/lib"
+	"github.com/kmilodenisglez/drones.restapi/schema"
+	"github.com/kmilodenisglez/drones.restapi/schema/dto"
+	"github.com/kmilodenisglez/drones.restapi/schema/mapper"
+	"github.com/kmilodenisglez/drones.restapi/schema/pb"
+	"github.com/kmilodenisglez/drones.restapi/service"
+	"github.com/kmilodenisglez/drones.restapi/service/utils"
+)
+
+// dronesServer gRPC DronesService, the counterpart of the drones and medications REST endpoints
+type dronesServer struct {
+	pb.UnimplementedDronesServiceServer
+	service *service.ISvcDrones
+	audit   *service.ISvcAudit
+	appConf *utils.SvcConfig
+}
+
+// region ======== Drones ================================================================
+
+// ListDrones a page of the drones that match the filter
+func (s *dronesServer) ListDrones(ctx context.Context, req *pb.ListDronesRequest) (*pb.ListDronesResponse, error) {
+	filter, err := droneFilter(req)
+	if err != nil {
+		return nil, problemStatus(dto.NewProblem(http.StatusBadRequest, schema.ErrParamURL, err.Error()), s.appConf)
+	}
+
+	page, problem := (*s.service).GetDronesSvc(ctx, filter)
+	if problem != nil {
+		return nil, problemStatus(problem, s.appConf)
+	}
+	return &pb.ListDronesResponse{
+		Items:      mapper.ToDroneListPb(page.Items).Items,
+		Total:      int32(page.Total),
+		NextCursor: page.NextCursor,
+	}, nil
+}
+
+// GetDrone a drone by its serial number
+func (s *dronesServer) GetDrone(ctx context.Context, req *pb.GetDroneRequest) (*pb.Drone, error) {
+	if req.SerialNumber == "" {
+		return nil, problemStatus(dto.NewProblem(http.StatusBadRequest, schema.ErrProcParam, schema.DetInvalidField), s.appConf)
+	}
+	drone, problem := (*s.service).GetADroneSvc(ctx, req.SerialNumber)
+	if problem != nil {
+		return nil, problemStatus(problem, s.appConf)
+	}
+	return mapper.ToDronePb(drone), nil
+}
+
+// RegisterDrone registers a new drone, the weight limit is calculated from its model
+func (s *dronesServer) RegisterDrone(ctx context.Context, req *pb.RegisterDroneRequest) (*pb.Drone, error) {
+	drone := &dto.Drone{
+		SerialNumber:    req.SerialNumber,
+		Model:           dto.DroneModel(req.Model),
+		BatteryCapacity: req.BatteryCapacity,
+		State:           dto.DroneState(req.State),
+	}
+	// calculate drone weight limit
+	drone.WeightLimit = lib.CalculateDroneWeightLimit(drone.Model)
+
+	// validate drone fields
+	if _, err := govalidator.ValidateStruct(drone); err != nil {
+		return nil, problemStatus(lib.ValidationProblem(err), s.appConf)
+	}
+
+	if problem := (*s.service).RegisterDroneSvc(ctx, drone); problem != nil {
+		return nil, problemStatus(problem, s.appConf)
+	}
+	// the failure is logged by the service with the request ID
+	_ = (*s.audit).RecordSvc(ctx, actorOf(ctx), dto.AuditActionRegisterDrone, drone.SerialNumber, nil, drone)
+	return mapper.ToDronePb(drone), nil
+}
+
+// endregion ======== Drones =============================================================
+
+// region ======== Medications ===========================================================
+
+// ListMedications all the medications
+func (s *dronesServer) ListMedications(ctx context.Context, _ *pb.ListMedicationsRequest) (*pb.MedicationList, error) {
+	medications, problem := (*s.service).GetMedicationsSvc(ctx)
+	if problem != nil {
+		return nil, problemStatus(problem, s.appConf)
+	}
+	return mapper.ToMedicationListPb(*medications), nil
+}
+
+// LoadMedications load a drone with medication items
+func (s *dronesServer) LoadMedications(ctx context.Context, req *pb.LoadMedicationsRequest) (*pb.LoadMedicationsResponse, error) {
+	if problem := validSerialNumber(req.SerialNumber); problem != nil {
+		return nil, problemStatus(problem, s.appConf)
+	}
+
+	medicationItemIDs := make([]interface{}, len(req.MedicationCodes))
+	for i, code := range req.MedicationCodes {
+		medicationItemIDs[i] = code
+	}
+	// if false, then there is at least one invalid medication item id
+	if !lib.ValidateStringCollection(medicationItemIDs, dto.RegexpMedicationCode) {
+		return nil, problemStatus(dto.NewProblem(http.StatusBadRequest, schema.ErrValidationField, schema.DetInvalidMedicationIDs), s.appConf)
+	}
+
+	// the previously loaded items are kept for the audit trail
+	before, problem := (*s.service).LoadMedicationItemsADroneSvc(ctx, req.SerialNumber, medicationItemIDs)
+	if problem != nil {
+		return nil, problemStatus(problem, s.appConf)
+	}
+	_ = (*s.audit).RecordSvc(ctx, actorOf(ctx), dto.AuditActionLoadMedications, req.SerialNumber, before, lib.Unique(medicationItemIDs))
+	return &pb.LoadMedicationsResponse{}, nil
+}
+
+// GetLoadedMedications codes of the medications loaded in a drone
+func (s *dronesServer) GetLoadedMedications(ctx context.Context, req *pb.GetLoadedMedicationsRequest) (*pb.LoadedMedications, error) {
+	if problem := validSerialNumber(req.SerialNumber); problem != nil {
+		return nil, problemStatus(problem, s.appConf)
+	}
+	codes, problem := (*s.service).CheckingLoadedMedicationsItemsSvc(ctx, req.SerialNumber)
+	if problem != nil {
+		return nil, problemStatus(problem, s.appConf)
+	}
+	return &pb.LoadedMedications{Codes: *codes}, nil
+}
+
+// endregion ======== Medications ========================================================
+
+// region ======== PRIVATE AUX ===========================================================
+
+// droneFilter the typed filter of the drone list, with the same rules of the query parameters of GET /drones
+func droneFilter(req *pb.ListDronesRequest) (*dto.DroneFilter, error) {
+	filter := dto.DroneFilter{
+		BatteryMin:         req.BatteryMin,
+		BatteryMax:         req.BatteryMax,
+		MinAvailableWeight: req.AvailableWeight,
+		Retired:            req.Retired,
+		SortBy:             dto.DroneSortBattery,
+		Desc:               true,
+		Limit:              int(req.Limit),
+		Cursor:             req.Cursor,
+	}
+
+	for _, v := range req.States {
+		if dto.DroneState(v).String() == "unknown" {
+			return nil, fmt.Errorf("unknown drone state '%d'", v)
+		}
+		filter.States = append(filter.States, dto.DroneState(v))
+	}
+	for _, v := range req.Models {
+		if dto.DroneModel(v).String() == "unknown" {
+			return nil, fmt.Errorf("unknown drone model '%d'", v)
+		}
+		filter.Models = append(filter.Models, dto.DroneModel(v))
+	}
+	if filter.BatteryMin != nil && filter.BatteryMax != nil && *filter.BatteryMin > *filter.BatteryMax {
+		return nil, fmt.Errorf("battery_min can't be greater than battery_max")
+	}
+
+	if req.Sort != "" {
+		if !lib.Contains([]string{dto.DroneSortBattery, dto.DroneSortSerialNumber, dto.DroneSortWeightLimit, dto.DroneSortModel, dto.DroneSortState}, req.Sort) {
+			return nil, fmt.Errorf("the drones can't be sorted by '%s'", req.Sort)
+		}
+		filter.SortBy = req.Sort
+		// only the battery capacity is sorted descending by default
+		filter.Desc = req.Sort == dto.DroneSortBattery
+	}
+	switch req.Order {
+	case "":
+	case "asc":
+		filter.Desc = false
+	case "desc":
+		filter.Desc = true
+	default:
+		return nil, fmt.Errorf("order must be 'asc' or 'desc'")
+	}
+
+	if filter.Limit < 0 {
+		return nil, fmt.Errorf("limit must be a positive integer")
+	}
+	return &filter, nil
+}
+
+// validSerialNumber a problem if the serial number of a drone is missing or too long
+func validSerialNumber(serialNumber string) *dto.Problem {
+	if serialNumber == "" {
+		return dto.NewProblem(http.StatusBadRequest, schema.ErrProcParam, schema.DetInvalidField)
+	}
+	if !lib.ValidateSerialNumberDrone(serialNumber) {
+		return dto.NewProblemf(http.StatusBadRequest, schema.ErrValidationField, schema.DetSerialNumberTooLong, dto.MaxSerialNumberLength)
+	}
+	return nil
+}
+
+// endregion =============================================================================
@@ -0,0 +1,58 @@
+package rpc
+
+import (
+	"time"
+
+	"github.com/kmilodenisglez/drones.restapi/schema/dto"
+	"github.com/kmilodenisglez/drones.restapi/schema/mapper"
+	"github.com/kmilodenisglez/drones.restapi/schema/pb"
+	"github.com/kmilodenisglez/drones.restapi/service/cron"
+	"github.com/kmilodenisglez/drones.restapi/service/utils"
+)
+
+// eventLogServer gRPC EventLogService, the counterpart of GET /logs/event
+type eventLogServer struct {
+	pb.UnimplementedEventLogServiceServer
+	service *cron.ISvcEventLog
+	appConf *utils.SvcConfig
+	// pollInterval how often the event logs are read again when following the stream
+	pollInterval time.Duration
+}
+
+// StreamBatteryLogs send the last event logs, the oldest first. With follow the event logs are read again
+// every poll interval and the new ones are sent, until the client cancels the call
+func (s *eventLogServer) StreamBatteryLogs(req *pb.StreamBatteryLogsRequest, stream pb.EventLogService_StreamBatteryLogsServer) error {
+	ctx := stream.Context()
+	sent := make(map[string]bool)
+	for {
+		logs, problem := (*s.service).GetEventLogs(ctx)
+		if problem != nil {
+			return problemStatus(problem, s.appConf)
+		}
+		// the event logs are read the newest first, only the ones of the last read are remembered
+		latest := make(map[string]bool, len(*logs))
+		pending := make([]dto.LogEvent, 0)
+		for i := len(*logs) - 1; i >= 0; i-- {
+			l := (*logs)[i]
+			latest[l.UUID] = true
+			if !sent[l.UUID] {
+				pending = append(pending, l)
+			}
+		}
+		for _, l := range mapper.ToLogEventListPb(pending).Items {
+			if err := stream.Send(l); err != nil {
+				return err
+			}
+		}
+		sent = latest
+
+		if !req.Follow {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(s.pollInterval):
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package rpc
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kataras/golog"
+	"github.com/kataras/iris/v12/middleware/jwt"
+	"github.com/kmilodenisglez/drones.restapi/lib"
+	"github.com/kmilodenisglez/drones.restapi/repo/db"
+	"github.com/kmilodenisglez/drones.restapi/schema"
+	"github.com/kmilodenisglez/drones.restapi/schema/dto"
+	"github.com/kmilodenisglez/drones.restapi/schema/pb"
+	"github.com/kmilodenisglez/drones.restapi/service"
+	"github.com/kmilodenisglez/drones.restapi/service/cron"
+	"github.com/kmilodenisglez/drones.restapi/service/i18n"
+	"github.com/kmilodenisglez/drones.restapi/service/utils"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// region ======== TYPES =================================================================
+
+// metadata keys of the calls
+const (
+	mdAuthorization = "authorization"
+	mdRequestID     = "x-request-id"
+)
+
+// ErrorDomain domain of the ErrorInfo detail of the errors, its reason is the problem code (schema.Err* key)
+const ErrorDomain = "drones.restapi"
+
+// claimsKey context key of the access token data of the call
+type claimsKey struct{}
+
+// authenticator verifies the access token of the calls
+type authenticator struct {
+	verifier *jwt.Verifier
+}
+
+// endregion =============================================================================
+
+// NewServer create the gRPC server of the drones and event log services. They are served by the same
+// service layer of the REST API and every call is authenticated with its access tokens
+//
+// - verifier [*jwt.Verifier] ~ Access token verifier, shared with the REST authentication middleware
+//
+// - svcC [*utils.SvcConfig] ~ Configuration service instance
+//
+// - svcL [*utils.SvcLogger] ~ Logger service instance
+func NewServer(verifier *jwt.Verifier, svcC *utils.SvcConfig, svcL *utils.SvcLogger) *grpc.Server {
+	repoDrones := db.NewRepoDrones(svcC, svcL)
+	svcDrones := service.NewSvcDronesReqs(svcC, &repoDrones, svcL)
+	repoAudit := db.NewRepoAudit(svcC, svcL)
+	svcAudit := service.NewSvcAuditReqs(&repoAudit, svcL)
+	svcEventLog := cron.NewSvcRepoEventLog(svcC, svcL)
+
+	auth := authenticator{verifier: verifier}
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(requestIDUnary, accessLogUnary(svcL), auth.unary),
+		grpc.ChainStreamInterceptor(requestIDStream, accessLogStream(svcL), auth.stream),
+	)
+	pb.RegisterDronesServiceServer(server, &dronesServer{service: &svcDrones, audit: &svcAudit, appConf: svcC})
+	pb.RegisterEventLogServiceServer(server, &eventLogServer{service: &svcEventLog, appConf: svcC, pollInterval: time.Second})
+	return server
+}
+
+// region ======== INTERCEPTORS ==========================================================
+
+// unary authenticate the call, the access token data is stored in the call context
+func (a authenticator) unary(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := a.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// stream authenticate the streaming call, the access token data is stored in the stream context
+func (a authenticator) stream(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := a.authenticate(ss.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &contextStream{ss, ctx})
+}
+
+// authenticate verify the bearer token of the authorization metadata, like the REST authentication
+// middleware does with the Authorization header. A token invalidated by a logout is refused
+func (a authenticator) authenticate(ctx context.Context) (context.Context, error) {
+	token := ""
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(mdAuthorization); len(values) > 0 {
+		token = strings.TrimSpace(strings.TrimPrefix(values[0], "Bearer "))
+	}
+	if token == "" {
+		return nil, status.Error(codes.Unauthenticated, "missing access token, send it as 'authorization: Bearer <token>'")
+	}
+
+	validators := make([]jwt.TokenValidator, 0)
+	if a.verifier.Blocklist != nil {
+		validators = append(validators, a.verifier.Blocklist)
+	}
+	verified, err := a.verifier.VerifyToken([]byte(token), validators...)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	claims := new(dto.AccessTokenData)
+	if err := verified.Claims(claims); err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return context.WithValue(ctx, claimsKey{}, claims), nil
+}
+
+// requestIDUnary assign an ID to the call, the x-request-id metadata of the client is reused if present.
+// It is sent back in the header metadata and stored in the context, so the services can log it
+func requestIDUnary(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	return handler(withRequestID(ctx), req)
+}
+
+// requestIDStream requestIDUnary for the streaming calls
+func requestIDStream(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &contextStream{ss, withRequestID(ss.Context())})
+}
+
+// accessLogUnary log one structured line per call (method, status code and latency)
+func accessLogUnary(svcLog *utils.SvcLogger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		res, err := handler(ctx, req)
+		logCall(svcLog, ctx, info.FullMethod, err, start)
+		return res, err
+	}
+}
+
+// accessLogStream accessLogUnary for the streaming calls, the line is logged when the stream ends
+func accessLogStream(svcLog *utils.SvcLogger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		logCall(svcLog, ss.Context(), info.FullMethod, err, start)
+		return err
+	}
+}
+
+// endregion =============================================================================
+
+// region ======== ERRORS ================================================================
+
+// problemStatus the gRPC status of a problem of the service layer. The message is the detail, hidden for
+// the internal errors unless the server runs in debug mode, and the problem code is sent as the reason of
+// an ErrorInfo detail. The invalid fields of a validation failure are sent as a BadRequest detail
+//
+// - problem [*dto.Problem] ~ Problem of the service layer
+//
+// - appConf [*utils.SvcConfig] ~ App conf instance pointer
+func problemStatus(problem *dto.Problem, appConf *utils.SvcConfig) error {
+	problemType := schema.ProblemTypeOf(problem.Code)
+	message := i18n.Message(i18n.DefaultLanguage, problem.Detail, problem.DetailArgs...)
+	if problemType.Internal && !appConf.Reloadable().Debug {
+		message = ""
+	}
+	if message == "" {
+		message = i18n.Title(i18n.DefaultLanguage, problem.Code)
+	}
+
+	st := status.New(grpcCode(problem.Code, problemType.Status), message)
+	info := &errdetails.ErrorInfo{Reason: problem.Code, Domain: ErrorDomain}
+	if len(problem.Errors) == 0 {
+		if withDetails, err := st.WithDetails(info); err == nil {
+			st = withDetails
+		}
+		return st.Err()
+	}
+
+	badRequest := &errdetails.BadRequest{}
+	for _, fe := range problem.Errors {
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{Field: fe.Field, Description: fe.Message})
+	}
+	if withDetails, err := st.WithDetails(info, badRequest); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
+
+// grpcCode the gRPC code of an error code, after the HTTP status of its problem type
+func grpcCode(code string, httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest, http.StatusNotAcceptable:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		if code == schema.ErrDuplicateKey {
+			return codes.AlreadyExists
+		}
+		return codes.FailedPrecondition
+	case http.StatusPreconditionFailed:
+		return codes.FailedPrecondition
+	case http.StatusServiceUnavailable, http.StatusBadGateway:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+	return codes.Internal
+}
+
+// endregion =============================================================================
+
+// region ======== PRIVATE AUX ===========================================================
+
+// contextStream a server stream with a derived context
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+// withRequestID the context with the ID of the call, also sent back in the header metadata
+func withRequestID(ctx context.Context) context.Context {
+	id := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(mdRequestID); len(values) > 0 {
+			id = values[0]
+		}
+	}
+	if id == "" {
+		id = lib.GenerateUUIDStr()
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs(mdRequestID, id))
+	return lib.WithRequestID(ctx, id)
+}
+
+// logCall log the access line of a call
+func logCall(svcLog *utils.SvcLogger, ctx context.Context, method string, err error, start time.Time) {
+	svcLog.InfoFields(ctx, "call completed", golog.Fields{
+		"method":  method,
+		"code":    status.Code(err).String(),
+		"latency": time.Since(start).String(),
+	})
+}
+
+// actorOf the user of the call, taken from its access token
+func actorOf(ctx context.Context) dto.InjectedParam {
+	if claims, ok := ctx.Value(claimsKey{}).(*dto.AccessTokenData); ok {
+		return claims.Claims
+	}
+	return dto.InjectedParam{}
+}
+
+// endregion =============================================================================
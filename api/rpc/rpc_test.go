@@ -0,0 +1,212 @@
+package rpc_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kmilodenisglez/drones.restapi/api/middlewares"
+	"github.com/kmilodenisglez/drones.restapi/api/rpc"
+	"github.com/kmilodenisglez/drones.restapi/lib"
+	"github.com/kmilodenisglez/drones.restapi/repo/db"
+	"github.com/kmilodenisglez/drones.restapi/schema"
+	"github.com/kmilodenisglez/drones.restapi/schema/dto"
+	"github.com/kmilodenisglez/drones.restapi/schema/pb"
+	"github.com/kmilodenisglez/drones.restapi/service/utils"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// TestServer call the gRPC services through an in-memory connection
+func TestServer(t *testing.T) {
+	svcConf, err := utils.NewSvcConfig("")
+	if err != nil {
+		t.Fatalf("config: %s", err)
+	}
+	svcConf.StoreBackend = schema.StoreBackendBuntDB
+	svcConf.StoreDBPath = filepath.Join(t.TempDir(), "data.db")
+	svcConf.LogDBPath = filepath.Join(t.TempDir(), "event_log.db")
+	svcConf.AuditDBPath = filepath.Join(t.TempDir(), "audit.db")
+	svcLog := utils.NewSvcLogger(svcConf)
+	svcLog.SetOutput(io.Discard)
+	lib.InitValidator()
+
+	repo := db.NewRepoDrones(svcConf, svcLog)
+	if _, err := repo.Migrate(context.Background(), false); err != nil {
+		t.Fatalf("migrate: %s", err)
+	}
+	dataset := dto.Dataset{
+		Version: dto.DatasetVersion,
+		Users:   []dto.User{{Username: "ana@example.com", Name: "Ana", Passphrase: "hash"}},
+		Drones: []dto.Drone{
+			{SerialNumber: "SN-1", Model: dto.Heavyweight, WeightLimit: 500, BatteryCapacity: 90, State: dto.IDLE, Version: 1},
+			{SerialNumber: "SN-2", Model: dto.Lightweight, WeightLimit: 125, BatteryCapacity: 40, State: dto.IDLE, Version: 1},
+			{SerialNumber: "SN-0", Model: dto.Lightweight, WeightLimit: 125, BatteryCapacity: 10, State: dto.IDLE, Version: 1},
+		},
+		Medications: []dto.Medication{{Name: "LIGHT", Weight: 10, Code: "LIGHT", Image: "aW1hZ2U="}},
+		Payloads:    map[string][]string{},
+	}
+	if err := repo.ImportData(context.Background(), &dataset, true); err != nil {
+		t.Fatalf("import: %s", err)
+	}
+	logs := db.NewRepoEventLog(svcConf, svcLog)
+	levels := []dto.DroneBatteryLevel{{SerialNumber: "SN-1", BatteryCapacity: 90}}
+	if err := logs.ImportEventLogs(context.Background(), []dto.LogEvent{
+		{Created: "20240101-000000", UUID: "a", DronesBatteryLevels: levels},
+		{Created: "20240102-000000", UUID: "b", DronesBatteryLevels: levels},
+	}, true); err != nil {
+		t.Fatalf("import event logs: %s", err)
+	}
+
+	verifier := middlewares.NewAuthVerifier([]byte(svcConf.JWTSignKey))
+	lis := bufconn.Listen(1 << 20)
+	server := rpc.NewServer(verifier, svcConf, svcLog)
+	go func() { _ = server.Serve(lis) }()
+	defer server.Stop()
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial: %s", err)
+	}
+	defer conn.Close()
+	drones := pb.NewDronesServiceClient(conn)
+	eventLogs := pb.NewEventLogServiceClient(conn)
+
+	// without the access token
+	if _, err := drones.ListDrones(context.Background(), &pb.ListDronesRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("a call without token is unauthenticated, got %v", err)
+	}
+	tk, err := lib.MkAccessToken(&dto.AccessTokenData{Claims: dto.InjectedParam{Username: "ana@example.com"}}, []byte(svcConf.JWTSignKey), 1)
+	if err != nil {
+		t.Fatalf("token: %s", err)
+	}
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+string(tk), "x-request-id", "rpc-test")
+
+	// drones
+	var header metadata.MD
+	page, err := drones.ListDrones(ctx, &pb.ListDronesRequest{}, grpc.Header(&header))
+	if err != nil || page.Total != 3 || page.Items[0].SerialNumber != "SN-1" {
+		t.Fatalf("the drones, the highest battery first, got %v (%v)", page, err)
+	}
+	if id := header.Get("x-request-id"); len(id) != 1 || id[0] != "rpc-test" {
+		t.Errorf("the request ID is sent back, got %v", id)
+	}
+	page, err = drones.ListDrones(ctx, &pb.ListDronesRequest{Models: []pb.DroneModel{pb.DroneModel(dto.Lightweight)}, Limit: 1})
+	if err != nil || page.Total != 2 || page.Items[0].SerialNumber != "SN-2" || page.NextCursor == "" {
+		t.Fatalf("the drones filtered by model, got %v (%v)", page, err)
+	}
+	if _, err := drones.ListDrones(ctx, &pb.ListDronesRequest{Sort: "color"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("an unknown sort is an invalid argument, got %v", err)
+	}
+
+	drone, err := drones.GetDrone(ctx, &pb.GetDroneRequest{SerialNumber: "SN-2"})
+	if err != nil || drone.BatteryCapacity != 40 {
+		t.Fatalf("the drone SN-2, got %v (%v)", drone, err)
+	}
+	_, err = drones.GetDrone(ctx, &pb.GetDroneRequest{SerialNumber: "SN-404"})
+	if status.Code(err) != codes.NotFound || reason(err) != schema.ErrBuntdbItemNotFound {
+		t.Errorf("an unknown drone is not found, got %v", err)
+	}
+
+	registered, err := drones.RegisterDrone(ctx, &pb.RegisterDroneRequest{SerialNumber: "SN-3", Model: pb.DroneModel(dto.Middleweight), BatteryCapacity: 80})
+	if err != nil || registered.WeightLimit != lib.CalculateDroneWeightLimit(dto.Middleweight) || registered.Version == 0 {
+		t.Fatalf("the drone is registered with the weight limit of its model, got %v (%v)", registered, err)
+	}
+	_, err = drones.RegisterDrone(ctx, &pb.RegisterDroneRequest{SerialNumber: "SN-3", Model: pb.DroneModel(dto.Middleweight), BatteryCapacity: 80})
+	if status.Code(err) != codes.AlreadyExists || reason(err) != schema.ErrDuplicateKey {
+		t.Errorf("a duplicated serial number already exists, got %v", err)
+	}
+	_, err = drones.RegisterDrone(ctx, &pb.RegisterDroneRequest{SerialNumber: "SN-4", Model: pb.DroneModel(dto.Middleweight), BatteryCapacity: 101})
+	if st := status.Convert(err); st.Code() != codes.InvalidArgument || len(st.Details()) != 2 {
+		t.Errorf("an invalid drone is an invalid argument with the invalid fields, got %v", err)
+	} else if badRequest, ok := st.Details()[1].(*errdetails.BadRequest); !ok || badRequest.FieldViolations[0].Field != "batteryCapacity" {
+		t.Errorf("the battery capacity is invalid, got %v", st.Details())
+	}
+	entries, err := db.NewRepoAudit(svcConf, svcLog).GetAuditEntries(context.Background(), &dto.AuditFilter{Action: dto.AuditActionRegisterDrone})
+	if err != nil || len(*entries) != 1 || (*entries)[0].Actor.Username != "ana@example.com" {
+		t.Errorf("the registration is recorded in the audit trail by the user of the token, got %v (%v)", entries, err)
+	}
+
+	// medications
+	medications, err := drones.ListMedications(ctx, &pb.ListMedicationsRequest{})
+	if err != nil || len(medications.Items) != 1 {
+		t.Fatalf("the medications, got %v (%v)", medications, err)
+	}
+	if _, err := drones.LoadMedications(ctx, &pb.LoadMedicationsRequest{SerialNumber: "SN-1", MedicationCodes: []string{"LIGHT"}}); err != nil {
+		t.Fatalf("load medications: %s", err)
+	}
+	if _, err := drones.LoadMedications(ctx, &pb.LoadMedicationsRequest{SerialNumber: "SN-1", MedicationCodes: []string{"light"}}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("an invalid medication code is an invalid argument, got %v", err)
+	}
+	_, err = drones.LoadMedications(ctx, &pb.LoadMedicationsRequest{SerialNumber: "SN-0", MedicationCodes: []string{"LIGHT"}})
+	if status.Code(err) != codes.FailedPrecondition || reason(err) != schema.ErrDroneVeryLowBatteryKey {
+		t.Errorf("a drone with a low battery can't be loaded, got %v", err)
+	}
+	loaded, err := drones.GetLoadedMedications(ctx, &pb.GetLoadedMedicationsRequest{SerialNumber: "SN-1"})
+	if err != nil || len(loaded.Codes) != 1 || loaded.Codes[0] != "LIGHT" {
+		t.Fatalf("the loaded medications, got %v (%v)", loaded, err)
+	}
+
+	// event logs, the oldest first
+	stream, err := eventLogs.StreamBatteryLogs(ctx, &pb.StreamBatteryLogsRequest{})
+	if err != nil {
+		t.Fatalf("stream: %s", err)
+	}
+	uuids := make([]string, 0)
+	for {
+		event, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("recv: %s", err)
+		}
+		uuids = append(uuids, event.Uuid)
+	}
+	if len(uuids) != 2 || uuids[0] != "a" || uuids[1] != "b" {
+		t.Fatalf("the event logs, the oldest first, got %v", uuids)
+	}
+
+	// following the stream, the new event logs are sent until the call is canceled
+	followCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	follow, err := eventLogs.StreamBatteryLogs(followCtx, &pb.StreamBatteryLogsRequest{Follow: true})
+	if err != nil {
+		t.Fatalf("stream: %s", err)
+	}
+	for _, want := range []string{"a", "b"} {
+		if event, err := follow.Recv(); err != nil || event.Uuid != want {
+			t.Fatalf("event log %s expected, got %v (%v)", want, event, err)
+		}
+	}
+	if err := logs.ImportEventLogs(context.Background(), []dto.LogEvent{{Created: "20240103-000000", UUID: "c", DronesBatteryLevels: levels}}, false); err != nil {
+		t.Fatalf("import event logs: %s", err)
+	}
+	if event, err := follow.Recv(); err != nil || event.Uuid != "c" {
+		t.Fatalf("the new event log is sent, got %v (%v)", event, err)
+	}
+	cancel()
+	if _, err := follow.Recv(); status.Code(err) != codes.Canceled {
+		t.Errorf("the stream ends when the call is canceled, got %v", err)
+	}
+}
+
+// reason the problem code of the ErrorInfo detail of an error
+func reason(err error) string {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.Domain == rpc.ErrorDomain {
+			return info.Reason
+		}
+	}
+	return ""
+}
@@ -0,0 +1,606 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kmilodenisglez/drones.restapi/lib"
+	"github.com/kmilodenisglez/drones.restapi/repo/db"
+	"github.com/kmilodenisglez/drones.restapi/schema/dto"
+	"github.com/kmilodenisglez/drones.restapi/service"
+	"github.com/kmilodenisglez/drones.restapi/service/backup"
+	"github.com/kmilodenisglez/drones.restapi/service/i18n"
+	"github.com/kmilodenisglez/drones.restapi/service/seed"
+	"github.com/kmilodenisglez/drones.restapi/service/utils"
+)
+
+// region ======== SETUP =================================================================
+
+// cliCommand subcommand of the binary, the name can have several words (e.g. "db populate")
+type cliCommand struct {
+	name string
+	desc string
+	run  func(env *cliEnv, args []string) error
+}
+
+// cliCommands the subcommands, running the binary without any of them starts the server
+var cliCommands = []cliCommand{
+	{"serve", "start the REST API server (default)", cmdServe},
+	{"db populate", "populate the database with the data of a fixture file or generated", cmdDBPopulate},
+	{"db reset", "wipe the database and populate it again", cmdDBReset},
+	{"db export", "export the whole dataset as versioned JSON", cmdDBExport},
+	{"db import", "import a JSON dataset created by 'db export'", cmdDBImport},
+	{"db backup", "take a snapshot of the store and event log databases", cmdDBBackup},
+	{"db backups", "list the database snapshots", cmdDBBackups},
+	{"db restore", "restore a database snapshot", cmdDBRestore},
+	{"db migrate", "run the pending schema migrations of the store database", cmdDBMigrate},
+	{"users create", "create a user that can log in to the API", cmdUsersCreate},
+	{"drones list", "list the drones", cmdDronesList},
+	{"logs tail", "print the last battery level event logs", cmdLogsTail},
+}
+
+// errUsage the arguments are wrong, the usage has already been printed
+var errUsage = errors.New("usage")
+
+// cliEnv the standard streams and the services of the commands. The commands reuse the repositories
+// and the configuration of the server, they don't need a running server nor an access token
+type cliEnv struct {
+	configPath string
+	stdin      io.Reader
+	stdout     io.Writer
+	stderr     io.Writer
+
+	config *utils.SvcConfig
+	logger *utils.SvcLogger
+}
+
+// endregion =============================================================================
+
+// runCLI run the command of the arguments and return the exit code
+//
+// - args [[]string] ~ Arguments without the program name
+//
+// - stdin, stdout, stderr [io.Reader, io.Writer] ~ Standard streams of the command
+func runCLI(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	env := &cliEnv{stdin: stdin, stdout: stdout, stderr: stderr}
+
+	global := env.flagSet("drones.restapi")
+	global.Usage = func() { env.usage() }
+	if err := parseFlags(global, args); err != nil {
+		return exitCode(err)
+	}
+	args = global.Args()
+
+	cmd, rest := findCommand(args)
+	if cmd == nil {
+		if len(args) == 0 {
+			return exitCode(cmdServe(env, nil))
+		}
+		fmt.Fprintf(stderr, "unknown command '%s'\n\n", strings.Join(args, " "))
+		env.usage()
+		return 2
+	}
+
+	if err := cmd.run(env, rest); err != nil {
+		if err != errUsage && err != flag.ErrHelp {
+			fmt.Fprintf(stderr, "%s: %s\n", cmd.name, err)
+		}
+		return exitCode(err)
+	}
+	return 0
+}
+
+// region ======== COMMANDS ==============================================================
+
+func cmdServe(env *cliEnv, args []string) error {
+	fs := env.flagSet("serve")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	return serve(env.configPath)
+}
+
+func cmdDBPopulate(env *cliEnv, args []string) error {
+	return seedCommand(env, "db populate", args, false)
+}
+
+func cmdDBReset(env *cliEnv, args []string) error {
+	return seedCommand(env, "db reset", args, true)
+}
+
+func cmdDBExport(env *cliEnv, args []string) error {
+	fs := env.flagSet("db export")
+	out := fs.String("out", "-", "file to write the dataset, - for the standard output")
+	passphrases := fs.Bool("passphrases", false, "include the passphrases (SHA256 of the passwords) of the users")
+	if err := env.parse(fs, args); err != nil {
+		return err
+	}
+
+	ctx := env.context()
+	if err := env.migrate(ctx); err != nil {
+		return err
+	}
+	dataset, problem := env.backup().ExportSvc(ctx, *passphrases)
+	if problem != nil {
+		return problemErr(problem)
+	}
+	data, err := json.MarshalIndent(dataset, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if *out == "-" {
+		_, err = env.stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(*out, data, 0o600); err != nil {
+		return err
+	}
+	fmt.Fprintf(env.stdout, "%d users, %d drones, %d medications and %d event logs exported to %s\n", len(dataset.Users), len(dataset.Drones), len(dataset.Medications), len(dataset.Logs), *out)
+	return nil
+}
+
+func cmdDBImport(env *cliEnv, args []string) error {
+	fs := env.flagSet("db import")
+	in := fs.String("in", "-", "file to read the dataset, - for the standard input")
+	replace := fs.Bool("replace", false, "overwrite a populated database")
+	if err := env.parse(fs, args); err != nil {
+		return err
+	}
+
+	var data []byte
+	var err error
+	if *in == "-" {
+		data, err = io.ReadAll(env.stdin)
+	} else {
+		data, err = os.ReadFile(*in)
+	}
+	if err != nil {
+		return err
+	}
+	dataset := dto.Dataset{}
+	if err := json.Unmarshal(data, &dataset); err != nil {
+		return fmt.Errorf("invalid dataset: %w", err)
+	}
+
+	ctx := env.context()
+	if problem := env.backup().ImportSvc(ctx, &dataset, *replace); problem != nil {
+		return problemErr(problem)
+	}
+	summary := map[string]int{"users": len(dataset.Users), "drones": len(dataset.Drones), "medications": len(dataset.Medications), "logs": len(dataset.Logs)}
+	env.audit(ctx, dto.AuditActionImportDB, "database", summary)
+	fmt.Fprintf(env.stdout, "%d users, %d drones, %d medications and %d event logs imported\n", len(dataset.Users), len(dataset.Drones), len(dataset.Medications), len(dataset.Logs))
+	return nil
+}
+
+func cmdDBBackup(env *cliEnv, args []string) error {
+	fs := env.flagSet("db backup")
+	if err := env.parse(fs, args); err != nil {
+		return err
+	}
+
+	ctx := env.context()
+	snapshot, problem := env.backup().CreateSnapshotSvc(ctx)
+	if problem != nil {
+		return problemErr(problem)
+	}
+	env.audit(ctx, dto.AuditActionBackupDB, snapshot.ID, snapshot)
+	fmt.Fprintf(env.stdout, "snapshot %s created in %s\n", snapshot.ID, filepath.Join(env.config.BackupDir, snapshot.ID))
+	return nil
+}
+
+func cmdDBBackups(env *cliEnv, args []string) error {
+	fs := env.flagSet("db backups")
+	asJSON := fs.Bool("json", false, "print JSON instead of a table")
+	if err := env.parse(fs, args); err != nil {
+		return err
+	}
+
+	list, problem := env.backup().ListSnapshotsSvc(env.context())
+	if problem != nil {
+		return problemErr(problem)
+	}
+	if *asJSON {
+		return json.NewEncoder(env.stdout).Encode(list)
+	}
+
+	w := tabwriter.NewWriter(env.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tCREATED\tFILES\tSIZE")
+	for _, s := range *list {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", s.ID, s.Created, strings.Join(s.Files, ","), s.Size)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(env.stdout, "%d snapshots\n", len(*list))
+	return nil
+}
+
+func cmdDBRestore(env *cliEnv, args []string) error {
+	fs := env.flagSet("db restore")
+	id := fs.String("id", "", "ID of the snapshot, required (see 'db backups')")
+	if err := env.parse(fs, args); err != nil {
+		return err
+	}
+	if *id == "" {
+		return errors.New("the -id flag is required")
+	}
+
+	ctx := env.context()
+	previous, problem := env.backup().RestoreSnapshotSvc(ctx, *id)
+	if problem != nil {
+		return problemErr(problem)
+	}
+	env.audit(ctx, dto.AuditActionRestoreDB, *id, nil)
+	fmt.Fprintf(env.stdout, "snapshot %s restored, the previous data was saved as snapshot %s\n", *id, previous.ID)
+	return nil
+}
+
+func cmdDBMigrate(env *cliEnv, args []string) error {
+	fs := env.flagSet("db migrate")
+	dryRun := fs.Bool("dry-run", false, "report what would change without writing it")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	if err := env.parse(fs, args); err != nil {
+		return err
+	}
+
+	ctx := env.context()
+	repo := db.NewRepoDrones(env.config, env.logger)
+	report, err := repo.Migrate(ctx, *dryRun)
+	if err != nil {
+		return err
+	}
+	if !*dryRun && len(report.Migrations) > 0 {
+		env.audit(ctx, dto.AuditActionMigrateDB, "database", report)
+	}
+	if *asJSON {
+		return json.NewEncoder(env.stdout).Encode(report)
+	}
+
+	for _, m := range report.Migrations {
+		fmt.Fprintf(env.stdout, "migration %d: %s\n", m.Version, m.Name)
+		for _, change := range m.Changes {
+			fmt.Fprintf(env.stdout, "  %s\n", change)
+		}
+	}
+	switch {
+	case len(report.Migrations) == 0:
+		fmt.Fprintf(env.stdout, "the store database is up to date (schema version %d)\n", report.ToVersion)
+	case *dryRun:
+		fmt.Fprintf(env.stdout, "dry run, the schema version would go from %d to %d\n", report.FromVersion, report.ToVersion)
+	default:
+		fmt.Fprintf(env.stdout, "schema version migrated from %d to %d\n", report.FromVersion, report.ToVersion)
+	}
+	return nil
+}
+
+func cmdUsersCreate(env *cliEnv, args []string) error {
+	fs := env.flagSet("users create")
+	username := fs.String("username", "", "username (e-mail) of the user, required")
+	name := fs.String("name", "", "full name of the user")
+	password := fs.String("password", "", "password of the user, read from the standard input if omitted")
+	admin := fs.Bool("admin", false, "allow the user in the /admin endpoints")
+	if err := env.parse(fs, args); err != nil {
+		return err
+	}
+	if *username == "" {
+		return errors.New("the -username flag is required")
+	}
+	if *password == "" {
+		line, err := bufio.NewReader(env.stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		*password = strings.TrimRight(line, "\r\n")
+	}
+	if *password == "" {
+		return errors.New("the password can't be empty")
+	}
+
+	passphrase, err := lib.Checksum(lib.SHA256, []byte(*password))
+	if err != nil {
+		return err
+	}
+	user := dto.User{Username: *username, Name: *name, Passphrase: passphrase, Admin: *admin}
+
+	ctx := env.context()
+	if err := env.migrate(ctx); err != nil {
+		return err
+	}
+	repo := db.NewRepoDrones(env.config, env.logger)
+	if err := repo.CreateUser(ctx, &user); err != nil {
+		return err
+	}
+	user.Passphrase = "" // never recorded in the audit trail
+	env.audit(ctx, dto.AuditActionCreateUser, user.Username, user)
+	fmt.Fprintf(env.stdout, "user '%s' created\n", user.Username)
+	return nil
+}
+
+func cmdDronesList(env *cliEnv, args []string) error {
+	fs := env.flagSet("drones list")
+	states := fs.String("state", "", "comma separated states, by name (IDLE) or number (0)")
+	retired := fs.Bool("retired", false, "list the retired drones instead of the ones in service")
+	limit := fs.Int("limit", 0, "maximum number of drones, 0 for all")
+	asJSON := fs.Bool("json", false, "print JSON instead of a table")
+	if err := env.parse(fs, args); err != nil {
+		return err
+	}
+
+	filter := dto.DroneFilter{Retired: *retired, Limit: *limit, SortBy: dto.DroneSortSerialNumber}
+	for _, s := range splitList(*states) {
+		state, err := parseDroneState(s)
+		if err != nil {
+			return err
+		}
+		filter.States = append(filter.States, state)
+	}
+
+	repo := db.NewRepoDrones(env.config, env.logger)
+	page, err := repo.GetDrones(env.context(), &filter)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return json.NewEncoder(env.stdout).Encode(page.Items)
+	}
+
+	w := tabwriter.NewWriter(env.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SERIAL NUMBER\tMODEL\tSTATE\tBATTERY\tWEIGHT LIMIT\tVERSION")
+	for _, d := range page.Items {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%.2f%%\t%.0fgr\t%d\n", d.SerialNumber, d.Model, d.State, d.BatteryCapacity, d.WeightLimit, d.Version)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(env.stdout, "%d drones\n", page.Total)
+	return nil
+}
+
+func cmdLogsTail(env *cliEnv, args []string) error {
+	fs := env.flagSet("logs tail")
+	n := fs.Int("n", 10, "number of event logs")
+	follow := fs.Bool("follow", false, "keep printing the new event logs until interrupted")
+	interval := fs.Duration("interval", 5*time.Second, "how often the new event logs are checked with -follow")
+	asJSON := fs.Bool("json", false, "print one JSON event log per line")
+	if err := env.parse(fs, args); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(env.context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	repo := db.NewRepoEventLog(env.config, env.logger)
+	last := ""
+	for {
+		logs, err := repo.TailEventLogs(ctx, *n)
+		if err != nil {
+			return err
+		}
+		// the newest come first, they are printed in chronological order
+		for i := len(*logs) - 1; i >= 0; i-- {
+			event := (*logs)[i]
+			if event.Created <= last {
+				continue
+			}
+			last = event.Created
+			if err := printLogEvent(env.stdout, event, *asJSON); err != nil {
+				return err
+			}
+		}
+
+		if !*follow {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(*interval):
+		}
+	}
+}
+
+// endregion =============================================================================
+
+// region ======== PRIVATE AUX ===========================================================
+
+// flagSet create the flags of a command, -config is accepted by every command
+func (e *cliEnv) flagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.StringVar(&e.configPath, "config", e.configPath, "path to the config YAML file (default: $SERVER_CONFIG or ./conf/conf.yaml)")
+	return fs
+}
+
+// parse parse the flags of the command and load the configuration
+func (e *cliEnv) parse(fs *flag.FlagSet, args []string) error {
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(e.stderr, "unexpected arguments: %s\n", strings.Join(fs.Args(), " "))
+		fs.Usage()
+		return errUsage
+	}
+
+	config, err := utils.NewSvcConfig(e.configPath)
+	if err != nil {
+		return err
+	}
+	e.config = config
+	// the standard output is kept for the result of the command
+	e.logger = utils.NewSvcLogger(config)
+	e.logger.SetOutput(e.stderr)
+	return nil
+}
+
+// context the commands are traced and logged like a request, with their own ID
+func (e *cliEnv) context() context.Context {
+	return lib.WithRequestID(context.Background(), "cli-"+lib.GenerateUUIDStr())
+}
+
+// migrate run the pending migrations of the store database before a command reads or writes the users,
+// like the server does at startup
+func (e *cliEnv) migrate(ctx context.Context) error {
+	repo := db.NewRepoDrones(e.config, e.logger)
+	_, err := repo.Migrate(ctx, false)
+	return err
+}
+
+// backup the backup service, shared by the export, import, backup and restore commands
+func (e *cliEnv) backup() backup.ISvcBackup {
+	repoDrones := db.NewRepoDrones(e.config, e.logger)
+	repoEventLog := db.NewRepoEventLog(e.config, e.logger)
+	return backup.NewSvcBackupReqs(e.config, &repoDrones, &repoEventLog, e.logger)
+}
+
+// seedCommand populate or, with reset, wipe and populate the database
+func seedCommand(env *cliEnv, name string, args []string, reset bool) error {
+	fs := env.flagSet(name)
+	fixture := fs.String("file", "", "YAML or JSON fixture file (default: the SeedFile setting)")
+	seedValue := fs.Int64("seed", 0, "seed of the generated data, the same seed generates the same data (default: the Seed setting)")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	if err := env.parse(fs, args); err != nil {
+		return err
+	}
+
+	ctx := env.context()
+	if err := env.migrate(ctx); err != nil {
+		return err
+	}
+	// the fixtures are validated like the requests of the API
+	lib.InitValidator()
+	repoDrones := db.NewRepoDrones(env.config, env.logger)
+	repoEventLog := db.NewRepoEventLog(env.config, env.logger)
+	svc := seed.NewSvcSeedReqs(env.config, &repoDrones, &repoEventLog, env.logger)
+
+	options := dto.SeedOptions{Fixture: *fixture, Seed: *seedValue}
+	action, run := dto.AuditActionPopulateDB, svc.PopulateSvc
+	if reset {
+		action, run = dto.AuditActionResetDB, svc.ResetSvc
+	}
+	report, problem := run(ctx, &options)
+	if problem != nil {
+		return problemErr(problem)
+	}
+	env.audit(ctx, action, "database", report)
+
+	if *asJSON {
+		return json.NewEncoder(env.stdout).Encode(report)
+	}
+	source := "generated data, seed " + strconv.FormatInt(report.Seed, 10)
+	if report.Source == dto.SeedSourceFixture {
+		source = "fixture " + report.Fixture
+	}
+	fmt.Fprintf(env.stdout, "database seeded with %d users, %d drones and %d medications from %s\n", report.Users, report.Drones, report.Medications, source)
+	return nil
+}
+
+// audit record a state-changing command in the audit trail, the failure is logged by the service
+func (e *cliEnv) audit(ctx context.Context, action, target string, after interface{}) {
+	repoAudit := db.NewRepoAudit(e.config, e.logger)
+	svcAudit := service.NewSvcAuditReqs(&repoAudit, e.logger)
+	_ = svcAudit.RecordSvc(ctx, dto.InjectedParam{Username: dto.AuditCLIActor}, action, target, nil, after)
+}
+
+func (e *cliEnv) usage() {
+	fmt.Fprintln(e.stderr, "Usage: drones.restapi [-config FILE] [command] [flags]")
+	fmt.Fprintln(e.stderr, "\nCommands:")
+	w := tabwriter.NewWriter(e.stderr, 0, 0, 2, ' ', 0)
+	for _, c := range cliCommands {
+		fmt.Fprintf(w, "  %s\t%s\n", c.name, c.desc)
+	}
+	_ = w.Flush()
+	fmt.Fprintln(e.stderr, "\nRun 'drones.restapi <command> -h' for the flags of a command.")
+}
+
+// findCommand the command with the longest name matching the first arguments, and the rest of them
+func findCommand(args []string) (*cliCommand, []string) {
+	commands := make([]cliCommand, len(cliCommands))
+	copy(commands, cliCommands)
+	sort.SliceStable(commands, func(i, j int) bool { return len(commands[i].name) > len(commands[j].name) })
+
+	for i := range commands {
+		words := strings.Fields(commands[i].name)
+		if len(args) >= len(words) && strings.Join(args[:len(words)], " ") == commands[i].name {
+			return &commands[i], args[len(words):]
+		}
+	}
+	return nil, args
+}
+
+// parseFlags parse the flags, the errors have already been printed with the usage by the flag package
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil && err != flag.ErrHelp {
+		return errUsage
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// problemErr the problem of a service as the error of a command
+func problemErr(problem *dto.Problem) error {
+	return fmt.Errorf("%s (%s)", i18n.Message(i18n.DefaultLanguage, problem.Detail, problem.DetailArgs...), problem.Code)
+}
+
+func exitCode(err error) int {
+	switch err {
+	case nil, flag.ErrHelp:
+		return 0
+	case errUsage:
+		return 2
+	}
+	return 1
+}
+
+func parseDroneState(s string) (dto.DroneState, error) {
+	if n, err := strconv.ParseUint(s, 10, 32); err == nil && dto.DroneState(n).String() != "unknown" {
+		return dto.DroneState(n), nil
+	}
+	for state := dto.IDLE; state <= dto.RETURNING; state++ {
+		if strings.EqualFold(state.String(), s) {
+			return state, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown drone state '%s'", s)
+}
+
+func splitList(s string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func printLogEvent(w io.Writer, event dto.LogEvent, asJSON bool) error {
+	if asJSON {
+		return json.NewEncoder(w).Encode(event)
+	}
+	levels := make([]string, 0, len(event.DronesBatteryLevels))
+	for _, l := range event.DronesBatteryLevels {
+		levels = append(levels, fmt.Sprintf("%s=%.2f%%", l.SerialNumber, l.BatteryCapacity))
+	}
+	_, err := fmt.Fprintf(w, "%s  %s\n", event.Created, strings.Join(levels, " "))
+	return err
+}
+
+// endregion =============================================================================
@@ -7,17 +7,29 @@ require (
 	github.com/alecthomas/template v0.0.0-20190718012654-fb15b899a751
 	github.com/asaskevich/govalidator v0.0.0-20210307081110-f21760c49a8d
 	github.com/brianvoe/gofakeit/v6 v6.18.0
+	github.com/ghodss/yaml v1.0.0
 	github.com/go-co-op/gocron v1.17.0
 	github.com/go-openapi/spec v0.20.3 // indirect
 	github.com/go-playground/validator/v10 v10.4.1
+	github.com/graphql-go/graphql v0.8.1
+	github.com/iris-contrib/httpexpect/v2 v2.0.5
 	github.com/iris-contrib/swagger/v12 v12.2.0-alpha
 	github.com/json-iterator/go v1.1.12
+	github.com/kataras/golog v0.1.7
 	github.com/kataras/iris/v12 v12.2.0-alpha2.0.20210304161013-7272c76847eb
 	github.com/lib/pq v1.10.0
+	github.com/prometheus/client_golang v1.11.1
 	github.com/swaggo/swag v1.7.0
 	github.com/tidwall/buntdb v1.2.8
-	github.com/tkanos/gonfig v0.0.0-20210106201359-53e13348de2f
+	github.com/vmihailenco/msgpack/v5 v5.2.0
+	go.opentelemetry.io/otel v1.3.0
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp v1.3.0
+	go.opentelemetry.io/otel/exporters/stdout/stdouttrace v1.3.0
+	go.opentelemetry.io/otel/sdk v1.3.0
+	go.opentelemetry.io/otel/trace v1.3.0
 	golang.org/x/text v0.3.5
-	google.golang.org/protobuf v1.25.0
+	google.golang.org/genproto v0.0.0-20200526211855-cb27e3aa2013
+	google.golang.org/grpc v1.42.0
+	google.golang.org/protobuf v1.27.1
 	gopkg.in/alexcesaro/quotedprintable.v3 v3.0.0-20150716171945-2caba252f4dc // indirect
 )
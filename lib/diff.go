@@ -0,0 +1,79 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+
+	"github.com/kmilodenisglez/drones.restapi/schema/dto"
+)
+
+// MarshalSnapshot marshal a state snapshot to be stored in an audit entry. A nil value
+// returns a nil snapshot, so the field is omitted
+//
+// - v [interface] ~ "Object" to be marshalled
+func MarshalSnapshot(v interface{}) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// DiffSnapshots compares two JSON snapshots and returns the top-level fields that changed.
+// If any of the snapshots is not a JSON object, the whole value is reported using "$" as field
+//
+// - before [json.RawMessage] ~ Snapshot before the operation
+//
+// - after [json.RawMessage] ~ Snapshot after the operation
+func DiffSnapshots(before, after json.RawMessage) []dto.AuditChange {
+	changes := make([]dto.AuditChange, 0)
+
+	beforeMap, okBefore := toJSONObject(before)
+	afterMap, okAfter := toJSONObject(after)
+	if !okBefore || !okAfter {
+		if !bytes.Equal(before, after) {
+			changes = append(changes, dto.AuditChange{Field: "$", Before: before, After: after})
+		}
+		return changes
+	}
+
+	fields := make(map[string]bool)
+	for k := range beforeMap {
+		fields[k] = true
+	}
+	for k := range afterMap {
+		fields[k] = true
+	}
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if !bytes.Equal(beforeMap[k], afterMap[k]) {
+			changes = append(changes, dto.AuditChange{Field: k, Before: beforeMap[k], After: afterMap[k]})
+		}
+	}
+	return changes
+}
+
+// toJSONObject decode a snapshot as a JSON object, an empty snapshot is an empty object
+func toJSONObject(data json.RawMessage) (map[string]json.RawMessage, bool) {
+	obj := make(map[string]json.RawMessage)
+	if len(data) == 0 {
+		return obj, true
+	}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, false
+	}
+	return obj, true
+}
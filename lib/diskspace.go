@@ -0,0 +1,15 @@
+//go:build !windows
+// +build !windows
+
+package lib
+
+import "syscall"
+
+// FreeDiskSpace return the bytes available to the process in the filesystem that contains the path
+func FreeDiskSpace(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return stat.Bavail * uint64(stat.Bsize), nil //nolint:unconvert
+}
@@ -0,0 +1,11 @@
+//go:build windows
+// +build windows
+
+package lib
+
+import "github.com/kmilodenisglez/drones.restapi/schema"
+
+// FreeDiskSpace the free disk space is not checked on Windows, it always returns schema.ErrDiskSpaceUnsupported
+func FreeDiskSpace(path string) (uint64, error) {
+	return 0, schema.ErrDiskSpaceUnsupported
+}
@@ -0,0 +1,26 @@
+package lib
+
+import "context"
+
+// requestIDKey unexported type of the context key, so it can't collide with other packages
+type requestIDKey struct{}
+
+// WithRequestID return a copy of the context that carries the request ID
+//
+// - ctx [context.Context] ~ Parent context
+//
+// - requestID [string] ~ ID of the request
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext return the request ID carried by the context, or an empty string
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if requestID, ok := ctx.Value(requestIDKey{}).(string); ok {
+		return requestID
+	}
+	return ""
+}
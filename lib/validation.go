@@ -1,9 +1,11 @@
 package lib
 
 import (
-	"fmt"
+	"net/http"
+	"strings"
 
 	"github.com/asaskevich/govalidator"
+	"github.com/kmilodenisglez/drones.restapi/schema"
 	"github.com/kmilodenisglez/drones.restapi/schema/dto"
 	reg "regexp"
 )
@@ -16,7 +18,6 @@ func ValidateString(data string, regexp string) bool {
 // ValidateStringCollection validate a string collection given a regular expression
 func ValidateStringCollection(data []interface{}, regexp string) bool {
 	var fn govalidator.ConditionIterator = func(value interface{}, index int) bool {
-		fmt.Println(value.(string))
 		return reg.MustCompile(regexp).MatchString(value.(string))
 	}
 	return govalidator.ValidateArray(data, fn)
@@ -43,6 +44,57 @@ func InitValidator() {
 	}
 }
 
+// ValidationProblem the problem of a failed govalidator.ValidateStruct, with an entry per invalid field
+// instead of the concatenated message
+func ValidationProblem(err error) *dto.Problem {
+	fieldErrors := FieldErrors(err)
+	problem := dto.NewProblemf(http.StatusBadRequest, schema.ErrValidationField, schema.DetInvalidFields, len(fieldErrors))
+	problem.Errors = fieldErrors
+	return problem
+}
+
+// FieldErrors the invalid fields of a govalidator error, named after their JSON field
+func FieldErrors(err error) []dto.FieldError {
+	fieldErrors := make([]dto.FieldError, 0)
+	switch e := err.(type) {
+	case govalidator.Errors:
+		for _, inner := range e {
+			fieldErrors = append(fieldErrors, FieldErrors(inner)...)
+		}
+	case govalidator.Error:
+		field := e.Name
+		if len(e.Path) > 0 {
+			field = strings.Join(append(e.Path, e.Name), ".")
+		}
+		fieldErrors = append(fieldErrors, dto.FieldError{Field: field, Message: e.Err.Error(), Rule: e.Validator, Args: ruleArgs(e)})
+	case nil:
+	default:
+		fieldErrors = append(fieldErrors, dto.FieldError{Message: e.Error()})
+	}
+	return fieldErrors
+}
+
+// ruleArgs the invalid value followed by the parameters of the rule, parsed from the default govalidator
+// message "<value> does not validate as <rule>(<param>|<param>)". The custom messages have none
+func ruleArgs(e govalidator.Error) []interface{} {
+	if e.CustomErrorMessageExists {
+		return nil
+	}
+	matches := regexpRuleMessage.FindStringSubmatch(e.Err.Error())
+	if matches == nil {
+		return nil
+	}
+	args := []interface{}{matches[1]}
+	if matches[2] != "" {
+		for _, param := range strings.Split(matches[2], "|") {
+			args = append(args, param)
+		}
+	}
+	return args
+}
+
+var regexpRuleMessage = reg.MustCompile(`^(.*) does not validate as \w+(?:\((.*)\))?$`)
+
 func ValidateSerialNumberDrone(serialNumber string) bool {
 	return govalidator.MaxStringLength(serialNumber, dto.MaxSerialNumberLength)
 }
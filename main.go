@@ -1,23 +1,45 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/iris-contrib/swagger/v12"              // swagger middleware for Iris
 	"github.com/iris-contrib/swagger/v12/swaggerFiles" // swagger embed files
 	"github.com/kataras/iris/v12"
-	"github.com/kataras/iris/v12/middleware/logger"
 	"github.com/kmilodenisglez/drones.restapi/api/endpoints"
 	"github.com/kmilodenisglez/drones.restapi/api/middlewares"
+	"github.com/kmilodenisglez/drones.restapi/api/rpc"
 	"github.com/kmilodenisglez/drones.restapi/docs"
 	"github.com/kmilodenisglez/drones.restapi/lib"
+	"github.com/kmilodenisglez/drones.restapi/repo/db"
 	"github.com/kmilodenisglez/drones.restapi/service/cron"
+	"github.com/kmilodenisglez/drones.restapi/service/i18n"
+	"github.com/kmilodenisglez/drones.restapi/service/metrics"
+	"github.com/kmilodenisglez/drones.restapi/service/tracing"
 	"github.com/kmilodenisglez/drones.restapi/service/utils"
-	_ "github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
 )
 
-func newApp() (*iris.Application, *utils.SvcConfig) {
+// appServices the services created by newApp that are also needed by main
+type appServices struct {
+	config          *utils.SvcConfig
+	logger          *utils.SvcLogger
+	cronJob         cron.ISvcEventLog
+	grpcServer      *grpc.Server
+	shutdownTracing tracing.ShutdownFunc
+}
+
+// newApp create the Iris app and its services, the configuration errors are returned
+//
+// - configPath [string] ~ Path to the config YAML file, empty to use SERVER_CONFIG or ./conf/conf.yaml
+func newApp(configPath string) (*iris.Application, *appServices, error) {
 	docs.SwaggerInfo.BasePath = "/api/v1"
 
 	// region ======== GLOBALS ===============================================================
@@ -25,10 +47,31 @@ func newApp() (*iris.Application, *utils.SvcConfig) {
 
 	app := iris.New() // App instance
 	app.Validator = v // Register validation on the iris app
+	// Register the message catalogues, the locale of a request is picked from Accept-Language
+	app.I18n = i18n.Catalogue()
 
 	// Services
-	svcConfig := utils.NewSvcConfig()              // Creating Configuration Service
+	svcConfig, err := utils.NewSvcConfig(configPath) // Creating Configuration Service
+	if err != nil {
+		return nil, nil, err
+	}
 	svcResponse := utils.NewSvcResponse(svcConfig) // Creating Response Service
+	svcLogger := utils.NewSvcLogger(svcConfig)     // Creating Logger Service
+	svcLogger.Configure(app.Logger(), svcConfig)   // the Iris logger shares the level and format
+
+	shutdownTracing, err := tracing.NewTracerProvider(svcConfig) // Creating the OpenTelemetry tracer provider
+	if err != nil {
+		return nil, nil, err
+	}
+	// endregion =============================================================================
+
+	// region ======== STORE MIGRATIONS ======================================================
+	// the pending schema migrations run before any request is served, the server doesn't start if they fail
+	repoDrones := db.NewRepoDrones(svcConfig, svcLogger)
+	migrationCtx := lib.WithRequestID(context.Background(), "startup-"+lib.GenerateUUIDStr())
+	if _, err := repoDrones.Migrate(migrationCtx, false); err != nil {
+		return nil, nil, fmt.Errorf("the store database could not be migrated: %w", err)
+	}
 	// endregion =============================================================================
 
 	// region ======== MIDDLEWARES ===========================================================
@@ -36,13 +79,14 @@ func newApp() (*iris.Application, *utils.SvcConfig) {
 	crs := func(ctx iris.Context) {
 		ctx.Header("Access-Control-Allow-Origin", "*")
 		ctx.Header("Access-Control-Allow-Credentials", "true")
+		ctx.Header("Access-Control-Expose-Headers", "ETag,X-Total-Count,X-Next-Cursor,X-Request-Id")
 
 		if ctx.Method() == iris.MethodOptions {
 			ctx.Header("Access-Control-Methods",
 				"POST, PUT, PATCH, DELETE")
 
 			ctx.Header("Access-Control-Allow-Headers",
-				"Access-Control-Allow-Origin,Content-Type,authorization")
+				"Access-Control-Allow-Origin,Content-Type,authorization,If-Match,X-Request-Id")
 
 			ctx.Header("Access-Control-Max-Age",
 				"86400")
@@ -58,19 +102,41 @@ func newApp() (*iris.Application, *utils.SvcConfig) {
 	lib.InitValidator()
 
 	// built-ins
-	app.Use(logger.New())
+	app.UseRouter(middlewares.NewRequestIDMiddleware())
+	app.UseRouter(tracing.NewHTTPMiddleware())
+	app.UseRouter(middlewares.NewAccessLogMiddleware(svcLogger))
 	app.UseRouter(crs) // Recovery middleware recovers from any panics and writes a 500 if there was one.
+	app.UseRouter(metrics.NewHTTPMiddleware())
 
 	// custom middleware
-	mdwAuthChecker := middlewares.NewAuthCheckerMiddleware([]byte(svcConfig.JWTSignKey))
+	verifier := middlewares.NewAuthVerifier([]byte(svcConfig.JWTSignKey)) // shared by the REST and the gRPC APIs
+	mdwAuthChecker := middlewares.NewAuthCheckerMiddleware(verifier, svcResponse)
 
 	// endregion =============================================================================
 
 	// region ======== ENDPOINT REGISTRATIONS ================================================
 
-	endpoints.NewAuthHandler(app, &mdwAuthChecker, svcResponse, svcConfig)
-	endpoints.NewDronesHandler(app, &mdwAuthChecker, svcResponse, svcConfig)   // Drones request handlers
-	endpoints.NewEventLogHandler(app, &mdwAuthChecker, svcResponse, svcConfig) // EventLog request handlers
+	endpoints.NewAuthHandler(app, &mdwAuthChecker, svcResponse, svcConfig, svcLogger)
+	endpoints.NewDronesHandler(app, &mdwAuthChecker, svcResponse, svcConfig, svcLogger)   // Drones request handlers
+	endpoints.NewEventLogHandler(app, &mdwAuthChecker, svcResponse, svcConfig, svcLogger) // EventLog request handlers
+	endpoints.NewAuditHandler(app, &mdwAuthChecker, svcResponse, svcConfig, svcLogger)    // Audit trail request handlers
+	endpoints.NewBackupHandler(app, &mdwAuthChecker, svcResponse, svcConfig, svcLogger)   // Backups, export and import request handlers
+	endpoints.NewSeedHandler(app, &mdwAuthChecker, svcResponse, svcConfig, svcLogger)     // Populate and reset request handlers
+	// GraphQL queries and mutations
+	if _, err := endpoints.NewGraphQLHandler(app, &mdwAuthChecker, svcResponse, svcConfig, svcLogger); err != nil {
+		return nil, nil, fmt.Errorf("the GraphQL schema could not be built: %w", err)
+	}
+
+	cronJob := cron.NewSvcRepoEventLog(svcConfig, svcLogger)                     // started by main, its scheduler is checked by /readyz
+	endpoints.NewHealthHandler(app, svcResponse, svcConfig, svcLogger, &cronJob) // Liveness and readiness probes
+	// endregion =============================================================================
+
+	// region ======== gRPC SERVICES =========================================================
+	grpcServer := rpc.NewServer(verifier, svcConfig, svcLogger) // served by main on GrpcPort
+	// endregion =============================================================================
+
+	// region ======== METRICS REGISTRATION ==================================================
+	app.Get("/metrics", iris.FromStd(promhttp.HandlerFor(metrics.NewRegistry(repoDrones), promhttp.HandlerOpts{})))
 	// endregion =============================================================================
 
 	// region ======== SWAGGER REGISTRATION ==================================================
@@ -78,7 +144,7 @@ func newApp() (*iris.Application, *utils.SvcConfig) {
 	app.Get("/swagger/{any:path}", swagger.WrapHandler(swaggerFiles.Handler))
 	// endregion =============================================================================
 
-	return app, svcConfig
+	return app, &appServices{config: svcConfig, logger: svcLogger, cronJob: cronJob, grpcServer: grpcServer, shutdownTracing: shutdownTracing}, nil
 }
 
 // @title drones
@@ -95,15 +161,60 @@ func newApp() (*iris.Application, *utils.SvcConfig) {
 
 // @BasePath /
 func main() {
-	app, svcConfig := newApp()
+	os.Exit(runCLI(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// serve run the REST API server, and the gRPC server if GrpcPort is set, until SIGINT or SIGTERM
+//
+// - configPath [string] ~ Path to the config YAML file, empty to use SERVER_CONFIG or ./conf/conf.yaml
+func serve(configPath string) error {
+	app, svc, err := newApp(configPath)
+	if err != nil {
+		return err
+	}
 
 	// region ======== Cron Job ==================================================
-	cronJob := cron.NewSvcRepoEventLog(svcConfig)
-	_ = cronJob.MeinerCronJob()
+	_ = svc.cronJob.MeinerCronJob()
 	// endregion =============================================================================
 
-	addr := fmt.Sprintf(":%s", svcConfig.DappPort)
+	// region ======== Config hot reload ==================================================
+	watchConfig(app, svc)
+	// endregion =============================================================================
 
-	app.Run(iris.Addr(addr))
-}
+	// region ======== Graceful shutdown ==================================================
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+		sig := <-quit
+		gracefulShutdown(app, svc, sig)
+	}()
+	// endregion =============================================================================
 
+	// region ======== gRPC server ==================================================
+	if svc.config.GrpcPort != "" {
+		lis, err := net.Listen("tcp", fmt.Sprintf(":%s", svc.config.GrpcPort))
+		if err != nil {
+			svc.logger.Errorf(context.Background(), "the gRPC server could not be started: %s", err)
+			return err
+		}
+		go func() {
+			if err := svc.grpcServer.Serve(lis); err != nil {
+				svc.logger.Errorf(context.Background(), "the gRPC server stopped: %s", err)
+			}
+		}()
+	}
+	// endregion =============================================================================
+
+	addr := fmt.Sprintf(":%s", svc.config.DappPort)
+
+	// the interrupt handler of Iris is replaced by gracefulShutdown
+	err = app.Run(iris.Addr(addr), iris.WithoutInterruptHandler, iris.WithoutServerError(iris.ErrServerClosed))
+	if err != nil {
+		svc.logger.Errorf(context.Background(), "the server could not be started: %s", err)
+		return err
+	}
+	<-shutdownDone
+	return nil
+}
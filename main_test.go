@@ -1,7 +1,11 @@
 package main
 
 import (
+	"context"
+	"bytes"
 	"encoding/base64"
+	"encoding/json"
+	"path/filepath"
 
 	"github.com/kmilodenisglez/drones.restapi/repo/db"
 
@@ -10,35 +14,106 @@ import (
 	"github.com/kmilodenisglez/drones.restapi/lib"
 	"github.com/kmilodenisglez/drones.restapi/schema"
 	"github.com/kmilodenisglez/drones.restapi/schema/dto"
+	"github.com/kmilodenisglez/drones.restapi/schema/pb"
+	"github.com/kmilodenisglez/drones.restapi/service/i18n"
+	"github.com/kmilodenisglez/drones.restapi/service/utils"
 
 	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 	"testing"
 
+	"github.com/iris-contrib/httpexpect/v2"
 	"github.com/kataras/iris/v12/httptest"
+	"github.com/tidwall/buntdb"
+	"github.com/vmihailenco/msgpack/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/proto"
 )
 
+// problemJSON the media type of the problem responses
+var problemJSON = httpexpect.ContentOpts{MediaType: "application/problem+json"}
+
 func TestNewApp(t *testing.T) {
 	// set environment variable
 	_ = os.Setenv(schema.EnvConfigPath, "./conf/conf.yaml")
-	app, config := newApp()
+	_ = os.Setenv("SERVER_BACKUP_DIR", t.TempDir())
+	defer os.Unsetenv("SERVER_BACKUP_DIR")
+	app, svc, err := newApp("")
+	if err != nil {
+		t.Fatalf("error creating the app: %s", err)
+	}
 	e := httptest.New(t, app)
 
-	repo := db.NewRepoDrones(config)
+	repo := db.NewRepoDrones(svc.config, svc.logger)
 
-	isPopulated := repo.IsPopulated()
+	isPopulated := repo.IsPopulated(context.Background())
 	if !isPopulated {
-		// populate database
-		err := repo.PopulateDB()
-		if err != nil {
-			t.Errorf("error populating the database")
+		// populate database, the seed generates the same medications every time. An empty database has no
+		// users to log in with, so in development mode it is populated without a token, only once
+		e.POST("/api/v1/database/populate").WithQuery("seed", 42).Expect().Status(httptest.StatusOK).JSON().Object().
+			ValueEqual("source", dto.SeedSourceGenerated).ValueEqual("seed", 42)
+		e.POST("/api/v1/database/populate").Expect().Status(httptest.StatusUnauthorized)
+		var out, errOut bytes.Buffer
+		if code := runCLI([]string{"db", "populate", "-seed", "42"}, nil, &out, &errOut); code != 1 {
+			t.Errorf("db populate must refuse a populated database, got %d", code)
 		}
 	}
 	// check server status
 	e.GET("/status").Expect().Status(httptest.StatusOK)
 
+	// liveness and readiness probes, the app is not ready until the cron scheduler runs
+	e.GET("/healthz").Expect().Status(httptest.StatusOK).JSON().Object().ValueEqual("status", dto.HealthOK)
+	notReady := e.GET("/readyz").Expect().Status(httptest.StatusServiceUnavailable).JSON().Object()
+	notReady.Value("checks").Object().Value(dto.HealthCheckCron).Object().ValueEqual("status", dto.HealthFail)
+	notReady.Value("checks").Object().Value(dto.HealthCheckPopulated).Object().ValueEqual("status", dto.HealthOK)
+	if err := svc.cronJob.MeinerCronJob(); err != nil {
+		t.Errorf("error starting the cron job: %s", err)
+	}
+	e.GET("/readyz").Expect().Status(httptest.StatusOK).JSON().Object().ValueEqual("status", dto.HealthOK)
+
+	// configuration: clear errors at startup, environment overrides and hot reload of the reloadable settings
+	if _, err := utils.NewSvcConfig("./conf/missing.yaml"); err == nil {
+		t.Errorf("a missing config file must fail")
+	}
+	_ = os.Setenv("SERVER_EVERY_TIME", "often")
+	_ = os.Setenv("SERVER_MIN_BATTERY_TO_LOAD", "101")
+	if _, err := utils.NewSvcConfig(""); err == nil || !strings.Contains(err.Error(), "SERVER_EVERY_TIME") || !strings.Contains(err.Error(), "MinBatteryToLoad") {
+		t.Errorf("the invalid settings must be reported, got: %v", err)
+	}
+	reloadConfig(app, svc, "test")
+	if svc.config.Reloadable().EveryTime != svc.config.EveryTime {
+		t.Errorf("an invalid configuration must not be applied")
+	}
+	_ = os.Setenv("SERVER_EVERY_TIME", "60")
+	_ = os.Setenv("SERVER_MIN_BATTERY_TO_LOAD", "30")
+	_ = os.Setenv("SERVER_DAPP_PORT", "7003")
+	if _, ignored, err := svc.config.Reload(); err != nil || len(ignored) != 1 || ignored[0] != "DappPort" {
+		t.Errorf("only the structural DappPort must be ignored, got %v (%v)", ignored, err)
+	}
+	_ = os.Setenv("SERVER_EVERY_TIME", "120")
+	reloadConfig(app, svc, "test")
+	if live := svc.config.Reloadable(); live.EveryTime != 120 || live.MinBatteryToLoad != 30 {
+		t.Errorf("the reloadable settings must be applied, got %+v", live)
+	}
+	if !svc.cronJob.SchedulerStatus().Running {
+		t.Errorf("the cron job must keep running after being rescheduled")
+	}
+	_ = os.Unsetenv("SERVER_EVERY_TIME")
+	_ = os.Unsetenv("SERVER_MIN_BATTERY_TO_LOAD")
+	_ = os.Unsetenv("SERVER_DAPP_PORT")
+	reloadConfig(app, svc, "test")
+
 	// without basic auth
 	e.GET("/api/v1/drones").Expect().Status(httptest.StatusUnauthorized)
 	e.GET("/api/v1/medications").Expect().Status(httptest.StatusUnauthorized)
+	e.GET("/api/v1/audit").Expect().Status(httptest.StatusUnauthorized)
 
 	// with valid JWT auth
 	cred := dto.UserCredIn{
@@ -46,7 +121,13 @@ func TestNewApp(t *testing.T) {
 		Password: "password1",
 	}
 
-	_ = e.POST("/api/v1/auth").WithJSON(cred).Expect().Status(httptest.StatusOK)
+	token := e.POST("/api/v1/auth").WithJSON(cred).Expect().Status(httptest.StatusOK).JSON().String().Raw()
+
+	// the login has been recorded in the audit trail and the hash chain is intact
+	e.GET("/api/v1/audit").WithHeader("Authorization", "Bearer "+token).WithQuery("action", dto.AuditActionLogin).
+		Expect().Status(httptest.StatusOK).JSON().Array().NotEmpty()
+	e.GET("/api/v1/audit/verify").WithHeader("Authorization", "Bearer "+token).
+		Expect().Status(httptest.StatusOK).JSON().Object().ValueEqual("valid", true)
 
 	// with invalid JWT auth
 	cred = dto.UserCredIn{
@@ -71,6 +152,280 @@ func TestNewApp(t *testing.T) {
 		t.Errorf("drone %s must be valid", droneValid.SerialNumber)
 	}
 
+	// register the drone, a second registration with the same serial number is a conflict
+	e.POST("/api/v1/drones").WithHeader("Authorization", "Bearer "+token).WithJSON(droneValid).
+		Expect().Status(httptest.StatusNoContent).Header("ETag").Equal(`"1"`)
+	conflict := e.POST("/api/v1/drones").WithHeader("Authorization", "Bearer "+token).WithHeader("X-Request-Id", "test-conflict").
+		WithJSON(droneValid).Expect().Status(httptest.StatusConflict)
+	// the request ID is sent back in the header and in the problem
+	conflict.Header("X-Request-Id").Equal("test-conflict")
+	conflict.Body().Contains(`"requestId": "test-conflict"`)
+
+	// problem responses (RFC 7807): type, title and status of the catalogue, the instance and the field errors
+	conflict.ContentType("application/problem+json").JSON(problemJSON).Object().
+		ValueEqual("code", schema.ErrDuplicateKey).ValueEqual("status", 409).ValueEqual("title", "Duplicate key").
+		ValueEqual("type", schema.ProblemTypeURI(schema.ErrDuplicateKey)).ValueEqual("instance", "/api/v1/drones")
+	invalid := e.POST("/api/v1/drones").WithHeader("Authorization", "Bearer "+token).
+		WithJSON(dto.RequestDrone{SerialNumber: lib.GenerateUUIDStr(), Model: dto.Lightweight, BatteryCapacity: 101, State: 9}).
+		Expect().Status(httptest.StatusBadRequest).JSON(problemJSON).Object()
+	invalid.ValueEqual("code", schema.ErrValidationField)
+	invalid.Value("errors").Array().Length().Equal(2)
+	invalid.Value("errors").Array().Path("$[*].field").Array().ContainsOnly("batteryCapacity", "state")
+	invalid.Value("errors").Array().Path("$[*].message").Array().ContainsOnly("101 is not between 0 and 100", "unknown drone state")
+	e.GET("/api/v1/medications/items/"+strings.Repeat("X", 101)).WithHeader("Authorization", "Bearer "+token).
+		Expect().Status(httptest.StatusBadRequest).JSON(problemJSON).Object().ValueEqual("code", schema.ErrValidationField).
+		ValueEqual("detail", "the serial number of a drone must have a 100 characters max")
+	e.GET("/api/v1/drones").Expect().Status(httptest.StatusUnauthorized).
+		JSON(problemJSON).Object().ValueEqual("code", schema.ErrUnauthorized).ValueEqual("instance", "/api/v1/drones")
+	// outside debug mode the safe details are still sent
+	_ = os.Setenv("SERVER_DEBUG", "false")
+	if _, _, err := svc.config.Reload(); err != nil || svc.config.Reloadable().Debug {
+		t.Errorf("the debug mode must be reloaded: %v", err)
+	}
+	e.POST("/api/v1/drones").WithHeader("Authorization", "Bearer "+token).WithJSON(droneValid).
+		Expect().Status(httptest.StatusConflict).JSON(problemJSON).Object().Value("detail").String().NotEmpty()
+	_ = os.Unsetenv("SERVER_DEBUG")
+	_, _, _ = svc.config.Reload()
+	for _, key := range schema.ProblemKeys() {
+		if p := schema.ProblemTypeOf(key); p.Status < 400 {
+			t.Errorf("the problem type of %s must have an error status", key)
+		}
+		for _, lang := range i18n.Languages {
+			if title := i18n.Title(lang, key); title == "" || (lang != i18n.DefaultLanguage && title == i18n.Title(i18n.DefaultLanguage, key)) {
+				t.Errorf("the problem type of %s must have a title in %s", key, lang)
+			}
+		}
+	}
+
+	// the title, the detail and the field messages are translated to the locale of Accept-Language
+	conflictEs := e.POST("/api/v1/drones").WithHeader("Authorization", "Bearer "+token).WithHeader("Accept-Language", "es-MX,es;q=0.9,en;q=0.5").
+		WithJSON(droneValid).Expect().Status(httptest.StatusConflict)
+	conflictEs.Header("Content-Language").Equal("es-ES")
+	conflictEs.JSON(problemJSON).Object().ValueEqual("title", "Clave duplicada").
+		ValueEqual("detail", "ya existe un dron con el mismo número de serie").ValueEqual("code", schema.ErrDuplicateKey)
+	invalidEs := e.POST("/api/v1/drones").WithHeader("Authorization", "Bearer "+token).WithHeader("Accept-Language", "es").
+		WithJSON(dto.RequestDrone{SerialNumber: lib.GenerateUUIDStr(), Model: dto.Lightweight, BatteryCapacity: 101, State: 9}).
+		Expect().Status(httptest.StatusBadRequest).JSON(problemJSON).Object()
+	invalidEs.ValueEqual("title", "Validación fallida").ValueEqual("detail", "2 campo(s) no válido(s)")
+	invalidEs.Value("errors").Array().Path("$[*].message").Array().ContainsOnly("101 no está entre 0 y 100", "estado de dron desconocido")
+	invalidEs.Value("errors").Array().Path("$[*].rule").Array().ContainsOnly("range", "drone_enum_validation")
+	// an unsupported language falls back to English
+	e.POST("/api/v1/drones").WithHeader("Authorization", "Bearer "+token).WithHeader("Accept-Language", "ja").
+		WithJSON(droneValid).Expect().Status(httptest.StatusConflict).Header("Content-Language").Equal(i18n.DefaultLanguage)
+
+	// partial update with optimistic concurrency
+	e.PATCH("/api/v1/drones/"+droneValid.SerialNumber).WithHeader("Authorization", "Bearer "+token).
+		WithHeader("If-Match", `"2"`).WithJSON(map[string]interface{}{"batteryCapacity": 80}).
+		Expect().Status(httptest.StatusPreconditionFailed)
+	e.PATCH("/api/v1/drones/"+droneValid.SerialNumber).WithHeader("Authorization", "Bearer "+token).
+		WithHeader("If-Match", `"1"`).WithJSON(map[string]interface{}{"batteryCapacity": 80}).
+		Expect().Status(httptest.StatusOK).JSON().Object().ValueEqual("batteryCapacity", 80).ValueEqual("version", 2)
+	// the state only changes by loading the drone and by the lifecycle actions
+	e.PATCH("/api/v1/drones/"+droneValid.SerialNumber).WithHeader("Authorization", "Bearer "+token).
+		WithJSON(map[string]interface{}{"state": dto.LOADED}).Expect().Status(httptest.StatusConflict).
+		JSON(problemJSON).Object().ValueEqual("code", schema.ErrDroneStateReadOnlyKey)
+	movedDrone := droneValid
+	movedDrone.State = dto.DELIVERING
+	e.PUT("/api/v1/drones/"+droneValid.SerialNumber).WithHeader("Authorization", "Bearer "+token).
+		WithJSON(movedDrone).Expect().Status(httptest.StatusConflict).JSON(problemJSON).Object().ValueEqual("code", schema.ErrDroneStateReadOnlyKey)
+	movedDrone.SerialNumber = lib.GenerateUUIDStr()
+	e.POST("/api/v1/drones").WithHeader("Authorization", "Bearer "+token).
+		WithJSON(movedDrone).Expect().Status(httptest.StatusConflict).JSON(problemJSON).Object().ValueEqual("code", schema.ErrDroneStateReadOnlyKey)
+	e.GET("/api/v1/drones/"+droneValid.SerialNumber).WithHeader("Authorization", "Bearer "+token).
+		Expect().Status(httptest.StatusOK).JSON().Object().ValueEqual("state", dto.IDLE).ValueEqual("version", 2)
+	// the audit trail keeps the version that the update replaced
+	e.GET("/api/v1/audit").WithHeader("Authorization", "Bearer "+token).WithQuery("target", droneValid.SerialNumber).
+		WithQuery("action", dto.AuditActionUpdateDrone).Expect().Status(httptest.StatusOK).
+		JSON().Array().Path("$[*].before.version").Array().ContainsOnly(1)
+
+	// retire the drone, it is hidden from the list and its serial number can't be registered again
+	e.DELETE("/api/v1/drones/"+droneValid.SerialNumber).WithHeader("Authorization", "Bearer "+token).
+		Expect().Status(httptest.StatusNoContent)
+	e.DELETE("/api/v1/drones/"+droneValid.SerialNumber).WithHeader("Authorization", "Bearer "+token).
+		Expect().Status(httptest.StatusConflict)
+	e.POST("/api/v1/drones").WithHeader("Authorization", "Bearer "+token).WithJSON(droneValid).
+		Expect().Status(httptest.StatusConflict)
+	e.GET("/api/v1/drones").WithHeader("Authorization", "Bearer "+token).WithQuery("retired", true).
+		Expect().Status(httptest.StatusOK).JSON().Array().Path("$[*].serialNumber").Array().Contains(droneValid.SerialNumber)
+
+	// typed filters with cursor pagination, the idle drones sorted by battery descending
+	res := e.GET("/api/v1/drones").WithHeader("Authorization", "Bearer "+token).
+		WithQuery("state", "0").WithQuery("batteryMin", 20).WithQuery("limit", 2).Expect().Status(httptest.StatusOK)
+	res.Header("X-Total-Count").Equal("3")
+	res.JSON().Array().Length().Equal(2)
+	e.GET("/api/v1/drones").WithHeader("Authorization", "Bearer "+token).
+		WithQuery("state", "0").WithQuery("batteryMin", 20).WithQuery("limit", 2).WithQuery("cursor", res.Header("X-Next-Cursor").Raw()).
+		Expect().Status(httptest.StatusOK).JSON().Array().Length().Equal(1)
+	e.GET("/api/v1/drones").WithHeader("Authorization", "Bearer "+token).WithQuery("sort", "unknown").
+		Expect().Status(httptest.StatusBadRequest)
+
+	// content negotiation: the lists in MessagePack, Protobuf and CSV, and 406 for an unsupported type
+	drones := e.GET("/api/v1/drones").WithHeader("Authorization", "Bearer "+token).Expect().Status(httptest.StatusOK).JSON().Array()
+	packed := e.GET("/api/v1/drones").WithHeader("Authorization", "Bearer "+token).WithHeader("Accept", "application/msgpack").
+		Expect().Status(httptest.StatusOK).ContentType("application/msgpack")
+	var packedDrones []map[string]interface{}
+	if err := msgpack.Unmarshal([]byte(packed.Body().Raw()), &packedDrones); err != nil || len(packedDrones) != int(drones.Length().Raw()) || packedDrones[0]["serialNumber"] == nil {
+		t.Errorf("the MessagePack drones must match the JSON ones with the same keys: %v", err)
+	}
+	protoDrones := pb.DroneList{}
+	body := e.GET("/api/v1/drones").WithHeader("Authorization", "Bearer "+token).WithHeader("Accept", "application/x-protobuf").
+		Expect().Status(httptest.StatusOK).ContentType("application/x-protobuf").Body().Raw()
+	if err := proto.Unmarshal([]byte(body), &protoDrones); err != nil || len(protoDrones.Items) != int(drones.Length().Raw()) ||
+		protoDrones.Items[0].SerialNumber != drones.First().Object().Value("serialNumber").String().Raw() {
+		t.Errorf("the Protobuf drones must match the JSON ones: %v", err)
+	}
+	csvDrones := e.GET("/api/v1/drones").WithHeader("Authorization", "Bearer "+token).WithHeader("Accept", "text/csv").
+		Expect().Status(httptest.StatusOK).ContentType("text/csv").Body()
+	csvDrones.Contains("serialNumber,model,weightLimit,batteryCapacity,state,version,retired,retiredAt\n")
+	csvDrones.Contains(drones.First().Object().Value("serialNumber").String().Raw())
+	e.GET("/api/v1/logs").WithHeader("Authorization", "Bearer "+token).WithHeader("Accept", "text/csv").
+		Expect().Status(httptest.StatusOK).Body().Contains("created,uuid,serialNumber,batteryCapacity\n")
+	e.GET("/api/v1/medications").WithHeader("Authorization", "Bearer "+token).WithHeader("Accept", "text/csv").
+		Expect().Status(httptest.StatusNotAcceptable)
+	e.GET("/api/v1/medications").WithHeader("Authorization", "Bearer "+token).WithHeader("Accept", "text/html, */*").
+		Expect().Status(httptest.StatusOK).ContentType("application/json")
+
+	// fleet statistics, the retired drones are not in service
+	e.GET("/api/v1/fleet/stats").WithHeader("Authorization", "Bearer "+token).
+		Expect().Status(httptest.StatusOK).JSON().Object().ValueEqual("total", 10).Value("retired").Number().Gt(0)
+
+	// graphql: a drone with its loaded medications and battery history in one round trip, the mutations and the limits
+	gqlQuery := func(query string, variables map[string]interface{}) *httpexpect.Object {
+		return e.POST("/api/v1/graphql").WithHeader("Authorization", "Bearer "+token).
+			WithJSON(dto.GraphQLRequest{Query: query, Variables: variables}).Expect().Status(httptest.StatusOK).JSON().Object()
+	}
+	e.POST("/api/v1/graphql").WithJSON(dto.GraphQLRequest{Query: "{ medications { code } }"}).Expect().Status(httptest.StatusUnauthorized)
+	gqlDrone := lib.GenerateUUIDStr()
+	gqlQuery(`mutation ($input: DroneInput!) { registerDrone(input: $input) { serialNumber weightLimit state } }`,
+		map[string]interface{}{"input": map[string]interface{}{"serialNumber": gqlDrone, "model": "Heavyweight", "batteryCapacity": 90}}).
+		Value("data").Object().Value("registerDrone").Object().
+		ValueEqual("weightLimit", lib.CalculateDroneWeightLimit(dto.Heavyweight)).ValueEqual("state", "IDLE")
+	lightest := gqlQuery(`{ medications { code weight } }`, nil).Value("data").Object().Value("medications").Array()
+	lightestCode, lightestWeight := "", 0.0
+	for _, m := range lightest.Iter() {
+		if w := m.Object().Value("weight").Number().Raw(); lightestCode == "" || w < lightestWeight {
+			lightestCode, lightestWeight = m.Object().Value("code").String().Raw(), w
+		}
+	}
+	gqlQuery(`mutation ($sn: String!, $codes: [String!]!) { loadMedications(serialNumber: $sn, codes: $codes) { state } }`,
+		map[string]interface{}{"sn": gqlDrone, "codes": []string{lightestCode}}).Value("data").Object().Value("loadMedications").Object().NotEmpty()
+	gqlLoaded := gqlQuery(`query ($sn: String!) { drone(serialNumber: $sn) { loadedMedications { code weight } batteryHistory { created } } }`,
+		map[string]interface{}{"sn": gqlDrone}).Value("data").Object().Value("drone").Object()
+	gqlLoaded.Value("loadedMedications").Array().Path("$[*].code").Array().ContainsOnly(lightestCode)
+	gqlLoaded.Value("batteryHistory").Array().Empty()
+	e.GET("/api/v1/audit").WithHeader("Authorization", "Bearer "+token).WithQuery("target", gqlDrone).
+		Expect().Status(httptest.StatusOK).JSON().Array().Path("$[*].action").Array().ContainsOnly(dto.AuditActionRegisterDrone, dto.AuditActionLoadMedications)
+	gqlQuery(`{ drone(serialNumber: "unknown") { state } }`, nil).ValueEqual("data", map[string]interface{}{"drone": nil}).NotContainsKey("errors")
+	gqlQuery(`{ drones(state: [IDLE], limit: 2) { total nextCursor items { serialNumber } } }`, nil).
+		Value("data").Object().Value("drones").Object().Value("items").Array().Length().Equal(2)
+	// a page without limit has 10 drones, the 10 seeded and the registered one don't fit, and a limit is capped to 100
+	gqlPage := gqlQuery(`{ drones { total nextCursor items { serialNumber } } }`, nil).Value("data").Object().Value("drones").Object()
+	gqlPage.Value("total").Number().Gt(10)
+	gqlPage.Value("items").Array().Length().Equal(10)
+	gqlPage.Value("nextCursor").String().NotEmpty()
+	gqlPage = gqlQuery(`{ drones(limit: 1000) { total nextCursor items { serialNumber } } }`, nil).Value("data").Object().Value("drones").Object()
+	gqlPage.Value("items").Array().Length().Equal(gqlPage.Value("total").Number().Raw())
+	gqlPage.ValueEqual("nextCursor", nil)
+	gqlQuery(`{ drones { items { color } } }`, nil).Value("errors").Array().NotEmpty()
+	// the errors of the services carry the problem code and are translated
+	gqlConflict := e.POST("/api/v1/graphql").WithHeader("Authorization", "Bearer "+token).WithHeader("Accept-Language", "es").
+		WithJSON(dto.GraphQLRequest{Query: `mutation { registerDrone(input: {serialNumber: "` + gqlDrone + `", model: Heavyweight, batteryCapacity: 90}) { state } }`}).
+		Expect().Status(httptest.StatusOK)
+	gqlConflict.Header("Content-Language").Equal("es-ES")
+	gqlConflict.JSON().Object().Value("errors").Array().First().Object().
+		ValueEqual("message", "ya existe un dron con el mismo número de serie").
+		Value("extensions").Object().ValueEqual("code", schema.ErrDuplicateKey).ValueEqual("status", 409)
+	gqlQuery(`mutation { registerDrone(input: {serialNumber: "`+lib.GenerateUUIDStr()+`", model: Heavyweight, batteryCapacity: 90, state: DELIVERING}) { state } }`, nil).
+		Value("errors").Array().First().Object().Value("extensions").Object().ValueEqual("code", schema.ErrDroneStateReadOnlyKey)
+	// depth and complexity limits, a list without limit counts as 10 items
+	maxDepth, maxComplexity := svc.config.GraphQLMaxDepth, svc.config.GraphQLMaxComplexity
+	svc.config.GraphQLMaxDepth, svc.config.GraphQLMaxComplexity = 3, 100
+	gqlQuery(`{ drone(serialNumber: "unknown") { loadedMedications { code } } }`, nil).Value("data").Object().NotEmpty()
+	gqlQuery(`{ drones { items { loadedMedications { code } } } }`, nil).Value("errors").Array().First().Object().
+		Value("message").String().Contains("too deep")
+	svc.config.GraphQLMaxDepth = 4
+	gqlQuery(`{ drones { items { loadedMedications { code } } } }`, nil).Value("errors").Array().First().Object().
+		Value("message").String().Contains("too complex")
+	gqlQuery(`{ drones(limit: 2) { items { loadedMedications { code } } } }`, nil).Value("data").Object().NotEmpty()
+	svc.config.GraphQLMaxDepth, svc.config.GraphQLMaxComplexity = maxDepth, maxComplexity
+
+	// bulk registration and loading: atomic (all or nothing) and best-effort, from a JSON array or a CSV file
+	bulkA, bulkB := lib.GenerateUUIDStr(), lib.GenerateUUIDStr()
+	rejected := e.POST("/api/v1/drones/bulk").WithHeader("Authorization", "Bearer "+token).WithJSON([]dto.RequestDrone{
+		{SerialNumber: bulkA, Model: dto.Lightweight, BatteryCapacity: 90},
+		{SerialNumber: droneValid.SerialNumber, Model: dto.Lightweight, BatteryCapacity: 90},
+		{SerialNumber: bulkB, Model: dto.Lightweight, BatteryCapacity: 101},
+	}).Expect().Status(httptest.StatusUnprocessableEntity).JSON().Object()
+	rejected.ValueEqual("mode", dto.BulkModeAtomic).ValueEqual("succeeded", 0).ValueEqual("failed", 2)
+	rejected.Value("items").Array().Path("$[*].status").Equal([]int{424, 409, 400})
+	rejected.Value("items").Array().Element(1).Object().Value("problem").Object().ValueEqual("code", schema.ErrDroneRetiredKey)
+	e.GET("/api/v1/drones/"+bulkA).WithHeader("Authorization", "Bearer "+token).Expect().Status(httptest.StatusNotFound)
+	partial := e.POST("/api/v1/drones/bulk").WithHeader("Authorization", "Bearer "+token).WithHeader("Accept-Language", "es").
+		WithQuery("mode", dto.BulkModeBestEffort).WithHeader("Content-Type", "text/csv").
+		WithBytes([]byte("serialNumber,model,batteryCapacity,state\n" + bulkA + ",Lightweight,90,IDLE\n" + bulkA + ",Heavyweight,50,\n")).
+		Expect().Status(httptest.StatusMultiStatus)
+	partial.Header("Content-Language").Equal("es-ES")
+	partial.JSON().Object().ValueEqual("succeeded", 1).Value("items").Array().Element(1).Object().
+		ValueEqual("status", 409).Value("problem").Object().ValueEqual("detail", "el número de serie se repite en la solicitud, es el del elemento 0")
+	e.POST("/api/v1/drones/bulk").WithHeader("Authorization", "Bearer "+token).
+		WithJSON([]dto.RequestDrone{{SerialNumber: bulkB, Model: dto.Heavyweight, BatteryCapacity: 90}}).
+		Expect().Status(httptest.StatusOK).JSON().Object().Value("items").Array().First().Object().ValueEqual("status", 201)
+	e.POST("/api/v1/drones/bulk").WithHeader("Authorization", "Bearer "+token).WithQuery("mode", "some").
+		WithJSON([]dto.RequestDrone{{SerialNumber: lib.GenerateUUIDStr()}}).Expect().Status(httptest.StatusBadRequest)
+	e.POST("/api/v1/drones/bulk").WithHeader("Authorization", "Bearer "+token).WithJSON([]dto.RequestDrone{}).
+		Expect().Status(httptest.StatusBadRequest)
+	e.POST("/api/v1/medications/items").WithHeader("Authorization", "Bearer "+token).WithJSON([]dto.LoadInstruction{
+		{SerialNumber: bulkA, MedicationCodes: []string{lightestCode}},
+		{SerialNumber: "unknown", MedicationCodes: []string{lightestCode}},
+	}).Expect().Status(httptest.StatusUnprocessableEntity).JSON().Object().Value("items").Array().Path("$[*].status").Equal([]int{424, 404})
+	e.GET("/api/v1/medications/items/"+bulkA).WithHeader("Authorization", "Bearer "+token).
+		Expect().Status(httptest.StatusOK).JSON().Array().Empty()
+	e.POST("/api/v1/medications/items").WithHeader("Authorization", "Bearer "+token).WithMultipart().
+		WithFileBytes("file", "loads.csv", []byte("serialNumber,medicationCode\n"+bulkA+","+lightestCode+"\n"+bulkB+","+lightestCode+"\n")).
+		Expect().Status(httptest.StatusOK).JSON().Object().ValueEqual("succeeded", 2)
+	e.GET("/api/v1/medications/items/"+bulkB).WithHeader("Authorization", "Bearer "+token).
+		Expect().Status(httptest.StatusOK).JSON().Array().ContainsOnly(lightestCode)
+	e.GET("/api/v1/audit").WithHeader("Authorization", "Bearer "+token).WithQuery("target", bulkB).
+		Expect().Status(httptest.StatusOK).JSON().Array().Path("$[*].action").Array().ContainsOnly(dto.AuditActionRegisterDrone, dto.AuditActionLoadMedications)
+
+	// prometheus metrics, labeled by route template
+	metricsBody := e.GET("/metrics").Expect().Status(httptest.StatusOK).Body()
+	metricsBody.Contains(`drones_http_requests_total{method="PATCH",route="/api/v1/drones/{serialNumber:string}",status="200"}`)
+	metricsBody.Contains(`drones_logins_total{result="success"}`)
+	metricsBody.Contains(`drones_fleet_drones{state="IDLE"}`)
+
+	// tracing: the repository span is nested in the service span, and this one in the request span. The errors
+	// are recorded in the spans, the latest span with a name is looked up
+	recorder := tracetest.NewSpanRecorder()
+	otel.GetTracerProvider().(*sdktrace.TracerProvider).RegisterSpanProcessor(recorder)
+	spanNamed := func(name string, traceID oteltrace.TraceID) sdktrace.ReadOnlySpan {
+		ended := recorder.Ended()
+		for i := len(ended) - 1; i >= 0; i-- {
+			if span := ended[i]; span.Name() == name && (!traceID.IsValid() || span.SpanContext().TraceID() == traceID) {
+				return span
+			}
+		}
+		t.Fatalf("span '%s' not found", name)
+		return nil
+	}
+	e.GET("/api/v1/drones/"+bulkB).WithHeader("Authorization", "Bearer "+token).Expect().Status(httptest.StatusOK)
+	requestSpan := spanNamed("GET /api/v1/drones/{serialNumber:string}", oteltrace.TraceID{})
+	svcSpan := spanNamed("ISvcDrones.GetADroneSvc", requestSpan.SpanContext().TraceID())
+	repoSpan := spanNamed("db.drones.get_drone", requestSpan.SpanContext().TraceID())
+	if svcSpan.Parent().SpanID() != requestSpan.SpanContext().SpanID() || repoSpan.Parent().SpanID() != svcSpan.SpanContext().SpanID() {
+		t.Errorf("the spans must be chained request -> service -> repository, got parents %s and %s", svcSpan.Parent().SpanID(), repoSpan.Parent().SpanID())
+	}
+	if svcSpan.Status().Code != codes.Unset || repoSpan.Status().Code != codes.Unset {
+		t.Errorf("the spans of a successful request must not be failed, got %v and %v", svcSpan.Status(), repoSpan.Status())
+	}
+	e.GET("/api/v1/drones/"+lib.GenerateUUIDStr()).WithHeader("Authorization", "Bearer "+token).Expect().Status(httptest.StatusNotFound)
+	requestSpan = spanNamed("GET /api/v1/drones/{serialNumber:string}", oteltrace.TraceID{})
+	svcSpan = spanNamed("ISvcDrones.GetADroneSvc", requestSpan.SpanContext().TraceID())
+	repoSpan = spanNamed("db.drones.get_drone", requestSpan.SpanContext().TraceID())
+	if repoSpan.Status().Code != codes.Error || svcSpan.Status().Code != codes.Error || !strings.Contains(svcSpan.Status().Description, schema.ErrBuntdbItemNotFound) {
+		t.Errorf("the spans of a missing drone must be failed, got %v and %v", repoSpan.Status(), svcSpan.Status())
+	}
+
 	// drone invalid
 	droneInvalid := dto.Drone{
 		SerialNumber:    lib.GenerateUUIDStr(),
@@ -97,4 +452,383 @@ func TestNewApp(t *testing.T) {
 	if !ok {
 		t.Errorf("medication %s must be valid", medicationValid.Code)
 	}
-}
\ No newline at end of file
+
+	// backups: online snapshots, restore and the versioned export of the whole dataset
+	e.POST("/api/v1/admin/backups").Expect().Status(httptest.StatusUnauthorized)
+	snapshotID := e.POST("/api/v1/admin/backups").WithHeader("Authorization", "Bearer "+token).
+		Expect().Status(httptest.StatusCreated).JSON().Object().Value("id").String().Raw()
+	e.GET("/api/v1/admin/backups").WithHeader("Authorization", "Bearer "+token).
+		Expect().Status(httptest.StatusOK).JSON().Array().First().Object().ValueEqual("id", snapshotID)
+	e.POST("/api/v1/admin/backups/"+snapshotID+"/restore").WithHeader("Authorization", "Bearer "+token).
+		Expect().Status(httptest.StatusOK).JSON().Object().Value("id").NotEqual(snapshotID)
+	e.POST("/api/v1/admin/backups/19700101-000000.000/restore").WithHeader("Authorization", "Bearer "+token).
+		Expect().Status(httptest.StatusNotFound)
+	e.POST("/api/v1/admin/backups/latest/restore").WithHeader("Authorization", "Bearer "+token).
+		Expect().Status(httptest.StatusBadRequest)
+	dataset := e.GET("/api/v1/admin/export").WithHeader("Authorization", "Bearer "+token).
+		Expect().Status(httptest.StatusOK).JSON().Object()
+	dataset.ValueEqual("version", dto.DatasetVersion).Value("drones").Array().NotEmpty()
+	dataset.Value("logs").Array()
+	dataset.Value("users").Array().First().Object().NotContainsKey("passphrase").ValueEqual("admin", true)
+	e.GET("/api/v1/admin/export").WithHeader("Authorization", "Bearer "+token).WithQuery("passphrases", true).
+		Expect().Status(httptest.StatusOK).JSON().Object().Value("users").Array().First().Object().ContainsKey("passphrase")
+	e.POST("/api/v1/admin/import").WithHeader("Authorization", "Bearer "+token).WithJSON(dataset.Raw()).
+		Expect().Status(httptest.StatusConflict)
+	e.POST("/api/v1/admin/import").WithHeader("Authorization", "Bearer "+token).WithQuery("replace", true).
+		WithJSON(map[string]interface{}{"version": 99}).Expect().Status(httptest.StatusBadRequest)
+	// a user without passphrase keeps the one of the same username, a user not in the database is refused
+	unknownUser := map[string]interface{}{}
+	for k, v := range dataset.Raw() {
+		unknownUser[k] = v
+	}
+	unknownUser["users"] = append(append([]interface{}{}, dataset.Raw()["users"].([]interface{})...), map[string]interface{}{"username": "richard"})
+	e.POST("/api/v1/admin/import").WithHeader("Authorization", "Bearer "+token).WithQuery("replace", true).
+		WithJSON(unknownUser).Expect().Status(httptest.StatusBadRequest).JSON(problemJSON).Object().ValueEqual("code", schema.ErrVal)
+	e.POST("/api/v1/admin/import").WithHeader("Authorization", "Bearer "+token).WithQuery("replace", true).
+		WithJSON(dataset.Raw()).Expect().Status(httptest.StatusNoContent)
+	e.POST("/api/v1/auth").WithJSON(dto.UserCredIn{Username: "richard.sargon@meinermail.com", Password: "password1"}).
+		Expect().Status(httptest.StatusOK) // the imported users keep their passphrases
+
+	// command line: the admin tasks reuse the repositories without a running server nor a token
+	var out, errOut bytes.Buffer
+	if code := runCLI([]string{"drones", "list", "-json", "-state", "IDLE"}, nil, &out, &errOut); code != 0 {
+		t.Errorf("drones list must succeed, got %d: %s", code, errOut.String())
+	}
+	var idle []dto.Drone
+	if err := json.Unmarshal(out.Bytes(), &idle); err != nil || len(idle) == 0 {
+		t.Errorf("drones list must print the idle drones as JSON: %v", err)
+	}
+	dumpFile := filepath.Join(t.TempDir(), "dataset.json")
+	if code := runCLI([]string{"db", "export", "-out", dumpFile}, nil, &out, &errOut); code != 0 {
+		t.Errorf("db export must succeed, got %d: %s", code, errOut.String())
+	}
+	if code := runCLI([]string{"db", "import", "-in", dumpFile}, nil, &out, &errOut); code != 1 {
+		t.Errorf("db import must refuse to overwrite a populated database without -replace, got %d", code)
+	}
+	if code := runCLI([]string{"db", "import", "-in", dumpFile, "-replace"}, nil, &out, &errOut); code != 0 {
+		t.Errorf("db import -replace must succeed, got %d: %s", code, errOut.String())
+	}
+	if code := runCLI([]string{"db", "backup"}, nil, &out, &errOut); code != 0 {
+		t.Errorf("db backup must succeed, got %d: %s", code, errOut.String())
+	}
+	out.Reset()
+	if code := runCLI([]string{"db", "backups", "-json"}, nil, &out, &errOut); code != 0 {
+		t.Errorf("db backups must succeed, got %d: %s", code, errOut.String())
+	}
+	var snapshots []dto.Snapshot
+	if err := json.Unmarshal(out.Bytes(), &snapshots); err != nil || len(snapshots) < 2 {
+		t.Errorf("db backups must list the snapshots as JSON: %v", err)
+	} else if code := runCLI([]string{"db", "restore", "-id", snapshots[0].ID}, nil, &out, &errOut); code != 0 {
+		t.Errorf("db restore must succeed, got %d: %s", code, errOut.String())
+	}
+	if code := runCLI([]string{"db", "restore"}, nil, &out, &errOut); code != 1 {
+		t.Errorf("db restore must fail without -id, got %d", code)
+	}
+	cliUser := dto.UserCredIn{Username: gofakeit.Email(), Password: "cli-password"}
+	if code := runCLI([]string{"users", "create", "-username", cliUser.Username, "-name", "Cli User"}, strings.NewReader(cliUser.Password+"\n"), &out, &errOut); code != 0 {
+		t.Errorf("users create must succeed, got %d: %s", code, errOut.String())
+	}
+	if code := runCLI([]string{"users", "create", "-username", cliUser.Username, "-password", "other"}, nil, &out, &errOut); code != 1 {
+		t.Errorf("users create must fail for an existing username, got %d", code)
+	}
+	cliToken := e.POST("/api/v1/auth").WithJSON(cliUser).Expect().Status(httptest.StatusOK).JSON().String().Raw()
+	e.GET("/api/v1/admin/export").WithHeader("Authorization", "Bearer "+cliToken).
+		Expect().Status(httptest.StatusForbidden).JSON(problemJSON).Object().ValueEqual("code", schema.ErrForbidden)
+	for _, path := range []string{"/api/v1/audit", "/api/v1/audit/verify"} {
+		e.GET(path).WithHeader("Authorization", "Bearer "+cliToken).
+			Expect().Status(httptest.StatusForbidden).JSON(problemJSON).Object().ValueEqual("code", schema.ErrForbidden)
+	}
+
+	// Idempotency-Key: the retries replay the first response, the key can't be reused with another request
+	// and the keys of every user are apart
+	idemKey := lib.GenerateUUIDStr()
+	idemDrone := dto.RequestDrone{SerialNumber: lib.GenerateUUIDStr(), Model: dto.Middleweight, BatteryCapacity: 80}
+	registerIdempotent := func(tk string, drone dto.RequestDrone) *httpexpect.Response {
+		return e.POST("/api/v1/drones").WithHeader("Authorization", "Bearer "+tk).WithHeader(dto.IdempotencyKeyHeader, idemKey).
+			WithJSON(drone).Expect()
+	}
+	first := registerIdempotent(token, idemDrone).Status(httptest.StatusNoContent)
+	first.Header(dto.IdempotencyReplayedHeader).Empty()
+	replayed := registerIdempotent(token, idemDrone).Status(httptest.StatusNoContent)
+	replayed.Header(dto.IdempotencyReplayedHeader).Equal("true")
+	replayed.Header("ETag").Equal(first.Header("ETag").Raw())
+	e.GET("/api/v1/audit").WithHeader("Authorization", "Bearer "+token).WithQuery("target", idemDrone.SerialNumber).
+		Expect().Status(httptest.StatusOK).JSON().Array().Length().Equal(1)
+	reused := idemDrone
+	reused.BatteryCapacity = 70
+	registerIdempotent(token, reused).Status(httptest.StatusUnprocessableEntity).JSON(problemJSON).Object().ValueEqual("code", schema.ErrIdempotencyKeyReused)
+	registerIdempotent(cliToken, idemDrone).Status(httptest.StatusConflict).JSON(problemJSON).Object().ValueEqual("code", schema.ErrDuplicateKey)
+	// an unknown medication is a client mistake, not a server error
+	e.POST("/api/v1/medications/items/"+idemDrone.SerialNumber).WithHeader("Authorization", "Bearer "+token).
+		WithJSON([]string{"UNKNOWN_MEDICATION"}).Expect().Status(httptest.StatusNotFound).JSON(problemJSON).Object().
+		ValueEqual("code", schema.ErrNotFound).ValueEqual("detail", "at least one of the medication items does not exist")
+	for i := 0; i < 2; i++ {
+		e.POST("/api/v1/medications/items/"+idemDrone.SerialNumber).WithHeader("Authorization", "Bearer "+token).
+			WithHeader(dto.IdempotencyKeyHeader, idemKey+"-load").WithJSON([]string{lightestCode}).
+			Expect().Status(httptest.StatusNoContent)
+	}
+	e.GET("/api/v1/medications/items/"+idemDrone.SerialNumber).WithHeader("Authorization", "Bearer "+token).
+		Expect().Status(httptest.StatusOK).JSON().Array().Length().Equal(1)
+	// parallel requests with the same key: the drone is registered once, the other ones are replayed or
+	// refused while the first one is in progress
+	parallelKey, parallelDrone := lib.GenerateUUIDStr(), dto.RequestDrone{SerialNumber: lib.GenerateUUIDStr(), Model: dto.Lightweight, BatteryCapacity: 90}
+	responses := make([]*httpexpect.Response, 20)
+	var wgParallel sync.WaitGroup
+	for i := range responses {
+		wgParallel.Add(1)
+		go func(i int) {
+			defer wgParallel.Done()
+			responses[i] = e.POST("/api/v1/drones").WithHeader("Authorization", "Bearer "+token).
+				WithHeader(dto.IdempotencyKeyHeader, parallelKey).WithJSON(parallelDrone).Expect()
+		}(i)
+	}
+	wgParallel.Wait()
+	for _, res := range responses {
+		if res.Raw().StatusCode != httptest.StatusNoContent {
+			res.Status(httptest.StatusConflict).JSON(problemJSON).Object().ValueEqual("code", schema.ErrIdempotencyInProgress)
+		}
+	}
+	e.GET("/api/v1/audit").WithHeader("Authorization", "Bearer "+token).WithQuery("target", parallelDrone.SerialNumber).
+		Expect().Status(httptest.StatusOK).JSON().Array().Length().Equal(1)
+	registerParallel := e.POST("/api/v1/drones").WithHeader("Authorization", "Bearer "+token).
+		WithHeader(dto.IdempotencyKeyHeader, parallelKey).WithJSON(parallelDrone).Expect().Status(httptest.StatusNoContent)
+	registerParallel.Header(dto.IdempotencyReplayedHeader).Equal("true")
+
+	e.POST("/api/v1/drones").WithHeader("Authorization", "Bearer "+token).WithHeader(dto.IdempotencyKeyHeader, "a key").
+		WithJSON(idemDrone).Expect().Status(httptest.StatusBadRequest).JSON(problemJSON).Object().ValueEqual("code", schema.ErrProcParam)
+
+	// delivery lifecycle: the loaded drone is dispatched, delivered (the medications are unloaded), returns and is
+	// returned; the abort path keeps the medications on board and the actions not allowed in the state of the
+	// drone are refused
+	lifecycle := func(serialNumber, action string) *httpexpect.Response {
+		return e.POST("/api/v1/drones/"+serialNumber+"/"+action).WithHeader("Authorization", "Bearer "+token).Expect()
+	}
+	loadedItems := func(serialNumber string) *httpexpect.Array {
+		return e.GET("/api/v1/medications/items/"+serialNumber).WithHeader("Authorization", "Bearer "+token).
+			Expect().Status(httptest.StatusOK).JSON().Array()
+	}
+	lifecycle(idemDrone.SerialNumber, dto.DeliveryActionDelivered).Status(httptest.StatusConflict).JSON(problemJSON).Object().
+		ValueEqual("code", schema.ErrDroneInvalidTransitionKey).ValueEqual("detail", "the 'delivered' action is not allowed for a drone in LOADED state")
+	dispatched := lifecycle(idemDrone.SerialNumber, dto.DeliveryActionDispatch).Status(httptest.StatusOK)
+	dispatched.Header("ETag").Equal(`"3"`)
+	dispatched.JSON().Object().Value("drone").Object().ValueEqual("state", dto.DELIVERING)
+	delivery := dispatched.JSON().Object().Value("delivery").Object()
+	delivery.ValueEqual("medications", []string{lightestCode}).ValueEqual("status", dto.DeliveryInTransit).NotContainsKey("deliveredAt")
+	e.POST("/api/v1/drones/"+idemDrone.SerialNumber+"/"+dto.DeliveryActionDelivered).WithHeader("Authorization", "Bearer "+token).
+		WithHeader("If-Match", `"1"`).Expect().Status(httptest.StatusPreconditionFailed)
+	delivered := lifecycle(idemDrone.SerialNumber, dto.DeliveryActionDelivered).Status(httptest.StatusOK).JSON().Object()
+	delivered.Value("drone").Object().ValueEqual("state", dto.DELIVERED)
+	delivered.Value("delivery").Object().ValueEqual("id", delivery.Value("id").Raw()).ValueEqual("status", dto.DeliveryDelivered).
+		Value("deliveredAt").String().NotEmpty()
+	loadedItems(idemDrone.SerialNumber).Empty()
+	lifecycle(idemDrone.SerialNumber, dto.DeliveryActionDispatch).Status(httptest.StatusConflict)
+	lifecycle(idemDrone.SerialNumber, dto.DeliveryActionReturned).Status(httptest.StatusConflict).JSON(problemJSON).Object().
+		ValueEqual("code", schema.ErrDroneInvalidTransitionKey)
+	lifecycle(idemDrone.SerialNumber, dto.DeliveryActionReturn).Status(httptest.StatusOK).JSON().Object().
+		Value("drone").Object().ValueEqual("state", dto.RETURNING)
+	returned := lifecycle(idemDrone.SerialNumber, dto.DeliveryActionReturned).Status(httptest.StatusOK).JSON().Object()
+	returned.Value("drone").Object().ValueEqual("state", dto.IDLE)
+	returned.Value("delivery").Object().ValueEqual("status", dto.DeliveryDelivered).Value("returnedAt").String().NotEmpty()
+	lifecycle(idemDrone.SerialNumber, dto.DeliveryActionDispatch).Status(httptest.StatusConflict).JSON(problemJSON).Object().
+		ValueEqual("code", schema.ErrDroneInvalidTransitionKey)
+	e.POST("/api/v1/medications/items/"+idemDrone.SerialNumber).WithHeader("Authorization", "Bearer "+token).
+		WithJSON([]string{lightestCode}).Expect().Status(httptest.StatusNoContent)
+	e.GET("/api/v1/drones/"+idemDrone.SerialNumber).WithHeader("Authorization", "Bearer "+token).
+		Expect().Status(httptest.StatusOK).JSON().Object().ValueEqual("state", dto.LOADED)
+	lifecycle(idemDrone.SerialNumber, dto.DeliveryActionDispatch).Status(httptest.StatusOK)
+	aborted := lifecycle(idemDrone.SerialNumber, dto.DeliveryActionAbort).Status(httptest.StatusOK).JSON().Object()
+	aborted.Value("drone").Object().ValueEqual("state", dto.RETURNING)
+	aborted.Value("delivery").Object().ValueEqual("status", dto.DeliveryAborted).Value("abortedAt").String().NotEmpty()
+	lifecycle(idemDrone.SerialNumber, dto.DeliveryActionDelivered).Status(httptest.StatusConflict)
+	lifecycle(idemDrone.SerialNumber, dto.DeliveryActionReturned).Status(httptest.StatusOK).JSON().Object().
+		Value("drone").Object().ValueEqual("state", dto.LOADED)
+	loadedItems(idemDrone.SerialNumber).Length().Equal(1)
+	lifecycle(lib.GenerateUUIDStr(), dto.DeliveryActionDispatch).Status(httptest.StatusNotFound)
+
+	// delivery history of the drone and of the medication, filtered by dispatch time and in CSV
+	deliveries := func(path string, query map[string]string) *httpexpect.Response {
+		req := e.GET(path).WithHeader("Authorization", "Bearer "+token)
+		for k, v := range query {
+			req = req.WithQuery(k, v)
+		}
+		return req.Expect()
+	}
+	droneHistory := deliveries("/api/v1/drones/"+idemDrone.SerialNumber+"/deliveries", nil).Status(httptest.StatusOK).JSON().Array()
+	droneHistory.Length().Equal(2)
+	droneHistory.Path("$[*].status").Array().ContainsOnly(dto.DeliveryDelivered, dto.DeliveryAborted)
+	deliveries("/api/v1/medications/"+lightestCode+"/deliveries", nil).Status(httptest.StatusOK).JSON().Array().
+		Path("$[*].serialNumber").Array().Contains(idemDrone.SerialNumber)
+	deliveries("/api/v1/medications/"+lightestCode+"/deliveries", map[string]string{"from": "2000-01-01T00:00:00Z", "to": "2000-12-31T23:59:59Z"}).
+		Status(httptest.StatusOK).JSON().Array().Empty()
+	deliveries("/api/v1/drones/"+idemDrone.SerialNumber+"/deliveries", map[string]string{"from": "2000-01-01T00:00:00+02:00"}).
+		Status(httptest.StatusOK).JSON().Array().Length().Equal(2)
+	deliveries("/api/v1/drones/"+idemDrone.SerialNumber+"/deliveries", map[string]string{"from": "yesterday"}).
+		Status(httptest.StatusBadRequest).JSON(problemJSON).Object().ValueEqual("code", schema.ErrParamURL)
+	deliveries("/api/v1/drones/"+idemDrone.SerialNumber+"/deliveries", map[string]string{"from": "2001-01-01T00:00:00Z", "to": "2000-01-01T00:00:00Z"}).
+		Status(httptest.StatusBadRequest)
+	deliveries("/api/v1/drones/"+lib.GenerateUUIDStr()+"/deliveries", nil).Status(httptest.StatusNotFound)
+	deliveries("/api/v1/medications/UNKNOWN_CODE/deliveries", nil).Status(httptest.StatusOK).JSON().Array().Empty()
+	e.GET("/api/v1/drones/"+idemDrone.SerialNumber+"/deliveries").WithHeader("Authorization", "Bearer "+token).
+		WithHeader("Accept", "text/csv").Expect().Status(httptest.StatusOK).ContentType("text/csv").Body().
+		Contains("dispatchedAt,id,serialNumber,medication,status,deliveredAt,abortedAt,returnedAt\n").Contains("," + lightestCode + ",delivered,")
+	e.GET("/api/v1/audit").WithHeader("Authorization", "Bearer "+token).WithQuery("target", idemDrone.SerialNumber).
+		WithQuery("action", dto.AuditActionDispatchDrone).Expect().Status(httptest.StatusOK).JSON().Array().Length().Equal(2)
+
+	if code := runCLI([]string{"logs", "tail", "-n", "1"}, nil, &out, &errOut); code != 0 {
+		t.Errorf("logs tail must succeed, got %d: %s", code, errOut.String())
+	}
+	// seeding: populate only once, an admin reset with a seed generates the same data, fixtures and the dev mode guard
+	e.POST("/api/v1/database/populate").Expect().Status(httptest.StatusUnauthorized)
+	e.POST("/api/v1/database/populate").WithHeader("Authorization", "Bearer "+cliToken).Expect().Status(httptest.StatusConflict)
+	e.POST("/api/v1/database/populate").WithHeader("Authorization", "Bearer "+cliToken).WithQuery("seed", "abc").
+		Expect().Status(httptest.StatusBadRequest)
+	e.POST("/api/v1/admin/database/reset").Expect().Status(httptest.StatusUnauthorized)
+	e.POST("/api/v1/admin/database/reset").WithHeader("Authorization", "Bearer "+cliToken).
+		Expect().Status(httptest.StatusForbidden).JSON(problemJSON).Object().ValueEqual("code", schema.ErrForbidden)
+	medicationCodes := func(seed int) string {
+		e.POST("/api/v1/admin/database/reset").WithHeader("Authorization", "Bearer "+token).WithQuery("seed", seed).
+			Expect().Status(httptest.StatusOK).JSON().Object().ValueEqual("seed", seed).ValueEqual("drones", 10)
+		codes := make([]string, 0)
+		for _, m := range e.GET("/api/v1/medications").WithHeader("Authorization", "Bearer "+token).
+			Expect().Status(httptest.StatusOK).JSON().Array().Iter() {
+			codes = append(codes, m.Object().Value("code").String().Raw())
+		}
+		return strings.Join(codes, ",")
+	}
+	if first, again, other := medicationCodes(42), medicationCodes(42), medicationCodes(7); first != again || first == other {
+		t.Errorf("the same seed must generate the same medications and another seed different ones: %s, %s, %s", first, again, other)
+	}
+	fixtureFile := filepath.Join(t.TempDir(), "seed.yaml")
+	fixture := `users:
+  - username: fixture.user@example.com
+    name: Fixture User
+    password: fixture-password
+drones:
+  - serialNumber: FIXTURE-01
+    model: 3
+    batteryCapacity: 80
+    state: 0
+medications:
+  - name: Fixture_Med
+    weight: 120
+    code: FIXTURE_MED
+payloads:
+  FIXTURE-01: [FIXTURE_MED]
+`
+	if err := os.WriteFile(fixtureFile, []byte(fixture), 0o600); err != nil {
+		t.Fatalf("error writing the fixture: %s", err)
+	}
+	out.Reset()
+	if code := runCLI([]string{"db", "reset", "-file", fixtureFile, "-json"}, nil, &out, &errOut); code != 0 {
+		t.Errorf("db reset -file must succeed, got %d: %s", code, errOut.String())
+	}
+	var seedReport dto.SeedReport
+	if err := json.Unmarshal(out.Bytes(), &seedReport); err != nil || seedReport.Source != dto.SeedSourceFixture || seedReport.Users != 1 || seedReport.Drones != 1 {
+		t.Errorf("db reset -file must report the fixture, got %+v: %v", seedReport, err)
+	}
+	e.POST("/api/v1/auth").WithJSON(dto.UserCredIn{Username: "fixture.user@example.com", Password: "fixture-password"}).
+		Expect().Status(httptest.StatusOK)
+	if err := os.WriteFile(fixtureFile, []byte("drones:\n  - serialNumber: FIXTURE-01\n    model: 9\n"), 0o600); err != nil {
+		t.Fatalf("error writing the fixture: %s", err)
+	}
+	if code := runCLI([]string{"db", "reset", "-file", fixtureFile}, nil, &out, &errOut); code != 1 {
+		t.Errorf("db reset must refuse an invalid fixture, got %d", code)
+	}
+	_ = os.Setenv("SERVER_DEV_MODE", "false")
+	if code := runCLI([]string{"db", "reset", "-seed", "42"}, nil, &out, &errOut); code != 1 {
+		t.Errorf("db reset must be refused outside development mode, got %d", code)
+	}
+	_ = os.Unsetenv("SERVER_DEV_MODE")
+	svc.config.DevMode = false
+	e.POST("/api/v1/database/populate").WithHeader("Authorization", "Bearer "+token).Expect().Status(httptest.StatusForbidden).
+		JSON(problemJSON).Object().ValueEqual("code", schema.ErrSeedDisabled)
+	svc.config.DevMode = true
+	if code := runCLI([]string{"db", "reset", "-seed", "42"}, nil, &out, &errOut); code != 0 {
+		t.Errorf("db reset -seed must succeed, got %d: %s", code, errOut.String())
+	}
+
+	// schema migrations: a user under the legacy integer key is rekeyed, a dry run only reports it
+	legacyUser := dto.User{Username: gofakeit.Email(), Name: "Legacy User", Passphrase: "0b14d501a594442a01c6859541bcb3e8164d183d32937b851835442f69d5c94e"} // password1
+	if err := writeLegacyUser(repo, strconv.Itoa(gofakeit.Number(1000, 999999)), legacyUser); err != nil {
+		t.Fatalf("error writing a legacy user: %s", err)
+	}
+	out.Reset()
+	if code := runCLI([]string{"db", "migrate", "-dry-run", "-json"}, nil, &out, &errOut); code != 0 {
+		t.Errorf("db migrate -dry-run must succeed, got %d: %s", code, errOut.String())
+	}
+	var report dto.MigrationReport
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil || !report.DryRun || len(report.Migrations) != 2 ||
+		len(report.Migrations[0].Changes) != 1 || report.FromVersion != 0 || report.ToVersion != 2 {
+		t.Errorf("db migrate -dry-run must report the rekey of the legacy user, got %+v: %v", report, err)
+	}
+	e.POST("/api/v1/auth").WithJSON(dto.UserCredIn{Username: legacyUser.Username, Password: "password1"}).
+		Expect().Status(httptest.StatusUnauthorized)
+	if code := runCLI([]string{"db", "migrate"}, nil, &out, &errOut); code != 0 {
+		t.Errorf("db migrate must succeed, got %d: %s", code, errOut.String())
+	}
+	e.POST("/api/v1/auth").WithJSON(dto.UserCredIn{Username: legacyUser.Username, Password: "password1"}).
+		Expect().Status(httptest.StatusOK)
+	out.Reset()
+	if code := runCLI([]string{"db", "migrate", "-json"}, nil, &out, &errOut); code != 0 {
+		t.Errorf("db migrate must be idempotent, got %d: %s", code, errOut.String())
+	}
+	report = dto.MigrationReport{}
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil || len(report.Migrations) != 0 || report.ToVersion != 2 {
+		t.Errorf("a migrated database must be up to date, got %+v: %v", report, err)
+	}
+	if code := runCLI([]string{"drones", "fly"}, nil, &out, &errOut); code != 2 {
+		t.Errorf("an unknown command must fail with the usage, got %d", code)
+	}
+
+	// shutdown: the scheduler is stopped and no database handle can be opened afterwards
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := svc.cronJob.StopCronJob(shutdownCtx); err != nil {
+		t.Errorf("error stopping the cron job: %s", err)
+	}
+	if svc.cronJob.SchedulerStatus().Running {
+		t.Errorf("the cron scheduler must be stopped")
+	}
+	if err := db.CloseDatabases(shutdownCtx); err != nil {
+		t.Errorf("error closing the databases: %s", err)
+	}
+	if err := repo.Ping(context.Background()); err != schema.ErrShuttingDown {
+		t.Errorf("opening a database after the shutdown must fail with '%s', got '%v'", schema.ErrShuttingDown, err)
+	}
+}
+
+// writeLegacyUser write a user the way the store database did before the schema migrations: under a bare
+// integer key and with a config record without schema version. The store is open by the server, so the
+// user is written to a snapshot of it that is restored
+func writeLegacyUser(repo db.RepoDrones, key string, user dto.User) error {
+	var snapshot bytes.Buffer
+	if err := repo.Snapshot(context.Background(), &snapshot); err != nil {
+		return err
+	}
+	store, err := buntdb.Open(":memory:")
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	if err := store.Load(&snapshot); err != nil {
+		return err
+	}
+	err = store.Update(func(tx *buntdb.Tx) error {
+		value, err := json.Marshal(user)
+		if err != nil {
+			return err
+		}
+		if _, _, err := tx.Set(key, string(value), nil); err != nil {
+			return err
+		}
+		_, _, err = tx.Set("config", `{"isPopulated":true}`, nil)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := store.Save(&snapshot); err != nil {
+		return err
+	}
+	return repo.RestoreSnapshot(context.Background(), &snapshot)
+}
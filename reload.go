@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/kataras/iris/v12"
+)
+
+// configPollInterval how often the config file is checked for changes
+const configPollInterval = 5 * time.Second
+
+// watchConfig reload the configuration on SIGHUP or when the config file changes, until the process exits
+//
+// - app [*iris.Application] ~ Iris App instance
+//
+// - svc [*appServices] ~ Services created by newApp
+func watchConfig(app *iris.Application, svc *appServices) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	ticker := time.NewTicker(configPollInterval)
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-hup:
+				reloadConfig(app, svc, "SIGHUP received")
+			case <-ticker.C:
+				if svc.config.FileChanged() {
+					reloadConfig(app, svc, "config file changed")
+				}
+			}
+		}
+	}()
+}
+
+// reloadConfig read the configuration again and apply the reloadable settings: the debug details of
+// the errors, the log level, the interval of the cron job and the thresholds. An invalid configuration
+// is logged and the current one is kept
+//
+// - app [*iris.Application] ~ Iris App instance
+//
+// - svc [*appServices] ~ Services created by newApp
+//
+// - reason [string] ~ What triggered the reload, for the logs
+func reloadConfig(app *iris.Application, svc *appServices, reason string) {
+	ctx := context.Background()
+	previous, ignored, err := svc.config.Reload()
+	if err != nil {
+		svc.logger.Errorf(ctx, "%s, the configuration could not be reloaded, the current one is kept: %s", reason, err)
+		return
+	}
+	if len(ignored) > 0 {
+		svc.logger.Warnf(ctx, "%s, the changes of %s require a restart", reason, strings.Join(ignored, ", "))
+	}
+
+	current := svc.config.Reloadable()
+	if current.LogLevel != previous.LogLevel {
+		svc.logger.SetLevel(current.LogLevel)
+		app.Logger().SetLevel(current.LogLevel)
+	}
+	if current.EveryTime != previous.EveryTime {
+		if err := svc.cronJob.RescheduleCronJob(current.EveryTime); err != nil {
+			svc.logger.Errorf(ctx, "the cron job could not be rescheduled: %s", err)
+		}
+	}
+	svc.logger.Infof(ctx, "%s, the configuration has been reloaded from '%s'", reason, svc.config.Path)
+}
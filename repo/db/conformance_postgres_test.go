@@ -0,0 +1,106 @@
+//go:build postgres
+// +build postgres
+
+package db_test
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// envTestPostgresBin directory of the PostgreSQL binaries (initdb, pg_ctl) of the local server, by default
+// they are looked up in the PATH and in /usr/lib/postgresql/<version>/bin
+const envTestPostgresBin = "SERVER_TEST_POSTGRES_BIN"
+
+// TestMain with the postgres build tag the conformance suite never skips the postgres backend: without
+// SERVER_TEST_POSTGRES_DSN a local server is started in a temporary directory and stopped at the end
+func TestMain(m *testing.M) {
+	if os.Getenv(envTestPostgresDSN) != "" {
+		os.Exit(m.Run())
+	}
+	dsn, stop, err := startLocalPostgres()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "local postgres: %s\n", err)
+		os.Exit(1)
+	}
+	_ = os.Setenv(envTestPostgresDSN, dsn)
+	code := m.Run()
+	stop()
+	os.Exit(code)
+}
+
+// startLocalPostgres initialize a cluster in a temporary directory and start it on a free port, trusting
+// the local connections. It returns the DSN of its postgres database and the function that stops it
+func startLocalPostgres() (string, func(), error) {
+	bin, err := postgresBinDir()
+	if err != nil {
+		return "", nil, err
+	}
+	dir, err := os.MkdirTemp("", "drones-postgres-")
+	if err != nil {
+		return "", nil, err
+	}
+	data := filepath.Join(dir, "data")
+	port, err := freePort()
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return "", nil, err
+	}
+
+	run := func(name string, args ...string) error {
+		out, err := exec.Command(filepath.Join(bin, name), args...).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%s: %w\n%s", name, err, out)
+		}
+		return nil
+	}
+	if err := run("initdb", "-D", data, "-U", "drones", "--auth=trust", "-E", "UTF8"); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", nil, err
+	}
+	options := fmt.Sprintf("-p %d -k %s -c listen_addresses=127.0.0.1 -c fsync=off", port, dir)
+	if err := run("pg_ctl", "-D", data, "-o", options, "-l", filepath.Join(dir, "postgres.log"), "-w", "start"); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", nil, err
+	}
+
+	stop := func() {
+		if err := run("pg_ctl", "-D", data, "-m", "fast", "-w", "stop"); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		_ = os.RemoveAll(dir)
+	}
+	return fmt.Sprintf("postgres://drones@127.0.0.1:%d/postgres?sslmode=disable", port), stop, nil
+}
+
+// postgresBinDir the directory of initdb and pg_ctl: SERVER_TEST_POSTGRES_BIN, the PATH or the last
+// version (in lexical order) installed by the Debian and Ubuntu packages
+func postgresBinDir() (string, error) {
+	if bin := os.Getenv(envTestPostgresBin); bin != "" {
+		return bin, nil
+	}
+	if initdb, err := exec.LookPath("initdb"); err == nil {
+		return filepath.Dir(initdb), nil
+	}
+	installed, _ := filepath.Glob("/usr/lib/postgresql/*/bin/initdb")
+	if len(installed) == 0 {
+		return "", fmt.Errorf("initdb not found, install PostgreSQL or set %s or %s", envTestPostgresBin, envTestPostgresDSN)
+	}
+	sort.Strings(installed)
+	return filepath.Dir(installed[len(installed)-1]), nil
+}
+
+// freePort a TCP port of the loopback interface that is free now
+func freePort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
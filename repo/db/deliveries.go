@@ -0,0 +1,211 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/kmilodenisglez/drones.restapi/lib"
+	"github.com/kmilodenisglez/drones.restapi/schema"
+	"github.com/kmilodenisglez/drones.restapi/schema/dto"
+	"github.com/tidwall/buntdb"
+)
+
+// TransitionError returned by every store backend when a lifecycle action is not allowed in the state of
+// the drone, it wraps schema.ErrDroneInvalidTransition
+type TransitionError struct {
+	Action string
+	State  dto.DroneState
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("the '%s' action is not allowed for a drone in %s state", e.Action, e.State)
+}
+
+func (e *TransitionError) Unwrap() error {
+	return schema.ErrDroneInvalidTransition
+}
+
+// deliveryTransition the states a drone may be in for a lifecycle action and the state it moves to
+type deliveryTransition struct {
+	from []dto.DroneState
+	to   dto.DroneState
+}
+
+// deliveryTransitions the lifecycle of a loaded drone: LOADED -> DELIVERING -> DELIVERED -> RETURNING -> IDLE,
+// or DELIVERING -> RETURNING -> LOADED when the delivery is aborted. The drones are moved to LOADED by loading
+// them, the state is never changed by an update
+var deliveryTransitions = map[string]deliveryTransition{
+	dto.DeliveryActionDispatch:  {[]dto.DroneState{dto.LOADED}, dto.DELIVERING},
+	dto.DeliveryActionDelivered: {[]dto.DroneState{dto.DELIVERING}, dto.DELIVERED},
+	dto.DeliveryActionReturn:    {[]dto.DroneState{dto.DELIVERED}, dto.RETURNING},
+	dto.DeliveryActionReturned:  {[]dto.DroneState{dto.RETURNING}, dto.IDLE},
+	dto.DeliveryActionAbort:     {[]dto.DroneState{dto.DELIVERING}, dto.RETURNING},
+}
+
+// advanceDrone apply a lifecycle action to a drone in service and increment its version. It returns the
+// delivery to write: a new one on dispatch, the active one updated otherwise. A drone seeded or imported in
+// DELIVERING state has no active delivery, it is created from the medications on board at the time of its
+// first action; nil is only returned when a drone seeded after its delivery returns
+//
+// - payload [[]string] ~ Codes of the medications loaded on the drone
+//
+// - active [*dto.Delivery] ~ Delivery of the drone not returned yet, nil if there is none
+func advanceDrone(drone *dto.Drone, action string, payload []string, active *dto.Delivery) (*dto.Delivery, error) {
+	transition, ok := deliveryTransitions[action]
+	if !ok {
+		return nil, fmt.Errorf("unknown lifecycle action '%s'", action)
+	}
+	if !containsState(transition.from, drone.State) {
+		return nil, &TransitionError{Action: action, State: drone.State}
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	delivery := active
+	if action == dto.DeliveryActionDispatch {
+		if len(payload) == 0 {
+			return nil, schema.ErrDroneNotLoaded
+		}
+		delivery = &dto.Delivery{DispatchedAt: now}
+	}
+	if delivery == nil && drone.State == dto.DELIVERING {
+		delivery = &dto.Delivery{DispatchedAt: now}
+	}
+	if delivery != nil && delivery.ID == "" {
+		delivery.ID = lib.GenerateUUIDStr()
+		delivery.SerialNumber = drone.SerialNumber
+		delivery.Medications = append(make([]string, 0, len(payload)), payload...)
+		delivery.Status = dto.DeliveryInTransit
+	}
+
+	switch action {
+	case dto.DeliveryActionDelivered:
+		delivery.Status, delivery.DeliveredAt = dto.DeliveryDelivered, now
+	case dto.DeliveryActionAbort:
+		delivery.Status, delivery.AbortedAt = dto.DeliveryAborted, now
+	case dto.DeliveryActionReturned:
+		if delivery != nil {
+			delivery.ReturnedAt = now
+		}
+	}
+	drone.State = transition.to
+	// the medications of an aborted delivery are still on board when the drone is back
+	if action == dto.DeliveryActionReturned && len(payload) > 0 {
+		drone.State = dto.LOADED
+	}
+	drone.Version++
+	return delivery, nil
+}
+
+// sortDeliveries sort the deliveries by dispatch time, the ties by ID, like the postgres backend
+func sortDeliveries(deliveries []dto.Delivery) {
+	sort.SliceStable(deliveries, func(i, j int) bool {
+		if deliveries[i].DispatchedAt != deliveries[j].DispatchedAt {
+			return deliveries[i].DispatchedAt < deliveries[j].DispatchedAt
+		}
+		return deliveries[i].ID < deliveries[j].ID
+	})
+}
+
+// deliveryRange the time range of a filter in the format of the delivery timestamps (RFC 3339, UTC, in
+// seconds), so both backends compare them as strings. An empty bound is returned empty
+func deliveryRange(filter *dto.DeliveryFilter) (from, to string, err error) {
+	if filter.From != "" {
+		t, err := time.Parse(time.RFC3339, filter.From)
+		if err != nil {
+			return "", "", err
+		}
+		// a bound with a fraction of second doesn't include the second it starts
+		if t.Nanosecond() > 0 {
+			t = t.Truncate(time.Second).Add(time.Second)
+		}
+		from = t.UTC().Format(time.RFC3339)
+	}
+	if filter.To != "" {
+		t, err := time.Parse(time.RFC3339, filter.To)
+		if err != nil {
+			return "", "", err
+		}
+		to = t.UTC().Truncate(time.Second).Format(time.RFC3339)
+	}
+	return from, to, nil
+}
+
+// matchDelivery check a delivery against a filter, the range bounds are the ones of deliveryRange
+func matchDelivery(delivery *dto.Delivery, filter *dto.DeliveryFilter, from, to string) bool {
+	if filter.SerialNumber != "" && delivery.SerialNumber != filter.SerialNumber {
+		return false
+	}
+	if filter.MedicationCode != "" && !lib.Contains(delivery.Medications, filter.MedicationCode) {
+		return false
+	}
+	if from == "" && to == "" {
+		return true
+	}
+	return delivery.DispatchedAt != "" && delivery.DispatchedAt >= from && (to == "" || delivery.DispatchedAt <= to)
+}
+
+// region ======== BUNTDB ================================================================
+
+// deliveryKeyPrefix prefix of the delivery keys, followed by the delivery ID
+const deliveryKeyPrefix = "delivery:"
+
+// idxDeliveryDrone index of the deliveries by drone serial number, case sensitive like the keys
+const idxDeliveryDrone = "delivery_drone"
+
+func createDeliveryIndexes(db *buntdb.DB) error {
+	if err := db.CreateIndex(idxDeliveryDrone, deliveryKeyPrefix+"*", buntdb.IndexJSONCaseSensitive("serialNumber")); err != nil && err != buntdb.ErrIndexExists {
+		return err
+	}
+	return nil
+}
+
+// droneDeliveries the deliveries of a drone, through the idxDeliveryDrone index
+func droneDeliveries(tx *buntdb.Tx, serialNumber string) ([]dto.Delivery, error) {
+	pivot, err := jsoniter.MarshalToString(map[string]string{"serialNumber": serialNumber})
+	if err != nil {
+		return nil, err
+	}
+	deliveries := make([]dto.Delivery, 0)
+	var errUnmarshal error
+	err = tx.AscendEqual(idxDeliveryDrone, pivot, func(key, value string) bool {
+		delivery := dto.Delivery{}
+		errUnmarshal = jsoniter.UnmarshalFromString(value, &delivery)
+		deliveries = append(deliveries, delivery)
+		return errUnmarshal == nil
+	})
+	if err != nil || errUnmarshal != nil {
+		return nil, firstError(err, errUnmarshal)
+	}
+	sortDeliveries(deliveries)
+	return deliveries, nil
+}
+
+// activeDelivery the delivery of a drone that has not returned yet, nil if there is none
+func activeDelivery(tx *buntdb.Tx, serialNumber string) (*dto.Delivery, error) {
+	deliveries, err := droneDeliveries(tx, serialNumber)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(deliveries) - 1; i >= 0; i-- {
+		if deliveries[i].ReturnedAt == "" {
+			return &deliveries[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// loadedMedications the codes of the medications loaded on a drone, empty if it has not been loaded
+func loadedMedications(tx *buntdb.Tx, serialNumber string) ([]string, error) {
+	codes := make([]string, 0)
+	value, err := tx.Get("loaded_medications:" + serialNumber)
+	if err == buntdb.ErrNotFound {
+		return codes, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return codes, jsoniter.UnmarshalFromString(value, &codes)
+}
+
+// endregion =============================================================================
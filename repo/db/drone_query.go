@@ -0,0 +1,327 @@
+package db
+
+import (
+	"encoding/base64"
+	"sort"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/kmilodenisglez/drones.restapi/lib"
+	"github.com/kmilodenisglez/drones.restapi/schema"
+	"github.com/kmilodenisglez/drones.restapi/schema/dto"
+	"github.com/tidwall/buntdb"
+)
+
+// droneCursor keyset position of the last drone of a page, it is bound to the sort it was issued for
+type droneCursor struct {
+	SortBy string  `json:"f"`
+	Desc   bool    `json:"d"`
+	Value  float64 `json:"v"`
+	Serial string  `json:"s"`
+}
+
+// matchDroneFilter check the typed criteria of the filter against a drone
+func matchDroneFilter(drone *dto.Drone, filter *dto.DroneFilter, loadedWeights map[string]float64) bool {
+	if drone.Retired != filter.Retired {
+		return false
+	}
+	if len(filter.States) > 0 && !containsState(filter.States, drone.State) {
+		return false
+	}
+	if len(filter.Models) > 0 && !containsModel(filter.Models, drone.Model) {
+		return false
+	}
+	if filter.BatteryMin != nil && drone.BatteryCapacity < *filter.BatteryMin {
+		return false
+	}
+	if filter.BatteryMax != nil && drone.BatteryCapacity > *filter.BatteryMax {
+		return false
+	}
+	if filter.MinAvailableWeight != nil && drone.WeightLimit-loadedWeights[drone.SerialNumber] < *filter.MinAvailableWeight {
+		return false
+	}
+	return true
+}
+
+// loadedWeightByDrone total weight of the medications loaded by every drone
+func loadedWeightByDrone(tx *buntdb.Tx) (map[string]float64, error) {
+	medicationWeights := make(map[string]float64)
+	var errIter error
+	err := tx.AscendKeys("med:*", func(key, value string) bool {
+		medication := dto.Medication{}
+		if errIter = jsoniter.UnmarshalFromString(value, &medication); errIter != nil {
+			return false
+		}
+		medicationWeights[medication.Code] = medication.Weight
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if errIter != nil {
+		return nil, errIter
+	}
+
+	loadedWeights := make(map[string]float64)
+	err = tx.AscendKeys("loaded_medications:*", func(key, value string) bool {
+		loadedMeds := make([]string, 0)
+		if errIter = jsoniter.UnmarshalFromString(value, &loadedMeds); errIter != nil {
+			return false
+		}
+		serialNumber := strings.TrimPrefix(key, "loaded_medications:")
+		for _, code := range loadedMeds {
+			loadedWeights[serialNumber] += medicationWeights[code]
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return loadedWeights, errIter
+}
+
+// fleetStatsBuilder accumulate the fleet summary drone by drone, it is shared by the store backends
+type fleetStatsBuilder struct {
+	stats        dto.FleetStats
+	minBattery   float64 // minimum battery level to load a drone
+	totalBattery float64
+}
+
+func newFleetStatsBuilder(minBattery float64) *fleetStatsBuilder {
+	b := fleetStatsBuilder{stats: dto.FleetStats{ByState: make(map[string]int), ByModel: make(map[string]int)}, minBattery: minBattery}
+	for state := dto.IDLE; state <= dto.RETURNING; state++ {
+		b.stats.ByState[state.String()] = 0
+	}
+	for model := dto.Lightweight; model <= dto.Heavyweight; model++ {
+		b.stats.ByModel[model.String()] = 0
+	}
+	return &b
+}
+
+// add count a drone, loaded is the weight of its medications
+func (b *fleetStatsBuilder) add(drone *dto.Drone, loaded float64) {
+	if drone.Retired {
+		b.stats.Retired++
+		return
+	}
+
+	b.stats.Total++
+	b.stats.ByState[drone.State.String()]++
+	b.stats.ByModel[drone.Model.String()]++
+	b.totalBattery += drone.BatteryCapacity
+	if b.stats.Total == 1 || drone.BatteryCapacity < b.stats.MinBattery {
+		b.stats.MinBattery = drone.BatteryCapacity
+	}
+	if drone.State == dto.IDLE && drone.BatteryCapacity >= b.minBattery {
+		b.stats.AvailableForLoading++
+	}
+	if loaded > 0 {
+		b.stats.ActiveLoads++
+	}
+	if free := drone.WeightLimit - loaded; free > 0 {
+		b.stats.FreeCapacity += free
+	}
+}
+
+func (b *fleetStatsBuilder) result() *dto.FleetStats {
+	if b.stats.Total > 0 {
+		b.stats.AverageBattery = b.totalBattery / float64(b.stats.Total)
+	}
+	return &b.stats
+}
+
+// validatePayload check that the medications exist and that the drone can carry them, the repeated IDs
+// are removed
+//
+// - medicationWeights [map[string]float64] ~ Weight of every medication by code
+func validatePayload(drone *dto.Drone, medicationWeights map[string]float64, medicationItemIDs []interface{}) ([]interface{}, error) {
+	// to guarantee non-repeated id
+	medicationItemIDs = lib.Unique(medicationItemIDs)
+
+	// compares the request IDs (medicationItemIDs) with the collection obtained from the database (medicationWeights)
+	// also returns the total weight
+	packedTotalWeight, allIDValid := thereAreAll(medicationWeights, medicationItemIDs)
+	if !allIDValid {
+		return nil, schema.ErrMedicationNotFound
+	}
+	if packedTotalWeight > drone.WeightLimit {
+		// prevent the drone from being loaded with more weight that it can carry
+		return nil, schema.ErrDroneMaximumLoadWeightExceeded
+	}
+	return medicationItemIDs, nil
+}
+
+// checkLoadable check that a drone can be loaded: in service, not on a trip and with at least minBattery. The
+// load transactions check the drone they read, the one the service checked may have changed since
+func checkLoadable(drone *dto.Drone, minBattery float64) error {
+	switch {
+	case drone.Retired:
+		return schema.ErrDroneRetired
+	case drone.BatteryCapacity < minBattery:
+		return &BatteryError{Level: drone.BatteryCapacity, Min: minBattery}
+	case !drone.State.Loadable():
+		return schema.ErrDroneBusy
+	}
+	return nil
+}
+
+// txDrone read a drone inside a transaction
+func txDrone(tx *buntdb.Tx, serialNumber string) (*dto.Drone, error) {
+	value, err := tx.Get("drone:" + serialNumber)
+	if err != nil {
+		return nil, err
+	}
+	drone := dto.Drone{}
+	if err = jsoniter.UnmarshalFromString(value, &drone); err != nil {
+		return nil, err
+	}
+	return &drone, nil
+}
+
+// loadedState the state of a drone after loading it: LOADED, or IDLE if it has been emptied
+func loadedState(medicationItemIDs []interface{}) dto.DroneState {
+	if len(medicationItemIDs) > 0 {
+		return dto.LOADED
+	}
+	return dto.IDLE
+}
+
+// txLoadedDrone write a drone that has just been loaded inside a transaction, with its loadedState and
+// the next version
+func txLoadedDrone(tx *buntdb.Tx, drone *dto.Drone, medicationItemIDs []interface{}) error {
+	drone.State = loadedState(medicationItemIDs)
+	drone.Version++
+	res, err := jsoniter.MarshalToString(drone)
+	if err != nil {
+		return err
+	}
+	_, _, err = tx.Set("drone:"+drone.SerialNumber, res, nil)
+	return err
+}
+
+// paginateDrones sort the drones and cut the page that follows the cursor
+func paginateDrones(drones []dto.Drone, filter *dto.DroneFilter) (*dto.DronePage, error) {
+	sortBy := filter.SortBy
+	if sortBy == "" {
+		sortBy = dto.DroneSortBattery
+	}
+	less := func(a, b *dto.Drone) bool {
+		va, vb := droneSortValue(a, sortBy), droneSortValue(b, sortBy)
+		if sortBy != dto.DroneSortSerialNumber && va != vb {
+			if filter.Desc {
+				return va > vb
+			}
+			return va < vb
+		}
+		// ties (and the serial number sort) are resolved by serial number, so the order is total
+		if filter.Desc && sortBy == dto.DroneSortSerialNumber {
+			return a.SerialNumber > b.SerialNumber
+		}
+		return a.SerialNumber < b.SerialNumber
+	}
+	sort.SliceStable(drones, func(i, j int) bool { return less(&drones[i], &drones[j]) })
+
+	start := 0
+	if filter.Cursor != "" {
+		cursor, err := decodeDroneCursor(filter.Cursor)
+		if err != nil || cursor.SortBy != sortBy || cursor.Desc != filter.Desc {
+			return nil, schema.ErrInvalidCursor
+		}
+		last := cursorDrone(cursor)
+		start = sort.Search(len(drones), func(i int) bool { return less(&last, &drones[i]) })
+	}
+
+	page := dto.DronePage{Items: drones[start:], Total: len(drones)}
+	if filter.Limit > 0 && len(page.Items) > filter.Limit {
+		page.Items = page.Items[:filter.Limit]
+		last := page.Items[len(page.Items)-1]
+		page.NextCursor = encodeDroneCursor(droneCursor{SortBy: sortBy, Desc: filter.Desc, Value: droneSortValue(&last, sortBy), Serial: last.SerialNumber})
+	}
+	return &page, nil
+}
+
+// droneSortValue numeric value of the sort field, the serial number sort only uses the key
+func droneSortValue(drone *dto.Drone, sortBy string) float64 {
+	switch sortBy {
+	case dto.DroneSortWeightLimit:
+		return drone.WeightLimit
+	case dto.DroneSortModel:
+		return float64(drone.Model)
+	case dto.DroneSortState:
+		return float64(drone.State)
+	case dto.DroneSortSerialNumber:
+		return 0
+	}
+	return drone.BatteryCapacity
+}
+
+// cursorDrone rebuild the sort key of the last drone of the previous page
+func cursorDrone(cursor *droneCursor) dto.Drone {
+	drone := dto.Drone{SerialNumber: cursor.Serial}
+	switch cursor.SortBy {
+	case dto.DroneSortWeightLimit:
+		drone.WeightLimit = cursor.Value
+	case dto.DroneSortModel:
+		drone.Model = dto.DroneModel(cursor.Value)
+	case dto.DroneSortState:
+		drone.State = dto.DroneState(cursor.Value)
+	default:
+		drone.BatteryCapacity = cursor.Value
+	}
+	return drone
+}
+
+func encodeDroneCursor(cursor droneCursor) string {
+	data, _ := jsoniter.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeDroneCursor(encoded string) (*droneCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	cursor := droneCursor{}
+	if err = jsoniter.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
+
+func containsState(states []dto.DroneState, state dto.DroneState) bool {
+	for _, s := range states {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+func containsModel(models []dto.DroneModel, model dto.DroneModel) bool {
+	for _, m := range models {
+		if m == model {
+			return true
+		}
+	}
+	return false
+}
+
+func uniqueStates(states []dto.DroneState) []dto.DroneState {
+	u := make([]dto.DroneState, 0, len(states))
+	for _, s := range states {
+		if !containsState(u, s) {
+			u = append(u, s)
+		}
+	}
+	return u
+}
+
+func uniqueModels(models []dto.DroneModel) []dto.DroneModel {
+	u := make([]dto.DroneModel, 0, len(models))
+	for _, m := range models {
+		if !containsModel(u, m) {
+			u = append(u, m)
+		}
+	}
+	return u
+}
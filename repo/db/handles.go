@@ -0,0 +1,188 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync"
+
+	"github.com/kmilodenisglez/drones.restapi/schema"
+	"github.com/tidwall/buntdb"
+)
+
+// ErrNotFound returned by every store backend when a record doesn't exist
+var ErrNotFound = buntdb.ErrNotFound
+
+// BatchError returned by every store backend when some items of a batch can't be written, then none of
+// them is written. The errors are indexed by the position of the item in the batch
+type BatchError struct {
+	Errors map[int]error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("%d item(s) of the batch can't be written", len(e.Errors))
+}
+
+// BatteryError returned by every store backend when the battery level of a drone is too low to load it,
+// it wraps schema.ErrDroneVeryLowBattery
+type BatteryError struct {
+	Level float64
+	Min   float64
+}
+
+func (e *BatteryError) Error() string {
+	return fmt.Sprintf("the battery level %.2f%% is below %.2f%%", e.Level, e.Min)
+}
+
+func (e *BatteryError) Unwrap() error {
+	return schema.ErrDroneVeryLowBattery
+}
+
+// region ======== HANDLES ===============================================================
+
+// handle buntdb database used by a repository operation. Every operation of a file shares the same
+// *buntdb.DB, opened once for the whole process, so their transactions are serialized by buntdb: a
+// check and the write that depends on it (e.g. the version of a drone) can't interleave with another
+// operation. Closing the handle only releases it, the files are closed by CloseDatabases
+type handle struct {
+	*buntdb.DB
+	release sync.Once
+}
+
+// handles the buntdb databases and the handles in use of every repository, so the shutdown can wait for
+// the writes in progress. The postgres connection pools are shared by the repositories, one per DSN
+var handles struct {
+	sync.Mutex
+	open    sync.WaitGroup
+	closing bool
+	dbs     map[string]*buntdb.DB
+	pools   map[string]*sql.DB
+}
+
+// openDB the database of a buntdb file, the file is opened (and created if it doesn't exist) the
+// first time. It fails with schema.ErrShuttingDown once CloseDatabases has been called
+func openDB(path string) (*handle, error) {
+	handles.Lock()
+	defer handles.Unlock()
+	if handles.closing {
+		return nil, schema.ErrShuttingDown
+	}
+
+	path = filepath.Clean(path)
+	db, ok := handles.dbs[path]
+	if !ok {
+		var err error
+		if db, err = buntdb.Open(path); err != nil {
+			return nil, err
+		}
+		if handles.dbs == nil {
+			handles.dbs = make(map[string]*buntdb.DB)
+		}
+		handles.dbs[path] = db
+	}
+	handles.open.Add(1)
+	return &handle{DB: db}, nil
+}
+
+// Close release the handle, the database stays open for the next operations
+func (h *handle) Close() error {
+	h.release.Do(handles.open.Done)
+	return nil
+}
+
+// CreateIndex create an index of the database, it is kept by the shared database so an index that
+// already exists is not an error
+func (h *handle) CreateIndex(name, pattern string, less ...func(a, b string) bool) error {
+	if err := h.DB.CreateIndex(name, pattern, less...); err != nil && err != buntdb.ErrIndexExists {
+		return err
+	}
+	return nil
+}
+
+// CloseDatabases refuse to open new database handles and wait until the open ones are closed, so
+// no write is cut in half, then sync and close the buntdb files and the postgres pools. It gives up
+// when the context is done
+//
+// - ctx [context.Context] ~ Context with the shutdown deadline
+func CloseDatabases(ctx context.Context) error {
+	handles.Lock()
+	handles.closing = true
+	handles.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		handles.open.Wait()
+		handles.Lock()
+		dbs, pools := handles.dbs, handles.pools
+		handles.dbs, handles.pools = nil, nil
+		handles.Unlock()
+		for _, db := range dbs {
+			_ = db.Close()
+		}
+		// closing a pool waits for the queries in progress
+		for _, pool := range pools {
+			_ = pool.Close()
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// endregion =============================================================================
+
+// region ======== SNAPSHOTS =============================================================
+
+// saveDB write a consistent snapshot of a database file while it stays online, the writes wait
+// until the snapshot has been written
+func saveDB(path string, w io.Writer) error {
+	db, err := openDB(path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.Save(w)
+}
+
+// restoreDB replace the content of a database file with a snapshot written by saveDB. The snapshot
+// is loaded in memory first, then the keys are replaced in a single transaction, so the readers see
+// either the old or the new content
+func restoreDB(path string, r io.Reader) error {
+	snapshot, err := buntdb.Open(":memory:")
+	if err != nil {
+		return err
+	}
+	defer snapshot.Close()
+	if err := snapshot.Load(r); err != nil {
+		return err
+	}
+
+	db, err := openDB(path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Update(func(tx *buntdb.Tx) error {
+		if err := tx.DeleteAll(); err != nil {
+			return err
+		}
+		return snapshot.View(func(stx *buntdb.Tx) error {
+			var errSet error
+			err := stx.Ascend("", func(key, value string) bool {
+				_, _, errSet = tx.Set(key, value, nil)
+				return errSet == nil
+			})
+			return firstError(err, errSet)
+		})
+	})
+}
+
+// endregion =============================================================================
@@ -0,0 +1,49 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/tidwall/buntdb"
+)
+
+// TestOpenDBShared two operations in flight on the same file see each other's writes, a check and the
+// write that depends on it can't be based on a stale copy of the file
+func TestOpenDBShared(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shared.db")
+	first, err := openDB(path)
+	if err != nil {
+		t.Fatalf("open: %s", err)
+	}
+	defer first.Close()
+	second, err := openDB(path)
+	if err != nil {
+		t.Fatalf("open: %s", err)
+	}
+	defer second.Close()
+
+	// both handles reserve the same key when it is missing, like RegisterDrone and ReserveIdempotencyKey
+	reserve := func(h *handle) (reserved bool, err error) {
+		err = h.Update(func(tx *buntdb.Tx) error {
+			if _, err := tx.Get("key"); err != buntdb.ErrNotFound {
+				return err
+			}
+			reserved = true
+			_, _, err := tx.Set("key", "value", nil)
+			return err
+		})
+		return reserved, err
+	}
+	if reserved, err := reserve(first); err != nil || !reserved {
+		t.Fatalf("the first handle must reserve the key, got %v (%v)", reserved, err)
+	}
+	if reserved, err := reserve(second); err != nil || reserved {
+		t.Fatalf("the second handle must find the key reserved, got %v (%v)", reserved, err)
+	}
+	if err := first.CreateIndex("keys", "*", buntdb.IndexString); err != nil {
+		t.Fatalf("create index: %s", err)
+	}
+	if err := second.CreateIndex("keys", "*", buntdb.IndexString); err != nil {
+		t.Fatalf("an index that exists must not fail, got %s", err)
+	}
+}
@@ -0,0 +1,183 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/kmilodenisglez/drones.restapi/schema"
+	"github.com/kmilodenisglez/drones.restapi/schema/dto"
+	"github.com/tidwall/buntdb"
+)
+
+// region ======== MIGRATIONS ============================================================
+
+// migration ordered change of the store database. The version is the schema version of the database
+// once it has run, a migration must be idempotent and only run inside the transaction it is given
+type migration struct {
+	version int
+	name    string
+	up      func(tx *buntdb.Tx) ([]string, error) // it returns a description of every change
+}
+
+// migrations the migrations of the store database, in order. New ones are appended with the next
+// version, the released ones are never edited
+var migrations = []migration{
+	{1, "rekey the users under the 'user:' prefix", migrateUserKeys},
+	{2, "move the loaded IDLE drones to LOADED", migrateLoadedDrones},
+}
+
+// errDryRun rolls back the transaction of a dry run once every migration has run
+var errDryRun = errors.New("dry run")
+
+// latestSchemaVersion the schema version of the store database written by this build
+func latestSchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// runMigrations run the migrations newer than the schema version of the database and record the new
+// version, all of them in the transaction. It fails with schema.ErrSchemaVersion if the database is
+// newer than this build
+func runMigrations(tx *buntdb.Tx, report *dto.MigrationReport) error {
+	config, err := readConfig(tx)
+	if err != nil {
+		return err
+	}
+	report.FromVersion, report.ToVersion = config.SchemaVersion, config.SchemaVersion
+	if config.SchemaVersion > latestSchemaVersion() {
+		return fmt.Errorf("%w: schema version %d, this build supports up to %d", schema.ErrSchemaVersion, config.SchemaVersion, latestSchemaVersion())
+	}
+
+	for _, m := range migrations {
+		if m.version <= config.SchemaVersion {
+			continue
+		}
+		changes, err := m.up(tx)
+		if err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+		}
+		report.Migrations = append(report.Migrations, dto.MigrationResult{Version: m.version, Name: m.name, Changes: changes})
+		report.ToVersion = m.version
+	}
+	if report.ToVersion == config.SchemaVersion {
+		return nil
+	}
+	config.SchemaVersion = report.ToVersion
+	return writeConfig(tx, config)
+}
+
+// migrateUserKeys version 1: the users were stored under bare integer keys ("0", "1", ...) that clash
+// with any other key scheme, they are moved to "user:<n>"
+func migrateUserKeys(tx *buntdb.Tx) ([]string, error) {
+	ids := make([]int, 0)
+	values := make(map[int]string)
+	err := tx.AscendKeys("*", func(key, value string) bool {
+		if id, err := strconv.Atoi(key); err == nil {
+			ids = append(ids, id)
+			values[id] = value
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Ints(ids) // the keys are ascended as strings: "10" < "2"
+
+	changes := make([]string, 0, len(ids))
+	for _, id := range ids {
+		user := dto.User{}
+		if err := jsoniter.UnmarshalFromString(values[id], &user); err != nil {
+			return nil, fmt.Errorf("key '%d' is not a user: %w", id, err)
+		}
+		if _, err := tx.Get(userKey(id)); err == nil {
+			return nil, fmt.Errorf("both '%d' and '%s' exist", id, userKey(id))
+		}
+		if _, _, err := tx.Set(userKey(id), values[id], nil); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Delete(strconv.Itoa(id)); err != nil {
+			return nil, err
+		}
+		changes = append(changes, fmt.Sprintf("%d -> %s (%s)", id, userKey(id), user.Username))
+	}
+	return changes, nil
+}
+
+// migrateLoadedDrones version 2: the drones were left IDLE when they were loaded, the ones that carry
+// medications are moved to LOADED, the state they are dispatched from
+func migrateLoadedDrones(tx *buntdb.Tx) ([]string, error) {
+	drones := make([]dto.Drone, 0)
+	var errUnmarshal error
+	err := tx.AscendKeys("drone:*", func(key, value string) bool {
+		drone := dto.Drone{}
+		if errUnmarshal = jsoniter.UnmarshalFromString(value, &drone); errUnmarshal != nil {
+			return false
+		}
+		if drone.State == dto.IDLE {
+			drones = append(drones, drone)
+		}
+		return true
+	})
+	if err != nil || errUnmarshal != nil {
+		return nil, firstError(err, errUnmarshal)
+	}
+
+	changes := make([]string, 0)
+	for i := range drones {
+		drone := &drones[i]
+		payload, err := loadedMedications(tx, drone.SerialNumber)
+		if err != nil {
+			return nil, err
+		}
+		if len(payload) == 0 {
+			continue
+		}
+		drone.State = dto.LOADED
+		drone.Version++
+		res, err := jsoniter.MarshalToString(drone)
+		if err != nil {
+			return nil, err
+		}
+		if _, _, err := tx.Set("drone:"+drone.SerialNumber, res, nil); err != nil {
+			return nil, err
+		}
+		changes = append(changes, fmt.Sprintf("%s: IDLE -> LOADED (%d medications)", drone.SerialNumber, len(payload)))
+	}
+	return changes, nil
+}
+
+// endregion =============================================================================
+
+// region ======== PRIVATE AUX ===========================================================
+
+// userKeyPrefix prefix of the user keys, followed by an integer
+const userKeyPrefix = "user:"
+
+func userKey(id int) string {
+	return userKeyPrefix + strconv.Itoa(id)
+}
+
+// readConfig the config record of the store database, the zero value if it doesn't exist yet
+func readConfig(tx *buntdb.Tx) (dto.ConfigDB, error) {
+	config := dto.ConfigDB{}
+	value, err := tx.Get("config")
+	if err == buntdb.ErrNotFound {
+		return config, nil
+	} else if err != nil {
+		return config, err
+	}
+	return config, jsoniter.UnmarshalFromString(value, &config)
+}
+
+func writeConfig(tx *buntdb.Tx, config dto.ConfigDB) error {
+	res, err := jsoniter.MarshalToString(config)
+	if err != nil {
+		return err
+	}
+	_, _, err = tx.Set("config", res, nil)
+	return err
+}
+
+// endregion =============================================================================
@@ -0,0 +1,53 @@
+-- Store and event log tables of the postgres backend. The payloads reference their drone and their
+-- medications, so a payload can't point to a record that doesn't exist
+
+CREATE TABLE users (
+    id         SERIAL PRIMARY KEY,
+    username   TEXT NOT NULL UNIQUE,
+    name       TEXT NOT NULL DEFAULT '',
+    passphrase TEXT NOT NULL
+);
+
+CREATE TABLE drones (
+    serial_number    VARCHAR(100) PRIMARY KEY,
+    model            SMALLINT NOT NULL CHECK (model BETWEEN 0 AND 3),
+    weight_limit     DOUBLE PRECISION NOT NULL,
+    battery_capacity DOUBLE PRECISION NOT NULL CHECK (battery_capacity BETWEEN 0 AND 100),
+    state            SMALLINT NOT NULL CHECK (state BETWEEN 0 AND 5),
+    version          BIGINT NOT NULL DEFAULT 1,
+    retired          BOOLEAN NOT NULL DEFAULT FALSE,
+    retired_at       TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX drones_state_idx ON drones (state) WHERE NOT retired;
+CREATE INDEX drones_battery_idx ON drones (battery_capacity) WHERE NOT retired;
+
+CREATE TABLE medications (
+    code   TEXT PRIMARY KEY,
+    name   TEXT NOT NULL,
+    weight DOUBLE PRECISION NOT NULL,
+    image  TEXT NOT NULL DEFAULT ''
+);
+
+-- the medications loaded on a drone, position keeps the order of the request
+CREATE TABLE payloads (
+    serial_number   VARCHAR(100) NOT NULL REFERENCES drones (serial_number) ON DELETE CASCADE,
+    medication_code TEXT NOT NULL REFERENCES medications (code) ON DELETE RESTRICT,
+    position        INTEGER NOT NULL,
+    PRIMARY KEY (serial_number, medication_code)
+);
+
+CREATE INDEX payloads_medication_idx ON payloads (medication_code);
+
+-- single row, the buntdb "config" record
+CREATE TABLE store_config (
+    id           BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    is_populated BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+-- battery level event logs, keyed by their timestamp like the buntdb keys
+CREATE TABLE event_logs (
+    created               TEXT PRIMARY KEY,
+    uuid                  TEXT NOT NULL,
+    drones_battery_levels JSONB NOT NULL
+);
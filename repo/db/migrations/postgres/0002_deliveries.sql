@@ -0,0 +1,15 @@
+-- Delivery records of the lifecycle actions, from the dispatch of a drone to its return. They keep the
+-- medications carried, so the codes don't reference the medications table: the history outlives the catalogue
+
+CREATE TABLE deliveries (
+    id            TEXT PRIMARY KEY,
+    serial_number VARCHAR(100) NOT NULL REFERENCES drones (serial_number) ON DELETE CASCADE,
+    medications   TEXT[] NOT NULL,
+    status        TEXT NOT NULL,
+    dispatched_at TEXT NOT NULL DEFAULT '',
+    delivered_at  TEXT NOT NULL DEFAULT '',
+    aborted_at    TEXT NOT NULL DEFAULT '',
+    returned_at   TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX deliveries_drone_idx ON deliveries (serial_number, dispatched_at);
@@ -0,0 +1,3 @@
+-- Administrators of the API, their access tokens are allowed in the /admin endpoints
+
+ALTER TABLE users ADD COLUMN admin BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,5 @@
+-- The drones were left IDLE (0) when they were loaded, the ones that carry medications are moved to
+-- LOADED (2), the state they are dispatched from
+
+UPDATE drones SET state = 2, version = version + 1
+WHERE state = 0 AND serial_number IN (SELECT serial_number FROM payloads);
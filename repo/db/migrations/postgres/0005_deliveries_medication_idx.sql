@@ -0,0 +1,4 @@
+-- The deliveries of a medication are looked up by the codes carried, a GIN index of the array spares the
+-- scan of the whole history
+
+CREATE INDEX deliveries_medications_idx ON deliveries USING GIN (medications);
@@ -0,0 +1,190 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kmilodenisglez/drones.restapi/schema"
+	"github.com/kmilodenisglez/drones.restapi/schema/dto"
+	"github.com/kmilodenisglez/drones.restapi/service/tracing"
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// region ======== POSTGRES ==============================================================
+
+// pgMigrationFiles the SQL migrations of the postgres backend, named <version>_<name>.sql
+//
+//go:embed migrations/postgres/*.sql
+var pgMigrationFiles embed.FS
+
+// pgMigrationLock key of the advisory lock held by the migrations, two servers starting at once don't
+// both run them
+const pgMigrationLock = 7001040
+
+// pgUniqueViolation SQLSTATE of a duplicate key
+const pgUniqueViolation = "23505"
+
+// pgStatementRegexp the statements of a migration reported by a dry run, e.g. "CREATE TABLE drones"
+var pgStatementRegexp = regexp.MustCompile(`(?im)^\s*((?:CREATE|ALTER|DROP)\s+(?:UNIQUE\s+)?(?:TABLE|INDEX|TYPE|VIEW|EXTENSION)\s+(?:IF\s+(?:NOT\s+)?EXISTS\s+)?[\w.]+)`)
+
+// pgMigration SQL migration of the postgres backend
+type pgMigration struct {
+	version int
+	name    string
+	sql     string
+}
+
+// openPostgres the connection pool of a DSN, shared by the repositories and closed by CloseDatabases.
+// It fails with schema.ErrShuttingDown once CloseDatabases has been called
+func openPostgres(dsn string) (*sql.DB, error) {
+	handles.Lock()
+	defer handles.Unlock()
+	if handles.closing {
+		return nil, schema.ErrShuttingDown
+	}
+	if pool, ok := handles.pools[dsn]; ok {
+		return pool, nil
+	}
+
+	pool, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if handles.pools == nil {
+		handles.pools = make(map[string]*sql.DB)
+	}
+	handles.pools[dsn] = pool
+	return pool, nil
+}
+
+// pgTx run fn in a transaction, it is committed if fn succeeds and rolled back otherwise
+func pgTx(ctx context.Context, pool *sql.DB, opts *sql.TxOptions, fn func(tx *sql.Tx) error) error {
+	tx, err := pool.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// startPgSpan create the span of a postgres repository operation, like tracing.StartDB
+func startPgSpan(ctx context.Context, repo, operation string) (context.Context, trace.Span) {
+	ctx, span := tracing.StartDB(ctx, repo, operation)
+	span.SetAttributes(attribute.String("db.system", "postgresql"))
+	return ctx, span
+}
+
+// pgNotFound the no rows error of database/sql is ErrNotFound, like the buntdb one
+func pgNotFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// isPgUniqueViolation the error is a duplicate key
+func isPgUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == pgUniqueViolation
+}
+
+// runPgMigrations apply the pending SQL migrations in a single transaction and record them in the
+// schema_migrations table. In a dry run the transaction is rolled back, the DDL of postgres is
+// transactional, so the report tells what would change
+func runPgMigrations(ctx context.Context, pool *sql.DB, dryRun bool) (*dto.MigrationReport, error) {
+	list, err := pgMigrations()
+	if err != nil {
+		return nil, err
+	}
+	latest := list[len(list)-1].version
+
+	report := dto.MigrationReport{DryRun: dryRun, Migrations: make([]dto.MigrationResult, 0)}
+	err = pgTx(ctx, pool, nil, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", pgMigrationLock); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INTEGER PRIMARY KEY,
+			name       TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)`)
+		if err != nil {
+			return err
+		}
+		current := 0
+		if err := tx.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current); err != nil {
+			return err
+		}
+		report.FromVersion, report.ToVersion = current, current
+		if current > latest {
+			return fmt.Errorf("%w: schema version %d, this build supports up to %d", schema.ErrSchemaVersion, current, latest)
+		}
+
+		for _, m := range list {
+			if m.version <= current {
+				continue
+			}
+			// without arguments the file is sent as a simple query, it can have several statements
+			if _, err := tx.ExecContext(ctx, m.sql); err != nil {
+				return fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+			}
+			if _, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", m.version, m.name); err != nil {
+				return err
+			}
+			changes := make([]string, 0)
+			for _, match := range pgStatementRegexp.FindAllStringSubmatch(m.sql, -1) {
+				changes = append(changes, strings.Join(strings.Fields(match[1]), " "))
+			}
+			report.Migrations = append(report.Migrations, dto.MigrationResult{Version: m.version, Name: m.name, Changes: changes})
+			report.ToVersion = m.version
+		}
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && err != errDryRun {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// pgMigrations the embedded SQL migrations sorted by version
+func pgMigrations() ([]pgMigration, error) {
+	const dir = "migrations/postgres"
+	entries, err := pgMigrationFiles.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]pgMigration, 0, len(entries))
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".sql")
+		parts := strings.SplitN(name, "_", 2)
+		version, err := strconv.Atoi(parts[0])
+		if err != nil || len(parts) != 2 {
+			return nil, fmt.Errorf("invalid migration file name '%s', expected <version>_<name>.sql", entry.Name())
+		}
+		data, err := pgMigrationFiles.ReadFile(path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, pgMigration{version: version, name: strings.ReplaceAll(parts[1], "_", " "), sql: string(data)})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].version < list[j].version })
+	return list, nil
+}
+
+// endregion =============================================================================
@@ -0,0 +1,272 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kmilodenisglez/drones.restapi/lib"
+	"github.com/kmilodenisglez/drones.restapi/schema/dto"
+	"github.com/kmilodenisglez/drones.restapi/service/metrics"
+	"github.com/kmilodenisglez/drones.restapi/service/tracing"
+	"github.com/kmilodenisglez/drones.restapi/service/utils"
+	"github.com/tidwall/buntdb"
+)
+
+// region ======== SETUP =================================================================
+
+type RepoAudit interface {
+	AppendAuditEntry(ctx context.Context, entry *dto.AuditEntry) error
+	GetAuditEntries(ctx context.Context, filter *dto.AuditFilter) (*[]dto.AuditEntry, error)
+	VerifyAuditChain(ctx context.Context) (*dto.AuditChainStatus, error)
+	Ping(ctx context.Context) error
+}
+
+type repoAudit struct {
+	AuditDBLocation string
+	logger          *utils.SvcLogger
+}
+
+// appendMutex serializes the appends, so two entries can never be chained to the same previous hash
+var appendMutex sync.Mutex
+
+const auditKeyPrefix = "audit:"
+
+// endregion =============================================================================
+
+func NewRepoAudit(svcConf *utils.SvcConfig, svcLog *utils.SvcLogger) RepoAudit {
+	return &repoAudit{AuditDBLocation: svcConf.AuditDBPath, logger: svcLog}
+}
+
+// region ======== METHODS ===============================================================
+
+// AppendAuditEntry append a new entry at the end of the audit trail. The sequence, the previous
+// hash and the hash of the entry are computed here, existing entries are never overwritten
+func (r *repoAudit) AppendAuditEntry(ctx context.Context, entry *dto.AuditEntry) (err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoAudit, "append_audit_entry", time.Now())
+	ctx, span := tracing.StartDB(ctx, metrics.RepoAudit, "append_audit_entry")
+	defer func() { tracing.End(span, err) }()
+
+	appendMutex.Lock()
+	defer appendMutex.Unlock()
+
+	db, err := r.loadAuditDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *buntdb.Tx) error {
+		last, err := lastAuditEntry(tx)
+		if err != nil {
+			return err
+		}
+
+		entry.Sequence = 1
+		entry.PrevHash = ""
+		if last != nil {
+			entry.Sequence = last.Sequence + 1
+			entry.PrevHash = last.Hash
+		}
+		if entry.Created == "" {
+			entry.Created = time.Now().UTC().Format(time.RFC3339Nano)
+		}
+		entry.Hash, err = hashAuditEntry(entry)
+		if err != nil {
+			return err
+		}
+
+		key := auditKey(entry.Sequence)
+		// append-only: an existing key means the chain is corrupted, never overwrite it
+		if _, err := tx.Get(key); err == nil {
+			return fmt.Errorf("audit entry %d already exists", entry.Sequence)
+		} else if err != buntdb.ErrNotFound {
+			return err
+		}
+
+		res, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		_, _, err = tx.Set(key, string(res), nil)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	r.logger.Debugf(ctx, "audit entry %d '%s' appended for '%s'", entry.Sequence, entry.Action, entry.Target)
+	return nil
+}
+
+// GetAuditEntries A read-only transaction, return the audit entries that match the filter, newest first
+func (r *repoAudit) GetAuditEntries(ctx context.Context, filter *dto.AuditFilter) (_ *[]dto.AuditEntry, err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoAudit, "get_audit_entries", time.Now())
+	ctx, span := tracing.StartDB(ctx, metrics.RepoAudit, "get_audit_entries")
+	defer func() { tracing.End(span, err) }()
+
+	db, err := r.loadAuditDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	entries := make([]dto.AuditEntry, 0)
+	err = db.View(func(tx *buntdb.Tx) error {
+		var errIter error
+		err := tx.DescendKeys(auditKeyPrefix+"*", func(key, value string) bool {
+			entry := dto.AuditEntry{}
+			if errIter = json.Unmarshal([]byte(value), &entry); errIter != nil {
+				return false
+			}
+			if matchAuditFilter(&entry, filter) {
+				entries = append(entries, entry)
+			}
+			return filter == nil || filter.Limit <= 0 || len(entries) < filter.Limit
+		})
+		if err != nil {
+			return err
+		}
+		return errIter
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &entries, nil
+}
+
+// VerifyAuditChain walk the whole audit trail checking the sequence continuity and recomputing every hash
+func (r *repoAudit) VerifyAuditChain(ctx context.Context) (_ *dto.AuditChainStatus, err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoAudit, "verify_audit_chain", time.Now())
+	ctx, span := tracing.StartDB(ctx, metrics.RepoAudit, "verify_audit_chain")
+	defer func() { tracing.End(span, err) }()
+
+	db, err := r.loadAuditDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	status := dto.AuditChainStatus{Valid: true}
+	err = db.View(func(tx *buntdb.Tx) error {
+		var errIter error
+		err := tx.AscendKeys(auditKeyPrefix+"*", func(key, value string) bool {
+			entry := dto.AuditEntry{}
+			if errIter = json.Unmarshal([]byte(value), &entry); errIter != nil {
+				return false
+			}
+			hash, err := hashAuditEntry(&entry)
+			if err != nil {
+				errIter = err
+				return false
+			}
+			if entry.Sequence != status.Entries+1 || entry.PrevHash != status.LastHash || entry.Hash != hash || key != auditKey(entry.Sequence) {
+				status.Valid = false
+				status.BrokenAt = status.Entries + 1
+				return false
+			}
+			status.Entries = entry.Sequence
+			status.LastHash = entry.Hash
+			return true
+		})
+		if err != nil {
+			return err
+		}
+		return errIter
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &status, nil
+}
+
+// Ping check that the audit database can be opened and read
+func (r *repoAudit) Ping(ctx context.Context) (err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoAudit, "ping", time.Now())
+	_, span := tracing.StartDB(ctx, metrics.RepoAudit, "ping")
+	defer func() { tracing.End(span, err) }()
+
+	db, err := r.loadAuditDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.View(func(tx *buntdb.Tx) error {
+		_, err := tx.Len()
+		return err
+	})
+}
+
+// region ======== PRIVATE AUX ===========================================================
+
+func (r *repoAudit) loadAuditDB() (*handle, error) {
+	// Open the audit.db file. It will be created if it doesn't exist.
+	return openDB(r.AuditDBLocation)
+}
+
+// lastAuditEntry return the last entry of the chain or nil if the audit trail is empty
+func lastAuditEntry(tx *buntdb.Tx) (*dto.AuditEntry, error) {
+	var last *dto.AuditEntry
+	var errIter error
+	err := tx.DescendKeys(auditKeyPrefix+"*", func(key, value string) bool {
+		last = &dto.AuditEntry{}
+		errIter = json.Unmarshal([]byte(value), last)
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	return last, errIter
+}
+
+// hashAuditEntry compute the SHA256 of the entry (without its own hash), the entry is chained
+// to the previous one because the PrevHash is part of the hashed content
+func hashAuditEntry(entry *dto.AuditEntry) (string, error) {
+	e := *entry
+	e.Hash = ""
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	return lib.Checksum(lib.SHA256, data)
+}
+
+// auditKey zero padded, so the keys are sorted by sequence
+func auditKey(sequence uint64) string {
+	return fmt.Sprintf("%s%020d", auditKeyPrefix, sequence)
+}
+
+func matchAuditFilter(entry *dto.AuditEntry, filter *dto.AuditFilter) bool {
+	if filter == nil {
+		return true
+	}
+	if filter.Actor != "" && entry.Actor.Username != filter.Actor && entry.Actor.Did != filter.Actor {
+		return false
+	}
+	if filter.Action != "" && entry.Action != filter.Action {
+		return false
+	}
+	if filter.Target != "" && entry.Target != filter.Target {
+		return false
+	}
+	created, err := time.Parse(time.RFC3339Nano, entry.Created)
+	if err != nil {
+		return filter.From == "" && filter.To == ""
+	}
+	if filter.From != "" {
+		if from, err := time.Parse(time.RFC3339, filter.From); err == nil && created.Before(from) {
+			return false
+		}
+	}
+	if filter.To != "" {
+		if to, err := time.Parse(time.RFC3339, filter.To); err == nil && created.After(to) {
+			return false
+		}
+	}
+	return true
+}
+
+// endregion =============================================================================
@@ -1,190 +1,128 @@
 package db
 
 import (
-	"encoding/base64"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 
-	"github.com/brianvoe/gofakeit/v6"
 	jsoniter "github.com/json-iterator/go"
-	"github.com/kmilodenisglez/drones.restapi/lib"
 	"github.com/kmilodenisglez/drones.restapi/schema"
 	"github.com/kmilodenisglez/drones.restapi/schema/dto"
+	"github.com/kmilodenisglez/drones.restapi/service/metrics"
+	"github.com/kmilodenisglez/drones.restapi/service/tracing"
 	"github.com/kmilodenisglez/drones.restapi/service/utils"
 	"github.com/tidwall/buntdb"
-	"log"
+	"go.opentelemetry.io/otel/attribute"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // region ======== SETUP =================================================================
 
 type RepoDrones interface {
-	IsPopulated() bool
-	PopulateDB() error
-
-	GetUser(field string, filterOptional ...bool) (*dto.User, error)
-	GetUsers() (*[]dto.User, error)
-
-	GetDrone(serialNumber string) (*dto.Drone, error)
-	GetDrones(filter string) (*[]dto.Drone, error)
-	RegisterDrone(drone *dto.Drone) error
-	CheckingLoadedMedicationsItems(serialNumber string) (*[]string, error)
-	LoadMedicationItemsADrone(drone *dto.Drone, medicationItemIDs []interface{}) error
-	ExistDrone(serialNumber string) error
-
-	GetMedications() (*[]dto.Medication, error)
+	IsPopulated(ctx context.Context) bool
+
+	GetUser(ctx context.Context, field string, filterOptional ...bool) (*dto.User, error)
+	GetUsers(ctx context.Context) (*[]dto.User, error)
+	CreateUser(ctx context.Context, user *dto.User) error
+
+	GetDrone(ctx context.Context, serialNumber string) (*dto.Drone, error)
+	GetDrones(ctx context.Context, filter *dto.DroneFilter) (*dto.DronePage, error)
+	RegisterDrone(ctx context.Context, drone *dto.Drone) error
+	RegisterDrones(ctx context.Context, drones []dto.Drone) error
+	UpdateDrone(ctx context.Context, drone *dto.Drone, expectedVersion *uint64) (*dto.Drone, error)
+	RetireDrone(ctx context.Context, serialNumber string, expectedVersion *uint64) (*dto.Drone, *dto.Drone, error)
+	AdvanceDrone(ctx context.Context, serialNumber, action string, expectedVersion *uint64) (*dto.Drone, *dto.Drone, *dto.Delivery, error)
+	GetDeliveries(ctx context.Context, filter *dto.DeliveryFilter) (*[]dto.Delivery, error)
+	CheckingLoadedMedicationsItems(ctx context.Context, serialNumber string) (*[]string, error)
+	LoadMedicationItemsADrone(ctx context.Context, drone *dto.Drone, medicationItemIDs []interface{}) ([]string, error)
+	LoadMedicationItemsDrones(ctx context.Context, loads []dto.DroneLoad) ([][]string, error)
+	ExistDrone(ctx context.Context, serialNumber string) error
+	GetFleetStats(ctx context.Context) (*dto.FleetStats, error)
+
+	GetMedications(ctx context.Context) (*[]dto.Medication, error)
+
+	ExportData(ctx context.Context) (*dto.Dataset, error)
+	ImportData(ctx context.Context, dataset *dto.Dataset, replace bool) error
+	Snapshot(ctx context.Context, w io.Writer) error
+	RestoreSnapshot(ctx context.Context, r io.Reader) error
+	Migrate(ctx context.Context, dryRun bool) (*dto.MigrationReport, error)
+
+	Ping(ctx context.Context) error
 }
 
 type repoDrones struct {
 	DBUserLocation string
+	svcConf        *utils.SvcConfig
+	logger         *utils.SvcLogger
 }
 
 // endregion =============================================================================
 
-func NewRepoDrones(svcConf *utils.SvcConfig) RepoDrones {
-	return &repoDrones{DBUserLocation: svcConf.StoreDBPath}
+// NewRepoDrones the RepoDrones of the configured store backend
+func NewRepoDrones(svcConf *utils.SvcConfig, svcLog *utils.SvcLogger) RepoDrones {
+	if svcConf.StoreBackend == schema.StoreBackendPostgres {
+		return newPgRepoDrones(svcConf, svcLog)
+	}
+	return &repoDrones{DBUserLocation: svcConf.StoreDBPath, svcConf: svcConf, logger: svcLog}
 }
 
 // region ======== METHODS ===============================================================
 
-func (r *repoDrones) IsPopulated() bool {
+func (r *repoDrones) IsPopulated(ctx context.Context) bool {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "is_populated", time.Now())
+	ctx, span := tracing.StartDB(ctx, metrics.RepoDrones, "is_populated")
+	defer span.End()
+
 	db, err := r.loadDB()
 	if err != nil {
 		return false
 	}
 	defer db.Close()
-	return isPopulated(db)
+	return isPopulated(db.DB)
 }
 
 
-// PopulateDB Populate the database with the initial information only if "IsPopulated" is
-// false or does not exist
-//nolint:gocognit
-func (r *repoDrones) PopulateDB() error {
-	db, err := r.loadDB()
-	if err != nil {
-		return err
-	}
-	defer db.Close()
-
-	// If it is already populated, the execution of the function stops
-	if isPopulated(db) {return errors.New(schema.ErrBuntdbPopulated)}
-
-	var fakeUsersList = fakeUsers()
-	var fakeDronesList = fakeDrones()
-	var fakeMedicationsList = fakeMedications()
-
-	log.Println("writing users in database")
-	err = db.Update(func(tx *buntdb.Tx) error {
-		for i := 0; i < len(fakeUsersList); i++ {
-			res, err := jsoniter.MarshalToString(fakeUsersList[i])
-			log.Printf("user #%d: %s", i, res)
-			if err != nil {
-				return err
-			}
-			_, _, err = tx.Set(strconv.Itoa(i), res, nil)
-			if err != nil {
-				return err
-			}
-		}
-		return nil
-	})
-	if err != nil {
-		return err
-	}
-	log.Println("successfully added users")
-
-	log.Println("writing drones in database")
-	err = db.Update(func(tx *buntdb.Tx) error {
-		for i := 0; i < len(fakeDronesList); i++ {
-			res, err := jsoniter.MarshalToString(fakeDronesList[i])
-			if err != nil {
-				return err
-			}
-			// add drone value with "serialnumber" key
-			_, _, err = tx.Set("drone:"+fakeDronesList[i].SerialNumber, res, nil)
-			if err != nil {
-				return err
-			}
-		}
-		return nil
-	})
-	if err != nil {
-		return err
-	}
-	log.Println("successfully added drones")
-
-	log.Println("writing medications in database")
-	err = db.Update(func(tx *buntdb.Tx) error {
-		for i := 0; i < len(fakeMedicationsList); i++ {
-			res, err := jsoniter.MarshalToString(fakeMedicationsList[i])
-			if err != nil {
-				return err
-			}
-			// add drone value with "code" key
-			_, _, err = tx.Set("med:"+fakeMedicationsList[i].Code, res, nil)
-			if err != nil {
-				return err
-			}
-		}
-		return nil
-	})
-	if err != nil {
-		return err
-	}
-	log.Println("successfully added medications")
-
-	// set IsPopulated to true
-	err = db.Update(func(tx *buntdb.Tx) error {
-		res, err := jsoniter.MarshalToString(dto.ConfigDB{IsPopulated: true})
-		if err != nil {
-			return err
-		}
-		_, _, err = tx.Set("config", res, nil)
-		return err
-	})
-	if err != nil {
-		return err
-	}
-	log.Println("'IsPopulated' has been set to true")
-
-	return nil
-}
-
 // GetUser get the user from the DB
-func (r *repoDrones) GetUser(field string, filterOptional ...bool) (*dto.User, error) {
+func (r *repoDrones) GetUser(ctx context.Context, field string, filterOptional ...bool) (_ *dto.User, err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "get_user", time.Now())
+	ctx, span := tracing.StartDB(ctx, metrics.RepoDrones, "get_user")
+	defer func() { tracing.End(span, err) }()
+
 	filter := false
 	if len(filterOptional) > 0 {
 		filter = filterOptional[0]
 	}
 	user := dto.User{}
 
-	// Open the data.db file. It will be created if it doesn't exist.
-	db, err := buntdb.Open(r.DBUserLocation)
+	db, err := r.loadDB()
 	if err != nil {
 		return nil, err
 	}
 	defer db.Close()
 
-	err = db.CreateIndex("username", "*", buntdb.IndexString)
+	err = db.CreateIndex("username", userKeyPrefix+"*", buntdb.IndexString)
 	if err != nil {
 		return nil, err
 	}
 	err = db.View(func(tx *buntdb.Tx) error {
 		if filter {
+			// the username must match exactly, like the postgres backend
+			var errUnmarshal error
 			err := tx.Ascend("username", func(key, value string) bool {
-				if strings.Contains(value, field) {
-					err := jsoniter.UnmarshalFromString(value, &user)
-					if err != nil {
-						return false
-					}
+				candidate := dto.User{}
+				if errUnmarshal = jsoniter.UnmarshalFromString(value, &candidate); errUnmarshal != nil {
+					return false
+				}
+				if candidate.Username == field {
+					user = candidate
 					return false
 				}
-
 				return true
 			})
-			return err
+			return firstError(err, errUnmarshal)
 		}
 		// filter = false
 		//value, err := tx.Get(field)
@@ -205,23 +143,26 @@ func (r *repoDrones) GetUser(field string, filterOptional ...bool) (*dto.User, e
 }
 
 // GetUsers return a list of dto.User
-func (r *repoDrones) GetUsers() (*[]dto.User, error) {
-	// Open the data.db file. It will be created if it doesn't exist.
-	db, err := buntdb.Open(r.DBUserLocation)
+func (r *repoDrones) GetUsers(ctx context.Context) (_ *[]dto.User, err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "get_users", time.Now())
+	ctx, span := tracing.StartDB(ctx, metrics.RepoDrones, "get_users")
+	defer func() { tracing.End(span, err) }()
+
+	db, err := r.loadDB()
 	if err != nil {
 		return nil, err
 	}
 	defer db.Close()
 
-	user := dto.User{}
 	var list []dto.User
 
-	err = db.CreateIndex("username", "*", buntdb.IndexString)
+	err = db.CreateIndex("username", userKeyPrefix+"*", buntdb.IndexString)
 	if err != nil {
 		return nil, err
 	}
 	err = db.View(func(tx *buntdb.Tx) error {
 		tx.Ascend("username", func(key, value string) bool {
+			user := dto.User{} // the fields left out of the JSON (admin) are not kept from the previous user
 			err = jsoniter.UnmarshalFromString(value, &user)
 			if err == nil {
 				list = append(list, user)
@@ -237,10 +178,58 @@ func (r *repoDrones) GetUsers() (*[]dto.User, error) {
 	return &list, nil
 }
 
+// CreateUser create a new user under the next free user key, it fails with schema.ErrUserAlreadyExists if
+// the username is in use. The passphrase must be already hashed
+func (r *repoDrones) CreateUser(ctx context.Context, user *dto.User) (err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "create_user", time.Now())
+	ctx, span := tracing.StartDB(ctx, metrics.RepoDrones, "create_user")
+	defer func() { tracing.End(span, err) }()
+
+	db, err := r.loadDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *buntdb.Tx) error {
+		next := 0
+		exists := false
+		err := ascendUsers(tx, func(key int, u dto.User) bool {
+			if key >= next {
+				next = key + 1
+			}
+			exists = u.Username == user.Username
+			return !exists
+		})
+		if err != nil {
+			return err
+		}
+		if exists {
+			return schema.ErrUserAlreadyExists
+		}
+
+		res, err := jsoniter.MarshalToString(user)
+		if err != nil {
+			return err
+		}
+		_, _, err = tx.Set(userKey(next), res, nil)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	r.logger.Infof(ctx, "user '%s' created", user.Username)
+	return nil
+}
+
 // region ======== Drones ======================================================
 
 // GetDrone get a specific drone
-func (r *repoDrones) GetDrone(serialNumber string) (*dto.Drone, error) {
+func (r *repoDrones) GetDrone(ctx context.Context, serialNumber string) (_ *dto.Drone, err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "get_drone", time.Now())
+	ctx, span := tracing.StartDB(ctx, metrics.RepoDrones, "get_drone")
+	defer func() { tracing.End(span, err) }()
+
 	db, err := r.loadDB()
 	if err != nil {
 		return nil, err
@@ -249,7 +238,6 @@ func (r *repoDrones) GetDrone(serialNumber string) (*dto.Drone, error) {
 
 	drone := dto.Drone{}
 
-	db.CreateIndex("drone_state", "drone:*", buntdb.IndexJSON("batteryCapacity"))
 	err = db.View(func(tx *buntdb.Tx) error {
 		value, err := tx.Get("drone:"+serialNumber)
 		if err != nil{
@@ -268,58 +256,113 @@ func (r *repoDrones) GetDrone(serialNumber string) (*dto.Drone, error) {
 	return &drone, nil
 }
 
-// GetDrones A read-only transaction, return drones in db
-// allows filtering by a specific string field
-func (r *repoDrones) GetDrones(filter string) (*[]dto.Drone, error) {
+// GetDrones A read-only transaction, return a page of the drones that match the filter. The candidates
+// are scanned through the buntdb index of the most selective criteria, then sorted and paginated
+func (r *repoDrones) GetDrones(ctx context.Context, filter *dto.DroneFilter) (_ *dto.DronePage, err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "get_drones", time.Now())
+	ctx, span := tracing.StartDB(ctx, metrics.RepoDrones, "get_drones")
+	defer func() { tracing.End(span, err) }()
+
 	db, err := r.loadDB()
 	if err != nil {
 		return nil, err
 	}
 	defer db.Close()
 
-	drone := dto.Drone{}
+	if err = createDroneIndexes(db.DB); err != nil {
+		return nil, err
+	}
+
 	dronesList := make([]dto.Drone, 0)
-	// custom index: sort drones descending by battery capacity
-	db.CreateIndex("drone_state", "drone:*", buntdb.IndexJSON("batteryCapacity"))
 	err = db.View(func(tx *buntdb.Tx) error {
-		if filter != "" {
-			err := tx.Descend("drone_state", func(key, value string) bool {
-				if strings.Contains(value, filter) {
-					err = jsoniter.UnmarshalFromString(value, &drone)
-					if err == nil {
-						dronesList = append(dronesList, drone)
-					}
-					return err == nil
-				}
-				return true
-			})
-			return err
+		// the loaded weights are only needed to filter by available capacity
+		var loadedWeights map[string]float64
+		if filter.MinAvailableWeight != nil {
+			if loadedWeights, err = loadedWeightByDrone(tx); err != nil {
+				return err
+			}
 		}
-		err := tx.Descend("drone_state", func(key, value string) bool {
-			err = jsoniter.UnmarshalFromString(value, &drone)
-			if err == nil {
+
+		var errIter error
+		collect := func(key, value string) bool {
+			drone := dto.Drone{}
+			if errIter = jsoniter.UnmarshalFromString(value, &drone); errIter != nil {
+				return false
+			}
+			if matchDroneFilter(&drone, filter, loadedWeights) {
 				dronesList = append(dronesList, drone)
 			}
-			return err == nil
-		})
-		return err
+			return true
+		}
+
+		switch {
+		case len(filter.States) > 0:
+			for _, state := range uniqueStates(filter.States) {
+				if err := tx.AscendEqual(idxDroneState, fmt.Sprintf(`{"state":%d}`, state), collect); err != nil {
+					return err
+				}
+			}
+		case filter.BatteryMin != nil || filter.BatteryMax != nil:
+			// the range scan stops at the first drone above the maximum battery
+			inRange := func(key, value string) bool {
+				if filter.BatteryMax != nil && jsoniter.Get([]byte(value), "batteryCapacity").ToFloat64() > *filter.BatteryMax {
+					return false
+				}
+				return collect(key, value)
+			}
+			if filter.BatteryMin != nil {
+				err = tx.AscendGreaterOrEqual(idxDroneBattery, fmt.Sprintf(`{"batteryCapacity":%v}`, *filter.BatteryMin), inRange)
+			} else {
+				err = tx.Ascend(idxDroneBattery, inRange)
+			}
+			if err != nil {
+				return err
+			}
+		case len(filter.Models) > 0:
+			for _, model := range uniqueModels(filter.Models) {
+				if err := tx.AscendEqual(idxDroneModel, fmt.Sprintf(`{"model":%d}`, model), collect); err != nil {
+					return err
+				}
+			}
+		default:
+			if err := tx.AscendKeys("drone:*", collect); err != nil {
+				return err
+			}
+		}
+		return errIter
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	return &dronesList, nil
+	return paginateDrones(dronesList, filter)
 }
 
-func (r *repoDrones) RegisterDrone(drone *dto.Drone) error {
+// RegisterDrone create a new drone, it fails with schema.ErrDroneAlreadyExists if the serial number is in use
+func (r *repoDrones) RegisterDrone(ctx context.Context, drone *dto.Drone) (err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "register_drone", time.Now())
+	ctx, span := tracing.StartDB(ctx, metrics.RepoDrones, "register_drone")
+	defer func() { tracing.End(span, err) }()
+
 	db, err := r.loadDB()
 	if err != nil {
 		return err
 	}
 	defer db.Close()
 
-	log.Printf("writing the drone '%s' in database", drone.SerialNumber)
 	err = db.Update(func(tx *buntdb.Tx) error {
+		// never overwrite an existing drone, the updates go through UpdateDrone
+		if value, err := tx.Get("drone:" + drone.SerialNumber); err == nil {
+			// a retired serial number is kept with its history, it can't be re-registered
+			if retired, _ := isRetiredDrone(value); retired {
+				return schema.ErrDroneRetired
+			}
+			return schema.ErrDroneAlreadyExists
+		} else if err != buntdb.ErrNotFound {
+			return err
+		}
+
+		drone.Version = 1
 		res, err := jsoniter.MarshalToString(drone)
 		if err != nil {
 			return err
@@ -333,141 +376,606 @@ func (r *repoDrones) RegisterDrone(drone *dto.Drone) error {
 	if err != nil {
 		return err
 	}
-	log.Println("successfully added drone")
+	r.logger.Infof(ctx, "drone '%s' registered", drone.SerialNumber)
 	return  nil
 }
 
-// CheckingLoadedMedicationsItems checking loaded medication items for a given drone
-func (r *repoDrones) CheckingLoadedMedicationsItems(serialNumber string) (*[]string, error) {
+// RegisterDrones create the drones in a single transaction. If a serial number is in use (or repeated in
+// the batch) none of them is created and it fails with a *BatchError of the ErrDroneAlreadyExists and
+// ErrDroneRetired errors
+func (r *repoDrones) RegisterDrones(ctx context.Context, drones []dto.Drone) (err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "register_drones", time.Now())
+	ctx, span := tracing.StartDB(ctx, metrics.RepoDrones, "register_drones")
+	defer func() { tracing.End(span, err) }()
+	span.SetAttributes(attribute.Int("drones.count", len(drones)))
+
 	db, err := r.loadDB()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer db.Close()
 
-	// medications id slice loaded by the drone
-	loadedMeds := make([]string, 0)
-
-	db.CreateIndex("loaded_medications", "loaded_medications:*", buntdb.IndexString)
-	err = db.View(func(tx *buntdb.Tx) error {
-		value, err := tx.Get("loaded_medications:"+serialNumber)
-		if err != nil{
-			return err
+	err = db.Update(func(tx *buntdb.Tx) error {
+		batchErr := &BatchError{Errors: make(map[int]error)}
+		seen := make(map[string]bool, len(drones))
+		for i := range drones {
+			if value, err := tx.Get("drone:" + drones[i].SerialNumber); err == nil {
+				if retired, _ := isRetiredDrone(value); retired {
+					batchErr.Errors[i] = schema.ErrDroneRetired
+				} else {
+					batchErr.Errors[i] = schema.ErrDroneAlreadyExists
+				}
+			} else if err != buntdb.ErrNotFound {
+				return err
+			} else if seen[drones[i].SerialNumber] {
+				batchErr.Errors[i] = schema.ErrDroneAlreadyExists
+			}
+			seen[drones[i].SerialNumber] = true
 		}
-		err = jsoniter.UnmarshalFromString(value, &loadedMeds)
-		if err != nil{
-			return err
+		if len(batchErr.Errors) > 0 {
+			// returning an error rolls back the transaction
+			return batchErr
+		}
+
+		for i := range drones {
+			drones[i].Version = 1
+			res, err := jsoniter.MarshalToString(drones[i])
+			if err != nil {
+				return err
+			}
+			if _, _, err = tx.Set("drone:"+drones[i].SerialNumber, res, nil); err != nil {
+				return err
+			}
 		}
 		return nil
 	})
 	if err != nil {
-		return nil, err
+		return err
 	}
-	return &loadedMeds, nil
+	r.logger.Infof(ctx, "%d drones registered", len(drones))
+	return nil
 }
 
-func (r *repoDrones) LoadMedicationItemsADrone(drone *dto.Drone, medicationItemIDs []interface{}) error {
+// UpdateDrone replace an existing drone and increment its version. If expectedVersion is not nil
+// and differs from the stored version, it fails with schema.ErrDroneVersionMismatch; the state can't be
+// changed, it fails with schema.ErrDroneStateReadOnly. It returns the drone as it was before the update,
+// read in the same transaction
+func (r *repoDrones) UpdateDrone(ctx context.Context, drone *dto.Drone, expectedVersion *uint64) (_ *dto.Drone, err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "update_drone", time.Now())
+	ctx, span := tracing.StartDB(ctx, metrics.RepoDrones, "update_drone")
+	defer func() { tracing.End(span, err) }()
+
 	db, err := r.loadDB()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer db.Close()
 
-	// begin: validating medication item IDs
-	medication := dto.Medication{}
-	medicationIdsRealMap := make(map[string]float64)
-
-	db.CreateIndex("medication_id", "med:*", buntdb.IndexJSON("weight"))
-	err = db.View(func(tx *buntdb.Tx) error {
-		err := tx.Descend("medication_id", func(key, value string) bool {
-			err = jsoniter.UnmarshalFromString(value, &medication)
-			if err == nil {
-				medicationIdsRealMap[medication.Code] = medication.Weight
-			}
-			return err == nil
-		})
-		return err
-	})
-	if err != nil {
-		return err
-	}
-
-	// to guarantee non-repeated id
-	medicationItemIDs = lib.Unique(medicationItemIDs)
-
-	// compares the request IDs (medicationItemIDs) with the collection obtained from the database (medicationIdsRealMap)
-	// also returns the total weight
-	packedTotalWeight, allIDValid := thereAreAll(medicationIdsRealMap, medicationItemIDs)
-	if !allIDValid {
-		return fmt.Errorf("at least one of the medication items does not exist")
-	}
-
-	// prevent the drone from being loaded with more weight that it can carry
-	if packedTotalWeight > drone.WeightLimit {
-		return schema.ErrDroneMaximumLoadWeightExceeded
-	}
-
-	// end: validating medication item IDs
-
-	log.Printf("loading a drone '%s' with medication items: %s", drone.SerialNumber, medicationItemIDs)
+	current := dto.Drone{}
 	err = db.Update(func(tx *buntdb.Tx) error {
-		res, err := jsoniter.MarshalToString(medicationItemIDs)
+		value, err := tx.Get("drone:" + drone.SerialNumber)
 		if err != nil {
 			return err
 		}
-		_, _, err = tx.Set("loaded_medications:"+drone.SerialNumber, res, nil)
+		if err = jsoniter.UnmarshalFromString(value, &current); err != nil {
+			return err
+		}
+		if current.Retired {
+			return schema.ErrDroneRetired
+		}
+		// the version is checked inside the write transaction of the database shared by every operation
+		// (see openDB), so two concurrent updates can't both succeed
+		if expectedVersion != nil && *expectedVersion != current.Version {
+			return schema.ErrDroneVersionMismatch
+		}
+		// the state only changes by loading the drone and by the lifecycle actions
+		if drone.State != current.State {
+			return schema.ErrDroneStateReadOnly
+		}
+
+		drone.Version = current.Version + 1
+		res, err := jsoniter.MarshalToString(drone)
 		if err != nil {
 			return err
 		}
-		return nil
+		_, _, err = tx.Set("drone:"+drone.SerialNumber, res, nil)
+		return err
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
-	log.Println("successfully loaded medication items")
-
-	return  nil
+	r.logger.Infof(ctx, "drone '%s' updated to version %d", drone.SerialNumber, drone.Version)
+	return &current, nil
 }
 
-func (r *repoDrones) ExistDrone(serialNumber string) error {
+// RetireDrone decommission a drone (soft delete). The drone is kept with its history, but it is hidden from
+// GetDrones and can't be updated nor re-registered. It fails with schema.ErrDroneNotRetirable if the drone
+// is mid-delivery or has loaded medications. It returns the drone before and after the retirement
+func (r *repoDrones) RetireDrone(ctx context.Context, serialNumber string, expectedVersion *uint64) (_, _ *dto.Drone, err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "retire_drone", time.Now())
+	ctx, span := tracing.StartDB(ctx, metrics.RepoDrones, "retire_drone")
+	defer func() { tracing.End(span, err) }()
+
 	db, err := r.loadDB()
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	defer db.Close()
 
-	err = db.View(func(tx *buntdb.Tx) error {
-		_, err := tx.Get("drone:"+serialNumber)
+	var previous dto.Drone
+	drone := dto.Drone{}
+	err = db.Update(func(tx *buntdb.Tx) error {
+		value, err := tx.Get("drone:" + serialNumber)
 		if err != nil {
 			return err
 		}
-		return nil
+		if err = jsoniter.UnmarshalFromString(value, &drone); err != nil {
+			return err
+		}
+		if drone.Retired {
+			return schema.ErrDroneRetired
+		}
+		if expectedVersion != nil && *expectedVersion != drone.Version {
+			return schema.ErrDroneVersionMismatch
+		}
+		if drone.State != dto.IDLE {
+			return schema.ErrDroneNotRetirable
+		}
+		// a drone with loaded medications is not retired, the items must be unloaded first
+		loaded, err := tx.Get("loaded_medications:" + serialNumber)
+		if err == nil {
+			loadedMeds := make([]string, 0)
+			if err = jsoniter.UnmarshalFromString(loaded, &loadedMeds); err != nil {
+				return err
+			}
+			if len(loadedMeds) > 0 {
+				return schema.ErrDroneNotRetirable
+			}
+		} else if err != buntdb.ErrNotFound {
+			return err
+		}
+
+		previous = drone
+		drone.Retired = true
+		drone.RetiredAt = time.Now().UTC().Format(time.RFC3339)
+		drone.Version++
+		res, err := jsoniter.MarshalToString(drone)
+		if err != nil {
+			return err
+		}
+		_, _, err = tx.Set("drone:"+serialNumber, res, nil)
+		return err
 	})
-	// Getting non-existent values will cause an ErrNotFound error.
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-
-	return nil
+	r.logger.Infof(ctx, "drone '%s' retired", serialNumber)
+	return &previous, &drone, nil
 }
 
-// endregion ======== Drones ======================================================
-
-// region ======== Medications ======================================================
+// AdvanceDrone apply a lifecycle action (dto.DeliveryAction*) to a drone and write its delivery in the same
+// transaction. It fails with a *TransitionError if the action is not allowed in the state of the drone and
+// with schema.ErrDroneNotLoaded if a drone without medications is dispatched. The medications are unloaded
+// when they are delivered. It returns the drone before and after the action, and the delivery
+func (r *repoDrones) AdvanceDrone(ctx context.Context, serialNumber, action string, expectedVersion *uint64) (_, _ *dto.Drone, _ *dto.Delivery, err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "advance_drone", time.Now())
+	ctx, span := tracing.StartDB(ctx, metrics.RepoDrones, "advance_drone")
+	defer func() { tracing.End(span, err) }()
+	span.SetAttributes(attribute.String("delivery.action", action))
 
-func (r *repoDrones) GetMedications() (*[]dto.Medication, error) {
 	db, err := r.loadDB()
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 	defer db.Close()
 
-	medication := dto.Medication{}
-	medicationsList := make([]dto.Medication, 0)
-	// custom index: sort medications descending by weight
-	db.CreateIndex("medication_state", "med:*", buntdb.IndexJSON("weight"))
-	err = db.View(func(tx *buntdb.Tx) error {
-		err := tx.Descend("medication_state", func(key, value string) bool {
+	if err = createDeliveryIndexes(db.DB); err != nil {
+		return nil, nil, nil, err
+	}
+
+	var previous dto.Drone
+	drone := dto.Drone{}
+	var delivery *dto.Delivery
+	err = db.Update(func(tx *buntdb.Tx) error {
+		value, err := tx.Get("drone:" + serialNumber)
+		if err != nil {
+			return err
+		}
+		if err = jsoniter.UnmarshalFromString(value, &drone); err != nil {
+			return err
+		}
+		if drone.Retired {
+			return schema.ErrDroneRetired
+		}
+		if expectedVersion != nil && *expectedVersion != drone.Version {
+			return schema.ErrDroneVersionMismatch
+		}
+
+		payload, err := loadedMedications(tx, serialNumber)
+		if err != nil {
+			return err
+		}
+		active, err := activeDelivery(tx, serialNumber)
+		if err != nil {
+			return err
+		}
+		previous = drone
+		if delivery, err = advanceDrone(&drone, action, payload, active); err != nil {
+			return err
+		}
+
+		res, err := jsoniter.MarshalToString(drone)
+		if err != nil {
+			return err
+		}
+		if _, _, err = tx.Set("drone:"+serialNumber, res, nil); err != nil {
+			return err
+		}
+		if delivery != nil {
+			if res, err = jsoniter.MarshalToString(delivery); err != nil {
+				return err
+			}
+			if _, _, err = tx.Set(deliveryKeyPrefix+delivery.ID, res, nil); err != nil {
+				return err
+			}
+		}
+		if action == dto.DeliveryActionDelivered {
+			if _, err = tx.Delete("loaded_medications:" + serialNumber); err != nil && err != buntdb.ErrNotFound {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	r.logger.Infof(ctx, "action '%s' applied to drone '%s', it is %s", action, serialNumber, drone.State)
+	return &previous, &drone, delivery, nil
+}
+
+// GetDeliveries the deliveries of a filter, the oldest dispatch first. The deliveries of a drone are read
+// through its index, the ones of a medication with a linear scan of all the deliveries: a buntdb index
+// only holds one value per item, not the codes of an array. The history grows with every dispatch, use
+// the postgres backend when it has to be queried by medication at scale
+func (r *repoDrones) GetDeliveries(ctx context.Context, filter *dto.DeliveryFilter) (_ *[]dto.Delivery, err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "get_deliveries", time.Now())
+	ctx, span := tracing.StartDB(ctx, metrics.RepoDrones, "get_deliveries")
+	defer func() { tracing.End(span, err) }()
+
+	from, to, err := deliveryRange(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := r.loadDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	if err = createDeliveryIndexes(db.DB); err != nil {
+		return nil, err
+	}
+
+	deliveries := make([]dto.Delivery, 0)
+	err = db.View(func(tx *buntdb.Tx) error {
+		if filter.SerialNumber != "" {
+			all, err := droneDeliveries(tx, filter.SerialNumber)
+			if err != nil {
+				return err
+			}
+			for i := range all {
+				if matchDelivery(&all[i], filter, from, to) {
+					deliveries = append(deliveries, all[i])
+				}
+			}
+			return nil
+		}
+
+		// linear scan, the medications of a delivery are not indexed
+		var errUnmarshal error
+		err := tx.AscendKeys(deliveryKeyPrefix+"*", func(key, value string) bool {
+			delivery := dto.Delivery{}
+			if errUnmarshal = jsoniter.UnmarshalFromString(value, &delivery); errUnmarshal != nil {
+				return false
+			}
+			if matchDelivery(&delivery, filter, from, to) {
+				deliveries = append(deliveries, delivery)
+			}
+			return true
+		})
+		return firstError(err, errUnmarshal)
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortDeliveries(deliveries)
+	return &deliveries, nil
+}
+
+// CheckingLoadedMedicationsItems checking loaded medication items for a given drone
+func (r *repoDrones) CheckingLoadedMedicationsItems(ctx context.Context, serialNumber string) (_ *[]string, err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "checking_loaded_medications_items", time.Now())
+	ctx, span := tracing.StartDB(ctx, metrics.RepoDrones, "checking_loaded_medications_items")
+	defer func() { tracing.End(span, err) }()
+
+	db, err := r.loadDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	// medications id slice loaded by the drone
+	loadedMeds := make([]string, 0)
+
+	db.CreateIndex("loaded_medications", "loaded_medications:*", buntdb.IndexString)
+	err = db.View(func(tx *buntdb.Tx) error {
+		value, err := tx.Get("loaded_medications:"+serialNumber)
+		if err != nil{
+			return err
+		}
+		err = jsoniter.UnmarshalFromString(value, &loadedMeds)
+		if err != nil{
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &loadedMeds, nil
+}
+
+// LoadMedicationItemsADrone replace the medications loaded on a drone, they must exist and the drone
+// must be able to carry them. It returns the codes that were loaded before, read in the same transaction
+func (r *repoDrones) LoadMedicationItemsADrone(ctx context.Context, drone *dto.Drone, medicationItemIDs []interface{}) (_ []string, err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "load_medication_items_a_drone", time.Now())
+	ctx, span := tracing.StartDB(ctx, metrics.RepoDrones, "load_medication_items_a_drone")
+	defer func() { tracing.End(span, err) }()
+
+	db, err := r.loadDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	// begin: validating medication item IDs
+	medication := dto.Medication{}
+	medicationIdsRealMap := make(map[string]float64)
+
+	_, scanSpan := tracing.Start(ctx, "scan_medications")
+	db.CreateIndex("medication_id", "med:*", buntdb.IndexJSON("weight"))
+	err = db.View(func(tx *buntdb.Tx) error {
+		err := tx.Descend("medication_id", func(key, value string) bool {
+			err = jsoniter.UnmarshalFromString(value, &medication)
+			if err == nil {
+				medicationIdsRealMap[medication.Code] = medication.Weight
+			}
+			return err == nil
+		})
+		return err
+	})
+	tracing.End(scanSpan, err)
+	if err != nil {
+		return nil, err
+	}
+
+	_, validateSpan := tracing.Start(ctx, "validate_medications", attribute.Int("medications.requested", len(medicationItemIDs)))
+	medicationItemIDs, err = validatePayload(drone, medicationIdsRealMap, medicationItemIDs)
+	tracing.End(validateSpan, err)
+	if err != nil {
+		return nil, err
+	}
+
+	// end: validating medication item IDs
+
+	var previous []string
+	_, writeSpan := tracing.Start(ctx, "write_loaded_medications")
+	err = db.Update(func(tx *buntdb.Tx) error {
+		// the drone is checked again with its stored state, it may have changed since it was read
+		current, err := txDrone(tx, drone.SerialNumber)
+		if err != nil {
+			return err
+		}
+		if err = checkLoadable(current, r.svcConf.Reloadable().MinBatteryToLoad); err != nil {
+			return err
+		}
+		if _, err = validatePayload(current, medicationIdsRealMap, medicationItemIDs); err != nil {
+			return err
+		}
+		if previous, err = loadedMedications(tx, drone.SerialNumber); err != nil {
+			return err
+		}
+
+		res, err := jsoniter.MarshalToString(medicationItemIDs)
+		if err != nil {
+			return err
+		}
+		_, _, err = tx.Set("loaded_medications:"+drone.SerialNumber, res, nil)
+		if err != nil {
+			return err
+		}
+		return txLoadedDrone(tx, current, medicationItemIDs)
+	})
+	tracing.End(writeSpan, err)
+	if err != nil {
+		return nil, err
+	}
+	r.logger.Infof(ctx, "drone '%s' loaded with medication items: %v", drone.SerialNumber, medicationItemIDs)
+
+	return previous, nil
+}
+
+// LoadMedicationItemsDrones replace the medications loaded on the drones in a single transaction. If the
+// medications of a drone don't exist, it can't carry them or it can't be loaded any longer (checkLoadable),
+// no drone is loaded and it fails with a *BatchError of the validation errors. It returns the codes that
+// were loaded on every drone before, in the order of the loads
+func (r *repoDrones) LoadMedicationItemsDrones(ctx context.Context, loads []dto.DroneLoad) (_ [][]string, err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "load_medication_items_drones", time.Now())
+	ctx, span := tracing.StartDB(ctx, metrics.RepoDrones, "load_medication_items_drones")
+	defer func() { tracing.End(span, err) }()
+	span.SetAttributes(attribute.Int("drones.count", len(loads)))
+
+	db, err := r.loadDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	previous := make([][]string, len(loads))
+	err = db.Update(func(tx *buntdb.Tx) error {
+		medicationWeights := make(map[string]float64)
+		var errIter error
+		err := tx.AscendKeys("med:*", func(key, value string) bool {
+			medication := dto.Medication{}
+			if errIter = jsoniter.UnmarshalFromString(value, &medication); errIter != nil {
+				return false
+			}
+			medicationWeights[medication.Code] = medication.Weight
+			return true
+		})
+		if err != nil {
+			return err
+		} else if errIter != nil {
+			return errIter
+		}
+
+		minBattery := r.svcConf.Reloadable().MinBatteryToLoad
+		batchErr := &BatchError{Errors: make(map[int]error)}
+		payloads := make([]string, len(loads))
+		drones := make([]*dto.Drone, len(loads))
+		items := make([][]interface{}, len(loads))
+		for i, load := range loads {
+			// the drones are checked again with their stored state, they may have changed since they were read
+			drone, err := txDrone(tx, load.Drone.SerialNumber)
+			if err != nil && err != buntdb.ErrNotFound {
+				return err
+			}
+			if err == nil {
+				err = checkLoadable(drone, minBattery)
+			}
+			if err != nil {
+				batchErr.Errors[i] = err
+				continue
+			}
+			medicationItemIDs, err := validatePayload(drone, medicationWeights, load.MedicationItemIDs)
+			if err != nil {
+				batchErr.Errors[i] = err
+				continue
+			}
+			if payloads[i], err = jsoniter.MarshalToString(medicationItemIDs); err != nil {
+				return err
+			}
+			drones[i], items[i] = drone, medicationItemIDs
+		}
+		if len(batchErr.Errors) > 0 {
+			return batchErr
+		}
+
+		for i, load := range loads {
+			if previous[i], err = loadedMedications(tx, load.Drone.SerialNumber); err != nil {
+				return err
+			}
+			if _, _, err := tx.Set("loaded_medications:"+load.Drone.SerialNumber, payloads[i], nil); err != nil {
+				return err
+			}
+			if err := txLoadedDrone(tx, drones[i], items[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	r.logger.Infof(ctx, "%d drones loaded with medication items", len(loads))
+	return previous, nil
+}
+
+func (r *repoDrones) ExistDrone(ctx context.Context, serialNumber string) (err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "exist_drone", time.Now())
+	ctx, span := tracing.StartDB(ctx, metrics.RepoDrones, "exist_drone")
+	defer func() { tracing.End(span, err) }()
+
+	db, err := r.loadDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	err = db.View(func(tx *buntdb.Tx) error {
+		_, err := tx.Get("drone:"+serialNumber)
+		if err != nil {
+			return err
+		}
+		return nil
+	})
+	// Getting non-existent values will cause an ErrNotFound error.
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetFleetStats A read-only transaction, compute the fleet summary in a single pass over the drones
+func (r *repoDrones) GetFleetStats(ctx context.Context) (_ *dto.FleetStats, err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "get_fleet_stats", time.Now())
+	ctx, span := tracing.StartDB(ctx, metrics.RepoDrones, "get_fleet_stats")
+	defer func() { tracing.End(span, err) }()
+
+	db, err := r.loadDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	stats := newFleetStatsBuilder(r.svcConf.Reloadable().MinBatteryToLoad)
+	err = db.View(func(tx *buntdb.Tx) error {
+		loadedWeights, err := loadedWeightByDrone(tx)
+		if err != nil {
+			return err
+		}
+
+		var errIter error
+		err = tx.AscendKeys("drone:*", func(key, value string) bool {
+			drone := dto.Drone{}
+			if errIter = jsoniter.UnmarshalFromString(value, &drone); errIter != nil {
+				return false
+			}
+			stats.add(&drone, loadedWeights[drone.SerialNumber])
+			return true
+		})
+		return firstError(err, errIter)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return stats.result(), nil
+}
+
+// endregion ======== Drones ======================================================
+
+// region ======== Medications ======================================================
+
+func (r *repoDrones) GetMedications(ctx context.Context) (_ *[]dto.Medication, err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "get_medications", time.Now())
+	ctx, span := tracing.StartDB(ctx, metrics.RepoDrones, "get_medications")
+	defer func() { tracing.End(span, err) }()
+
+	db, err := r.loadDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	medication := dto.Medication{}
+	medicationsList := make([]dto.Medication, 0)
+	// custom index: sort medications descending by weight
+	db.CreateIndex("medication_state", "med:*", buntdb.IndexJSON("weight"))
+	err = db.View(func(tx *buntdb.Tx) error {
+		err := tx.Descend("medication_state", func(key, value string) bool {
 			err = jsoniter.UnmarshalFromString(value, &medication)
 			if err == nil {
 				medicationsList = append(medicationsList, medication)
@@ -486,21 +994,262 @@ func (r *repoDrones) GetMedications() (*[]dto.Medication, error) {
 
 // endregion ======== Medications ======================================================
 
-// region ======== PRIVATE AUX ===========================================================
-func (r *repoDrones) loadDB() (*buntdb.DB, error) {
-	log.Println("Load DB ", r.DBUserLocation)
-	// Open the data.db file. It will be created if it doesn't exist.
-	db, err := buntdb.Open(r.DBUserLocation)
+// region ======== Dataset ======================================================
+
+// ExportData read the whole store database: users, drones (retired included), medications and the
+// medications loaded on every drone. The event logs are exported by RepoEventLog
+func (r *repoDrones) ExportData(ctx context.Context) (_ *dto.Dataset, err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "export_data", time.Now())
+	ctx, span := tracing.StartDB(ctx, metrics.RepoDrones, "export_data")
+	defer func() { tracing.End(span, err) }()
+
+	db, err := r.loadDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	dataset := dto.Dataset{
+		Version:     dto.DatasetVersion,
+		ExportedAt:  time.Now().UTC().Format(time.RFC3339),
+		Users:       make([]dto.User, 0),
+		Drones:      make([]dto.Drone, 0),
+		Medications: make([]dto.Medication, 0),
+		Payloads:    make(map[string][]string),
+		Logs:        make([]dto.LogEvent, 0),
+		Deliveries:  make([]dto.Delivery, 0),
+	}
+	err = db.View(func(tx *buntdb.Tx) error {
+		err := ascendUsers(tx, func(_ int, u dto.User) bool {
+			dataset.Users = append(dataset.Users, u)
+			return true
+		})
+		if err != nil {
+			return err
+		}
+
+		var errUnmarshal error
+		err = tx.AscendKeys("drone:*", func(key, value string) bool {
+			drone := dto.Drone{}
+			errUnmarshal = jsoniter.UnmarshalFromString(value, &drone)
+			dataset.Drones = append(dataset.Drones, drone)
+			return errUnmarshal == nil
+		})
+		if err != nil || errUnmarshal != nil {
+			return firstError(err, errUnmarshal)
+		}
+		err = tx.AscendKeys("med:*", func(key, value string) bool {
+			medication := dto.Medication{}
+			errUnmarshal = jsoniter.UnmarshalFromString(value, &medication)
+			dataset.Medications = append(dataset.Medications, medication)
+			return errUnmarshal == nil
+		})
+		if err != nil || errUnmarshal != nil {
+			return firstError(err, errUnmarshal)
+		}
+		err = tx.AscendKeys("loaded_medications:*", func(key, value string) bool {
+			codes := make([]string, 0)
+			errUnmarshal = jsoniter.UnmarshalFromString(value, &codes)
+			dataset.Payloads[strings.TrimPrefix(key, "loaded_medications:")] = codes
+			return errUnmarshal == nil
+		})
+		if err != nil || errUnmarshal != nil {
+			return firstError(err, errUnmarshal)
+		}
+		err = tx.AscendKeys(deliveryKeyPrefix+"*", func(key, value string) bool {
+			delivery := dto.Delivery{}
+			errUnmarshal = jsoniter.UnmarshalFromString(value, &delivery)
+			dataset.Deliveries = append(dataset.Deliveries, delivery)
+			return errUnmarshal == nil
+		})
+		return firstError(err, errUnmarshal)
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortDeliveries(dataset.Deliveries)
+	span.SetAttributes(attribute.Int("dataset.drones", len(dataset.Drones)))
+
+	return &dataset, nil
+}
+
+// ImportData write a dataset exported by ExportData in a single transaction. A populated database is
+// only overwritten if replace is true, then every key of the store database is deleted first
+//
+// - dataset [*dto.Dataset] ~ Dataset to import, up to version dto.DatasetVersion
+//
+// - replace [bool] ~ Overwrite a populated database
+func (r *repoDrones) ImportData(ctx context.Context, dataset *dto.Dataset, replace bool) (err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "import_data", time.Now())
+	ctx, span := tracing.StartDB(ctx, metrics.RepoDrones, "import_data")
+	defer func() { tracing.End(span, err) }()
+
+	if dataset.Version < 1 || dataset.Version > dto.DatasetVersion {
+		return fmt.Errorf("%w %d, expected 1 to %d", schema.ErrDatasetVersion, dataset.Version, dto.DatasetVersion)
+	}
+	if err := validateDataset(dataset); err != nil {
+		return err
+	}
+	loadDatasetDrones(dataset)
+
+	db, err := r.loadDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if isPopulated(db.DB) && !replace {
+		return errors.New(schema.ErrBuntdbPopulated)
+	}
+
+	err = db.Update(func(tx *buntdb.Tx) error {
+		if err := tx.DeleteAll(); err != nil {
+			return err
+		}
+
+		values := make(map[string]interface{})
+		for i, u := range dataset.Users {
+			values[userKey(i)] = u
+		}
+		for _, d := range dataset.Drones {
+			values["drone:"+d.SerialNumber] = d
+		}
+		for _, m := range dataset.Medications {
+			values["med:"+m.Code] = m
+		}
+		for serialNumber, codes := range dataset.Payloads {
+			values["loaded_medications:"+serialNumber] = codes
+		}
+		for _, d := range dataset.Deliveries {
+			values[deliveryKeyPrefix+d.ID] = d
+		}
+		values["config"] = dto.ConfigDB{IsPopulated: true, SchemaVersion: latestSchemaVersion()}
+
+		for key, value := range values {
+			res, err := jsoniter.MarshalToString(value)
+			if err != nil {
+				return err
+			}
+			if _, _, err = tx.Set(key, res, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	r.logger.Infof(ctx, "dataset imported: %d users, %d drones, %d medications", len(dataset.Users), len(dataset.Drones), len(dataset.Medications))
+
+	return nil
+}
+
+// Snapshot write a consistent snapshot of the store database file, it stays online
+func (r *repoDrones) Snapshot(ctx context.Context, w io.Writer) (err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "snapshot", time.Now())
+	_, span := tracing.StartDB(ctx, metrics.RepoDrones, "snapshot")
+	defer func() { tracing.End(span, err) }()
+
+	return saveDB(r.DBUserLocation, w)
+}
+
+// RestoreSnapshot replace the whole store database with a snapshot written by Snapshot
+func (r *repoDrones) RestoreSnapshot(ctx context.Context, rd io.Reader) (err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "restore_snapshot", time.Now())
+	ctx, span := tracing.StartDB(ctx, metrics.RepoDrones, "restore_snapshot")
+	defer func() { tracing.End(span, err) }()
+
+	if err := restoreDB(r.DBUserLocation, rd); err != nil {
+		return err
+	}
+	r.logger.Infof(ctx, "store database restored from a snapshot")
+	return nil
+}
+
+// Migrate run the pending schema migrations of the store database in a single transaction. In a dry
+// run the migrations run too, but the transaction is rolled back, so the report tells what would change
+//
+// - dryRun [bool] ~ Report the changes without writing them
+func (r *repoDrones) Migrate(ctx context.Context, dryRun bool) (_ *dto.MigrationReport, err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "migrate", time.Now())
+	ctx, span := tracing.StartDB(ctx, metrics.RepoDrones, "migrate")
+	defer func() { tracing.End(span, err) }()
+
+	db, err := r.loadDB()
 	if err != nil {
-		log.Fatal(err)
 		return nil, err
 	}
+	defer db.Close()
+
+	report := dto.MigrationReport{DryRun: dryRun, Migrations: make([]dto.MigrationResult, 0)}
+	err = db.Update(func(tx *buntdb.Tx) error {
+		if err := runMigrations(tx, &report); err != nil {
+			return err
+		}
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && err != errDryRun {
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("schema.from", report.FromVersion), attribute.Int("schema.to", report.ToVersion))
+	for _, m := range report.Migrations {
+		r.logger.Infof(ctx, "migration %d (%s): %d changes, dry run: %t", m.Version, m.Name, len(m.Changes), dryRun)
+	}
 
-	return db, nil
+	return &report, nil
+}
+
+// endregion ======== Dataset ======================================================
+
+// Ping check that the store database can be opened and read
+func (r *repoDrones) Ping(ctx context.Context) (err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "ping", time.Now())
+	_, span := tracing.StartDB(ctx, metrics.RepoDrones, "ping")
+	defer func() { tracing.End(span, err) }()
+
+	db, err := r.loadDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.View(func(tx *buntdb.Tx) error {
+		_, err := tx.Len()
+		return err
+	})
+}
+
+// region ======== PRIVATE AUX ===========================================================
+
+// names of the drone indexes
+const (
+	idxDroneBattery = "drone_battery"
+	idxDroneState   = "drone_state"
+	idxDroneModel   = "drone_model"
+)
+
+// createDroneIndexes create the indexes used to filter the drone list, the ties are sorted by key (serial number)
+func createDroneIndexes(db *buntdb.DB) error {
+	if err := db.CreateIndex(idxDroneBattery, "drone:*", buntdb.IndexJSON("batteryCapacity")); err != nil && err != buntdb.ErrIndexExists {
+		return err
+	}
+	if err := db.CreateIndex(idxDroneState, "drone:*", buntdb.IndexJSON("state")); err != nil && err != buntdb.ErrIndexExists {
+		return err
+	}
+	if err := db.CreateIndex(idxDroneModel, "drone:*", buntdb.IndexJSON("model")); err != nil && err != buntdb.ErrIndexExists {
+		return err
+	}
+	return nil
+}
+
+func (r *repoDrones) loadDB() (*handle, error) {
+	// Open the data.db file. It will be created if it doesn't exist.
+	return openDB(r.DBUserLocation)
 }
 
 func isPopulated(db *buntdb.DB) bool {
-	log.Println("checking if StoreDB has already been populated")
 	configDB := dto.ConfigDB{}
 	db.CreateIndex("config", "config", buntdb.IndexString)
 	err := db.View(func(tx *buntdb.Tx) error {
@@ -508,15 +1257,9 @@ func isPopulated(db *buntdb.DB) bool {
 		if err != nil{
 			return err
 		}
-		err = jsoniter.UnmarshalFromString(value, &configDB)
-		if err != nil{
-			log.Println("Unmarshal Error in IsPopulated func: ", err)
-			return err
-		}
-		return nil
+		return jsoniter.UnmarshalFromString(value, &configDB)
 	})
 	if err == buntdb.ErrNotFound {
-		log.Println("Database not found")
 		return false
 	} else if err != nil {
 		panic(err)
@@ -525,123 +1268,83 @@ func isPopulated(db *buntdb.DB) bool {
 	return configDB.IsPopulated
 }
 
-func fakeUsers() []dto.User {
-	var users = []dto.User{{
-		Passphrase: "0b14d501a594442a01c6859541bcb3e8164d183d32937b851835442f69d5c94e", // password1
-		Username:   "richard.sargon@meinermail.com",
-		Name:       "Richard Sargon",
-	}, {
-		Passphrase: "6cf615d5bcaac778352a8f1f3360d23f02f34ec182e259897fd6ce485d7870d4", // password2
-		Username:   "tom.carter@meinermail.com",
-		Name:       "Tom Carter",
-	}}
-	return users
-}
-
-func fakeDrones() []dto.Drone {
-	uuid := "123e4567-e89b-12d3-a456-4266141740"
-	var drones = []dto.Drone{{
-		SerialNumber:    uuid+"01",
-		Model:           dto.Cruiserweight,
-		WeightLimit:     lib.CalculateDroneWeightLimit(dto.Cruiserweight),
-		BatteryCapacity: 45,
-		State:           dto.IDLE,
-	}, {
-		SerialNumber:    uuid+"02",
-		Model:           dto.Middleweight,
-		WeightLimit:     lib.CalculateDroneWeightLimit(dto.Middleweight),
-		BatteryCapacity: 56.4,
-		State:           dto.DELIVERED,
-	}, {
-		SerialNumber:    uuid+"03",
-		Model:           dto.Heavyweight,
-		WeightLimit:     lib.CalculateDroneWeightLimit(dto.Heavyweight),
-		BatteryCapacity: 99.2,
-		State:           dto.LOADING,
-	}, {
-		SerialNumber:    uuid+"04",
-		Model:           dto.Middleweight,
-		WeightLimit:     lib.CalculateDroneWeightLimit(dto.Middleweight),
-		BatteryCapacity: 35.6,
-		State:           dto.RETURNING,
-	}, {
-		SerialNumber:    uuid+"05",
-		Model:           dto.Heavyweight,
-		WeightLimit:     lib.CalculateDroneWeightLimit(dto.Heavyweight),
-		BatteryCapacity: 52.9,
-		State:           dto.DELIVERING,
-	}, {
-		SerialNumber:    uuid+"06",
-		Model:           dto.Lightweight,
-		WeightLimit:     lib.CalculateDroneWeightLimit(dto.Lightweight),
-		BatteryCapacity: 12.9,
-		State:           dto.IDLE,
-	}, {
-		SerialNumber:    uuid+"07",
-		Model:           dto.Cruiserweight,
-		WeightLimit:     lib.CalculateDroneWeightLimit(dto.Cruiserweight),
-		BatteryCapacity: 91.3,
-		State:           dto.LOADED,
-	}, {
-		SerialNumber:    uuid+"08",
-		Model:           dto.Heavyweight,
-		WeightLimit:     lib.CalculateDroneWeightLimit(dto.Heavyweight),
-		BatteryCapacity: 73.6,
-		State:           dto.LOADED,
-	},{
-		SerialNumber:    uuid+"09",
-		Model:           dto.Lightweight,
-		WeightLimit:     lib.CalculateDroneWeightLimit(dto.Lightweight),
-		BatteryCapacity: 25,
-		State:           dto.IDLE,
-	},{
-		SerialNumber:    uuid+"10",
-		Model:           dto.Lightweight,
-		WeightLimit:     lib.CalculateDroneWeightLimit(dto.Lightweight),
-		BatteryCapacity: 25,
-		State:           dto.IDLE,
-	}}
-	return drones
-}
-
-func fakeMedications() []dto.Medication {
-	var medications = []dto.Medication{{
-		Name:   gofakeit.Password(true, true, true, false, false, 12),
-		Weight: 700,
-		Code:   gofakeit.Password(false, true, true, false, false, 10),
-		Image:  base64.StdEncoding.EncodeToString([]byte("fake_image")),
-	}, {
-		Name:   lib.NormalizeString(gofakeit.Company(), true),
-		Weight: 210,
-		Code:   gofakeit.Password(false, true, true, false, false, 10),
-		Image:  base64.StdEncoding.EncodeToString([]byte("fake_image")),
-	}, {
-		Name:   lib.NormalizeString(gofakeit.Company(), true),
-		Weight: 34,
-		Code:   gofakeit.Password(false, true, true, false, false, 10),
-		Image:  base64.StdEncoding.EncodeToString([]byte("fake_image")),
-	}, {
-		Name:   lib.NormalizeString(gofakeit.Company(), true),
-		Weight: 115,
-		Code:   gofakeit.Password(false, true, true, false, false, 10),
-		Image:  base64.StdEncoding.EncodeToString([]byte("fake_image")),
-	}, {
-		Name:   lib.NormalizeString(gofakeit.Company(), true),
-		Weight: 490,
-		Code:   gofakeit.Password(false, true, true, false, false, 10),
-		Image:  base64.StdEncoding.EncodeToString([]byte("fake_image")),
-	}, {
-		Name:   lib.NormalizeString(gofakeit.Company(), true),
-		Weight: 226,
-		Code:   gofakeit.Password(false, true, true, false, false, 10),
-		Image:  base64.StdEncoding.EncodeToString([]byte("fake_image")),
-	}, {
-		Name:   lib.NormalizeString(gofakeit.Company(), true),
-		Weight: 397,
-		Code:   gofakeit.Password(false, true, true, false, false, 10),
-		Image:  base64.StdEncoding.EncodeToString([]byte("fake_image")),
-	}}
-	return medications
+// ascendUsers iterate the users, stored under "user:<n>" keys, in key order
+func ascendUsers(tx *buntdb.Tx, iterator func(key int, user dto.User) bool) error {
+	var errUnmarshal error
+	err := tx.AscendKeys(userKeyPrefix+"*", func(key, value string) bool {
+		id, err := strconv.Atoi(strings.TrimPrefix(key, userKeyPrefix))
+		if err != nil {
+			return true // not a user
+		}
+		user := dto.User{}
+		if errUnmarshal = jsoniter.UnmarshalFromString(value, &user); errUnmarshal != nil {
+			return false
+		}
+		return iterator(id, user)
+	})
+	return firstError(err, errUnmarshal)
+}
+
+// validateDataset check that the payloads only reference drones and medications of the dataset
+func validateDataset(dataset *dto.Dataset) error {
+	drones := make(map[string]bool, len(dataset.Drones))
+	for _, d := range dataset.Drones {
+		if d.SerialNumber == "" {
+			return fmt.Errorf("%w: a drone without serial number", schema.ErrInvalidDataset)
+		}
+		drones[d.SerialNumber] = true
+	}
+	medications := make(map[string]bool, len(dataset.Medications))
+	for _, m := range dataset.Medications {
+		medications[m.Code] = true
+	}
+	for serialNumber, codes := range dataset.Payloads {
+		if !drones[serialNumber] {
+			return fmt.Errorf("%w: payload of the unknown drone '%s'", schema.ErrInvalidDataset, serialNumber)
+		}
+		for _, code := range codes {
+			if !medications[code] {
+				return fmt.Errorf("%w: drone '%s' is loaded with the unknown medication '%s'", schema.ErrInvalidDataset, serialNumber, code)
+			}
+		}
+	}
+	for _, d := range dataset.Deliveries {
+		if d.ID == "" {
+			return fmt.Errorf("%w: a delivery without ID", schema.ErrInvalidDataset)
+		}
+		if !drones[d.SerialNumber] {
+			return fmt.Errorf("%w: delivery '%s' of the unknown drone '%s'", schema.ErrInvalidDataset, d.ID, d.SerialNumber)
+		}
+	}
+	return nil
+}
+
+// loadDatasetDrones move the IDLE drones of a dataset that carry medications to LOADED, like the version 2
+// migration, the datasets exported before it have them IDLE
+func loadDatasetDrones(dataset *dto.Dataset) {
+	for i := range dataset.Drones {
+		if drone := &dataset.Drones[i]; drone.State == dto.IDLE && len(dataset.Payloads[drone.SerialNumber]) > 0 {
+			drone.State = dto.LOADED
+		}
+	}
+}
+
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isRetiredDrone check the retired flag of a stored drone
+func isRetiredDrone(value string) (bool, error) {
+	drone := dto.Drone{}
+	if err := jsoniter.UnmarshalFromString(value, &drone); err != nil {
+		return false, err
+	}
+	return drone.Retired, nil
 }
 
 // thereAreAll compares the request IDs (medicationItemIDs) with the collection
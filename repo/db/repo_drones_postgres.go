@@ -0,0 +1,1164 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/kmilodenisglez/drones.restapi/schema"
+	"github.com/kmilodenisglez/drones.restapi/schema/dto"
+	"github.com/kmilodenisglez/drones.restapi/service/metrics"
+	"github.com/kmilodenisglez/drones.restapi/service/tracing"
+	"github.com/kmilodenisglez/drones.restapi/service/utils"
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// region ======== SETUP =================================================================
+
+// pgRepoDrones RepoDrones of the postgres backend, it behaves like the buntdb one: the same errors,
+// the same order of the lists and the same drone filters and pagination
+type pgRepoDrones struct {
+	DSN     string
+	svcConf *utils.SvcConfig
+	logger  *utils.SvcLogger
+}
+
+// pgQuerier a connection pool or a transaction
+type pgQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// pgRowScanner a single row or the current row of a result set
+type pgRowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// pgDroneColumns the columns of a drone, in the order of scanPgDrone
+const pgDroneColumns = "serial_number, model, weight_limit, battery_capacity, state, version, retired, retired_at"
+
+// pgDeliveryColumns the columns of a delivery, in the order of scanPgDelivery
+const pgDeliveryColumns = "id, serial_number, medications, status, dispatched_at, delivered_at, aborted_at, returned_at"
+
+// pgLoadedWeights the weight of the medications loaded on every drone
+const pgLoadedWeights = `SELECT p.serial_number, SUM(m.weight) AS loaded
+	FROM payloads p JOIN medications m ON m.code = p.medication_code
+	GROUP BY p.serial_number`
+
+// pgSnapshot the snapshot of the store tables, the populated flag is kept with the dataset
+type pgSnapshot struct {
+	Populated bool `json:"populated"`
+	dto.Dataset
+}
+
+// endregion =============================================================================
+
+func newPgRepoDrones(svcConf *utils.SvcConfig, svcLog *utils.SvcLogger) RepoDrones {
+	return &pgRepoDrones{DSN: svcConf.PostgresDSN, svcConf: svcConf, logger: svcLog}
+}
+
+// region ======== METHODS ===============================================================
+
+func (r *pgRepoDrones) IsPopulated(ctx context.Context) bool {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "is_populated", time.Now())
+	ctx, span := startPgSpan(ctx, metrics.RepoDrones, "is_populated")
+	defer span.End()
+
+	pool, err := openPostgres(r.DSN)
+	if err != nil {
+		return false
+	}
+	populated, err := pgIsPopulated(ctx, pool)
+	return err == nil && populated
+}
+
+// GetUser get the user by username, an empty user if it doesn't exist
+func (r *pgRepoDrones) GetUser(ctx context.Context, field string, filterOptional ...bool) (_ *dto.User, err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "get_user", time.Now())
+	ctx, span := startPgSpan(ctx, metrics.RepoDrones, "get_user")
+	defer func() { tracing.End(span, err) }()
+
+	user := dto.User{}
+	if len(filterOptional) == 0 || !filterOptional[0] {
+		return &user, nil
+	}
+
+	pool, err := openPostgres(r.DSN)
+	if err != nil {
+		return nil, err
+	}
+	err = pool.QueryRowContext(ctx, "SELECT username, name, passphrase, admin FROM users WHERE username = $1", field).
+		Scan(&user.Username, &user.Name, &user.Passphrase, &user.Admin)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetUsers return a list of dto.User
+func (r *pgRepoDrones) GetUsers(ctx context.Context) (_ *[]dto.User, err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "get_users", time.Now())
+	ctx, span := startPgSpan(ctx, metrics.RepoDrones, "get_users")
+	defer func() { tracing.End(span, err) }()
+
+	pool, err := openPostgres(r.DSN)
+	if err != nil {
+		return nil, err
+	}
+	var list []dto.User
+	err = pgEachUser(ctx, pool, func(user dto.User) {
+		list = append(list, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// CreateUser create a new user, it fails with schema.ErrUserAlreadyExists if the username is in use.
+// The passphrase must be already hashed
+func (r *pgRepoDrones) CreateUser(ctx context.Context, user *dto.User) (err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "create_user", time.Now())
+	ctx, span := startPgSpan(ctx, metrics.RepoDrones, "create_user")
+	defer func() { tracing.End(span, err) }()
+
+	pool, err := openPostgres(r.DSN)
+	if err != nil {
+		return err
+	}
+	if err := pgInsertUser(ctx, pool, user); err != nil {
+		if isPgUniqueViolation(err) {
+			return schema.ErrUserAlreadyExists
+		}
+		return err
+	}
+	r.logger.Infof(ctx, "user '%s' created", user.Username)
+	return nil
+}
+
+// region ======== Drones ======================================================
+
+// GetDrone get a specific drone
+func (r *pgRepoDrones) GetDrone(ctx context.Context, serialNumber string) (_ *dto.Drone, err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "get_drone", time.Now())
+	ctx, span := startPgSpan(ctx, metrics.RepoDrones, "get_drone")
+	defer func() { tracing.End(span, err) }()
+
+	pool, err := openPostgres(r.DSN)
+	if err != nil {
+		return nil, err
+	}
+	row := pool.QueryRowContext(ctx, "SELECT "+pgDroneColumns+" FROM drones WHERE serial_number = $1", serialNumber)
+	drone, err := scanPgDrone(row)
+	if err != nil {
+		return nil, pgNotFound(err)
+	}
+	return drone, nil
+}
+
+// GetDrones return a page of the drones that match the filter. The indexed criteria are filtered by the
+// query, then the drones are checked, sorted and paginated like the buntdb backend does
+func (r *pgRepoDrones) GetDrones(ctx context.Context, filter *dto.DroneFilter) (_ *dto.DronePage, err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "get_drones", time.Now())
+	ctx, span := startPgSpan(ctx, metrics.RepoDrones, "get_drones")
+	defer func() { tracing.End(span, err) }()
+
+	pool, err := openPostgres(r.DSN)
+	if err != nil {
+		return nil, err
+	}
+
+	query := "SELECT " + pgDroneColumns + ", COALESCE(l.loaded, 0) FROM drones LEFT JOIN (" + pgLoadedWeights + ") l USING (serial_number) WHERE retired = $1"
+	args := []interface{}{filter.Retired}
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		query += fmt.Sprintf(" AND "+condition, len(args))
+	}
+	if len(filter.States) > 0 {
+		states := make([]int64, 0, len(filter.States))
+		for _, s := range uniqueStates(filter.States) {
+			states = append(states, int64(s))
+		}
+		where("state = ANY($%d)", pq.Array(states))
+	}
+	if len(filter.Models) > 0 {
+		models := make([]int64, 0, len(filter.Models))
+		for _, m := range uniqueModels(filter.Models) {
+			models = append(models, int64(m))
+		}
+		where("model = ANY($%d)", pq.Array(models))
+	}
+	if filter.BatteryMin != nil {
+		where("battery_capacity >= $%d", *filter.BatteryMin)
+	}
+	if filter.BatteryMax != nil {
+		where("battery_capacity <= $%d", *filter.BatteryMax)
+	}
+
+	dronesList := make([]dto.Drone, 0)
+	loadedWeights := make(map[string]float64)
+	err = pgEachDrone(ctx, pool, query, args, func(drone *dto.Drone, loaded float64) {
+		loadedWeights[drone.SerialNumber] = loaded
+		if matchDroneFilter(drone, filter, loadedWeights) {
+			dronesList = append(dronesList, *drone)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return paginateDrones(dronesList, filter)
+}
+
+// RegisterDrone create a new drone, it fails with schema.ErrDroneAlreadyExists if the serial number is in use
+func (r *pgRepoDrones) RegisterDrone(ctx context.Context, drone *dto.Drone) (err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "register_drone", time.Now())
+	ctx, span := startPgSpan(ctx, metrics.RepoDrones, "register_drone")
+	defer func() { tracing.End(span, err) }()
+
+	pool, err := openPostgres(r.DSN)
+	if err != nil {
+		return err
+	}
+
+	err = pgTx(ctx, pool, nil, func(tx *sql.Tx) error {
+		retired := false
+		err := tx.QueryRowContext(ctx, "SELECT retired FROM drones WHERE serial_number = $1", drone.SerialNumber).Scan(&retired)
+		if err == nil {
+			// a retired serial number is kept with its history, it can't be re-registered
+			if retired {
+				return schema.ErrDroneRetired
+			}
+			return schema.ErrDroneAlreadyExists
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		drone.Version = 1
+		return pgInsertDrone(ctx, tx, drone)
+	})
+	if isPgUniqueViolation(err) {
+		// registered at the same time by another request
+		return schema.ErrDroneAlreadyExists
+	} else if err != nil {
+		return err
+	}
+	r.logger.Infof(ctx, "drone '%s' registered", drone.SerialNumber)
+	return nil
+}
+
+// RegisterDrones create the drones in a single transaction. If a serial number is in use (or repeated in
+// the batch) none of them is created and it fails with a *BatchError of the ErrDroneAlreadyExists and
+// ErrDroneRetired errors
+func (r *pgRepoDrones) RegisterDrones(ctx context.Context, drones []dto.Drone) (err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "register_drones", time.Now())
+	ctx, span := startPgSpan(ctx, metrics.RepoDrones, "register_drones")
+	defer func() { tracing.End(span, err) }()
+	span.SetAttributes(attribute.Int("drones.count", len(drones)))
+
+	pool, err := openPostgres(r.DSN)
+	if err != nil {
+		return err
+	}
+
+	err = pgTx(ctx, pool, nil, func(tx *sql.Tx) error {
+		batchErr := &BatchError{Errors: make(map[int]error)}
+		seen := make(map[string]bool, len(drones))
+		for i := range drones {
+			retired := false
+			err := tx.QueryRowContext(ctx, "SELECT retired FROM drones WHERE serial_number = $1", drones[i].SerialNumber).Scan(&retired)
+			switch {
+			case err == nil && retired:
+				batchErr.Errors[i] = schema.ErrDroneRetired
+			case err == nil || seen[drones[i].SerialNumber]:
+				batchErr.Errors[i] = schema.ErrDroneAlreadyExists
+			case !errors.Is(err, sql.ErrNoRows):
+				return err
+			}
+			seen[drones[i].SerialNumber] = true
+		}
+		if len(batchErr.Errors) > 0 {
+			return batchErr
+		}
+
+		for i := range drones {
+			drones[i].Version = 1
+			if err := pgInsertDrone(ctx, tx, &drones[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if isPgUniqueViolation(err) {
+		// registered at the same time by another request
+		return schema.ErrDroneAlreadyExists
+	} else if err != nil {
+		return err
+	}
+	r.logger.Infof(ctx, "%d drones registered", len(drones))
+	return nil
+}
+
+// UpdateDrone replace an existing drone and increment its version. If expectedVersion is not nil
+// and differs from the stored version, it fails with schema.ErrDroneVersionMismatch; the state can't be
+// changed, it fails with schema.ErrDroneStateReadOnly. It returns the drone as it was before the update,
+// read in the same transaction
+func (r *pgRepoDrones) UpdateDrone(ctx context.Context, drone *dto.Drone, expectedVersion *uint64) (_ *dto.Drone, err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "update_drone", time.Now())
+	ctx, span := startPgSpan(ctx, metrics.RepoDrones, "update_drone")
+	defer func() { tracing.End(span, err) }()
+
+	pool, err := openPostgres(r.DSN)
+	if err != nil {
+		return nil, err
+	}
+
+	var current *dto.Drone
+	err = pgTx(ctx, pool, nil, func(tx *sql.Tx) error {
+		// the row is locked until the commit, so two concurrent updates can't both succeed
+		current, err = pgLockDrone(ctx, tx, drone.SerialNumber)
+		if err != nil {
+			return err
+		}
+		if current.Retired {
+			return schema.ErrDroneRetired
+		}
+		if expectedVersion != nil && *expectedVersion != current.Version {
+			return schema.ErrDroneVersionMismatch
+		}
+		if drone.State != current.State {
+			return schema.ErrDroneStateReadOnly
+		}
+
+		drone.Version = current.Version + 1
+		return pgUpdateDrone(ctx, tx, drone)
+	})
+	if err != nil {
+		return nil, err
+	}
+	r.logger.Infof(ctx, "drone '%s' updated to version %d", drone.SerialNumber, drone.Version)
+	return current, nil
+}
+
+// RetireDrone decommission a drone (soft delete). The drone is kept with its history, but it is hidden from
+// GetDrones and can't be updated nor re-registered. It fails with schema.ErrDroneNotRetirable if the drone
+// is mid-delivery or has loaded medications. It returns the drone before and after the retirement
+func (r *pgRepoDrones) RetireDrone(ctx context.Context, serialNumber string, expectedVersion *uint64) (_, _ *dto.Drone, err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "retire_drone", time.Now())
+	ctx, span := startPgSpan(ctx, metrics.RepoDrones, "retire_drone")
+	defer func() { tracing.End(span, err) }()
+
+	pool, err := openPostgres(r.DSN)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var previous dto.Drone
+	var drone *dto.Drone
+	err = pgTx(ctx, pool, nil, func(tx *sql.Tx) error {
+		drone, err = pgLockDrone(ctx, tx, serialNumber)
+		if err != nil {
+			return err
+		}
+		if drone.Retired {
+			return schema.ErrDroneRetired
+		}
+		if expectedVersion != nil && *expectedVersion != drone.Version {
+			return schema.ErrDroneVersionMismatch
+		}
+		if drone.State != dto.IDLE {
+			return schema.ErrDroneNotRetirable
+		}
+		// a drone with loaded medications is not retired, the items must be unloaded first
+		loaded := 0
+		if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM payloads WHERE serial_number = $1", serialNumber).Scan(&loaded); err != nil {
+			return err
+		}
+		if loaded > 0 {
+			return schema.ErrDroneNotRetirable
+		}
+
+		previous = *drone
+		drone.Retired = true
+		drone.RetiredAt = time.Now().UTC().Format(time.RFC3339)
+		drone.Version++
+		return pgUpdateDrone(ctx, tx, drone)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	r.logger.Infof(ctx, "drone '%s' retired", serialNumber)
+	return &previous, drone, nil
+}
+
+// AdvanceDrone apply a lifecycle action (dto.DeliveryAction*) to a drone and write its delivery in the same
+// transaction. It fails with a *TransitionError if the action is not allowed in the state of the drone and
+// with schema.ErrDroneNotLoaded if a drone without medications is dispatched. The medications are unloaded
+// when they are delivered. It returns the drone before and after the action, and the delivery
+func (r *pgRepoDrones) AdvanceDrone(ctx context.Context, serialNumber, action string, expectedVersion *uint64) (_, _ *dto.Drone, _ *dto.Delivery, err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "advance_drone", time.Now())
+	ctx, span := startPgSpan(ctx, metrics.RepoDrones, "advance_drone")
+	defer func() { tracing.End(span, err) }()
+	span.SetAttributes(attribute.String("delivery.action", action))
+
+	pool, err := openPostgres(r.DSN)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var previous dto.Drone
+	var drone *dto.Drone
+	var delivery *dto.Delivery
+	err = pgTx(ctx, pool, nil, func(tx *sql.Tx) error {
+		drone, err = pgLockDrone(ctx, tx, serialNumber)
+		if err != nil {
+			return err
+		}
+		if drone.Retired {
+			return schema.ErrDroneRetired
+		}
+		if expectedVersion != nil && *expectedVersion != drone.Version {
+			return schema.ErrDroneVersionMismatch
+		}
+
+		payloads, err := pgPayloads(ctx, tx, "WHERE serial_number = $1", serialNumber)
+		if err != nil {
+			return err
+		}
+		row := tx.QueryRowContext(ctx, "SELECT "+pgDeliveryColumns+` FROM deliveries
+			WHERE serial_number = $1 AND returned_at = '' ORDER BY dispatched_at DESC, id DESC LIMIT 1`, serialNumber)
+		active, err := scanPgDelivery(row)
+		if errors.Is(err, sql.ErrNoRows) {
+			active = nil
+		} else if err != nil {
+			return err
+		}
+		previous = *drone
+		if delivery, err = advanceDrone(drone, action, payloads[serialNumber], active); err != nil {
+			return err
+		}
+
+		if err := pgUpdateDrone(ctx, tx, drone); err != nil {
+			return err
+		}
+		if delivery != nil {
+			if err := pgUpsertDelivery(ctx, tx, delivery); err != nil {
+				return err
+			}
+		}
+		if action == dto.DeliveryActionDelivered {
+			_, err = tx.ExecContext(ctx, "DELETE FROM payloads WHERE serial_number = $1", serialNumber)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	r.logger.Infof(ctx, "action '%s' applied to drone '%s', it is %s", action, serialNumber, drone.State)
+	return &previous, drone, delivery, nil
+}
+
+// GetDeliveries the deliveries of a filter, the oldest dispatch first. The deliveries of a medication are
+// found through the GIN index of the medications carried
+func (r *pgRepoDrones) GetDeliveries(ctx context.Context, filter *dto.DeliveryFilter) (_ *[]dto.Delivery, err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "get_deliveries", time.Now())
+	ctx, span := startPgSpan(ctx, metrics.RepoDrones, "get_deliveries")
+	defer func() { tracing.End(span, err) }()
+
+	from, to, err := deliveryRange(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := openPostgres(r.DSN)
+	if err != nil {
+		return nil, err
+	}
+
+	clause := "WHERE TRUE"
+	args := make([]interface{}, 0, 4)
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		clause += fmt.Sprintf(" AND "+condition, len(args))
+	}
+	if filter.SerialNumber != "" {
+		where("serial_number = $%d", filter.SerialNumber)
+	}
+	if filter.MedicationCode != "" {
+		where("medications @> ARRAY[$%d]::TEXT[]", filter.MedicationCode)
+	}
+	if from != "" || to != "" {
+		clause += " AND dispatched_at <> ''"
+	}
+	if from != "" {
+		where("dispatched_at >= $%d", from)
+	}
+	if to != "" {
+		where("dispatched_at <= $%d", to)
+	}
+
+	deliveries := make([]dto.Delivery, 0)
+	err = pgEachDelivery(ctx, pool, clause+" ORDER BY dispatched_at, id", args, func(delivery dto.Delivery) {
+		deliveries = append(deliveries, delivery)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &deliveries, nil
+}
+
+// CheckingLoadedMedicationsItems checking loaded medication items for a given drone, it fails with
+// ErrNotFound if the drone has not been loaded
+func (r *pgRepoDrones) CheckingLoadedMedicationsItems(ctx context.Context, serialNumber string) (_ *[]string, err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "checking_loaded_medications_items", time.Now())
+	ctx, span := startPgSpan(ctx, metrics.RepoDrones, "checking_loaded_medications_items")
+	defer func() { tracing.End(span, err) }()
+
+	pool, err := openPostgres(r.DSN)
+	if err != nil {
+		return nil, err
+	}
+	payloads, err := pgPayloads(ctx, pool, "WHERE serial_number = $1", serialNumber)
+	if err != nil {
+		return nil, err
+	}
+	loadedMeds, ok := payloads[serialNumber]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &loadedMeds, nil
+}
+
+// LoadMedicationItemsADrone replace the medications loaded on a drone, they must exist and the drone
+// must be able to carry them. It returns the codes that were loaded before, read in the same transaction
+func (r *pgRepoDrones) LoadMedicationItemsADrone(ctx context.Context, drone *dto.Drone, medicationItemIDs []interface{}) (_ []string, err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "load_medication_items_a_drone", time.Now())
+	ctx, span := startPgSpan(ctx, metrics.RepoDrones, "load_medication_items_a_drone")
+	defer func() { tracing.End(span, err) }()
+
+	pool, err := openPostgres(r.DSN)
+	if err != nil {
+		return nil, err
+	}
+
+	var previous []string
+	err = pgTx(ctx, pool, nil, func(tx *sql.Tx) error {
+		medicationWeights := make(map[string]float64)
+		err := pgEachMedication(ctx, tx, func(medication dto.Medication) {
+			medicationWeights[medication.Code] = medication.Weight
+		})
+		if err != nil {
+			return err
+		}
+		// the drone is checked again with its stored state, it may have changed since it was read
+		current, err := pgLockDrone(ctx, tx, drone.SerialNumber)
+		if err != nil {
+			return err
+		}
+		if err = checkLoadable(current, r.svcConf.Reloadable().MinBatteryToLoad); err != nil {
+			return err
+		}
+		if medicationItemIDs, err = validatePayload(current, medicationWeights, medicationItemIDs); err != nil {
+			return err
+		}
+
+		codes := make([]string, 0, len(medicationItemIDs))
+		for _, id := range medicationItemIDs {
+			codes = append(codes, id.(string))
+		}
+		if previous, err = pgLoadedCodes(ctx, tx, drone.SerialNumber); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM payloads WHERE serial_number = $1", drone.SerialNumber); err != nil {
+			return err
+		}
+		if err := pgInsertPayload(ctx, tx, drone.SerialNumber, codes); err != nil {
+			return err
+		}
+		current.State = loadedState(medicationItemIDs)
+		current.Version++
+		return pgUpdateDrone(ctx, tx, current)
+	})
+	if err != nil {
+		return nil, err
+	}
+	r.logger.Infof(ctx, "drone '%s' loaded with medication items: %v", drone.SerialNumber, medicationItemIDs)
+	return previous, nil
+}
+
+// LoadMedicationItemsDrones replace the medications loaded on the drones in a single transaction. If the
+// medications of a drone don't exist, it can't carry them or it can't be loaded any longer (checkLoadable),
+// no drone is loaded and it fails with a *BatchError of the validation errors. It returns the codes that
+// were loaded on every drone before, in the order of the loads
+func (r *pgRepoDrones) LoadMedicationItemsDrones(ctx context.Context, loads []dto.DroneLoad) (_ [][]string, err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "load_medication_items_drones", time.Now())
+	ctx, span := startPgSpan(ctx, metrics.RepoDrones, "load_medication_items_drones")
+	defer func() { tracing.End(span, err) }()
+	span.SetAttributes(attribute.Int("drones.count", len(loads)))
+
+	pool, err := openPostgres(r.DSN)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := make([][]string, len(loads))
+	err = pgTx(ctx, pool, nil, func(tx *sql.Tx) error {
+		medicationWeights := make(map[string]float64)
+		err := pgEachMedication(ctx, tx, func(medication dto.Medication) {
+			medicationWeights[medication.Code] = medication.Weight
+		})
+		if err != nil {
+			return err
+		}
+
+		minBattery := r.svcConf.Reloadable().MinBatteryToLoad
+		batchErr := &BatchError{Errors: make(map[int]error)}
+		payloads := make([][]string, len(loads))
+		drones := make([]*dto.Drone, len(loads))
+		for i, load := range loads {
+			// the drones are checked again with their stored state, they may have changed since they were read
+			drone, err := pgLockDrone(ctx, tx, load.Drone.SerialNumber)
+			if err != nil && err != ErrNotFound {
+				return err
+			}
+			if err == nil {
+				err = checkLoadable(drone, minBattery)
+			}
+			if err != nil {
+				batchErr.Errors[i] = err
+				continue
+			}
+			medicationItemIDs, err := validatePayload(drone, medicationWeights, load.MedicationItemIDs)
+			if err != nil {
+				batchErr.Errors[i] = err
+				continue
+			}
+			for _, id := range medicationItemIDs {
+				payloads[i] = append(payloads[i], id.(string))
+			}
+			drone.State = loadedState(medicationItemIDs)
+			drone.Version++
+			drones[i] = drone
+		}
+		if len(batchErr.Errors) > 0 {
+			return batchErr
+		}
+
+		for i, load := range loads {
+			if previous[i], err = pgLoadedCodes(ctx, tx, load.Drone.SerialNumber); err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, "DELETE FROM payloads WHERE serial_number = $1", load.Drone.SerialNumber); err != nil {
+				return err
+			}
+			if err := pgInsertPayload(ctx, tx, load.Drone.SerialNumber, payloads[i]); err != nil {
+				return err
+			}
+			if err := pgUpdateDrone(ctx, tx, drones[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	r.logger.Infof(ctx, "%d drones loaded with medication items", len(loads))
+	return previous, nil
+}
+
+func (r *pgRepoDrones) ExistDrone(ctx context.Context, serialNumber string) (err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "exist_drone", time.Now())
+	ctx, span := startPgSpan(ctx, metrics.RepoDrones, "exist_drone")
+	defer func() { tracing.End(span, err) }()
+
+	pool, err := openPostgres(r.DSN)
+	if err != nil {
+		return err
+	}
+	exists := 0
+	err = pool.QueryRowContext(ctx, "SELECT 1 FROM drones WHERE serial_number = $1", serialNumber).Scan(&exists)
+	return pgNotFound(err)
+}
+
+// GetFleetStats compute the fleet summary in a single pass over the drones
+func (r *pgRepoDrones) GetFleetStats(ctx context.Context) (_ *dto.FleetStats, err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "get_fleet_stats", time.Now())
+	ctx, span := startPgSpan(ctx, metrics.RepoDrones, "get_fleet_stats")
+	defer func() { tracing.End(span, err) }()
+
+	pool, err := openPostgres(r.DSN)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := newFleetStatsBuilder(r.svcConf.Reloadable().MinBatteryToLoad)
+	query := "SELECT " + pgDroneColumns + ", COALESCE(l.loaded, 0) FROM drones LEFT JOIN (" + pgLoadedWeights + ") l USING (serial_number)"
+	err = pgEachDrone(ctx, pool, query, nil, func(drone *dto.Drone, loaded float64) {
+		stats.add(drone, loaded)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats.result(), nil
+}
+
+// endregion ======== Drones ======================================================
+
+// region ======== Medications ======================================================
+
+// GetMedications the medications sorted descending by weight
+func (r *pgRepoDrones) GetMedications(ctx context.Context) (_ *[]dto.Medication, err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "get_medications", time.Now())
+	ctx, span := startPgSpan(ctx, metrics.RepoDrones, "get_medications")
+	defer func() { tracing.End(span, err) }()
+
+	pool, err := openPostgres(r.DSN)
+	if err != nil {
+		return nil, err
+	}
+	medicationsList := make([]dto.Medication, 0)
+	err = pgEachMedication(ctx, pool, func(medication dto.Medication) {
+		medicationsList = append(medicationsList, medication)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &medicationsList, nil
+}
+
+// endregion ======== Medications ======================================================
+
+// region ======== Dataset ======================================================
+
+// ExportData read the store tables in a read-only transaction: users, drones (retired included),
+// medications and the medications loaded on every drone. The event logs are exported by RepoEventLog
+func (r *pgRepoDrones) ExportData(ctx context.Context) (_ *dto.Dataset, err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "export_data", time.Now())
+	ctx, span := startPgSpan(ctx, metrics.RepoDrones, "export_data")
+	defer func() { tracing.End(span, err) }()
+
+	snapshot, err := r.readSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("dataset.drones", len(snapshot.Drones)))
+	return &snapshot.Dataset, nil
+}
+
+// ImportData write a dataset exported by ExportData in a single transaction. A populated database is
+// only overwritten if replace is true, then the store tables are emptied first
+//
+// - dataset [*dto.Dataset] ~ Dataset to import, up to version dto.DatasetVersion
+//
+// - replace [bool] ~ Overwrite a populated database
+func (r *pgRepoDrones) ImportData(ctx context.Context, dataset *dto.Dataset, replace bool) (err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "import_data", time.Now())
+	ctx, span := startPgSpan(ctx, metrics.RepoDrones, "import_data")
+	defer func() { tracing.End(span, err) }()
+
+	if dataset.Version < 1 || dataset.Version > dto.DatasetVersion {
+		return fmt.Errorf("%w %d, expected 1 to %d", schema.ErrDatasetVersion, dataset.Version, dto.DatasetVersion)
+	}
+	if err := validateDataset(dataset); err != nil {
+		return err
+	}
+	loadDatasetDrones(dataset)
+
+	pool, err := openPostgres(r.DSN)
+	if err != nil {
+		return err
+	}
+	err = pgTx(ctx, pool, nil, func(tx *sql.Tx) error {
+		if populated, err := pgIsPopulated(ctx, tx); err != nil {
+			return err
+		} else if populated && !replace {
+			return errors.New(schema.ErrBuntdbPopulated)
+		}
+		return pgReplaceDataset(ctx, tx, dataset, true)
+	})
+	if err != nil {
+		return err
+	}
+	r.logger.Infof(ctx, "dataset imported: %d users, %d drones, %d medications", len(dataset.Users), len(dataset.Drones), len(dataset.Medications))
+	return nil
+}
+
+// Snapshot write a consistent snapshot of the store tables as JSON, read in a repeatable read
+// transaction while the database stays online
+func (r *pgRepoDrones) Snapshot(ctx context.Context, w io.Writer) (err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "snapshot", time.Now())
+	ctx, span := startPgSpan(ctx, metrics.RepoDrones, "snapshot")
+	defer func() { tracing.End(span, err) }()
+
+	snapshot, err := r.readSnapshot(ctx)
+	if err != nil {
+		return err
+	}
+	return jsoniter.NewEncoder(w).Encode(snapshot)
+}
+
+// RestoreSnapshot replace the store tables with a snapshot written by Snapshot, in a single transaction
+func (r *pgRepoDrones) RestoreSnapshot(ctx context.Context, rd io.Reader) (err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "restore_snapshot", time.Now())
+	ctx, span := startPgSpan(ctx, metrics.RepoDrones, "restore_snapshot")
+	defer func() { tracing.End(span, err) }()
+
+	snapshot := pgSnapshot{}
+	if err := jsoniter.NewDecoder(rd).Decode(&snapshot); err != nil {
+		return fmt.Errorf("invalid postgres snapshot: %w", err)
+	}
+	pool, err := openPostgres(r.DSN)
+	if err != nil {
+		return err
+	}
+	err = pgTx(ctx, pool, nil, func(tx *sql.Tx) error {
+		return pgReplaceDataset(ctx, tx, &snapshot.Dataset, snapshot.Populated)
+	})
+	if err != nil {
+		return err
+	}
+	r.logger.Infof(ctx, "store database restored from a snapshot")
+	return nil
+}
+
+// Migrate run the pending SQL migrations in a single transaction. In a dry run the transaction is
+// rolled back, so the report tells what would change
+//
+// - dryRun [bool] ~ Report the changes without writing them
+func (r *pgRepoDrones) Migrate(ctx context.Context, dryRun bool) (_ *dto.MigrationReport, err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "migrate", time.Now())
+	ctx, span := startPgSpan(ctx, metrics.RepoDrones, "migrate")
+	defer func() { tracing.End(span, err) }()
+
+	pool, err := openPostgres(r.DSN)
+	if err != nil {
+		return nil, err
+	}
+	report, err := runPgMigrations(ctx, pool, dryRun)
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("schema.from", report.FromVersion), attribute.Int("schema.to", report.ToVersion))
+	for _, m := range report.Migrations {
+		r.logger.Infof(ctx, "migration %d (%s): %d changes, dry run: %t", m.Version, m.Name, len(m.Changes), dryRun)
+	}
+	return report, nil
+}
+
+// endregion ======== Dataset ======================================================
+
+// Ping check that the database can be reached and that its tables exist
+func (r *pgRepoDrones) Ping(ctx context.Context) (err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoDrones, "ping", time.Now())
+	ctx, span := startPgSpan(ctx, metrics.RepoDrones, "ping")
+	defer func() { tracing.End(span, err) }()
+
+	pool, err := openPostgres(r.DSN)
+	if err != nil {
+		return err
+	}
+	count := 0
+	return pool.QueryRowContext(ctx, "SELECT COUNT(*) FROM store_config").Scan(&count)
+}
+
+// region ======== PRIVATE AUX ===========================================================
+
+// readSnapshot read the store tables in a single repeatable read transaction, so they are consistent
+func (r *pgRepoDrones) readSnapshot(ctx context.Context) (*pgSnapshot, error) {
+	pool, err := openPostgres(r.DSN)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := pgSnapshot{Dataset: dto.Dataset{
+		Version:     dto.DatasetVersion,
+		ExportedAt:  time.Now().UTC().Format(time.RFC3339),
+		Users:       make([]dto.User, 0),
+		Drones:      make([]dto.Drone, 0),
+		Medications: make([]dto.Medication, 0),
+		Logs:        make([]dto.LogEvent, 0),
+		Deliveries:  make([]dto.Delivery, 0),
+	}}
+	err = pgTx(ctx, pool, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}, func(tx *sql.Tx) error {
+		if snapshot.Populated, err = pgIsPopulated(ctx, tx); err != nil {
+			return err
+		}
+		err := pgEachUser(ctx, tx, func(user dto.User) {
+			snapshot.Users = append(snapshot.Users, user)
+		})
+		if err != nil {
+			return err
+		}
+		err = pgEachDrone(ctx, tx, "SELECT "+pgDroneColumns+", 0 FROM drones ORDER BY serial_number", nil, func(drone *dto.Drone, _ float64) {
+			snapshot.Drones = append(snapshot.Drones, *drone)
+		})
+		if err != nil {
+			return err
+		}
+		rows, err := tx.QueryContext(ctx, "SELECT name, weight, code, image FROM medications ORDER BY code")
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			medication := dto.Medication{}
+			if err := rows.Scan(&medication.Name, &medication.Weight, &medication.Code, &medication.Image); err != nil {
+				return err
+			}
+			snapshot.Medications = append(snapshot.Medications, medication)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if snapshot.Payloads, err = pgPayloads(ctx, tx, ""); err != nil {
+			return err
+		}
+		return pgEachDelivery(ctx, tx, "ORDER BY dispatched_at, id", nil, func(delivery dto.Delivery) {
+			snapshot.Deliveries = append(snapshot.Deliveries, delivery)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+func pgIsPopulated(ctx context.Context, q pgQuerier) (bool, error) {
+	populated := false
+	err := q.QueryRowContext(ctx, "SELECT is_populated FROM store_config").Scan(&populated)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return populated, err
+}
+
+// pgReplaceDataset empty the store tables and write a dataset
+func pgReplaceDataset(ctx context.Context, tx *sql.Tx, dataset *dto.Dataset, populated bool) error {
+	if _, err := tx.ExecContext(ctx, "TRUNCATE deliveries, payloads, drones, medications, users, store_config RESTART IDENTITY"); err != nil {
+		return err
+	}
+	return pgWriteDataset(ctx, tx, dataset, populated)
+}
+
+// pgWriteDataset write the users, drones, medications and payloads of a dataset, the drones and the
+// medications that already exist are overwritten like the buntdb keys
+func pgWriteDataset(ctx context.Context, tx *sql.Tx, dataset *dto.Dataset, populated bool) error {
+	for i := range dataset.Users {
+		if err := pgInsertUser(ctx, tx, &dataset.Users[i]); err != nil {
+			return err
+		}
+	}
+	for i := range dataset.Drones {
+		if err := pgInsertDrone(ctx, tx, &dataset.Drones[i]); err != nil {
+			return err
+		}
+	}
+	for _, m := range dataset.Medications {
+		_, err := tx.ExecContext(ctx, `INSERT INTO medications (code, name, weight, image) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (code) DO UPDATE SET name = EXCLUDED.name, weight = EXCLUDED.weight, image = EXCLUDED.image`,
+			m.Code, m.Name, m.Weight, m.Image)
+		if err != nil {
+			return err
+		}
+	}
+	for serialNumber, codes := range dataset.Payloads {
+		if err := pgInsertPayload(ctx, tx, serialNumber, codes); err != nil {
+			return err
+		}
+	}
+	for i := range dataset.Deliveries {
+		if err := pgUpsertDelivery(ctx, tx, &dataset.Deliveries[i]); err != nil {
+			return err
+		}
+	}
+	_, err := tx.ExecContext(ctx, `INSERT INTO store_config (id, is_populated) VALUES (TRUE, $1)
+		ON CONFLICT (id) DO UPDATE SET is_populated = EXCLUDED.is_populated`, populated)
+	return err
+}
+
+func pgInsertUser(ctx context.Context, q pgQuerier, user *dto.User) error {
+	_, err := q.ExecContext(ctx, "INSERT INTO users (username, name, passphrase, admin) VALUES ($1, $2, $3, $4)", user.Username, user.Name, user.Passphrase, user.Admin)
+	return err
+}
+
+func pgInsertDrone(ctx context.Context, q pgQuerier, drone *dto.Drone) error {
+	_, err := q.ExecContext(ctx, `INSERT INTO drones (`+pgDroneColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (serial_number) DO UPDATE SET model = EXCLUDED.model, weight_limit = EXCLUDED.weight_limit,
+			battery_capacity = EXCLUDED.battery_capacity, state = EXCLUDED.state, version = EXCLUDED.version,
+			retired = EXCLUDED.retired, retired_at = EXCLUDED.retired_at`,
+		drone.SerialNumber, drone.Model, drone.WeightLimit, drone.BatteryCapacity, drone.State, drone.Version, drone.Retired, drone.RetiredAt)
+	return err
+}
+
+func pgUpdateDrone(ctx context.Context, q pgQuerier, drone *dto.Drone) error {
+	_, err := q.ExecContext(ctx, `UPDATE drones SET model = $2, weight_limit = $3, battery_capacity = $4, state = $5,
+		version = $6, retired = $7, retired_at = $8 WHERE serial_number = $1`,
+		drone.SerialNumber, drone.Model, drone.WeightLimit, drone.BatteryCapacity, drone.State, drone.Version, drone.Retired, drone.RetiredAt)
+	return err
+}
+
+// pgLockDrone read a drone and lock its row until the end of the transaction
+func pgLockDrone(ctx context.Context, tx *sql.Tx, serialNumber string) (*dto.Drone, error) {
+	row := tx.QueryRowContext(ctx, "SELECT "+pgDroneColumns+" FROM drones WHERE serial_number = $1 FOR UPDATE", serialNumber)
+	drone, err := scanPgDrone(row)
+	if err != nil {
+		return nil, pgNotFound(err)
+	}
+	return drone, nil
+}
+
+func pgInsertPayload(ctx context.Context, q pgQuerier, serialNumber string, codes []string) error {
+	for position, code := range codes {
+		_, err := q.ExecContext(ctx, "INSERT INTO payloads (serial_number, medication_code, position) VALUES ($1, $2, $3)", serialNumber, code, position)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pgPayloads the codes of the loaded medications by drone serial number, in the order they were loaded
+//
+// - where [string] ~ Optional WHERE clause of the payloads, with its arguments
+func pgPayloads(ctx context.Context, q pgQuerier, where string, args ...interface{}) (map[string][]string, error) {
+	rows, err := q.QueryContext(ctx, "SELECT serial_number, medication_code FROM payloads "+where+" ORDER BY serial_number, position", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payloads := make(map[string][]string)
+	for rows.Next() {
+		var serialNumber, code string
+		if err := rows.Scan(&serialNumber, &code); err != nil {
+			return nil, err
+		}
+		payloads[serialNumber] = append(payloads[serialNumber], code)
+	}
+	return payloads, rows.Err()
+}
+
+// pgLoadedCodes the codes of the medications loaded on a drone, an empty slice if it is not loaded
+func pgLoadedCodes(ctx context.Context, q pgQuerier, serialNumber string) ([]string, error) {
+	payloads, err := pgPayloads(ctx, q, "WHERE serial_number = $1", serialNumber)
+	if err != nil {
+		return nil, err
+	}
+	return append(make([]string, 0), payloads[serialNumber]...), nil
+}
+
+// pgUpsertDelivery write a delivery, an existing one is overwritten like the buntdb keys
+func pgUpsertDelivery(ctx context.Context, q pgQuerier, delivery *dto.Delivery) error {
+	_, err := q.ExecContext(ctx, `INSERT INTO deliveries (`+pgDeliveryColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (id) DO UPDATE SET serial_number = EXCLUDED.serial_number, medications = EXCLUDED.medications,
+			status = EXCLUDED.status, dispatched_at = EXCLUDED.dispatched_at, delivered_at = EXCLUDED.delivered_at,
+			aborted_at = EXCLUDED.aborted_at, returned_at = EXCLUDED.returned_at`,
+		delivery.ID, delivery.SerialNumber, pq.Array(delivery.Medications), delivery.Status, delivery.DispatchedAt,
+		delivery.DeliveredAt, delivery.AbortedAt, delivery.ReturnedAt)
+	return err
+}
+
+// pgEachDelivery iterate the deliveries of a query
+//
+// - clause [string] ~ WHERE and ORDER BY clauses of the deliveries, with its arguments
+func pgEachDelivery(ctx context.Context, q pgQuerier, clause string, args []interface{}, iterator func(delivery dto.Delivery)) error {
+	rows, err := q.QueryContext(ctx, "SELECT "+pgDeliveryColumns+" FROM deliveries "+clause, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		delivery, err := scanPgDelivery(rows)
+		if err != nil {
+			return err
+		}
+		iterator(*delivery)
+	}
+	return rows.Err()
+}
+
+// pgEachUser iterate the users in the order they were created
+func pgEachUser(ctx context.Context, q pgQuerier, iterator func(user dto.User)) error {
+	rows, err := q.QueryContext(ctx, "SELECT username, name, passphrase, admin FROM users ORDER BY id")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		user := dto.User{}
+		if err := rows.Scan(&user.Username, &user.Name, &user.Passphrase, &user.Admin); err != nil {
+			return err
+		}
+		iterator(user)
+	}
+	return rows.Err()
+}
+
+// pgEachDrone iterate the drones of a query that selects pgDroneColumns and the loaded weight
+func pgEachDrone(ctx context.Context, q pgQuerier, query string, args []interface{}, iterator func(drone *dto.Drone, loaded float64)) error {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		drone := dto.Drone{}
+		loaded := 0.0
+		err := rows.Scan(&drone.SerialNumber, &drone.Model, &drone.WeightLimit, &drone.BatteryCapacity, &drone.State,
+			&drone.Version, &drone.Retired, &drone.RetiredAt, &loaded)
+		if err != nil {
+			return err
+		}
+		iterator(&drone, loaded)
+	}
+	return rows.Err()
+}
+
+// pgEachMedication iterate the medications sorted descending by weight, the ties by code descending like
+// the buntdb index
+func pgEachMedication(ctx context.Context, q pgQuerier, iterator func(medication dto.Medication)) error {
+	rows, err := q.QueryContext(ctx, "SELECT name, weight, code, image FROM medications ORDER BY weight DESC, code DESC")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		medication := dto.Medication{}
+		if err := rows.Scan(&medication.Name, &medication.Weight, &medication.Code, &medication.Image); err != nil {
+			return err
+		}
+		iterator(medication)
+	}
+	return rows.Err()
+}
+
+func scanPgDelivery(row pgRowScanner) (*dto.Delivery, error) {
+	delivery := dto.Delivery{}
+	medications := pq.StringArray{}
+	err := row.Scan(&delivery.ID, &delivery.SerialNumber, &medications, &delivery.Status, &delivery.DispatchedAt,
+		&delivery.DeliveredAt, &delivery.AbortedAt, &delivery.ReturnedAt)
+	if err != nil {
+		return nil, err
+	}
+	delivery.Medications = append(make([]string, 0, len(medications)), medications...)
+	return &delivery, nil
+}
+
+func scanPgDrone(row pgRowScanner) (*dto.Drone, error) {
+	drone := dto.Drone{}
+	err := row.Scan(&drone.SerialNumber, &drone.Model, &drone.WeightLimit, &drone.BatteryCapacity, &drone.State,
+		&drone.Version, &drone.Retired, &drone.RetiredAt)
+	if err != nil {
+		return nil, err
+	}
+	return &drone, nil
+}
+
+// endregion =============================================================================
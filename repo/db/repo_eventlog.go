@@ -1,73 +1,79 @@
 package db
 
 import (
+	"context"
+	"io"
+
 	jsoniter "github.com/json-iterator/go"
 	"github.com/kmilodenisglez/drones.restapi/lib"
+	"github.com/kmilodenisglez/drones.restapi/schema"
 	"github.com/kmilodenisglez/drones.restapi/schema/dto"
+	"github.com/kmilodenisglez/drones.restapi/service/metrics"
+	"github.com/kmilodenisglez/drones.restapi/service/tracing"
 	"github.com/kmilodenisglez/drones.restapi/service/utils"
 	"github.com/tidwall/buntdb"
 	"google.golang.org/protobuf/types/known/timestamppb"
-	"log"
+	"time"
 )
 
 
 // region ======== SETUP =================================================================
 
 type RepoEventLog interface {
-	GetEventLogs() (*[]dto.LogEvent, error)
-	CheckBatteryLevelsDrones(drones *[]dto.Drone) error
+	GetEventLogs(ctx context.Context) (*[]dto.LogEvent, error)
+	TailEventLogs(ctx context.Context, limit int) (*[]dto.LogEvent, error)
+	ExportEventLogs(ctx context.Context) (*[]dto.LogEvent, error)
+	ImportEventLogs(ctx context.Context, logs []dto.LogEvent, replace bool) error
+	Snapshot(ctx context.Context, w io.Writer) error
+	RestoreSnapshot(ctx context.Context, r io.Reader) error
+	CheckBatteryLevelsDrones(ctx context.Context, drones *[]dto.Drone) error
+	Ping(ctx context.Context) error
 }
 
 type repoEventLog struct {
 	LogDBLocation string
+	logger        *utils.SvcLogger
 }
 
 // endregion =============================================================================
 
-func NewRepoEventLog(svcConf *utils.SvcConfig) RepoEventLog {
-	return &repoEventLog{LogDBLocation: svcConf.LogDBPath}
+// NewRepoEventLog the RepoEventLog of the configured store backend
+func NewRepoEventLog(svcConf *utils.SvcConfig, svcLog *utils.SvcLogger) RepoEventLog {
+	if svcConf.StoreBackend == schema.StoreBackendPostgres {
+		return newPgRepoEventLog(svcConf, svcLog)
+	}
+	return &repoEventLog{LogDBLocation: svcConf.LogDBPath, logger: svcLog}
 }
 
 // region ======== METHODS ===============================================================
 
 // GetEventLogs A read-only transaction, return events in db
-func (r *repoEventLog) GetEventLogs() (*[]dto.LogEvent, error) {
-	db, err := r.loadEventDB()
-	if err != nil {
-		return nil, err
-	}
-	defer db.Close()
+func (r *repoEventLog) GetEventLogs(ctx context.Context) (_ *[]dto.LogEvent, err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoEventLog, "get_event_logs", time.Now())
+	_, span := tracing.StartDB(ctx, metrics.RepoEventLog, "get_event_logs")
+	defer func() { tracing.End(span, err) }()
 
-	eventLog := dto.LogEvent{}
-	eventLogList := make([]dto.LogEvent, 0)
-	lastTen := 4
-	// custom index: sort drones descending by battery capacity
-	db.CreateIndex("log", "event_log:*", buntdb.IndexString)
-	err = db.View(func(tx *buntdb.Tx) error {
-		err := tx.Descend("log", func(key, value string) bool {
-			err = jsoniter.UnmarshalFromString(value, &eventLog)
-			if err == nil {
-				eventLogList = append(eventLogList, eventLog)
-				lastTen--
-				// return only the last 4 LogEvent
-				if lastTen == 0 {
-					return false
-				}
-			}
-			return err == nil
-		})
+	// return only the last 4 LogEvent
+	return r.lastEventLogs(4)
+}
 
-		return err
-	})
-	if err != nil {
-		return nil, err
-	}
+// TailEventLogs return the last event logs, the newest first
+//
+// - limit [int] ~ Maximum number of event logs
+func (r *repoEventLog) TailEventLogs(ctx context.Context, limit int) (_ *[]dto.LogEvent, err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoEventLog, "tail_event_logs", time.Now())
+	_, span := tracing.StartDB(ctx, metrics.RepoEventLog, "tail_event_logs")
+	defer func() { tracing.End(span, err) }()
 
-	return &eventLogList, nil
+	return r.lastEventLogs(limit)
 }
 
 // CheckBatteryLevelsDrones check drones battery levels and create history/audit event log for this
-func (r *repoEventLog) CheckBatteryLevelsDrones(drones *[]dto.Drone) error {
+func (r *repoEventLog) CheckBatteryLevelsDrones(ctx context.Context, drones *[]dto.Drone) (err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoEventLog, "check_battery_levels_drones", time.Now())
+	ctx, span := tracing.StartDB(ctx, metrics.RepoEventLog, "check_battery_levels_drones")
+	defer func() { tracing.End(span, err) }()
+
 	db, err := r.loadEventDB()
 	if err != nil {
 		return err
@@ -90,7 +96,6 @@ func (r *repoEventLog) CheckBatteryLevelsDrones(drones *[]dto.Drone) error {
 		DronesBatteryLevels: dronesBatteryLevelList,
 	}
 
-	log.Printf("writing event log")
 	err = db.Update(func(tx *buntdb.Tx) error {
 		res, err := jsoniter.MarshalToString(logEvent)
 		if err != nil {
@@ -102,23 +107,159 @@ func (r *repoEventLog) CheckBatteryLevelsDrones(drones *[]dto.Drone) error {
 	if err != nil {
 		return err
 	}
-	log.Println("successfully added event")
+	r.logger.Debugf(ctx, "event log '%s' written with %d drones", timestamp, len(dronesBatteryLevelList))
 
 	return nil
 }
 
+// ExportEventLogs return every event log, the oldest first
+func (r *repoEventLog) ExportEventLogs(ctx context.Context) (_ *[]dto.LogEvent, err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoEventLog, "export_event_logs", time.Now())
+	_, span := tracing.StartDB(ctx, metrics.RepoEventLog, "export_event_logs")
+	defer func() { tracing.End(span, err) }()
+
+	db, err := r.loadEventDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	eventLogList := make([]dto.LogEvent, 0)
+	err = db.View(func(tx *buntdb.Tx) error {
+		var errUnmarshal error
+		err := tx.AscendKeys("event_log:*", func(key, value string) bool {
+			eventLog := dto.LogEvent{}
+			errUnmarshal = jsoniter.UnmarshalFromString(value, &eventLog)
+			eventLogList = append(eventLogList, eventLog)
+			return errUnmarshal == nil
+		})
+		return firstError(err, errUnmarshal)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &eventLogList, nil
+}
+
+// ImportEventLogs write event logs exported by ExportEventLogs in a single transaction, keyed by their
+// timestamp. With replace the existing event logs are deleted first, otherwise they are merged
+//
+// - logs [[]dto.LogEvent] ~ Event logs to import
+//
+// - replace [bool] ~ Delete the existing event logs
+func (r *repoEventLog) ImportEventLogs(ctx context.Context, logs []dto.LogEvent, replace bool) (err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoEventLog, "import_event_logs", time.Now())
+	ctx, span := tracing.StartDB(ctx, metrics.RepoEventLog, "import_event_logs")
+	defer func() { tracing.End(span, err) }()
+
+	db, err := r.loadEventDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *buntdb.Tx) error {
+		if replace {
+			if err := tx.DeleteAll(); err != nil {
+				return err
+			}
+		}
+		for _, eventLog := range logs {
+			res, err := jsoniter.MarshalToString(eventLog)
+			if err != nil {
+				return err
+			}
+			if _, _, err = tx.Set("event_log:"+eventLog.Created, res, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	r.logger.Infof(ctx, "%d event logs imported", len(logs))
+	return nil
+}
+
+// Snapshot write a consistent snapshot of the event log database file, it stays online
+func (r *repoEventLog) Snapshot(ctx context.Context, w io.Writer) (err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoEventLog, "snapshot", time.Now())
+	_, span := tracing.StartDB(ctx, metrics.RepoEventLog, "snapshot")
+	defer func() { tracing.End(span, err) }()
+
+	return saveDB(r.LogDBLocation, w)
+}
+
+// RestoreSnapshot replace the whole event log database with a snapshot written by Snapshot
+func (r *repoEventLog) RestoreSnapshot(ctx context.Context, rd io.Reader) (err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoEventLog, "restore_snapshot", time.Now())
+	ctx, span := tracing.StartDB(ctx, metrics.RepoEventLog, "restore_snapshot")
+	defer func() { tracing.End(span, err) }()
+
+	if err := restoreDB(r.LogDBLocation, rd); err != nil {
+		return err
+	}
+	r.logger.Infof(ctx, "event log database restored from a snapshot")
+	return nil
+}
+
+// Ping check that the event log database can be opened and read
+func (r *repoEventLog) Ping(ctx context.Context) (err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoEventLog, "ping", time.Now())
+	_, span := tracing.StartDB(ctx, metrics.RepoEventLog, "ping")
+	defer func() { tracing.End(span, err) }()
+
+	db, err := r.loadEventDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.View(func(tx *buntdb.Tx) error {
+		_, err := tx.Len()
+		return err
+	})
+}
+
 // region ======== PRIVATE AUX ===========================================================
 
-func (r *repoEventLog) loadEventDB() (*buntdb.DB, error) {
-	log.Println("Load Event Log DB ", r.LogDBLocation)
-	// Open the data.db file. It will be created if it doesn't exist.
-	db, err := buntdb.Open(r.LogDBLocation)
+// lastEventLogs read the newest event logs, at most limit
+func (r *repoEventLog) lastEventLogs(limit int) (*[]dto.LogEvent, error) {
+	db, err := r.loadEventDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	eventLog := dto.LogEvent{}
+	eventLogList := make([]dto.LogEvent, 0)
+	// custom index: sort event logs descending by timestamp
+	db.CreateIndex("log", "event_log:*", buntdb.IndexString)
+	err = db.View(func(tx *buntdb.Tx) error {
+		err := tx.Descend("log", func(key, value string) bool {
+			if len(eventLogList) >= limit {
+				return false
+			}
+			err = jsoniter.UnmarshalFromString(value, &eventLog)
+			if err == nil {
+				eventLogList = append(eventLogList, eventLog)
+			}
+			return err == nil
+		})
+
+		return err
+	})
 	if err != nil {
-		log.Fatal(err)
 		return nil, err
 	}
 
-	return db, nil
+	return &eventLogList, nil
+}
+
+func (r *repoEventLog) loadEventDB() (*handle, error) {
+	// Open the event_log.db file. It will be created if it doesn't exist.
+	return openDB(r.LogDBLocation)
 }
 
 // endregion =============================================================================
@@ -0,0 +1,241 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/kmilodenisglez/drones.restapi/lib"
+	"github.com/kmilodenisglez/drones.restapi/schema/dto"
+	"github.com/kmilodenisglez/drones.restapi/service/metrics"
+	"github.com/kmilodenisglez/drones.restapi/service/tracing"
+	"github.com/kmilodenisglez/drones.restapi/service/utils"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// region ======== SETUP =================================================================
+
+// pgRepoEventLog RepoEventLog of the postgres backend, the event logs are keyed by their timestamp
+// like the buntdb keys
+type pgRepoEventLog struct {
+	DSN    string
+	logger *utils.SvcLogger
+}
+
+// endregion =============================================================================
+
+func newPgRepoEventLog(svcConf *utils.SvcConfig, svcLog *utils.SvcLogger) RepoEventLog {
+	return &pgRepoEventLog{DSN: svcConf.PostgresDSN, logger: svcLog}
+}
+
+// region ======== METHODS ===============================================================
+
+// GetEventLogs return the last 4 event logs
+func (r *pgRepoEventLog) GetEventLogs(ctx context.Context) (_ *[]dto.LogEvent, err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoEventLog, "get_event_logs", time.Now())
+	ctx, span := startPgSpan(ctx, metrics.RepoEventLog, "get_event_logs")
+	defer func() { tracing.End(span, err) }()
+
+	return r.queryEventLogs(ctx, "ORDER BY created DESC LIMIT 4")
+}
+
+// TailEventLogs return the last event logs, the newest first
+//
+// - limit [int] ~ Maximum number of event logs
+func (r *pgRepoEventLog) TailEventLogs(ctx context.Context, limit int) (_ *[]dto.LogEvent, err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoEventLog, "tail_event_logs", time.Now())
+	ctx, span := startPgSpan(ctx, metrics.RepoEventLog, "tail_event_logs")
+	defer func() { tracing.End(span, err) }()
+
+	if limit < 0 {
+		limit = 0
+	}
+	return r.queryEventLogs(ctx, "ORDER BY created DESC LIMIT $1", limit)
+}
+
+// CheckBatteryLevelsDrones check drones battery levels and create history/audit event log for this
+func (r *pgRepoEventLog) CheckBatteryLevelsDrones(ctx context.Context, drones *[]dto.Drone) (err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoEventLog, "check_battery_levels_drones", time.Now())
+	ctx, span := startPgSpan(ctx, metrics.RepoEventLog, "check_battery_levels_drones")
+	defer func() { tracing.End(span, err) }()
+
+	pool, err := openPostgres(r.DSN)
+	if err != nil {
+		return err
+	}
+
+	dronesBatteryLevelList := make([]dto.DroneBatteryLevel, 0)
+	for _, v := range *drones {
+		dronesBatteryLevelList = append(dronesBatteryLevelList, dto.DroneBatteryLevel{
+			SerialNumber:    v.SerialNumber,
+			BatteryCapacity: v.BatteryCapacity,
+		})
+	}
+
+	timestamp := timestamppb.Now().AsTime().Format("20060102-150405")
+	logEvent := dto.LogEvent{
+		Created:             timestamp,
+		UUID:                lib.GenerateUUIDStr(),
+		DronesBatteryLevels: dronesBatteryLevelList,
+	}
+	if err := pgWriteEventLog(ctx, pool, &logEvent); err != nil {
+		return err
+	}
+	r.logger.Debugf(ctx, "event log '%s' written with %d drones", timestamp, len(dronesBatteryLevelList))
+
+	return nil
+}
+
+// ExportEventLogs return every event log, the oldest first
+func (r *pgRepoEventLog) ExportEventLogs(ctx context.Context) (_ *[]dto.LogEvent, err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoEventLog, "export_event_logs", time.Now())
+	ctx, span := startPgSpan(ctx, metrics.RepoEventLog, "export_event_logs")
+	defer func() { tracing.End(span, err) }()
+
+	return r.queryEventLogs(ctx, "ORDER BY created")
+}
+
+// ImportEventLogs write event logs exported by ExportEventLogs in a single transaction, keyed by their
+// timestamp. With replace the existing event logs are deleted first, otherwise they are merged
+//
+// - logs [[]dto.LogEvent] ~ Event logs to import
+//
+// - replace [bool] ~ Delete the existing event logs
+func (r *pgRepoEventLog) ImportEventLogs(ctx context.Context, logs []dto.LogEvent, replace bool) (err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoEventLog, "import_event_logs", time.Now())
+	ctx, span := startPgSpan(ctx, metrics.RepoEventLog, "import_event_logs")
+	defer func() { tracing.End(span, err) }()
+
+	pool, err := openPostgres(r.DSN)
+	if err != nil {
+		return err
+	}
+	err = pgTx(ctx, pool, nil, func(tx *sql.Tx) error {
+		return pgWriteEventLogs(ctx, tx, logs, replace)
+	})
+	if err != nil {
+		return err
+	}
+	r.logger.Infof(ctx, "%d event logs imported", len(logs))
+	return nil
+}
+
+// Snapshot write every event log as JSON, the oldest first
+func (r *pgRepoEventLog) Snapshot(ctx context.Context, w io.Writer) (err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoEventLog, "snapshot", time.Now())
+	ctx, span := startPgSpan(ctx, metrics.RepoEventLog, "snapshot")
+	defer func() { tracing.End(span, err) }()
+
+	logs, err := r.queryEventLogs(ctx, "ORDER BY created")
+	if err != nil {
+		return err
+	}
+	return jsoniter.NewEncoder(w).Encode(logs)
+}
+
+// RestoreSnapshot replace every event log with a snapshot written by Snapshot, in a single transaction
+func (r *pgRepoEventLog) RestoreSnapshot(ctx context.Context, rd io.Reader) (err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoEventLog, "restore_snapshot", time.Now())
+	ctx, span := startPgSpan(ctx, metrics.RepoEventLog, "restore_snapshot")
+	defer func() { tracing.End(span, err) }()
+
+	logs := make([]dto.LogEvent, 0)
+	if err := jsoniter.NewDecoder(rd).Decode(&logs); err != nil {
+		return fmt.Errorf("invalid postgres snapshot: %w", err)
+	}
+	pool, err := openPostgres(r.DSN)
+	if err != nil {
+		return err
+	}
+	err = pgTx(ctx, pool, nil, func(tx *sql.Tx) error {
+		return pgWriteEventLogs(ctx, tx, logs, true)
+	})
+	if err != nil {
+		return err
+	}
+	r.logger.Infof(ctx, "event log database restored from a snapshot")
+	return nil
+}
+
+// Ping check that the database can be reached and that the event log table exists
+func (r *pgRepoEventLog) Ping(ctx context.Context) (err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoEventLog, "ping", time.Now())
+	ctx, span := startPgSpan(ctx, metrics.RepoEventLog, "ping")
+	defer func() { tracing.End(span, err) }()
+
+	pool, err := openPostgres(r.DSN)
+	if err != nil {
+		return err
+	}
+	_, err = pool.ExecContext(ctx, "SELECT 1 FROM event_logs LIMIT 1")
+	return err
+}
+
+// region ======== PRIVATE AUX ===========================================================
+
+// queryEventLogs read the event logs
+//
+// - clause [string] ~ ORDER BY and LIMIT clauses of the query, with its arguments
+func (r *pgRepoEventLog) queryEventLogs(ctx context.Context, clause string, args ...interface{}) (*[]dto.LogEvent, error) {
+	pool, err := openPostgres(r.DSN)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := pool.QueryContext(ctx, "SELECT created, uuid, drones_battery_levels FROM event_logs "+clause, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	eventLogList := make([]dto.LogEvent, 0)
+	for rows.Next() {
+		eventLog := dto.LogEvent{}
+		levels := ""
+		if err := rows.Scan(&eventLog.Created, &eventLog.UUID, &levels); err != nil {
+			return nil, err
+		}
+		if err := jsoniter.UnmarshalFromString(levels, &eventLog.DronesBatteryLevels); err != nil {
+			return nil, err
+		}
+		eventLogList = append(eventLogList, eventLog)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return &eventLogList, nil
+}
+
+func pgWriteEventLogs(ctx context.Context, tx *sql.Tx, logs []dto.LogEvent, replace bool) error {
+	if replace {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM event_logs"); err != nil {
+			return err
+		}
+	}
+	for i := range logs {
+		if err := pgWriteEventLog(ctx, tx, &logs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pgWriteEventLog insert an event log, it overwrites the one with the same timestamp like a buntdb key
+func pgWriteEventLog(ctx context.Context, q pgQuerier, eventLog *dto.LogEvent) error {
+	levels := eventLog.DronesBatteryLevels
+	if levels == nil {
+		levels = make([]dto.DroneBatteryLevel, 0)
+	}
+	res, err := jsoniter.MarshalToString(levels)
+	if err != nil {
+		return err
+	}
+	_, err = q.ExecContext(ctx, `INSERT INTO event_logs (created, uuid, drones_battery_levels) VALUES ($1, $2, $3)
+		ON CONFLICT (created) DO UPDATE SET uuid = EXCLUDED.uuid, drones_battery_levels = EXCLUDED.drones_battery_levels`,
+		eventLog.Created, eventLog.UUID, res)
+	return err
+}
+
+// endregion =============================================================================
@@ -0,0 +1,163 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/kmilodenisglez/drones.restapi/lib"
+	"github.com/kmilodenisglez/drones.restapi/schema/dto"
+	"github.com/kmilodenisglez/drones.restapi/service/metrics"
+	"github.com/kmilodenisglez/drones.restapi/service/tracing"
+	"github.com/kmilodenisglez/drones.restapi/service/utils"
+	"github.com/tidwall/buntdb"
+)
+
+// region ======== SETUP =================================================================
+
+type RepoIdempotency interface {
+	ReserveIdempotencyKey(ctx context.Context, username, key, fingerprint string) (*dto.IdempotentResponse, error)
+	SaveIdempotentResponse(ctx context.Context, username, key string, response *dto.IdempotentResponse) error
+	ReleaseIdempotencyKey(ctx context.Context, username, key string) error
+}
+
+type repoIdempotency struct {
+	IdempotencyDBLocation string
+	TTL                   time.Duration
+	logger                *utils.SvcLogger
+}
+
+const idempotencyKeyPrefix = "idempotency:"
+
+// idempotencyProcess identify the reservations of this server process. A reservation is freed when its
+// request completes or is released, the one of a request that never completed because the server
+// stopped has another process and it is free after the restart
+var idempotencyProcess = lib.GenerateUUIDStr()
+
+// endregion =============================================================================
+
+func NewRepoIdempotency(svcConf *utils.SvcConfig, svcLog *utils.SvcLogger) RepoIdempotency {
+	return &repoIdempotency{
+		IdempotencyDBLocation: svcConf.IdempotencyDBPath,
+		TTL:                   time.Duration(svcConf.IdempotencyTTL) * time.Second,
+		logger:                svcLog,
+	}
+}
+
+// region ======== METHODS ===============================================================
+
+// ReserveIdempotencyKey reserve the key for a new request. It returns the stored response (pending or
+// completed) when the key is already in use, nothing is written then; nil when the key was reserved.
+// The reservation lasts until the request completes or releases it, whatever it takes
+//
+// - username [string] ~ Owner of the key, the keys of different users never collide
+//
+// - key [string] ~ Idempotency-Key header
+//
+// - fingerprint [string] ~ Fingerprint of the request
+func (r *repoIdempotency) ReserveIdempotencyKey(ctx context.Context, username, key, fingerprint string) (_ *dto.IdempotentResponse, err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoIdempotency, "reserve_idempotency_key", time.Now())
+	_, span := tracing.StartDB(ctx, metrics.RepoIdempotency, "reserve_idempotency_key")
+	defer func() { tracing.End(span, err) }()
+
+	db, err := r.loadIdempotencyDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var stored *dto.IdempotentResponse
+	err = db.Update(func(tx *buntdb.Tx) error {
+		value, err := tx.Get(idempotencyKey(username, key))
+		if err == nil {
+			stored = &dto.IdempotentResponse{}
+			if err = json.Unmarshal([]byte(value), stored); err != nil {
+				return err
+			}
+			if stored.Completed || stored.Process == idempotencyProcess {
+				return nil
+			}
+			// reserved by a server process that stopped before the request completed
+			stored = nil
+		} else if err != buntdb.ErrNotFound {
+			return err
+		}
+
+		pending := dto.IdempotentResponse{
+			Fingerprint: fingerprint,
+			Created:     time.Now().UTC().Format(time.RFC3339Nano),
+			Process:     idempotencyProcess,
+		}
+		return setIdempotentResponse(tx, username, key, &pending, r.TTL)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+// SaveIdempotentResponse store the response of the request that reserved the key, it ends the reservation
+// and it is kept for the configured TTL
+func (r *repoIdempotency) SaveIdempotentResponse(ctx context.Context, username, key string, response *dto.IdempotentResponse) (err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoIdempotency, "save_idempotent_response", time.Now())
+	_, span := tracing.StartDB(ctx, metrics.RepoIdempotency, "save_idempotent_response")
+	defer func() { tracing.End(span, err) }()
+
+	db, err := r.loadIdempotencyDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Update(func(tx *buntdb.Tx) error {
+		return setIdempotentResponse(tx, username, key, response, r.TTL)
+	})
+}
+
+// ReleaseIdempotencyKey delete the key, so the request can be sent again with it (e.g. after a server error)
+func (r *repoIdempotency) ReleaseIdempotencyKey(ctx context.Context, username, key string) (err error) {
+	defer metrics.ObserveDBOperation(metrics.RepoIdempotency, "release_idempotency_key", time.Now())
+	_, span := tracing.StartDB(ctx, metrics.RepoIdempotency, "release_idempotency_key")
+	defer func() { tracing.End(span, err) }()
+
+	db, err := r.loadIdempotencyDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Update(func(tx *buntdb.Tx) error {
+		_, err := tx.Delete(idempotencyKey(username, key))
+		if err == buntdb.ErrNotFound {
+			return nil
+		}
+		return err
+	})
+}
+
+// endregion =============================================================================
+
+// region ======== PRIVATE AUX ===========================================================
+
+func (r *repoIdempotency) loadIdempotencyDB() (*handle, error) {
+	// Open the idempotency.db file. It will be created if it doesn't exist.
+	return openDB(r.IdempotencyDBLocation)
+}
+
+// setIdempotentResponse write the response of a key, buntdb deletes it when the TTL expires
+func setIdempotentResponse(tx *buntdb.Tx, username, key string, response *dto.IdempotentResponse, ttl time.Duration) error {
+	value, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	_, _, err = tx.Set(idempotencyKey(username, key), string(value), &buntdb.SetOptions{Expires: true, TTL: ttl})
+	return err
+}
+
+// idempotencyKey the keys have no spaces (they are validated by the service), so the space
+// separator keeps apart the keys of two users even if a username has one
+func idempotencyKey(username, key string) string {
+	return idempotencyKeyPrefix + username + " " + key
+}
+
+// endregion =============================================================================
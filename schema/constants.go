@@ -19,15 +19,23 @@ const (
 	ErrJwtGen                            = "err.jwt_generation"
 	ErrWrongAuthProvider                 = "err.wrong_auth_provider"
 	ErrUnauthorized                      = "err.unauthorized"
+	ErrForbidden                         = "err.forbidden"
 	ErrFileProc                          = "err.processing_file"
 	ErrFile                              = "err.system_file_related"
 	ErrBuntdbItemNotFound                = "err.database_related.item_not_found"
 	ErrBuntdb                            = "err.database_related"
 	ErrBuntdbPopulated                   = "err.database_populated"
 	ErrBuntdbNotPopulated                = "err.database_not_populated"
+	ErrSeedDisabled                      = "err.seed_disabled"
 	ErrDroneMaximumLoadWeightExceededKey = "err.drone_maximum_load_weight_exceeded"
 	ErrDroneVeryLowBatteryKey            = "err.drone_very_low_battery"
 	ErrDroneBusyKey                      = "err.drone_busy"
+	ErrDroneVersionMismatchKey           = "err.drone_version_mismatch"
+	ErrDroneRetiredKey                   = "err.drone_retired"
+	ErrDroneNotRetirableKey              = "err.drone_not_retirable"
+	ErrDroneInvalidTransitionKey         = "err.drone_invalid_transition"
+	ErrDroneNotLoadedKey                 = "err.drone_not_loaded"
+	ErrDroneStateReadOnlyKey             = "err.drone_state_read_only"
 	ErrBuntdbIndex                       = "err.database_index_related"
 	ErrStorageProc                       = "err.storage_service_processing"
 	ErrVal                               = "err.invalid_data"
@@ -37,6 +45,53 @@ const (
 	ErrCryptProcMissing                  = "err.crypt_material_processing.missing_files"
 	ErrParamURL                          = "err.query_parameter"
 	ErrValidationField                   = "err.validation_field"
+	ErrNotAcceptable                     = "err.not_acceptable"
+	ErrIdempotencyKeyReused              = "err.idempotency_key_reused"
+	ErrIdempotencyInProgress             = "err.idempotency_in_progress"
+)
+
+// endregion =============================================================================
+
+// region ======== i18n DETAIL KEYS ======================================================
+const (
+	DetDatabaseNotPopulated     = "detail.database_not_populated"
+	DetDatabasePopulated        = "detail.database_populated"
+	DetDatabasePopulatedImport  = "detail.database_populated_import"
+	DetImportUnknownUser        = "detail.import_unknown_user" // %s username
+	DetInvalidCredentials       = "detail.invalid_credentials"
+	DetAdminRequired            = "detail.admin_required"
+	DetInvalidField             = "detail.invalid_field"
+	DetInvalidFields            = "detail.invalid_fields" // %d number of invalid fields
+	DetInvalidLimit             = "detail.invalid_limit"
+	DetInvalidSeed              = "detail.invalid_seed"
+	DetSeedDisabled             = "detail.seed_disabled"
+	DetInvalidCursor            = "detail.invalid_cursor"
+	DetInvalidMedicationIDs     = "detail.invalid_medication_ids"
+	DetSerialNumberMismatch     = "detail.serial_number_mismatch"
+	DetSerialNumberTooLong      = "detail.serial_number_too_long" // %s max length
+	DetDroneNotFound            = "detail.drone_not_found"        // %s serial number
+	DetMedicationNotFound       = "detail.medication_not_found"
+	DetDroneAlreadyExists       = "detail.drone_already_exists"
+	DetDroneRetired             = "detail.drone_retired"
+	DetDroneRetiredRegistration = "detail.drone_retired_registration"
+	DetDroneNotRetirable        = "detail.drone_not_retirable"
+	DetDroneVersionMismatch     = "detail.drone_version_mismatch"
+	DetDroneVeryLowBattery      = "detail.drone_very_low_battery" // %f battery level, %f min battery level
+	DetDroneBusy                = "detail.drone_busy"
+	DetDroneInvalidTransition   = "detail.drone_invalid_transition" // %s action, %s state
+	DetDroneNotLoaded           = "detail.drone_not_loaded"
+	DetDroneStateReadOnly       = "detail.drone_state_read_only"
+	DetInvalidSnapshotID        = "detail.invalid_snapshot_id"
+	DetSnapshotNotFound         = "detail.snapshot_not_found" // %s snapshot ID
+	DetNotAcceptable            = "detail.not_acceptable"     // %s offered media types
+	DetBulkInvalidMode          = "detail.bulk_invalid_mode"
+	DetBulkEmpty                = "detail.bulk_empty"
+	DetBulkTooLarge             = "detail.bulk_too_large"          // %d number of items, %d max items
+	DetBulkInvalidCSV           = "detail.bulk_invalid_csv"        // %s parse error
+	DetBulkDuplicateItem        = "detail.bulk_duplicate_item"     // %d index of the first item
+	DetIdempotencyKeyInvalid    = "detail.idempotency_key_invalid" // %d max length
+	DetIdempotencyKeyReused     = "detail.idempotency_key_reused"
+	DetIdempotencyInProgress    = "detail.idempotency_in_progress"
 )
 
 // endregion =============================================================================
@@ -62,8 +117,41 @@ var (
 	// ErrDroneMaximumLoadWeightExceeded the drone from being loaded with more weight that it can carry
 	ErrDroneMaximumLoadWeightExceeded = errors.New("maximum load weight exceeded")
 	ErrDroneVeryLowBattery            = errors.New("battery level is **below 25%**")
-	// ErrDroneBusy when the state of the drone is different from IDLE
-	ErrDroneBusy = errors.New("drone busy, select a drone in IDLE mode")
+	// ErrDroneBusy when loading a drone that is on a delivery trip (DELIVERING, DELIVERED or RETURNING)
+	ErrDroneBusy = errors.New("drone busy, select a drone in IDLE, LOADING or LOADED mode")
+	// ErrMedicationNotFound when loading a drone with the code of a medication that doesn't exist
+	ErrMedicationNotFound = errors.New("at least one of the medication items does not exist")
+	// ErrDroneAlreadyExists when registering a drone with the serial number of an existing one
+	ErrDroneAlreadyExists = errors.New("a drone with the same serial number already exists")
+	// ErrDroneVersionMismatch when the drone has been modified since the version the client knows (If-Match)
+	ErrDroneVersionMismatch = errors.New("the drone has been modified, fetch it again and retry")
+	// ErrDroneRetired when operating on a decommissioned drone, its serial number can't be reused
+	ErrDroneRetired = errors.New("the drone has been retired")
+	// ErrInvalidCursor when the pagination cursor was not issued by this API or by a different sort
+	ErrInvalidCursor = errors.New("invalid pagination cursor")
+	// ErrDroneNotRetirable when retiring a drone that is mid-delivery or loaded with medications
+	ErrDroneNotRetirable = errors.New("the drone can't be retired while it is mid-delivery or loaded with medications")
+	// ErrDroneInvalidTransition when a lifecycle action is not allowed in the current state of the drone
+	ErrDroneInvalidTransition = errors.New("the lifecycle action is not allowed in the state of the drone")
+	// ErrDroneNotLoaded when dispatching a drone without loaded medications
+	ErrDroneNotLoaded = errors.New("the drone has no loaded medications")
+	// ErrDroneStateReadOnly when an update changes the state of the drone, it only changes by loading the
+	// drone and by the lifecycle actions
+	ErrDroneStateReadOnly = errors.New("the state of the drone can't be updated")
+	// ErrDiskSpaceUnsupported when the free disk space can't be checked on the platform
+	ErrDiskSpaceUnsupported = errors.New("the free disk space can't be checked on this platform")
+	// ErrShuttingDown when a database is opened after the shutdown has started
+	ErrShuttingDown = errors.New("the server is shutting down")
+	// ErrUserAlreadyExists when creating a user with the username of an existing one
+	ErrUserAlreadyExists = errors.New("a user with the same username already exists")
+	// ErrDatasetVersion when importing a dataset exported by an incompatible version
+	ErrDatasetVersion = errors.New("unsupported dataset version")
+	// ErrInvalidDataset when importing a dataset with dangling references
+	ErrInvalidDataset = errors.New("invalid dataset")
+	// ErrInvalidFixture when the seed fixture file can't be read or its data is not valid
+	ErrInvalidFixture = errors.New("invalid seed fixture")
+	// ErrSchemaVersion when the store database has a schema version newer than the migrations of this build
+	ErrSchemaVersion = errors.New("the store database was written by a newer version of the server")
 )
 
 // endregion =============================================================================
@@ -74,6 +162,13 @@ const (
 	// ENV VARS
 	EnvConfigPath = "SERVER_CONFIG"
 	EnvJWTSignKey = "SERVER_JWT_SIGN_KEY"
+
+	// DefaultConfigPath config file used when neither the --config flag nor SERVER_CONFIG are set
+	DefaultConfigPath = "./conf/conf.yaml"
+
+	// STORE BACKENDS of the drones and event log repositories
+	StoreBackendBuntDB   = "buntdb"
+	StoreBackendPostgres = "postgres"
 )
 
 // endregion =============================================================================
@@ -0,0 +1,72 @@
+package dto
+
+import "encoding/json"
+
+// audit actions, one for every state-changing operation of the API
+const (
+	AuditActionPopulateDB      = "database.populate"
+	AuditActionRegisterDrone   = "drone.register"
+	AuditActionUpdateDrone     = "drone.update"
+	AuditActionRetireDrone     = "drone.retire"
+	AuditActionLoadMedications = "drone.load_medications"
+	AuditActionDispatchDrone   = "drone.dispatch"
+	AuditActionDeliverDrone    = "drone.deliver"
+	AuditActionReturningDrone  = "drone.returning"
+	AuditActionReturnDrone     = "drone.return"
+	AuditActionAbortDrone      = "drone.abort"
+	AuditActionLogin           = "auth.login"
+	AuditActionLoginFailed     = "auth.login_failed"
+	AuditActionLogout          = "auth.logout"
+	AuditActionCreateUser      = "user.create"
+	AuditActionImportDB        = "database.import"
+	AuditActionBackupDB        = "database.backup"
+	AuditActionRestoreDB       = "database.restore"
+	AuditActionMigrateDB       = "database.migrate"
+	AuditActionResetDB         = "database.reset"
+
+	// AuditAnonymousActor actor used when the operation is not authenticated
+	AuditAnonymousActor = "anonymous"
+	// AuditCLIActor actor of the operations run from the command line
+	AuditCLIActor = "cli"
+)
+
+// AuditChange model
+// @Description a top-level field that changed between the before and after snapshots
+type AuditChange struct {
+	Field  string          `json:"field"`
+	Before json.RawMessage `json:"before,omitempty" swaggertype:"object"`
+	After  json.RawMessage `json:"after,omitempty" swaggertype:"object"`
+}
+
+// AuditEntry model
+// @Description append-only audit record of a state-changing operation, chained by hash
+type AuditEntry struct {
+	Sequence uint64          `json:"sequence"`
+	Created  string          `json:"created"`
+	Actor    InjectedParam   `json:"actor"`
+	Action   string          `json:"action"`
+	Target   string          `json:"target"`
+	Before   json.RawMessage `json:"before,omitempty" swaggertype:"object"`
+	After    json.RawMessage `json:"after,omitempty" swaggertype:"object"`
+	Changes  []AuditChange   `json:"changes"`
+	PrevHash string          `json:"prevHash"`
+	Hash     string          `json:"hash"`
+}
+
+// AuditFilter criteria used to query the audit trail, empty fields are ignored
+type AuditFilter struct {
+	Actor  string
+	Action string
+	Target string
+	From   string // RFC3339 timestamp, inclusive
+	To     string // RFC3339 timestamp, inclusive
+	Limit  int
+}
+
+// AuditChainStatus result of verifying the audit hash chain
+type AuditChainStatus struct {
+	Valid    bool   `json:"valid"`
+	Entries  uint64 `json:"entries"`
+	BrokenAt uint64 `json:"brokenAt,omitempty"`
+	LastHash string `json:"lastHash"`
+}
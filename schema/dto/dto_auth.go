@@ -10,8 +10,15 @@ type UserCredIn struct {
 type GrantIntentResponse struct {
 	Identifier string // if we use `json:"<source_name>"` we can map any source to a common particular / internal struct field as Identifier used here
 	DID        string
+	Admin      bool
 }
 
+// Scopes of the access tokens
+const (
+	ScopeDrones = "api.drones" // every user
+	ScopeAdmin  = "api.admin"  // the administrators, required by the /admin endpoints
+)
+
 // AccessTokenData using by this REST Api (HLF client node) to grant access to the resources
 type AccessTokenData struct {
 	Scope  []string
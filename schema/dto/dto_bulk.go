@@ -0,0 +1,42 @@
+package dto
+
+// modes of the bulk operations
+const (
+	BulkModeAtomic     = "atomic"      // all the items are written or none of them
+	BulkModeBestEffort = "best-effort" // the valid items are written, the others are reported
+	MaxBulkItems       = 500           // items of a bulk request
+)
+
+// LoadInstruction model
+// @Description the medications to load on a drone, an item of the bulk loading
+type LoadInstruction struct {
+	SerialNumber    string   `json:"serialNumber" example:"SN-1"`
+	MedicationCodes []string `json:"medicationCodes" example:"ASPIRIN_500,IBUPROFEN_200"`
+}
+
+// DroneLoad the medications to write on a drone by the bulk loading, the drone has been checked by the service
+type DroneLoad struct {
+	Drone             *Drone
+	MedicationItemIDs []interface{}
+}
+
+// BulkReport model
+// @Description the result of every item of a bulk request, in the order of the request
+type BulkReport struct {
+	Mode      string     `json:"mode" example:"best-effort"`
+	Total     int        `json:"total"`
+	Succeeded int        `json:"succeeded"`
+	Failed    int        `json:"failed"`
+	Items     []BulkItem `json:"items"`
+}
+
+// BulkItem model
+// @Description the result of an item of a bulk request. The status is 201 for a registered drone, 200 for a
+// @Description loaded one, the status of the problem for a failed one and 424 for an item that was valid but
+// @Description was not written because another item of an atomic request failed
+type BulkItem struct {
+	Index        int      `json:"index"`
+	SerialNumber string   `json:"serialNumber" example:"SN-1"`
+	Status       int      `json:"status" example:"201"`
+	Problem      *Problem `json:"problem,omitempty"`
+}
@@ -0,0 +1,85 @@
+package dto
+
+// DatasetVersion version of the export format, bumped on every incompatible change. Version 1 had no event logs
+const DatasetVersion = 2
+
+// Dataset model
+// @Description the whole dataset (store and event log databases), used to export and import it as JSON
+type Dataset struct {
+	Version     int                 `json:"version"`
+	ExportedAt  string              `json:"exportedAt"`
+	Users       []User              `json:"users"`
+	Drones      []Drone             `json:"drones"`
+	Medications []Medication        `json:"medications"`
+	Payloads    map[string][]string `json:"payloads"`   // codes of the loaded medications by drone serial number
+	Logs        []LogEvent          `json:"logs"`       // battery level event logs, the oldest first
+	Deliveries  []Delivery          `json:"deliveries"` // delivery records, the oldest dispatch first
+}
+
+// Snapshot model
+// @Description consistent copy of the store and event log database files
+type Snapshot struct {
+	ID      string   `json:"id" example:"20220301-020000.000"`
+	Created string   `json:"created" example:"2022-03-01T02:00:00Z"`
+	Files   []string `json:"files" example:"data.db,event_log.db"`
+	Size    int64    `json:"size"` // bytes of the snapshot files
+}
+
+// MigrationReport model
+// @Description schema migrations of the store database, the applied ones or the ones a dry run would apply
+type MigrationReport struct {
+	DryRun      bool              `json:"dryRun"`
+	FromVersion int               `json:"fromVersion"`
+	ToVersion   int               `json:"toVersion"`
+	Migrations  []MigrationResult `json:"migrations"`
+}
+
+// MigrationResult model
+// @Description a migration and the changes it made, or would make in a dry run
+type MigrationResult struct {
+	Version int      `json:"version"`
+	Name    string   `json:"name"`
+	Changes []string `json:"changes"`
+}
+
+// SeedOptions how the database is seeded, the zero values fall back to the SeedFile and Seed settings
+type SeedOptions struct {
+	Fixture string // YAML or JSON fixture file, it takes precedence over the seed
+	Seed    int64  // seed of the generated data, 0 for a random one
+}
+
+// SeedFixture model
+// @Description fixture file of the seeding, in YAML or JSON
+type SeedFixture struct {
+	Users       []SeedUser          `json:"users"`
+	Drones      []Drone             `json:"drones"`
+	Medications []Medication        `json:"medications"`
+	Payloads    map[string][]string `json:"payloads"` // codes of the loaded medications by drone serial number
+}
+
+// SeedUser model
+// @Description user of a seed fixture, with its password in plain text or already hashed
+type SeedUser struct {
+	Username   string `json:"username"`
+	Name       string `json:"name"`
+	Password   string `json:"password"`   // hashed when seeding
+	Passphrase string `json:"passphrase"` // SHA256 of the password, used when password is empty
+	Admin      bool   `json:"admin"`
+}
+
+// SeedReport model
+// @Description the data written by a populate or a reset, the seed reproduces the generated data
+type SeedReport struct {
+	Source      string `json:"source" example:"generated"` // generated or fixture
+	Fixture     string `json:"fixture,omitempty"`
+	Seed        int64  `json:"seed,omitempty" example:"42"`
+	Users       int    `json:"users"`
+	Drones      int    `json:"drones"`
+	Medications int    `json:"medications"`
+}
+
+// seed sources of a SeedReport
+const (
+	SeedSourceGenerated = "generated"
+	SeedSourceFixture   = "fixture"
+)
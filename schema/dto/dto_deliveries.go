@@ -0,0 +1,49 @@
+package dto
+
+// lifecycle actions of a loaded drone: dispatch (DELIVERING), delivered (DELIVERED), return (RETURNING),
+// returned (IDLE) and abort (RETURNING, with the medications still on board)
+const (
+	DeliveryActionDispatch  = "dispatch"
+	DeliveryActionDelivered = "delivered"
+	DeliveryActionReturn    = "return"
+	DeliveryActionReturned  = "returned"
+	DeliveryActionAbort     = "abort"
+)
+
+// status of a delivery
+const (
+	DeliveryInTransit = "in-transit"
+	DeliveryDelivered = "delivered"
+	DeliveryAborted   = "aborted"
+)
+
+// Delivery model
+// @Description the trip of a drone from its dispatch to its return, with the medications it carried.
+// @Description The timestamps are RFC 3339 (UTC), the ones of the steps not reached yet are omitted
+type Delivery struct {
+	ID           string   `json:"id" example:"0a3c7a1e-5b1f-4d8c-9f5e-2b6f1d0c9e7a"`
+	SerialNumber string   `json:"serialNumber" example:"SN-1"`
+	Medications  []string `json:"medications" example:"ASPIRIN_500,IBUPROFEN_200"` // codes of the medications carried
+	Status       string   `json:"status" example:"delivered"`
+	DispatchedAt string   `json:"dispatchedAt" example:"2022-03-01T10:00:00Z"`
+	DeliveredAt  string   `json:"deliveredAt,omitempty" example:"2022-03-01T10:20:00Z"`
+	AbortedAt    string   `json:"abortedAt,omitempty"`
+	ReturnedAt   string   `json:"returnedAt,omitempty"`
+}
+
+// DeliveryFilter criteria used to query the deliveries, empty fields are ignored. The time range applies
+// to the dispatch of the deliveries
+type DeliveryFilter struct {
+	SerialNumber   string
+	MedicationCode string
+	From           string // RFC3339 timestamp, inclusive
+	To             string // RFC3339 timestamp, inclusive
+}
+
+// DroneDelivery model
+// @Description the drone after a lifecycle action and its delivery, there is no delivery if the drone
+// @Description returned from a trip that was not dispatched through the API
+type DroneDelivery struct {
+	Drone    Drone     `json:"drone"`
+	Delivery *Delivery `json:"delivery,omitempty"`
+}
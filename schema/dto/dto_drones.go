@@ -27,6 +27,12 @@ func (droneState DroneState) String() string {
 	}
 	return names[droneState]
 }
+
+// Loadable whether a drone in the state can be loaded, it is not on a delivery trip
+func (droneState DroneState) Loadable() bool {
+	return droneState <= LOADED
+}
+
 func (droneModel DroneModel) String() string {
 	names := []string{"Lightweight", "Middleweight", "Cruiserweight", "Heavyweight"}
 	if droneModel < Lightweight || droneModel > Heavyweight {
@@ -36,7 +42,8 @@ func (droneModel DroneModel) String() string {
 }
 
 type ConfigDB struct {
-	IsPopulated bool `json:"isPopulated"`
+	IsPopulated   bool `json:"isPopulated"`
+	SchemaVersion int  `json:"schemaVersion"` // version of the key scheme and records, see the migrations of the store database
 }
 
 // RequestDrone model
@@ -57,6 +64,17 @@ type Drone struct {
 	WeightLimit     float64    `json:"weightLimit" valid:"required~the weight limit is between 1 and 500 gr,range(1|500)~the weight limit is between 1 and 500 gr"`
 	BatteryCapacity float64    `json:"batteryCapacity" valid:"range(0|100)"`
 	State           DroneState `json:"state" valid:"drone_enum_validation~unknown drone state"`
+	Version         uint64     `json:"version"` // incremented on every write, used as ETag for optimistic concurrency
+	Retired         bool       `json:"retired"`
+	RetiredAt       string     `json:"retiredAt,omitempty"`
+}
+
+// PatchDrone model
+// @Description drone fields for a partial update, the omitted fields keep their current value
+type PatchDrone struct {
+	Model           *DroneModel `json:"model,omitempty"`
+	BatteryCapacity *float64    `json:"batteryCapacity,omitempty"`
+	State           *DroneState `json:"state,omitempty"`
 }
 
 // Medication model
@@ -73,8 +91,55 @@ const (
 	RegexpMedicationCode  = "^[A-Z0-9_]*$"     // allowed only upper case letters, underscore and numbers
 	MaxSerialNumberLength = "100"              // serial number (100 characters max)
 	WeightLimitDrone      = 500                // weight limit (500gr max)
+	MinBatteryToLoad      = 25.0               // default of the MinBatteryToLoad setting, a drone can't be loaded if the battery level is below 25%
+)
+
+// drone fields allowed to sort the drone list
+const (
+	DroneSortBattery      = "batteryCapacity"
+	DroneSortSerialNumber = "serialNumber"
+	DroneSortWeightLimit  = "weightLimit"
+	DroneSortModel        = "model"
+	DroneSortState        = "state"
 )
 
+// DroneFilter typed criteria to list drones, the nil / empty fields are ignored
+type DroneFilter struct {
+	States             []DroneState
+	Models             []DroneModel
+	BatteryMin         *float64
+	BatteryMax         *float64
+	MinAvailableWeight *float64 // weight limit minus the weight of the loaded medications
+	Retired            bool     // list the retired drones instead of the ones in service
+
+	SortBy string // one of the DroneSort* constants, battery capacity by default
+	Desc   bool
+
+	Cursor string // opaque cursor returned as NextCursor by the previous page
+	Limit  int    // page size, 0 returns all the drones
+}
+
+// DronePage a page of the drone list
+type DronePage struct {
+	Items      []Drone `json:"items"`
+	Total      int     `json:"total"`
+	NextCursor string  `json:"nextCursor,omitempty"`
+}
+
+// FleetStats model
+// @Description summary of the drones in service, the retired drones are only counted in "retired"
+type FleetStats struct {
+	Total               int            `json:"total"`
+	Retired             int            `json:"retired"`
+	ByState             map[string]int `json:"byState"`
+	ByModel             map[string]int `json:"byModel"`
+	AverageBattery      float64        `json:"averageBattery"`
+	MinBattery          float64        `json:"minBattery"`
+	AvailableForLoading int            `json:"availableForLoading"` // IDLE and battery >= MinBatteryToLoad
+	FreeCapacity        float64        `json:"freeCapacity"`        // sum of the weight limits minus the loaded medications
+	ActiveLoads         int            `json:"activeLoads"`         // drones with loaded medications
+}
+
 type DroneBatteryLevel struct {
 	SerialNumber    string  `json:"serialNumber"`
 	BatteryCapacity float64 `json:"batteryCapacity"`
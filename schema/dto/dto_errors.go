@@ -1,19 +1,66 @@
 package dto
 
-// Problem api documentation
+import "errors"
+
+// Problem model
+// @Description problem details (RFC 7807), the code is stable and documented in the problem catalogue
 type Problem struct {
-	Status uint   `example:"503"`
-	Title  string `example:"err_code"`
-	Detail string `example:"Some error details"`
+	Type   string `json:"type" example:"https://github.com/kmilodenisglez/drones.restapi/blob/main/docs/problems.md#err.drone_busy"`
+	Title  string `json:"title" example:"Drone busy"`
+	Status uint   `json:"status" example:"412"`
+	// Detail explanation of this occurrence, omitted for the internal errors unless the server runs in debug mode
+	Detail   string `json:"detail,omitempty" example:"drone busy, select a drone in IDLE mode"`
+	Instance string `json:"instance" example:"/api/v1/medications/items/123e4567-e89b-12d3-a456-426614174001"`
+	// Code stable error code, one of the schema.Err* keys
+	Code string `json:"code" example:"err.drone_busy"`
+	// Errors the invalid fields of a validation failure
+	Errors []FieldError `json:"errors,omitempty"`
+	// RequestID ID of the request that failed, the same of the X-Request-Id header and the server logs
+	RequestID string `json:"requestId,omitempty" example:"0a3c7a1e-5b1f-4d8c-9f5e-2b6f1d0c9e7a"`
+	// DetailArgs arguments of the detail when it is a message key (schema.Det* key)
+	DetailArgs []interface{} `json:"-"`
+}
+
+// FieldError model
+// @Description an invalid field of a validation failure
+type FieldError struct {
+	Field   string `json:"field" example:"batteryCapacity"`
+	Message string `json:"message" example:"101 is not between 0 and 100"`
+	// Rule name of the validator that failed
+	Rule string `json:"rule,omitempty" example:"range"`
+	// Args the invalid value followed by the parameters of the rule, to translate the message
+	Args []interface{} `json:"-"`
 }
 
 // NewProblem construct a new api error struct and return a pointer to it
 //
 // - s [uint] ~ HTTP status tu respond
 //
-// - t [string] ~ Title of the error
+// - c [string] ~ Error code (schema.Err* key)
+//
+// - d [string] ~ Description or detail of the error, a message key (schema.Det* key) or free text
+func NewProblem(s uint, c string, d string) *Problem {
+	return &Problem{Status: s, Code: c, Detail: d}
+}
+
+// NewProblemf construct a new api error struct whose detail is a message key with arguments, and return
+// a pointer to it
+//
+// - s [uint] ~ HTTP status tu respond
+//
+// - c [string] ~ Error code (schema.Err* key)
 //
-// - d [string] ~ Description or detail of the error
-func NewProblem(s uint, t string, d string) *Problem {
-	return &Problem{Status: s, Title: t, Detail: d}
+// - d [string] ~ Message key of the detail (schema.Det* key)
+//
+// - args [...interface{}] ~ Arguments of the detail message
+func NewProblemf(s uint, c string, d string, args ...interface{}) *Problem {
+	return &Problem{Status: s, Code: c, Detail: d, DetailArgs: args}
+}
+
+// Err the problem as an error, nil when there is no problem (e.g. to end a span with tracing.End)
+func (p *Problem) Err() error {
+	if p == nil {
+		return nil
+	}
+	return errors.New(p.Code + ": " + p.Detail)
 }
@@ -0,0 +1,16 @@
+package dto
+
+// GraphQLRequest model
+// @Description a GraphQL query or mutation, with its variables
+type GraphQLRequest struct {
+	Query         string                 `json:"query" example:"{ drone(serialNumber: \"SN-1\") { state loadedMedications { code weight } } }"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty" swaggertype:"object"`
+}
+
+// GraphQLResponse model
+// @Description the data of a GraphQL request and its errors, the errors of the services carry the problem code in their extensions
+type GraphQLResponse struct {
+	Data   interface{}   `json:"data,omitempty" swaggertype:"object"`
+	Errors []interface{} `json:"errors,omitempty" swaggertype:"array,object"`
+}
@@ -0,0 +1,40 @@
+package dto
+
+// statuses of the health checks and reports
+const (
+	HealthOK       = "ok"
+	HealthFail     = "fail"
+	HealthDisabled = "disabled" // the checked feature is turned off in the configuration, it doesn't fail the report
+	HealthSkipped  = "skipped"  // the check can't run on this platform, it doesn't fail the report
+)
+
+// names of the readiness checks
+const (
+	HealthCheckStoreDB    = "store_db"
+	HealthCheckEventLogDB = "event_log_db"
+	HealthCheckAuditDB    = "audit_db"
+	HealthCheckPopulated  = "populated"
+	HealthCheckCron       = "cron"
+	HealthCheckDiskSpace  = "disk_space"
+)
+
+// HealthCheck result of a single readiness check
+type HealthCheck struct {
+	Status   string `json:"status" example:"ok"`
+	Detail   string `json:"detail,omitempty" example:"12034 MB free"`
+	Duration string `json:"duration" example:"1.2ms"`
+}
+
+// HealthReport result of the liveness and readiness probes, Status is "fail" if any check failed
+type HealthReport struct {
+	Status string                 `json:"status" example:"ok"`
+	Checks map[string]HealthCheck `json:"checks,omitempty"`
+}
+
+// SchedulerStatus state of the cron scheduler
+type SchedulerStatus struct {
+	Enabled bool   `json:"enabled"`
+	Running bool   `json:"running"`
+	LastRun string `json:"lastRun,omitempty"`
+	NextRun string `json:"nextRun,omitempty"`
+}
@@ -0,0 +1,20 @@
+package dto
+
+// IdempotencyKeyHeader the header with the key of an idempotent request
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotencyReplayedHeader = "Idempotent-Replayed" // "true" when the response is a replay
+	MaxIdempotencyKeyLength   = 255
+)
+
+// IdempotentResponse the first response of an Idempotency-Key, it is replayed to the retries of the request.
+// It is pending (not completed) while the first request is being handled
+type IdempotentResponse struct {
+	Fingerprint string            `json:"fingerprint"` // SHA256 of the method, the path and the body of the request
+	Completed   bool              `json:"completed"`
+	Status      int               `json:"status,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Body        []byte            `json:"body,omitempty"`
+	Created     string            `json:"created"`
+	Process     string            `json:"process,omitempty"` // server process that reserved a pending key
+}
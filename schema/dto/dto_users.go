@@ -3,6 +3,7 @@ package dto
 // User struct
 type User struct {
 	Username   string `json:"username"`
-	Passphrase string `json:"passphrase"`
+	Passphrase string `json:"passphrase,omitempty"`
 	Name       string `json:"name"`
-}
\ No newline at end of file
+	Admin      bool   `json:"admin,omitempty"` // the access tokens of the user have the ScopeAdmin scope
+}
@@ -1,8 +1,12 @@
 package mapper
 
 import (
-	"github.com/kmilodenisglez/drones.restapi/schema/dto"
+	"fmt"
+	"strconv"
 	"strings"
+
+	"github.com/kmilodenisglez/drones.restapi/schema/dto"
+	"github.com/kmilodenisglez/drones.restapi/schema/pb"
 )
 
 // TIP ref https://hellokoding.com/crud-restful-apis-with-go-modules-wire-gin-gorm-and-mysql/
@@ -15,7 +19,204 @@ func ToAccessTokenDataV(obj *dto.GrantIntentResponse) *dto.AccessTokenData {
 	// claims := dto.Claims{ Sub: obj.Identifier, Rol: "undefined" }
 	claims := dto.InjectedParam{ Did: obj.DID, Username: obj.Identifier }
 
-	return &dto.AccessTokenData{ Scope: strings.Fields("api.drones"), Claims: claims }
+	scope := []string{dto.ScopeDrones}
+	if obj.Admin {
+		scope = append(scope, dto.ScopeAdmin)
+	}
+	return &dto.AccessTokenData{ Scope: scope, Claims: claims }
+}
+
+// endregion =============================================================================
+
+// region ======== PROTOBUF ==============================================================
+
+// ToDronePb dto.Drone to pb.Drone
+func ToDronePb(obj *dto.Drone) *pb.Drone {
+	return &pb.Drone{
+		SerialNumber:    obj.SerialNumber,
+		Model:           pb.DroneModel(obj.Model),
+		WeightLimit:     obj.WeightLimit,
+		BatteryCapacity: obj.BatteryCapacity,
+		State:           pb.DroneState(obj.State),
+		Version:         obj.Version,
+		Retired:         obj.Retired,
+		RetiredAt:       obj.RetiredAt,
+	}
+}
+
+// ToDroneListPb []dto.Drone to pb.DroneList
+func ToDroneListPb(list []dto.Drone) *pb.DroneList {
+	items := make([]*pb.Drone, len(list))
+	for i := range list {
+		items[i] = ToDronePb(&list[i])
+	}
+	return &pb.DroneList{Items: items}
+}
+
+// ToMedicationListPb []dto.Medication to pb.MedicationList
+func ToMedicationListPb(list []dto.Medication) *pb.MedicationList {
+	items := make([]*pb.Medication, len(list))
+	for i, m := range list {
+		items[i] = &pb.Medication{Name: m.Name, Weight: m.Weight, Code: m.Code, Image: m.Image}
+	}
+	return &pb.MedicationList{Items: items}
+}
+
+// ToLogEventListPb []dto.LogEvent to pb.LogEventList
+func ToLogEventListPb(list []dto.LogEvent) *pb.LogEventList {
+	items := make([]*pb.LogEvent, len(list))
+	for i, l := range list {
+		levels := make([]*pb.DroneBatteryLevel, len(l.DronesBatteryLevels))
+		for j, level := range l.DronesBatteryLevels {
+			levels[j] = &pb.DroneBatteryLevel{SerialNumber: level.SerialNumber, BatteryCapacity: level.BatteryCapacity}
+		}
+		items[i] = &pb.LogEvent{Created: l.Created, Uuid: l.UUID, DronesBatteryLevels: levels}
+	}
+	return &pb.LogEventList{Items: items}
+}
+
+// endregion =============================================================================
+
+// region ======== CSV ===================================================================
+
+// ToDronesCSV []dto.Drone to CSV records, the first one is the header. The model and the state are
+// written by name so the file can be read in a spreadsheet
+func ToDronesCSV(list []dto.Drone) [][]string {
+	records := [][]string{{"serialNumber", "model", "weightLimit", "batteryCapacity", "state", "version", "retired", "retiredAt"}}
+	for _, d := range list {
+		records = append(records, []string{
+			d.SerialNumber,
+			d.Model.String(),
+			formatFloat(d.WeightLimit),
+			formatFloat(d.BatteryCapacity),
+			d.State.String(),
+			strconv.FormatUint(d.Version, 10),
+			strconv.FormatBool(d.Retired),
+			d.RetiredAt,
+		})
+	}
+	return records
+}
+
+// ToLogEventsCSV []dto.LogEvent to CSV records, the first one is the header. An event log is written
+// as one record per drone battery level
+func ToLogEventsCSV(list []dto.LogEvent) [][]string {
+	records := [][]string{{"created", "uuid", "serialNumber", "batteryCapacity"}}
+	for _, l := range list {
+		for _, level := range l.DronesBatteryLevels {
+			records = append(records, []string{l.Created, l.UUID, level.SerialNumber, formatFloat(level.BatteryCapacity)})
+		}
+	}
+	return records
+}
+
+// ToDeliveriesCSV []dto.Delivery to CSV records, the first one is the header. A delivery is written as
+// one record per medication carried
+func ToDeliveriesCSV(list []dto.Delivery) [][]string {
+	records := [][]string{{"dispatchedAt", "id", "serialNumber", "medication", "status", "deliveredAt", "abortedAt", "returnedAt"}}
+	for _, d := range list {
+		for _, code := range d.Medications {
+			records = append(records, []string{d.DispatchedAt, d.ID, d.SerialNumber, code, d.Status, d.DeliveredAt, d.AbortedAt, d.ReturnedAt})
+		}
+	}
+	return records
+}
+
+// FromDronesCSV CSV records to []dto.Drone, the first record is the header. The columns are found by
+// name, so a file written by ToDronesCSV can be read back: serialNumber, model and batteryCapacity are
+// required and state is IDLE if it is missing, the other columns are ignored. The model and the state
+// are read by name or by number, an unknown name is kept as an unknown value for the validation
+func FromDronesCSV(records [][]string) ([]dto.Drone, error) {
+	columns, err := csvColumns(records, []string{"serialNumber", "model", "batteryCapacity"}, []string{"state"})
+	if err != nil {
+		return nil, err
+	}
+	drones := make([]dto.Drone, 0, len(records)-1)
+	for line, record := range records[1:] {
+		drone := dto.Drone{
+			SerialNumber: record[columns["serialNumber"]],
+			Model:        dto.DroneModel(parseEnum(record[columns["model"]], uint(dto.Heavyweight)+1, func(v uint) string { return dto.DroneModel(v).String() })),
+		}
+		if drone.BatteryCapacity, err = strconv.ParseFloat(record[columns["batteryCapacity"]], 64); err != nil {
+			return nil, fmt.Errorf("line %d: the battery capacity is not a number", line+2)
+		}
+		if i, ok := columns["state"]; ok && record[i] != "" {
+			drone.State = dto.DroneState(parseEnum(record[i], uint(dto.RETURNING)+1, func(v uint) string { return dto.DroneState(v).String() }))
+		}
+		drones = append(drones, drone)
+	}
+	return drones, nil
+}
+
+// FromLoadInstructionsCSV CSV records to []dto.LoadInstruction, the first record is the header. A
+// record is a medication loaded on a drone (serialNumber and medicationCode columns), the records of a
+// drone are grouped in the order they appear. A record without medication code empties the drone
+func FromLoadInstructionsCSV(records [][]string) ([]dto.LoadInstruction, error) {
+	columns, err := csvColumns(records, []string{"serialNumber", "medicationCode"}, nil)
+	if err != nil {
+		return nil, err
+	}
+	loads := make([]dto.LoadInstruction, 0)
+	indexes := make(map[string]int)
+	for _, record := range records[1:] {
+		serialNumber, code := record[columns["serialNumber"]], record[columns["medicationCode"]]
+		i, ok := indexes[serialNumber]
+		if !ok {
+			i = len(loads)
+			indexes[serialNumber] = i
+			loads = append(loads, dto.LoadInstruction{SerialNumber: serialNumber, MedicationCodes: []string{}})
+		}
+		if code != "" {
+			loads[i].MedicationCodes = append(loads[i].MedicationCodes, code)
+		}
+	}
+	return loads, nil
+}
+
+// csvColumns the position of the required and optional columns in the header of the records. The
+// records must have the same number of fields, as encoding/csv reads them by default
+func csvColumns(records [][]string, required, optional []string) (map[string]int, error) {
+	if len(records) == 0 {
+		return nil, fmt.Errorf("the header is missing")
+	}
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, name := range required {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("the column %s is missing", name)
+		}
+	}
+	wanted := make(map[string]int, len(required)+len(optional))
+	for _, name := range append(required, optional...) {
+		if i, ok := columns[name]; ok {
+			wanted[name] = i
+		}
+	}
+	return wanted, nil
+}
+
+// parseEnum the value of an enum written by name (case insensitive) or by number, size if it is unknown
+//
+// - size [uint] ~ Number of values of the enum, they go from 0 to size-1
+//
+// - name [func(uint) string] ~ Name of a value, its String method
+func parseEnum(value string, size uint, name func(uint) string) uint {
+	value = strings.TrimSpace(value)
+	for v := uint(0); v < size; v++ {
+		if strings.EqualFold(value, name(v)) {
+			return v
+		}
+	}
+	if n, err := strconv.ParseUint(value, 10, 32); err == nil {
+		return uint(n)
+	}
+	return size
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
 }
 
 // endregion =============================================================================
@@ -0,0 +1,800 @@
+// Protobuf representations of the drones, medications and event logs, served by the list endpoints
+// to the clients that send "Accept: application/x-protobuf".
+//
+// Regenerate drones.pb.go after a change, from the root of the project:
+//   protoc --go_out=. --go_opt=module=github.com/kmilodenisglez/drones.restapi schema/pb/drones.proto
+//
+// The gRPC API (drones_api.proto) uses these messages too.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.27.1
+// 	protoc        v3.19.4
+// source: schema/pb/drones.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// DroneModel model of a drone, the values match dto.DroneModel
+type DroneModel int32
+
+const (
+	DroneModel_LIGHTWEIGHT   DroneModel = 0
+	DroneModel_MIDDLEWEIGHT  DroneModel = 1
+	DroneModel_CRUISERWEIGHT DroneModel = 2
+	DroneModel_HEAVYWEIGHT   DroneModel = 3
+)
+
+// Enum value maps for DroneModel.
+var (
+	DroneModel_name = map[int32]string{
+		0: "LIGHTWEIGHT",
+		1: "MIDDLEWEIGHT",
+		2: "CRUISERWEIGHT",
+		3: "HEAVYWEIGHT",
+	}
+	DroneModel_value = map[string]int32{
+		"LIGHTWEIGHT":   0,
+		"MIDDLEWEIGHT":  1,
+		"CRUISERWEIGHT": 2,
+		"HEAVYWEIGHT":   3,
+	}
+)
+
+func (x DroneModel) Enum() *DroneModel {
+	p := new(DroneModel)
+	*p = x
+	return p
+}
+
+func (x DroneModel) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (DroneModel) Descriptor() protoreflect.EnumDescriptor {
+	return file_schema_pb_drones_proto_enumTypes[0].Descriptor()
+}
+
+func (DroneModel) Type() protoreflect.EnumType {
+	return &file_schema_pb_drones_proto_enumTypes[0]
+}
+
+func (x DroneModel) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use DroneModel.Descriptor instead.
+func (DroneModel) EnumDescriptor() ([]byte, []int) {
+	return file_schema_pb_drones_proto_rawDescGZIP(), []int{0}
+}
+
+// DroneState state of a drone, the values match dto.DroneState
+type DroneState int32
+
+const (
+	DroneState_IDLE       DroneState = 0
+	DroneState_LOADING    DroneState = 1
+	DroneState_LOADED     DroneState = 2
+	DroneState_DELIVERING DroneState = 3
+	DroneState_DELIVERED  DroneState = 4
+	DroneState_RETURNING  DroneState = 5
+)
+
+// Enum value maps for DroneState.
+var (
+	DroneState_name = map[int32]string{
+		0: "IDLE",
+		1: "LOADING",
+		2: "LOADED",
+		3: "DELIVERING",
+		4: "DELIVERED",
+		5: "RETURNING",
+	}
+	DroneState_value = map[string]int32{
+		"IDLE":       0,
+		"LOADING":    1,
+		"LOADED":     2,
+		"DELIVERING": 3,
+		"DELIVERED":  4,
+		"RETURNING":  5,
+	}
+)
+
+func (x DroneState) Enum() *DroneState {
+	p := new(DroneState)
+	*p = x
+	return p
+}
+
+func (x DroneState) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (DroneState) Descriptor() protoreflect.EnumDescriptor {
+	return file_schema_pb_drones_proto_enumTypes[1].Descriptor()
+}
+
+func (DroneState) Type() protoreflect.EnumType {
+	return &file_schema_pb_drones_proto_enumTypes[1]
+}
+
+func (x DroneState) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use DroneState.Descriptor instead.
+func (DroneState) EnumDescriptor() ([]byte, []int) {
+	return file_schema_pb_drones_proto_rawDescGZIP(), []int{1}
+}
+
+type Drone struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	SerialNumber    string     `protobuf:"bytes,1,opt,name=serial_number,json=serialNumber,proto3" json:"serial_number,omitempty"`
+	Model           DroneModel `protobuf:"varint,2,opt,name=model,proto3,enum=drones.v1.DroneModel" json:"model,omitempty"`
+	WeightLimit     float64    `protobuf:"fixed64,3,opt,name=weight_limit,json=weightLimit,proto3" json:"weight_limit,omitempty"`
+	BatteryCapacity float64    `protobuf:"fixed64,4,opt,name=battery_capacity,json=batteryCapacity,proto3" json:"battery_capacity,omitempty"`
+	State           DroneState `protobuf:"varint,5,opt,name=state,proto3,enum=drones.v1.DroneState" json:"state,omitempty"`
+	Version         uint64     `protobuf:"varint,6,opt,name=version,proto3" json:"version,omitempty"`
+	Retired         bool       `protobuf:"varint,7,opt,name=retired,proto3" json:"retired,omitempty"`
+	RetiredAt       string     `protobuf:"bytes,8,opt,name=retired_at,json=retiredAt,proto3" json:"retired_at,omitempty"`
+}
+
+func (x *Drone) Reset() {
+	*x = Drone{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_schema_pb_drones_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Drone) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Drone) ProtoMessage() {}
+
+func (x *Drone) ProtoReflect() protoreflect.Message {
+	mi := &file_schema_pb_drones_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Drone.ProtoReflect.Descriptor instead.
+func (*Drone) Descriptor() ([]byte, []int) {
+	return file_schema_pb_drones_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Drone) GetSerialNumber() string {
+	if x != nil {
+		return x.SerialNumber
+	}
+	return ""
+}
+
+func (x *Drone) GetModel() DroneModel {
+	if x != nil {
+		return x.Model
+	}
+	return DroneModel_LIGHTWEIGHT
+}
+
+func (x *Drone) GetWeightLimit() float64 {
+	if x != nil {
+		return x.WeightLimit
+	}
+	return 0
+}
+
+func (x *Drone) GetBatteryCapacity() float64 {
+	if x != nil {
+		return x.BatteryCapacity
+	}
+	return 0
+}
+
+func (x *Drone) GetState() DroneState {
+	if x != nil {
+		return x.State
+	}
+	return DroneState_IDLE
+}
+
+func (x *Drone) GetVersion() uint64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *Drone) GetRetired() bool {
+	if x != nil {
+		return x.Retired
+	}
+	return false
+}
+
+func (x *Drone) GetRetiredAt() string {
+	if x != nil {
+		return x.RetiredAt
+	}
+	return ""
+}
+
+type Medication struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name   string  `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Weight float64 `protobuf:"fixed64,2,opt,name=weight,proto3" json:"weight,omitempty"`
+	Code   string  `protobuf:"bytes,3,opt,name=code,proto3" json:"code,omitempty"`
+	Image  string  `protobuf:"bytes,4,opt,name=image,proto3" json:"image,omitempty"` // base64
+}
+
+func (x *Medication) Reset() {
+	*x = Medication{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_schema_pb_drones_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Medication) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Medication) ProtoMessage() {}
+
+func (x *Medication) ProtoReflect() protoreflect.Message {
+	mi := &file_schema_pb_drones_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Medication.ProtoReflect.Descriptor instead.
+func (*Medication) Descriptor() ([]byte, []int) {
+	return file_schema_pb_drones_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Medication) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Medication) GetWeight() float64 {
+	if x != nil {
+		return x.Weight
+	}
+	return 0
+}
+
+func (x *Medication) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *Medication) GetImage() string {
+	if x != nil {
+		return x.Image
+	}
+	return ""
+}
+
+type DroneBatteryLevel struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	SerialNumber    string  `protobuf:"bytes,1,opt,name=serial_number,json=serialNumber,proto3" json:"serial_number,omitempty"`
+	BatteryCapacity float64 `protobuf:"fixed64,2,opt,name=battery_capacity,json=batteryCapacity,proto3" json:"battery_capacity,omitempty"`
+}
+
+func (x *DroneBatteryLevel) Reset() {
+	*x = DroneBatteryLevel{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_schema_pb_drones_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DroneBatteryLevel) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DroneBatteryLevel) ProtoMessage() {}
+
+func (x *DroneBatteryLevel) ProtoReflect() protoreflect.Message {
+	mi := &file_schema_pb_drones_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DroneBatteryLevel.ProtoReflect.Descriptor instead.
+func (*DroneBatteryLevel) Descriptor() ([]byte, []int) {
+	return file_schema_pb_drones_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *DroneBatteryLevel) GetSerialNumber() string {
+	if x != nil {
+		return x.SerialNumber
+	}
+	return ""
+}
+
+func (x *DroneBatteryLevel) GetBatteryCapacity() float64 {
+	if x != nil {
+		return x.BatteryCapacity
+	}
+	return 0
+}
+
+type LogEvent struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Created             string               `protobuf:"bytes,1,opt,name=created,proto3" json:"created,omitempty"`
+	Uuid                string               `protobuf:"bytes,2,opt,name=uuid,proto3" json:"uuid,omitempty"`
+	DronesBatteryLevels []*DroneBatteryLevel `protobuf:"bytes,3,rep,name=drones_battery_levels,json=dronesBatteryLevels,proto3" json:"drones_battery_levels,omitempty"`
+}
+
+func (x *LogEvent) Reset() {
+	*x = LogEvent{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_schema_pb_drones_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *LogEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LogEvent) ProtoMessage() {}
+
+func (x *LogEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_schema_pb_drones_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LogEvent.ProtoReflect.Descriptor instead.
+func (*LogEvent) Descriptor() ([]byte, []int) {
+	return file_schema_pb_drones_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *LogEvent) GetCreated() string {
+	if x != nil {
+		return x.Created
+	}
+	return ""
+}
+
+func (x *LogEvent) GetUuid() string {
+	if x != nil {
+		return x.Uuid
+	}
+	return ""
+}
+
+func (x *LogEvent) GetDronesBatteryLevels() []*DroneBatteryLevel {
+	if x != nil {
+		return x.DronesBatteryLevels
+	}
+	return nil
+}
+
+// DroneList a page of GET /drones, the total and the next cursor are sent in the headers
+type DroneList struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Items []*Drone `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"`
+}
+
+func (x *DroneList) Reset() {
+	*x = DroneList{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_schema_pb_drones_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DroneList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DroneList) ProtoMessage() {}
+
+func (x *DroneList) ProtoReflect() protoreflect.Message {
+	mi := &file_schema_pb_drones_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DroneList.ProtoReflect.Descriptor instead.
+func (*DroneList) Descriptor() ([]byte, []int) {
+	return file_schema_pb_drones_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *DroneList) GetItems() []*Drone {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+type MedicationList struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Items []*Medication `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"`
+}
+
+func (x *MedicationList) Reset() {
+	*x = MedicationList{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_schema_pb_drones_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MedicationList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MedicationList) ProtoMessage() {}
+
+func (x *MedicationList) ProtoReflect() protoreflect.Message {
+	mi := &file_schema_pb_drones_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MedicationList.ProtoReflect.Descriptor instead.
+func (*MedicationList) Descriptor() ([]byte, []int) {
+	return file_schema_pb_drones_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *MedicationList) GetItems() []*Medication {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+type LogEventList struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Items []*LogEvent `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"`
+}
+
+func (x *LogEventList) Reset() {
+	*x = LogEventList{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_schema_pb_drones_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *LogEventList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LogEventList) ProtoMessage() {}
+
+func (x *LogEventList) ProtoReflect() protoreflect.Message {
+	mi := &file_schema_pb_drones_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LogEventList.ProtoReflect.Descriptor instead.
+func (*LogEventList) Descriptor() ([]byte, []int) {
+	return file_schema_pb_drones_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *LogEventList) GetItems() []*LogEvent {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+var File_schema_pb_drones_proto protoreflect.FileDescriptor
+
+var file_schema_pb_drones_proto_rawDesc = []byte{
+	0x0a, 0x16, 0x73, 0x63, 0x68, 0x65, 0x6d, 0x61, 0x2f, 0x70, 0x62, 0x2f, 0x64, 0x72, 0x6f, 0x6e,
+	0x65, 0x73, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x09, 0x64, 0x72, 0x6f, 0x6e, 0x65, 0x73,
+	0x2e, 0x76, 0x31, 0x22, 0xa7, 0x02, 0x0a, 0x05, 0x44, 0x72, 0x6f, 0x6e, 0x65, 0x12, 0x23, 0x0a,
+	0x0d, 0x73, 0x65, 0x72, 0x69, 0x61, 0x6c, 0x5f, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x73, 0x65, 0x72, 0x69, 0x61, 0x6c, 0x4e, 0x75, 0x6d, 0x62,
+	0x65, 0x72, 0x12, 0x2b, 0x0a, 0x05, 0x6d, 0x6f, 0x64, 0x65, 0x6c, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x0e, 0x32, 0x15, 0x2e, 0x64, 0x72, 0x6f, 0x6e, 0x65, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x72,
+	0x6f, 0x6e, 0x65, 0x4d, 0x6f, 0x64, 0x65, 0x6c, 0x52, 0x05, 0x6d, 0x6f, 0x64, 0x65, 0x6c, 0x12,
+	0x21, 0x0a, 0x0c, 0x77, 0x65, 0x69, 0x67, 0x68, 0x74, 0x5f, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x01, 0x52, 0x0b, 0x77, 0x65, 0x69, 0x67, 0x68, 0x74, 0x4c, 0x69, 0x6d,
+	0x69, 0x74, 0x12, 0x29, 0x0a, 0x10, 0x62, 0x61, 0x74, 0x74, 0x65, 0x72, 0x79, 0x5f, 0x63, 0x61,
+	0x70, 0x61, 0x63, 0x69, 0x74, 0x79, 0x18, 0x04, 0x20, 0x01, 0x28, 0x01, 0x52, 0x0f, 0x62, 0x61,
+	0x74, 0x74, 0x65, 0x72, 0x79, 0x43, 0x61, 0x70, 0x61, 0x63, 0x69, 0x74, 0x79, 0x12, 0x2b, 0x0a,
+	0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x15, 0x2e, 0x64,
+	0x72, 0x6f, 0x6e, 0x65, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x72, 0x6f, 0x6e, 0x65, 0x53, 0x74,
+	0x61, 0x74, 0x65, 0x52, 0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65,
+	0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x06, 0x20, 0x01, 0x28, 0x04, 0x52, 0x07, 0x76, 0x65, 0x72,
+	0x73, 0x69, 0x6f, 0x6e, 0x12, 0x18, 0x0a, 0x07, 0x72, 0x65, 0x74, 0x69, 0x72, 0x65, 0x64, 0x18,
+	0x07, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x72, 0x65, 0x74, 0x69, 0x72, 0x65, 0x64, 0x12, 0x1d,
+	0x0a, 0x0a, 0x72, 0x65, 0x74, 0x69, 0x72, 0x65, 0x64, 0x5f, 0x61, 0x74, 0x18, 0x08, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x09, 0x72, 0x65, 0x74, 0x69, 0x72, 0x65, 0x64, 0x41, 0x74, 0x22, 0x62, 0x0a,
+	0x0a, 0x4d, 0x65, 0x64, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x12, 0x0a, 0x04, 0x6e,
+	0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12,
+	0x16, 0x0a, 0x06, 0x77, 0x65, 0x69, 0x67, 0x68, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x01, 0x52,
+	0x06, 0x77, 0x65, 0x69, 0x67, 0x68, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x69,
+	0x6d, 0x61, 0x67, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x69, 0x6d, 0x61, 0x67,
+	0x65, 0x22, 0x63, 0x0a, 0x11, 0x44, 0x72, 0x6f, 0x6e, 0x65, 0x42, 0x61, 0x74, 0x74, 0x65, 0x72,
+	0x79, 0x4c, 0x65, 0x76, 0x65, 0x6c, 0x12, 0x23, 0x0a, 0x0d, 0x73, 0x65, 0x72, 0x69, 0x61, 0x6c,
+	0x5f, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x73,
+	0x65, 0x72, 0x69, 0x61, 0x6c, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x12, 0x29, 0x0a, 0x10, 0x62,
+	0x61, 0x74, 0x74, 0x65, 0x72, 0x79, 0x5f, 0x63, 0x61, 0x70, 0x61, 0x63, 0x69, 0x74, 0x79, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x01, 0x52, 0x0f, 0x62, 0x61, 0x74, 0x74, 0x65, 0x72, 0x79, 0x43, 0x61,
+	0x70, 0x61, 0x63, 0x69, 0x74, 0x79, 0x22, 0x8a, 0x01, 0x0a, 0x08, 0x4c, 0x6f, 0x67, 0x45, 0x76,
+	0x65, 0x6e, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x64, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x64, 0x12, 0x12, 0x0a,
+	0x04, 0x75, 0x75, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x75, 0x75, 0x69,
+	0x64, 0x12, 0x50, 0x0a, 0x15, 0x64, 0x72, 0x6f, 0x6e, 0x65, 0x73, 0x5f, 0x62, 0x61, 0x74, 0x74,
+	0x65, 0x72, 0x79, 0x5f, 0x6c, 0x65, 0x76, 0x65, 0x6c, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x1c, 0x2e, 0x64, 0x72, 0x6f, 0x6e, 0x65, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x72, 0x6f,
+	0x6e, 0x65, 0x42, 0x61, 0x74, 0x74, 0x65, 0x72, 0x79, 0x4c, 0x65, 0x76, 0x65, 0x6c, 0x52, 0x13,
+	0x64, 0x72, 0x6f, 0x6e, 0x65, 0x73, 0x42, 0x61, 0x74, 0x74, 0x65, 0x72, 0x79, 0x4c, 0x65, 0x76,
+	0x65, 0x6c, 0x73, 0x22, 0x33, 0x0a, 0x09, 0x44, 0x72, 0x6f, 0x6e, 0x65, 0x4c, 0x69, 0x73, 0x74,
+	0x12, 0x26, 0x0a, 0x05, 0x69, 0x74, 0x65, 0x6d, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x10, 0x2e, 0x64, 0x72, 0x6f, 0x6e, 0x65, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x72, 0x6f, 0x6e,
+	0x65, 0x52, 0x05, 0x69, 0x74, 0x65, 0x6d, 0x73, 0x22, 0x3d, 0x0a, 0x0e, 0x4d, 0x65, 0x64, 0x69,
+	0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x4c, 0x69, 0x73, 0x74, 0x12, 0x2b, 0x0a, 0x05, 0x69, 0x74,
+	0x65, 0x6d, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x64, 0x72, 0x6f, 0x6e,
+	0x65, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x65, 0x64, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e,
+	0x52, 0x05, 0x69, 0x74, 0x65, 0x6d, 0x73, 0x22, 0x39, 0x0a, 0x0c, 0x4c, 0x6f, 0x67, 0x45, 0x76,
+	0x65, 0x6e, 0x74, 0x4c, 0x69, 0x73, 0x74, 0x12, 0x29, 0x0a, 0x05, 0x69, 0x74, 0x65, 0x6d, 0x73,
+	0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x64, 0x72, 0x6f, 0x6e, 0x65, 0x73, 0x2e,
+	0x76, 0x31, 0x2e, 0x4c, 0x6f, 0x67, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x52, 0x05, 0x69, 0x74, 0x65,
+	0x6d, 0x73, 0x2a, 0x53, 0x0a, 0x0a, 0x44, 0x72, 0x6f, 0x6e, 0x65, 0x4d, 0x6f, 0x64, 0x65, 0x6c,
+	0x12, 0x0f, 0x0a, 0x0b, 0x4c, 0x49, 0x47, 0x48, 0x54, 0x57, 0x45, 0x49, 0x47, 0x48, 0x54, 0x10,
+	0x00, 0x12, 0x10, 0x0a, 0x0c, 0x4d, 0x49, 0x44, 0x44, 0x4c, 0x45, 0x57, 0x45, 0x49, 0x47, 0x48,
+	0x54, 0x10, 0x01, 0x12, 0x11, 0x0a, 0x0d, 0x43, 0x52, 0x55, 0x49, 0x53, 0x45, 0x52, 0x57, 0x45,
+	0x49, 0x47, 0x48, 0x54, 0x10, 0x02, 0x12, 0x0f, 0x0a, 0x0b, 0x48, 0x45, 0x41, 0x56, 0x59, 0x57,
+	0x45, 0x49, 0x47, 0x48, 0x54, 0x10, 0x03, 0x2a, 0x5d, 0x0a, 0x0a, 0x44, 0x72, 0x6f, 0x6e, 0x65,
+	0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x08, 0x0a, 0x04, 0x49, 0x44, 0x4c, 0x45, 0x10, 0x00, 0x12,
+	0x0b, 0x0a, 0x07, 0x4c, 0x4f, 0x41, 0x44, 0x49, 0x4e, 0x47, 0x10, 0x01, 0x12, 0x0a, 0x0a, 0x06,
+	0x4c, 0x4f, 0x41, 0x44, 0x45, 0x44, 0x10, 0x02, 0x12, 0x0e, 0x0a, 0x0a, 0x44, 0x45, 0x4c, 0x49,
+	0x56, 0x45, 0x52, 0x49, 0x4e, 0x47, 0x10, 0x03, 0x12, 0x0d, 0x0a, 0x09, 0x44, 0x45, 0x4c, 0x49,
+	0x56, 0x45, 0x52, 0x45, 0x44, 0x10, 0x04, 0x12, 0x0d, 0x0a, 0x09, 0x52, 0x45, 0x54, 0x55, 0x52,
+	0x4e, 0x49, 0x4e, 0x47, 0x10, 0x05, 0x42, 0x34, 0x5a, 0x32, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62,
+	0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6b, 0x6d, 0x69, 0x6c, 0x6f, 0x64, 0x65, 0x6e, 0x69, 0x73, 0x67,
+	0x6c, 0x65, 0x7a, 0x2f, 0x64, 0x72, 0x6f, 0x6e, 0x65, 0x73, 0x2e, 0x72, 0x65, 0x73, 0x74, 0x61,
+	0x70, 0x69, 0x2f, 0x73, 0x63, 0x68, 0x65, 0x6d, 0x61, 0x2f, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_schema_pb_drones_proto_rawDescOnce sync.Once
+	file_schema_pb_drones_proto_rawDescData = file_schema_pb_drones_proto_rawDesc
+)
+
+func file_schema_pb_drones_proto_rawDescGZIP() []byte {
+	file_schema_pb_drones_proto_rawDescOnce.Do(func() {
+		file_schema_pb_drones_proto_rawDescData = protoimpl.X.CompressGZIP(file_schema_pb_drones_proto_rawDescData)
+	})
+	return file_schema_pb_drones_proto_rawDescData
+}
+
+var file_schema_pb_drones_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_schema_pb_drones_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
+var file_schema_pb_drones_proto_goTypes = []interface{}{
+	(DroneModel)(0),           // 0: drones.v1.DroneModel
+	(DroneState)(0),           // 1: drones.v1.DroneState
+	(*Drone)(nil),             // 2: drones.v1.Drone
+	(*Medication)(nil),        // 3: drones.v1.Medication
+	(*DroneBatteryLevel)(nil), // 4: drones.v1.DroneBatteryLevel
+	(*LogEvent)(nil),          // 5: drones.v1.LogEvent
+	(*DroneList)(nil),         // 6: drones.v1.DroneList
+	(*MedicationList)(nil),    // 7: drones.v1.MedicationList
+	(*LogEventList)(nil),      // 8: drones.v1.LogEventList
+}
+var file_schema_pb_drones_proto_depIdxs = []int32{
+	0, // 0: drones.v1.Drone.model:type_name -> drones.v1.DroneModel
+	1, // 1: drones.v1.Drone.state:type_name -> drones.v1.DroneState
+	4, // 2: drones.v1.LogEvent.drones_battery_levels:type_name -> drones.v1.DroneBatteryLevel
+	2, // 3: drones.v1.DroneList.items:type_name -> drones.v1.Drone
+	3, // 4: drones.v1.MedicationList.items:type_name -> drones.v1.Medication
+	5, // 5: drones.v1.LogEventList.items:type_name -> drones.v1.LogEvent
+	6, // [6:6] is the sub-list for method output_type
+	6, // [6:6] is the sub-list for method input_type
+	6, // [6:6] is the sub-list for extension type_name
+	6, // [6:6] is the sub-list for extension extendee
+	0, // [0:6] is the sub-list for field type_name
+}
+
+func init() { file_schema_pb_drones_proto_init() }
+func file_schema_pb_drones_proto_init() {
+	if File_schema_pb_drones_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_schema_pb_drones_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Drone); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_schema_pb_drones_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Medication); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_schema_pb_drones_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DroneBatteryLevel); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_schema_pb_drones_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*LogEvent); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_schema_pb_drones_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DroneList); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_schema_pb_drones_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MedicationList); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_schema_pb_drones_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*LogEventList); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_schema_pb_drones_proto_rawDesc,
+			NumEnums:      2,
+			NumMessages:   7,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_schema_pb_drones_proto_goTypes,
+		DependencyIndexes: file_schema_pb_drones_proto_depIdxs,
+		EnumInfos:         file_schema_pb_drones_proto_enumTypes,
+		MessageInfos:      file_schema_pb_drones_proto_msgTypes,
+	}.Build()
+	File_schema_pb_drones_proto = out.File
+	file_schema_pb_drones_proto_rawDesc = nil
+	file_schema_pb_drones_proto_goTypes = nil
+	file_schema_pb_drones_proto_depIdxs = nil
+}
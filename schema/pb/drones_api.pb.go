@@ -0,0 +1,925 @@
+// gRPC API of the drones, the same operations of the REST API served by the service layer. Every call
+// must send the access token of POST /api/v1/auth in the "authorization" metadata ("Bearer <token>").
+//
+// Regenerate drones_api.pb.go and drones_api_grpc.pb.go after a change, from the root of the project:
+//   protoc --go_out=. --go_opt=module=github.com/kmilodenisglez/drones.restapi \
+//     --go-grpc_out=. --go-grpc_opt=module=github.com/kmilodenisglez/drones.restapi schema/pb/drones_api.proto
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.27.1
+// 	protoc        v3.19.4
+// source: schema/pb/drones_api.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// ListDronesRequest the filter of GET /drones, the unset fields are ignored
+type ListDronesRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	States          []DroneState `protobuf:"varint,1,rep,packed,name=states,proto3,enum=drones.v1.DroneState" json:"states,omitempty"`
+	Models          []DroneModel `protobuf:"varint,2,rep,packed,name=models,proto3,enum=drones.v1.DroneModel" json:"models,omitempty"`
+	BatteryMin      *float64     `protobuf:"fixed64,3,opt,name=battery_min,json=batteryMin,proto3,oneof" json:"battery_min,omitempty"`
+	BatteryMax      *float64     `protobuf:"fixed64,4,opt,name=battery_max,json=batteryMax,proto3,oneof" json:"battery_max,omitempty"`
+	AvailableWeight *float64     `protobuf:"fixed64,5,opt,name=available_weight,json=availableWeight,proto3,oneof" json:"available_weight,omitempty"` // minimum free carrying capacity
+	Retired         bool         `protobuf:"varint,6,opt,name=retired,proto3" json:"retired,omitempty"`                                               // list the retired drones instead of the ones in service
+	Sort            string       `protobuf:"bytes,7,opt,name=sort,proto3" json:"sort,omitempty"`                                                      // batteryCapacity (default), serialNumber, weightLimit, model or state
+	Order           string       `protobuf:"bytes,8,opt,name=order,proto3" json:"order,omitempty"`                                                    // asc or desc, desc by default when sorting by battery capacity
+	Limit           int32        `protobuf:"varint,9,opt,name=limit,proto3" json:"limit,omitempty"`                                                   // page size, 0 returns all the drones
+	Cursor          string       `protobuf:"bytes,10,opt,name=cursor,proto3" json:"cursor,omitempty"`                                                 // next_cursor of the previous page
+}
+
+func (x *ListDronesRequest) Reset() {
+	*x = ListDronesRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_schema_pb_drones_api_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListDronesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListDronesRequest) ProtoMessage() {}
+
+func (x *ListDronesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_schema_pb_drones_api_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListDronesRequest.ProtoReflect.Descriptor instead.
+func (*ListDronesRequest) Descriptor() ([]byte, []int) {
+	return file_schema_pb_drones_api_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ListDronesRequest) GetStates() []DroneState {
+	if x != nil {
+		return x.States
+	}
+	return nil
+}
+
+func (x *ListDronesRequest) GetModels() []DroneModel {
+	if x != nil {
+		return x.Models
+	}
+	return nil
+}
+
+func (x *ListDronesRequest) GetBatteryMin() float64 {
+	if x != nil && x.BatteryMin != nil {
+		return *x.BatteryMin
+	}
+	return 0
+}
+
+func (x *ListDronesRequest) GetBatteryMax() float64 {
+	if x != nil && x.BatteryMax != nil {
+		return *x.BatteryMax
+	}
+	return 0
+}
+
+func (x *ListDronesRequest) GetAvailableWeight() float64 {
+	if x != nil && x.AvailableWeight != nil {
+		return *x.AvailableWeight
+	}
+	return 0
+}
+
+func (x *ListDronesRequest) GetRetired() bool {
+	if x != nil {
+		return x.Retired
+	}
+	return false
+}
+
+func (x *ListDronesRequest) GetSort() string {
+	if x != nil {
+		return x.Sort
+	}
+	return ""
+}
+
+func (x *ListDronesRequest) GetOrder() string {
+	if x != nil {
+		return x.Order
+	}
+	return ""
+}
+
+func (x *ListDronesRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *ListDronesRequest) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
+type ListDronesResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Items      []*Drone `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"`
+	Total      int32    `protobuf:"varint,2,opt,name=total,proto3" json:"total,omitempty"`                            // number of drones that match the filter
+	NextCursor string   `protobuf:"bytes,3,opt,name=next_cursor,json=nextCursor,proto3" json:"next_cursor,omitempty"` // empty in the last page
+}
+
+func (x *ListDronesResponse) Reset() {
+	*x = ListDronesResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_schema_pb_drones_api_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListDronesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListDronesResponse) ProtoMessage() {}
+
+func (x *ListDronesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_schema_pb_drones_api_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListDronesResponse.ProtoReflect.Descriptor instead.
+func (*ListDronesResponse) Descriptor() ([]byte, []int) {
+	return file_schema_pb_drones_api_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ListDronesResponse) GetItems() []*Drone {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+func (x *ListDronesResponse) GetTotal() int32 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *ListDronesResponse) GetNextCursor() string {
+	if x != nil {
+		return x.NextCursor
+	}
+	return ""
+}
+
+type GetDroneRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	SerialNumber string `protobuf:"bytes,1,opt,name=serial_number,json=serialNumber,proto3" json:"serial_number,omitempty"`
+}
+
+func (x *GetDroneRequest) Reset() {
+	*x = GetDroneRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_schema_pb_drones_api_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetDroneRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetDroneRequest) ProtoMessage() {}
+
+func (x *GetDroneRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_schema_pb_drones_api_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetDroneRequest.ProtoReflect.Descriptor instead.
+func (*GetDroneRequest) Descriptor() ([]byte, []int) {
+	return file_schema_pb_drones_api_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GetDroneRequest) GetSerialNumber() string {
+	if x != nil {
+		return x.SerialNumber
+	}
+	return ""
+}
+
+type RegisterDroneRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	SerialNumber    string     `protobuf:"bytes,1,opt,name=serial_number,json=serialNumber,proto3" json:"serial_number,omitempty"`
+	Model           DroneModel `protobuf:"varint,2,opt,name=model,proto3,enum=drones.v1.DroneModel" json:"model,omitempty"`
+	BatteryCapacity float64    `protobuf:"fixed64,3,opt,name=battery_capacity,json=batteryCapacity,proto3" json:"battery_capacity,omitempty"`
+	State           DroneState `protobuf:"varint,4,opt,name=state,proto3,enum=drones.v1.DroneState" json:"state,omitempty"`
+}
+
+func (x *RegisterDroneRequest) Reset() {
+	*x = RegisterDroneRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_schema_pb_drones_api_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RegisterDroneRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RegisterDroneRequest) ProtoMessage() {}
+
+func (x *RegisterDroneRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_schema_pb_drones_api_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RegisterDroneRequest.ProtoReflect.Descriptor instead.
+func (*RegisterDroneRequest) Descriptor() ([]byte, []int) {
+	return file_schema_pb_drones_api_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *RegisterDroneRequest) GetSerialNumber() string {
+	if x != nil {
+		return x.SerialNumber
+	}
+	return ""
+}
+
+func (x *RegisterDroneRequest) GetModel() DroneModel {
+	if x != nil {
+		return x.Model
+	}
+	return DroneModel_LIGHTWEIGHT
+}
+
+func (x *RegisterDroneRequest) GetBatteryCapacity() float64 {
+	if x != nil {
+		return x.BatteryCapacity
+	}
+	return 0
+}
+
+func (x *RegisterDroneRequest) GetState() DroneState {
+	if x != nil {
+		return x.State
+	}
+	return DroneState_IDLE
+}
+
+type ListMedicationsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *ListMedicationsRequest) Reset() {
+	*x = ListMedicationsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_schema_pb_drones_api_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListMedicationsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListMedicationsRequest) ProtoMessage() {}
+
+func (x *ListMedicationsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_schema_pb_drones_api_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListMedicationsRequest.ProtoReflect.Descriptor instead.
+func (*ListMedicationsRequest) Descriptor() ([]byte, []int) {
+	return file_schema_pb_drones_api_proto_rawDescGZIP(), []int{4}
+}
+
+type LoadMedicationsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	SerialNumber    string   `protobuf:"bytes,1,opt,name=serial_number,json=serialNumber,proto3" json:"serial_number,omitempty"`
+	MedicationCodes []string `protobuf:"bytes,2,rep,name=medication_codes,json=medicationCodes,proto3" json:"medication_codes,omitempty"`
+}
+
+func (x *LoadMedicationsRequest) Reset() {
+	*x = LoadMedicationsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_schema_pb_drones_api_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *LoadMedicationsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LoadMedicationsRequest) ProtoMessage() {}
+
+func (x *LoadMedicationsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_schema_pb_drones_api_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LoadMedicationsRequest.ProtoReflect.Descriptor instead.
+func (*LoadMedicationsRequest) Descriptor() ([]byte, []int) {
+	return file_schema_pb_drones_api_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *LoadMedicationsRequest) GetSerialNumber() string {
+	if x != nil {
+		return x.SerialNumber
+	}
+	return ""
+}
+
+func (x *LoadMedicationsRequest) GetMedicationCodes() []string {
+	if x != nil {
+		return x.MedicationCodes
+	}
+	return nil
+}
+
+type LoadMedicationsResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *LoadMedicationsResponse) Reset() {
+	*x = LoadMedicationsResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_schema_pb_drones_api_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *LoadMedicationsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LoadMedicationsResponse) ProtoMessage() {}
+
+func (x *LoadMedicationsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_schema_pb_drones_api_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LoadMedicationsResponse.ProtoReflect.Descriptor instead.
+func (*LoadMedicationsResponse) Descriptor() ([]byte, []int) {
+	return file_schema_pb_drones_api_proto_rawDescGZIP(), []int{6}
+}
+
+type GetLoadedMedicationsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	SerialNumber string `protobuf:"bytes,1,opt,name=serial_number,json=serialNumber,proto3" json:"serial_number,omitempty"`
+}
+
+func (x *GetLoadedMedicationsRequest) Reset() {
+	*x = GetLoadedMedicationsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_schema_pb_drones_api_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetLoadedMedicationsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetLoadedMedicationsRequest) ProtoMessage() {}
+
+func (x *GetLoadedMedicationsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_schema_pb_drones_api_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetLoadedMedicationsRequest.ProtoReflect.Descriptor instead.
+func (*GetLoadedMedicationsRequest) Descriptor() ([]byte, []int) {
+	return file_schema_pb_drones_api_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *GetLoadedMedicationsRequest) GetSerialNumber() string {
+	if x != nil {
+		return x.SerialNumber
+	}
+	return ""
+}
+
+type LoadedMedications struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Codes []string `protobuf:"bytes,1,rep,name=codes,proto3" json:"codes,omitempty"`
+}
+
+func (x *LoadedMedications) Reset() {
+	*x = LoadedMedications{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_schema_pb_drones_api_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *LoadedMedications) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LoadedMedications) ProtoMessage() {}
+
+func (x *LoadedMedications) ProtoReflect() protoreflect.Message {
+	mi := &file_schema_pb_drones_api_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LoadedMedications.ProtoReflect.Descriptor instead.
+func (*LoadedMedications) Descriptor() ([]byte, []int) {
+	return file_schema_pb_drones_api_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *LoadedMedications) GetCodes() []string {
+	if x != nil {
+		return x.Codes
+	}
+	return nil
+}
+
+type StreamBatteryLogsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Follow bool `protobuf:"varint,1,opt,name=follow,proto3" json:"follow,omitempty"` // keep the stream open and send the new event logs
+}
+
+func (x *StreamBatteryLogsRequest) Reset() {
+	*x = StreamBatteryLogsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_schema_pb_drones_api_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *StreamBatteryLogsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamBatteryLogsRequest) ProtoMessage() {}
+
+func (x *StreamBatteryLogsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_schema_pb_drones_api_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamBatteryLogsRequest.ProtoReflect.Descriptor instead.
+func (*StreamBatteryLogsRequest) Descriptor() ([]byte, []int) {
+	return file_schema_pb_drones_api_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *StreamBatteryLogsRequest) GetFollow() bool {
+	if x != nil {
+		return x.Follow
+	}
+	return false
+}
+
+var File_schema_pb_drones_api_proto protoreflect.FileDescriptor
+
+var file_schema_pb_drones_api_proto_rawDesc = []byte{
+	0x0a, 0x1a, 0x73, 0x63, 0x68, 0x65, 0x6d, 0x61, 0x2f, 0x70, 0x62, 0x2f, 0x64, 0x72, 0x6f, 0x6e,
+	0x65, 0x73, 0x5f, 0x61, 0x70, 0x69, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x09, 0x64, 0x72,
+	0x6f, 0x6e, 0x65, 0x73, 0x2e, 0x76, 0x31, 0x1a, 0x16, 0x73, 0x63, 0x68, 0x65, 0x6d, 0x61, 0x2f,
+	0x70, 0x62, 0x2f, 0x64, 0x72, 0x6f, 0x6e, 0x65, 0x73, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22,
+	0x94, 0x03, 0x0a, 0x11, 0x4c, 0x69, 0x73, 0x74, 0x44, 0x72, 0x6f, 0x6e, 0x65, 0x73, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x2d, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x65, 0x73, 0x18,
+	0x01, 0x20, 0x03, 0x28, 0x0e, 0x32, 0x15, 0x2e, 0x64, 0x72, 0x6f, 0x6e, 0x65, 0x73, 0x2e, 0x76,
+	0x31, 0x2e, 0x44, 0x72, 0x6f, 0x6e, 0x65, 0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x06, 0x73, 0x74,
+	0x61, 0x74, 0x65, 0x73, 0x12, 0x2d, 0x0a, 0x06, 0x6d, 0x6f, 0x64, 0x65, 0x6c, 0x73, 0x18, 0x02,
+	0x20, 0x03, 0x28, 0x0e, 0x32, 0x15, 0x2e, 0x64, 0x72, 0x6f, 0x6e, 0x65, 0x73, 0x2e, 0x76, 0x31,
+	0x2e, 0x44, 0x72, 0x6f, 0x6e, 0x65, 0x4d, 0x6f, 0x64, 0x65, 0x6c, 0x52, 0x06, 0x6d, 0x6f, 0x64,
+	0x65, 0x6c, 0x73, 0x12, 0x24, 0x0a, 0x0b, 0x62, 0x61, 0x74, 0x74, 0x65, 0x72, 0x79, 0x5f, 0x6d,
+	0x69, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x01, 0x48, 0x00, 0x52, 0x0a, 0x62, 0x61, 0x74, 0x74,
+	0x65, 0x72, 0x79, 0x4d, 0x69, 0x6e, 0x88, 0x01, 0x01, 0x12, 0x24, 0x0a, 0x0b, 0x62, 0x61, 0x74,
+	0x74, 0x65, 0x72, 0x79, 0x5f, 0x6d, 0x61, 0x78, 0x18, 0x04, 0x20, 0x01, 0x28, 0x01, 0x48, 0x01,
+	0x52, 0x0a, 0x62, 0x61, 0x74, 0x74, 0x65, 0x72, 0x79, 0x4d, 0x61, 0x78, 0x88, 0x01, 0x01, 0x12,
+	0x2e, 0x0a, 0x10, 0x61, 0x76, 0x61, 0x69, 0x6c, 0x61, 0x62, 0x6c, 0x65, 0x5f, 0x77, 0x65, 0x69,
+	0x67, 0x68, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x01, 0x48, 0x02, 0x52, 0x0f, 0x61, 0x76, 0x61,
+	0x69, 0x6c, 0x61, 0x62, 0x6c, 0x65, 0x57, 0x65, 0x69, 0x67, 0x68, 0x74, 0x88, 0x01, 0x01, 0x12,
+	0x18, 0x0a, 0x07, 0x72, 0x65, 0x74, 0x69, 0x72, 0x65, 0x64, 0x18, 0x06, 0x20, 0x01, 0x28, 0x08,
+	0x52, 0x07, 0x72, 0x65, 0x74, 0x69, 0x72, 0x65, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x73, 0x6f, 0x72,
+	0x74, 0x18, 0x07, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x73, 0x6f, 0x72, 0x74, 0x12, 0x14, 0x0a,
+	0x05, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x18, 0x08, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x6f, 0x72,
+	0x64, 0x65, 0x72, 0x12, 0x14, 0x0a, 0x05, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x18, 0x09, 0x20, 0x01,
+	0x28, 0x05, 0x52, 0x05, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x63, 0x75, 0x72,
+	0x73, 0x6f, 0x72, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x63, 0x75, 0x72, 0x73, 0x6f,
+	0x72, 0x42, 0x0e, 0x0a, 0x0c, 0x5f, 0x62, 0x61, 0x74, 0x74, 0x65, 0x72, 0x79, 0x5f, 0x6d, 0x69,
+	0x6e, 0x42, 0x0e, 0x0a, 0x0c, 0x5f, 0x62, 0x61, 0x74, 0x74, 0x65, 0x72, 0x79, 0x5f, 0x6d, 0x61,
+	0x78, 0x42, 0x13, 0x0a, 0x11, 0x5f, 0x61, 0x76, 0x61, 0x69, 0x6c, 0x61, 0x62, 0x6c, 0x65, 0x5f,
+	0x77, 0x65, 0x69, 0x67, 0x68, 0x74, 0x22, 0x73, 0x0a, 0x12, 0x4c, 0x69, 0x73, 0x74, 0x44, 0x72,
+	0x6f, 0x6e, 0x65, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x26, 0x0a, 0x05,
+	0x69, 0x74, 0x65, 0x6d, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x64, 0x72,
+	0x6f, 0x6e, 0x65, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x72, 0x6f, 0x6e, 0x65, 0x52, 0x05, 0x69,
+	0x74, 0x65, 0x6d, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x74, 0x6f, 0x74, 0x61, 0x6c, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x05, 0x52, 0x05, 0x74, 0x6f, 0x74, 0x61, 0x6c, 0x12, 0x1f, 0x0a, 0x0b, 0x6e, 0x65,
+	0x78, 0x74, 0x5f, 0x63, 0x75, 0x72, 0x73, 0x6f, 0x72, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x0a, 0x6e, 0x65, 0x78, 0x74, 0x43, 0x75, 0x72, 0x73, 0x6f, 0x72, 0x22, 0x36, 0x0a, 0x0f, 0x47,
+	0x65, 0x74, 0x44, 0x72, 0x6f, 0x6e, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x23,
+	0x0a, 0x0d, 0x73, 0x65, 0x72, 0x69, 0x61, 0x6c, 0x5f, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x73, 0x65, 0x72, 0x69, 0x61, 0x6c, 0x4e, 0x75, 0x6d,
+	0x62, 0x65, 0x72, 0x22, 0xc0, 0x01, 0x0a, 0x14, 0x52, 0x65, 0x67, 0x69, 0x73, 0x74, 0x65, 0x72,
+	0x44, 0x72, 0x6f, 0x6e, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x23, 0x0a, 0x0d,
+	0x73, 0x65, 0x72, 0x69, 0x61, 0x6c, 0x5f, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x0c, 0x73, 0x65, 0x72, 0x69, 0x61, 0x6c, 0x4e, 0x75, 0x6d, 0x62, 0x65,
+	0x72, 0x12, 0x2b, 0x0a, 0x05, 0x6d, 0x6f, 0x64, 0x65, 0x6c, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e,
+	0x32, 0x15, 0x2e, 0x64, 0x72, 0x6f, 0x6e, 0x65, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x72, 0x6f,
+	0x6e, 0x65, 0x4d, 0x6f, 0x64, 0x65, 0x6c, 0x52, 0x05, 0x6d, 0x6f, 0x64, 0x65, 0x6c, 0x12, 0x29,
+	0x0a, 0x10, 0x62, 0x61, 0x74, 0x74, 0x65, 0x72, 0x79, 0x5f, 0x63, 0x61, 0x70, 0x61, 0x63, 0x69,
+	0x74, 0x79, 0x18, 0x03, 0x20, 0x01, 0x28, 0x01, 0x52, 0x0f, 0x62, 0x61, 0x74, 0x74, 0x65, 0x72,
+	0x79, 0x43, 0x61, 0x70, 0x61, 0x63, 0x69, 0x74, 0x79, 0x12, 0x2b, 0x0a, 0x05, 0x73, 0x74, 0x61,
+	0x74, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x15, 0x2e, 0x64, 0x72, 0x6f, 0x6e, 0x65,
+	0x73, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x72, 0x6f, 0x6e, 0x65, 0x53, 0x74, 0x61, 0x74, 0x65, 0x52,
+	0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x22, 0x18, 0x0a, 0x16, 0x4c, 0x69, 0x73, 0x74, 0x4d, 0x65,
+	0x64, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x22, 0x68, 0x0a, 0x16, 0x4c, 0x6f, 0x61, 0x64, 0x4d, 0x65, 0x64, 0x69, 0x63, 0x61, 0x74, 0x69,
+	0x6f, 0x6e, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x23, 0x0a, 0x0d, 0x73, 0x65,
+	0x72, 0x69, 0x61, 0x6c, 0x5f, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x0c, 0x73, 0x65, 0x72, 0x69, 0x61, 0x6c, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x12,
+	0x29, 0x0a, 0x10, 0x6d, 0x65, 0x64, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x63, 0x6f,
+	0x64, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0f, 0x6d, 0x65, 0x64, 0x69, 0x63,
+	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x43, 0x6f, 0x64, 0x65, 0x73, 0x22, 0x19, 0x0a, 0x17, 0x4c, 0x6f,
+	0x61, 0x64, 0x4d, 0x65, 0x64, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x42, 0x0a, 0x1b, 0x47, 0x65, 0x74, 0x4c, 0x6f, 0x61, 0x64,
+	0x65, 0x64, 0x4d, 0x65, 0x64, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x12, 0x23, 0x0a, 0x0d, 0x73, 0x65, 0x72, 0x69, 0x61, 0x6c, 0x5f, 0x6e,
+	0x75, 0x6d, 0x62, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x73, 0x65, 0x72,
+	0x69, 0x61, 0x6c, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x22, 0x29, 0x0a, 0x11, 0x4c, 0x6f, 0x61,
+	0x64, 0x65, 0x64, 0x4d, 0x65, 0x64, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x14,
+	0x0a, 0x05, 0x63, 0x6f, 0x64, 0x65, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x05, 0x63,
+	0x6f, 0x64, 0x65, 0x73, 0x22, 0x32, 0x0a, 0x18, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x42, 0x61,
+	0x74, 0x74, 0x65, 0x72, 0x79, 0x4c, 0x6f, 0x67, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x12, 0x16, 0x0a, 0x06, 0x66, 0x6f, 0x6c, 0x6c, 0x6f, 0x77, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08,
+	0x52, 0x06, 0x66, 0x6f, 0x6c, 0x6c, 0x6f, 0x77, 0x32, 0xe1, 0x03, 0x0a, 0x0d, 0x44, 0x72, 0x6f,
+	0x6e, 0x65, 0x73, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x49, 0x0a, 0x0a, 0x4c, 0x69,
+	0x73, 0x74, 0x44, 0x72, 0x6f, 0x6e, 0x65, 0x73, 0x12, 0x1c, 0x2e, 0x64, 0x72, 0x6f, 0x6e, 0x65,
+	0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x44, 0x72, 0x6f, 0x6e, 0x65, 0x73, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1d, 0x2e, 0x64, 0x72, 0x6f, 0x6e, 0x65, 0x73, 0x2e,
+	0x76, 0x31, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x44, 0x72, 0x6f, 0x6e, 0x65, 0x73, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x38, 0x0a, 0x08, 0x47, 0x65, 0x74, 0x44, 0x72, 0x6f, 0x6e,
+	0x65, 0x12, 0x1a, 0x2e, 0x64, 0x72, 0x6f, 0x6e, 0x65, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x65,
+	0x74, 0x44, 0x72, 0x6f, 0x6e, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x10, 0x2e,
+	0x64, 0x72, 0x6f, 0x6e, 0x65, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x72, 0x6f, 0x6e, 0x65, 0x12,
+	0x42, 0x0a, 0x0d, 0x52, 0x65, 0x67, 0x69, 0x73, 0x74, 0x65, 0x72, 0x44, 0x72, 0x6f, 0x6e, 0x65,
+	0x12, 0x1f, 0x2e, 0x64, 0x72, 0x6f, 0x6e, 0x65, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x52, 0x65, 0x67,
+	0x69, 0x73, 0x74, 0x65, 0x72, 0x44, 0x72, 0x6f, 0x6e, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x1a, 0x10, 0x2e, 0x64, 0x72, 0x6f, 0x6e, 0x65, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x72,
+	0x6f, 0x6e, 0x65, 0x12, 0x4f, 0x0a, 0x0f, 0x4c, 0x69, 0x73, 0x74, 0x4d, 0x65, 0x64, 0x69, 0x63,
+	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x21, 0x2e, 0x64, 0x72, 0x6f, 0x6e, 0x65, 0x73, 0x2e,
+	0x76, 0x31, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x4d, 0x65, 0x64, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f,
+	0x6e, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x19, 0x2e, 0x64, 0x72, 0x6f, 0x6e,
+	0x65, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x65, 0x64, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e,
+	0x4c, 0x69, 0x73, 0x74, 0x12, 0x58, 0x0a, 0x0f, 0x4c, 0x6f, 0x61, 0x64, 0x4d, 0x65, 0x64, 0x69,
+	0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x21, 0x2e, 0x64, 0x72, 0x6f, 0x6e, 0x65, 0x73,
+	0x2e, 0x76, 0x31, 0x2e, 0x4c, 0x6f, 0x61, 0x64, 0x4d, 0x65, 0x64, 0x69, 0x63, 0x61, 0x74, 0x69,
+	0x6f, 0x6e, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x22, 0x2e, 0x64, 0x72, 0x6f,
+	0x6e, 0x65, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4c, 0x6f, 0x61, 0x64, 0x4d, 0x65, 0x64, 0x69, 0x63,
+	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x5c,
+	0x0a, 0x14, 0x47, 0x65, 0x74, 0x4c, 0x6f, 0x61, 0x64, 0x65, 0x64, 0x4d, 0x65, 0x64, 0x69, 0x63,
+	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x26, 0x2e, 0x64, 0x72, 0x6f, 0x6e, 0x65, 0x73, 0x2e,
+	0x76, 0x31, 0x2e, 0x47, 0x65, 0x74, 0x4c, 0x6f, 0x61, 0x64, 0x65, 0x64, 0x4d, 0x65, 0x64, 0x69,
+	0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1c,
+	0x2e, 0x64, 0x72, 0x6f, 0x6e, 0x65, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4c, 0x6f, 0x61, 0x64, 0x65,
+	0x64, 0x4d, 0x65, 0x64, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x32, 0x62, 0x0a, 0x0f,
+	0x45, 0x76, 0x65, 0x6e, 0x74, 0x4c, 0x6f, 0x67, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12,
+	0x4f, 0x0a, 0x11, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x42, 0x61, 0x74, 0x74, 0x65, 0x72, 0x79,
+	0x4c, 0x6f, 0x67, 0x73, 0x12, 0x23, 0x2e, 0x64, 0x72, 0x6f, 0x6e, 0x65, 0x73, 0x2e, 0x76, 0x31,
+	0x2e, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x42, 0x61, 0x74, 0x74, 0x65, 0x72, 0x79, 0x4c, 0x6f,
+	0x67, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x13, 0x2e, 0x64, 0x72, 0x6f, 0x6e,
+	0x65, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4c, 0x6f, 0x67, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x30, 0x01,
+	0x42, 0x34, 0x5a, 0x32, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6b,
+	0x6d, 0x69, 0x6c, 0x6f, 0x64, 0x65, 0x6e, 0x69, 0x73, 0x67, 0x6c, 0x65, 0x7a, 0x2f, 0x64, 0x72,
+	0x6f, 0x6e, 0x65, 0x73, 0x2e, 0x72, 0x65, 0x73, 0x74, 0x61, 0x70, 0x69, 0x2f, 0x73, 0x63, 0x68,
+	0x65, 0x6d, 0x61, 0x2f, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_schema_pb_drones_api_proto_rawDescOnce sync.Once
+	file_schema_pb_drones_api_proto_rawDescData = file_schema_pb_drones_api_proto_rawDesc
+)
+
+func file_schema_pb_drones_api_proto_rawDescGZIP() []byte {
+	file_schema_pb_drones_api_proto_rawDescOnce.Do(func() {
+		file_schema_pb_drones_api_proto_rawDescData = protoimpl.X.CompressGZIP(file_schema_pb_drones_api_proto_rawDescData)
+	})
+	return file_schema_pb_drones_api_proto_rawDescData
+}
+
+var file_schema_pb_drones_api_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_schema_pb_drones_api_proto_goTypes = []interface{}{
+	(*ListDronesRequest)(nil),           // 0: drones.v1.ListDronesRequest
+	(*ListDronesResponse)(nil),          // 1: drones.v1.ListDronesResponse
+	(*GetDroneRequest)(nil),             // 2: drones.v1.GetDroneRequest
+	(*RegisterDroneRequest)(nil),        // 3: drones.v1.RegisterDroneRequest
+	(*ListMedicationsRequest)(nil),      // 4: drones.v1.ListMedicationsRequest
+	(*LoadMedicationsRequest)(nil),      // 5: drones.v1.LoadMedicationsRequest
+	(*LoadMedicationsResponse)(nil),     // 6: drones.v1.LoadMedicationsResponse
+	(*GetLoadedMedicationsRequest)(nil), // 7: drones.v1.GetLoadedMedicationsRequest
+	(*LoadedMedications)(nil),           // 8: drones.v1.LoadedMedications
+	(*StreamBatteryLogsRequest)(nil),    // 9: drones.v1.StreamBatteryLogsRequest
+	(DroneState)(0),                     // 10: drones.v1.DroneState
+	(DroneModel)(0),                     // 11: drones.v1.DroneModel
+	(*Drone)(nil),                       // 12: drones.v1.Drone
+	(*MedicationList)(nil),              // 13: drones.v1.MedicationList
+	(*LogEvent)(nil),                    // 14: drones.v1.LogEvent
+}
+var file_schema_pb_drones_api_proto_depIdxs = []int32{
+	10, // 0: drones.v1.ListDronesRequest.states:type_name -> drones.v1.DroneState
+	11, // 1: drones.v1.ListDronesRequest.models:type_name -> drones.v1.DroneModel
+	12, // 2: drones.v1.ListDronesResponse.items:type_name -> drones.v1.Drone
+	11, // 3: drones.v1.RegisterDroneRequest.model:type_name -> drones.v1.DroneModel
+	10, // 4: drones.v1.RegisterDroneRequest.state:type_name -> drones.v1.DroneState
+	0,  // 5: drones.v1.DronesService.ListDrones:input_type -> drones.v1.ListDronesRequest
+	2,  // 6: drones.v1.DronesService.GetDrone:input_type -> drones.v1.GetDroneRequest
+	3,  // 7: drones.v1.DronesService.RegisterDrone:input_type -> drones.v1.RegisterDroneRequest
+	4,  // 8: drones.v1.DronesService.ListMedications:input_type -> drones.v1.ListMedicationsRequest
+	5,  // 9: drones.v1.DronesService.LoadMedications:input_type -> drones.v1.LoadMedicationsRequest
+	7,  // 10: drones.v1.DronesService.GetLoadedMedications:input_type -> drones.v1.GetLoadedMedicationsRequest
+	9,  // 11: drones.v1.EventLogService.StreamBatteryLogs:input_type -> drones.v1.StreamBatteryLogsRequest
+	1,  // 12: drones.v1.DronesService.ListDrones:output_type -> drones.v1.ListDronesResponse
+	12, // 13: drones.v1.DronesService.GetDrone:output_type -> drones.v1.Drone
+	12, // 14: drones.v1.DronesService.RegisterDrone:output_type -> drones.v1.Drone
+	13, // 15: drones.v1.DronesService.ListMedications:output_type -> drones.v1.MedicationList
+	6,  // 16: drones.v1.DronesService.LoadMedications:output_type -> drones.v1.LoadMedicationsResponse
+	8,  // 17: drones.v1.DronesService.GetLoadedMedications:output_type -> drones.v1.LoadedMedications
+	14, // 18: drones.v1.EventLogService.StreamBatteryLogs:output_type -> drones.v1.LogEvent
+	12, // [12:19] is the sub-list for method output_type
+	5,  // [5:12] is the sub-list for method input_type
+	5,  // [5:5] is the sub-list for extension type_name
+	5,  // [5:5] is the sub-list for extension extendee
+	0,  // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_schema_pb_drones_api_proto_init() }
+func file_schema_pb_drones_api_proto_init() {
+	if File_schema_pb_drones_api_proto != nil {
+		return
+	}
+	file_schema_pb_drones_proto_init()
+	if !protoimpl.UnsafeEnabled {
+		file_schema_pb_drones_api_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ListDronesRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_schema_pb_drones_api_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ListDronesResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_schema_pb_drones_api_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetDroneRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_schema_pb_drones_api_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RegisterDroneRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_schema_pb_drones_api_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ListMedicationsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_schema_pb_drones_api_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*LoadMedicationsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_schema_pb_drones_api_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*LoadMedicationsResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_schema_pb_drones_api_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetLoadedMedicationsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_schema_pb_drones_api_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*LoadedMedications); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_schema_pb_drones_api_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*StreamBatteryLogsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file_schema_pb_drones_api_proto_msgTypes[0].OneofWrappers = []interface{}{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_schema_pb_drones_api_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   10,
+			NumExtensions: 0,
+			NumServices:   2,
+		},
+		GoTypes:           file_schema_pb_drones_api_proto_goTypes,
+		DependencyIndexes: file_schema_pb_drones_api_proto_depIdxs,
+		MessageInfos:      file_schema_pb_drones_api_proto_msgTypes,
+	}.Build()
+	File_schema_pb_drones_api_proto = out.File
+	file_schema_pb_drones_api_proto_rawDesc = nil
+	file_schema_pb_drones_api_proto_goTypes = nil
+	file_schema_pb_drones_api_proto_depIdxs = nil
+}
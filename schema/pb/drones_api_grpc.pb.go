@@ -0,0 +1,414 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             v3.19.4
+// source: schema/pb/drones_api.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// DronesServiceClient is the client API for DronesService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type DronesServiceClient interface {
+	// ListDrones a page of the drones that match the filter, like GET /drones
+	ListDrones(ctx context.Context, in *ListDronesRequest, opts ...grpc.CallOption) (*ListDronesResponse, error)
+	// GetDrone a drone by its serial number, like GET /drones/{serialNumber}
+	GetDrone(ctx context.Context, in *GetDroneRequest, opts ...grpc.CallOption) (*Drone, error)
+	// RegisterDrone registers a new drone, the weight limit is calculated from its model, like POST /drones
+	RegisterDrone(ctx context.Context, in *RegisterDroneRequest, opts ...grpc.CallOption) (*Drone, error)
+	// ListMedications all the medications, like GET /medications
+	ListMedications(ctx context.Context, in *ListMedicationsRequest, opts ...grpc.CallOption) (*MedicationList, error)
+	// LoadMedications load a drone with medication items, like POST /medications/items/{serialNumber}
+	LoadMedications(ctx context.Context, in *LoadMedicationsRequest, opts ...grpc.CallOption) (*LoadMedicationsResponse, error)
+	// GetLoadedMedications codes of the medications loaded in a drone, like GET /medications/items/{serialNumber}
+	GetLoadedMedications(ctx context.Context, in *GetLoadedMedicationsRequest, opts ...grpc.CallOption) (*LoadedMedications, error)
+}
+
+type dronesServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDronesServiceClient(cc grpc.ClientConnInterface) DronesServiceClient {
+	return &dronesServiceClient{cc}
+}
+
+func (c *dronesServiceClient) ListDrones(ctx context.Context, in *ListDronesRequest, opts ...grpc.CallOption) (*ListDronesResponse, error) {
+	out := new(ListDronesResponse)
+	err := c.cc.Invoke(ctx, "/drones.v1.DronesService/ListDrones", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dronesServiceClient) GetDrone(ctx context.Context, in *GetDroneRequest, opts ...grpc.CallOption) (*Drone, error) {
+	out := new(Drone)
+	err := c.cc.Invoke(ctx, "/drones.v1.DronesService/GetDrone", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dronesServiceClient) RegisterDrone(ctx context.Context, in *RegisterDroneRequest, opts ...grpc.CallOption) (*Drone, error) {
+	out := new(Drone)
+	err := c.cc.Invoke(ctx, "/drones.v1.DronesService/RegisterDrone", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dronesServiceClient) ListMedications(ctx context.Context, in *ListMedicationsRequest, opts ...grpc.CallOption) (*MedicationList, error) {
+	out := new(MedicationList)
+	err := c.cc.Invoke(ctx, "/drones.v1.DronesService/ListMedications", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dronesServiceClient) LoadMedications(ctx context.Context, in *LoadMedicationsRequest, opts ...grpc.CallOption) (*LoadMedicationsResponse, error) {
+	out := new(LoadMedicationsResponse)
+	err := c.cc.Invoke(ctx, "/drones.v1.DronesService/LoadMedications", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dronesServiceClient) GetLoadedMedications(ctx context.Context, in *GetLoadedMedicationsRequest, opts ...grpc.CallOption) (*LoadedMedications, error) {
+	out := new(LoadedMedications)
+	err := c.cc.Invoke(ctx, "/drones.v1.DronesService/GetLoadedMedications", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DronesServiceServer is the server API for DronesService service.
+// All implementations must embed UnimplementedDronesServiceServer
+// for forward compatibility
+type DronesServiceServer interface {
+	// ListDrones a page of the drones that match the filter, like GET /drones
+	ListDrones(context.Context, *ListDronesRequest) (*ListDronesResponse, error)
+	// GetDrone a drone by its serial number, like GET /drones/{serialNumber}
+	GetDrone(context.Context, *GetDroneRequest) (*Drone, error)
+	// RegisterDrone registers a new drone, the weight limit is calculated from its model, like POST /drones
+	RegisterDrone(context.Context, *RegisterDroneRequest) (*Drone, error)
+	// ListMedications all the medications, like GET /medications
+	ListMedications(context.Context, *ListMedicationsRequest) (*MedicationList, error)
+	// LoadMedications load a drone with medication items, like POST /medications/items/{serialNumber}
+	LoadMedications(context.Context, *LoadMedicationsRequest) (*LoadMedicationsResponse, error)
+	// GetLoadedMedications codes of the medications loaded in a drone, like GET /medications/items/{serialNumber}
+	GetLoadedMedications(context.Context, *GetLoadedMedicationsRequest) (*LoadedMedications, error)
+	mustEmbedUnimplementedDronesServiceServer()
+}
+
+// UnimplementedDronesServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedDronesServiceServer struct {
+}
+
+func (UnimplementedDronesServiceServer) ListDrones(context.Context, *ListDronesRequest) (*ListDronesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDrones not implemented")
+}
+func (UnimplementedDronesServiceServer) GetDrone(context.Context, *GetDroneRequest) (*Drone, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDrone not implemented")
+}
+func (UnimplementedDronesServiceServer) RegisterDrone(context.Context, *RegisterDroneRequest) (*Drone, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RegisterDrone not implemented")
+}
+func (UnimplementedDronesServiceServer) ListMedications(context.Context, *ListMedicationsRequest) (*MedicationList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListMedications not implemented")
+}
+func (UnimplementedDronesServiceServer) LoadMedications(context.Context, *LoadMedicationsRequest) (*LoadMedicationsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LoadMedications not implemented")
+}
+func (UnimplementedDronesServiceServer) GetLoadedMedications(context.Context, *GetLoadedMedicationsRequest) (*LoadedMedications, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLoadedMedications not implemented")
+}
+func (UnimplementedDronesServiceServer) mustEmbedUnimplementedDronesServiceServer() {}
+
+// UnsafeDronesServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to DronesServiceServer will
+// result in compilation errors.
+type UnsafeDronesServiceServer interface {
+	mustEmbedUnimplementedDronesServiceServer()
+}
+
+func RegisterDronesServiceServer(s grpc.ServiceRegistrar, srv DronesServiceServer) {
+	s.RegisterService(&DronesService_ServiceDesc, srv)
+}
+
+func _DronesService_ListDrones_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListDronesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DronesServiceServer).ListDrones(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/drones.v1.DronesService/ListDrones",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DronesServiceServer).ListDrones(ctx, req.(*ListDronesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DronesService_GetDrone_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetDroneRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DronesServiceServer).GetDrone(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/drones.v1.DronesService/GetDrone",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DronesServiceServer).GetDrone(ctx, req.(*GetDroneRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DronesService_RegisterDrone_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterDroneRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DronesServiceServer).RegisterDrone(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/drones.v1.DronesService/RegisterDrone",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DronesServiceServer).RegisterDrone(ctx, req.(*RegisterDroneRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DronesService_ListMedications_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListMedicationsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DronesServiceServer).ListMedications(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/drones.v1.DronesService/ListMedications",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DronesServiceServer).ListMedications(ctx, req.(*ListMedicationsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DronesService_LoadMedications_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LoadMedicationsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DronesServiceServer).LoadMedications(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/drones.v1.DronesService/LoadMedications",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DronesServiceServer).LoadMedications(ctx, req.(*LoadMedicationsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DronesService_GetLoadedMedications_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLoadedMedicationsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DronesServiceServer).GetLoadedMedications(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/drones.v1.DronesService/GetLoadedMedications",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DronesServiceServer).GetLoadedMedications(ctx, req.(*GetLoadedMedicationsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// DronesService_ServiceDesc is the grpc.ServiceDesc for DronesService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var DronesService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "drones.v1.DronesService",
+	HandlerType: (*DronesServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListDrones",
+			Handler:    _DronesService_ListDrones_Handler,
+		},
+		{
+			MethodName: "GetDrone",
+			Handler:    _DronesService_GetDrone_Handler,
+		},
+		{
+			MethodName: "RegisterDrone",
+			Handler:    _DronesService_RegisterDrone_Handler,
+		},
+		{
+			MethodName: "ListMedications",
+			Handler:    _DronesService_ListMedications_Handler,
+		},
+		{
+			MethodName: "LoadMedications",
+			Handler:    _DronesService_LoadMedications_Handler,
+		},
+		{
+			MethodName: "GetLoadedMedications",
+			Handler:    _DronesService_GetLoadedMedications_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "schema/pb/drones_api.proto",
+}
+
+// EventLogServiceClient is the client API for EventLogService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type EventLogServiceClient interface {
+	// StreamBatteryLogs the last event logs, the oldest first, and with follow the new ones as they are
+	// written until the client cancels the call
+	StreamBatteryLogs(ctx context.Context, in *StreamBatteryLogsRequest, opts ...grpc.CallOption) (EventLogService_StreamBatteryLogsClient, error)
+}
+
+type eventLogServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewEventLogServiceClient(cc grpc.ClientConnInterface) EventLogServiceClient {
+	return &eventLogServiceClient{cc}
+}
+
+func (c *eventLogServiceClient) StreamBatteryLogs(ctx context.Context, in *StreamBatteryLogsRequest, opts ...grpc.CallOption) (EventLogService_StreamBatteryLogsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &EventLogService_ServiceDesc.Streams[0], "/drones.v1.EventLogService/StreamBatteryLogs", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &eventLogServiceStreamBatteryLogsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type EventLogService_StreamBatteryLogsClient interface {
+	Recv() (*LogEvent, error)
+	grpc.ClientStream
+}
+
+type eventLogServiceStreamBatteryLogsClient struct {
+	grpc.ClientStream
+}
+
+func (x *eventLogServiceStreamBatteryLogsClient) Recv() (*LogEvent, error) {
+	m := new(LogEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// EventLogServiceServer is the server API for EventLogService service.
+// All implementations must embed UnimplementedEventLogServiceServer
+// for forward compatibility
+type EventLogServiceServer interface {
+	// StreamBatteryLogs the last event logs, the oldest first, and with follow the new ones as they are
+	// written until the client cancels the call
+	StreamBatteryLogs(*StreamBatteryLogsRequest, EventLogService_StreamBatteryLogsServer) error
+	mustEmbedUnimplementedEventLogServiceServer()
+}
+
+// UnimplementedEventLogServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedEventLogServiceServer struct {
+}
+
+func (UnimplementedEventLogServiceServer) StreamBatteryLogs(*StreamBatteryLogsRequest, EventLogService_StreamBatteryLogsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamBatteryLogs not implemented")
+}
+func (UnimplementedEventLogServiceServer) mustEmbedUnimplementedEventLogServiceServer() {}
+
+// UnsafeEventLogServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to EventLogServiceServer will
+// result in compilation errors.
+type UnsafeEventLogServiceServer interface {
+	mustEmbedUnimplementedEventLogServiceServer()
+}
+
+func RegisterEventLogServiceServer(s grpc.ServiceRegistrar, srv EventLogServiceServer) {
+	s.RegisterService(&EventLogService_ServiceDesc, srv)
+}
+
+func _EventLogService_StreamBatteryLogs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamBatteryLogsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(EventLogServiceServer).StreamBatteryLogs(m, &eventLogServiceStreamBatteryLogsServer{stream})
+}
+
+type EventLogService_StreamBatteryLogsServer interface {
+	Send(*LogEvent) error
+	grpc.ServerStream
+}
+
+type eventLogServiceStreamBatteryLogsServer struct {
+	grpc.ServerStream
+}
+
+func (x *eventLogServiceStreamBatteryLogsServer) Send(m *LogEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// EventLogService_ServiceDesc is the grpc.ServiceDesc for EventLogService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var EventLogService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "drones.v1.EventLogService",
+	HandlerType: (*EventLogServiceServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamBatteryLogs",
+			Handler:       _EventLogService_StreamBatteryLogs_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "schema/pb/drones_api.proto",
+}
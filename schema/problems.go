@@ -0,0 +1,97 @@
+package schema
+
+import "net/http"
+
+// region ======== PROBLEM CATALOGUE =====================================================
+
+// ProblemTypeBaseURI base of the "type" URI of the problem responses (RFC 7807), the error key is the
+// fragment, so every type links to its entry in docs/problems.md
+const ProblemTypeBaseURI = "https://github.com/kmilodenisglez/drones.restapi/blob/main/docs/problems.md#"
+
+// ProblemType entry of the problem catalogue, every error key is always answered with the same status.
+// The title is translated, it comes from the message catalogues (title.<key>)
+type ProblemType struct {
+	Status int
+	// Internal the details come from the storage, the file system or a library and may reveal the
+	// internals of the server, they are only sent in debug mode
+	Internal bool
+}
+
+// problemCatalogue the problem type of every error key
+var problemCatalogue = map[string]ProblemType{
+	ErrAuth:                              {http.StatusUnauthorized, false},
+	ErrGeneric:                           {http.StatusInternalServerError, true},
+	ErrInvalidEnvVar:                     {http.StatusInternalServerError, true},
+	ErrRepositoryOps:                     {http.StatusInternalServerError, true},
+	ErrNotFound:                          {http.StatusNotFound, false},
+	ErrHttpResError:                      {http.StatusBadGateway, true},
+	ErrDuplicateKey:                      {http.StatusConflict, false},
+	ErrInvalidType:                       {http.StatusInternalServerError, true},
+	ErrNetwork:                           {http.StatusGatewayTimeout, true},
+	ErrBadGateway:                        {http.StatusBadGateway, true},
+	ErrJsonParse:                         {http.StatusBadRequest, false},
+	ErrProcParam:                         {http.StatusBadRequest, false},
+	ErrJwtGen:                            {http.StatusInternalServerError, true},
+	ErrWrongAuthProvider:                 {http.StatusBadRequest, false},
+	ErrUnauthorized:                      {http.StatusUnauthorized, false},
+	ErrForbidden:                         {http.StatusForbidden, false},
+	ErrFileProc:                          {http.StatusBadRequest, false},
+	ErrFile:                              {http.StatusInternalServerError, true},
+	ErrBuntdbItemNotFound:                {http.StatusNotFound, false},
+	ErrBuntdb:                            {http.StatusInternalServerError, true},
+	ErrBuntdbPopulated:                   {http.StatusConflict, false},
+	ErrBuntdbNotPopulated:                {http.StatusServiceUnavailable, false},
+	ErrSeedDisabled:                      {http.StatusForbidden, false},
+	ErrDroneMaximumLoadWeightExceededKey: {http.StatusPreconditionFailed, false},
+	ErrDroneVeryLowBatteryKey:            {http.StatusPreconditionFailed, false},
+	ErrDroneBusyKey:                      {http.StatusPreconditionFailed, false},
+	ErrDroneVersionMismatchKey:           {http.StatusPreconditionFailed, false},
+	ErrDroneRetiredKey:                   {http.StatusConflict, false},
+	ErrDroneNotRetirableKey:              {http.StatusConflict, false},
+	ErrDroneInvalidTransitionKey:         {http.StatusConflict, false},
+	ErrDroneNotLoadedKey:                 {http.StatusConflict, false},
+	ErrDroneStateReadOnlyKey:             {http.StatusConflict, false},
+	ErrBuntdbIndex:                       {http.StatusInternalServerError, true},
+	ErrStorageProc:                       {http.StatusInternalServerError, true},
+	ErrVal:                               {http.StatusBadRequest, false},
+	ErrBlockchainTxs:                     {http.StatusBadGateway, true},
+	ErrUnmarshalBcTxsResponse:            {http.StatusBadGateway, true},
+	ErrCryptProc:                         {http.StatusInternalServerError, true},
+	ErrCryptProcMissing:                  {http.StatusInternalServerError, true},
+	ErrParamURL:                          {http.StatusBadRequest, false},
+	ErrValidationField:                   {http.StatusBadRequest, false},
+	ErrNotAcceptable:                     {http.StatusNotAcceptable, false},
+	ErrIdempotencyKeyReused:              {http.StatusUnprocessableEntity, false},
+	ErrIdempotencyInProgress:             {http.StatusConflict, false},
+}
+
+// ProblemTypeOf the catalogue entry of an error key, an unknown key is an internal server error
+//
+// - key [string] ~ Error key (schema.Err* constant)
+func ProblemTypeOf(key string) ProblemType {
+	if p, ok := problemCatalogue[key]; ok {
+		return p
+	}
+	return problemCatalogue[ErrGeneric]
+}
+
+// ProblemTypeURI the "type" URI of an error key
+//
+// - key [string] ~ Error key (schema.Err* constant)
+func ProblemTypeURI(key string) string {
+	if _, ok := problemCatalogue[key]; !ok {
+		key = ErrGeneric
+	}
+	return ProblemTypeBaseURI + key
+}
+
+// ProblemKeys the error keys of the catalogue
+func ProblemKeys() []string {
+	keys := make([]string, 0, len(problemCatalogue))
+	for k := range problemCatalogue {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// endregion =============================================================================
@@ -1,6 +1,8 @@
 package auth
 
 import (
+	"context"
+
 	"github.com/kataras/iris/v12"
 	"github.com/kmilodenisglez/drones.restapi/lib"
 	"github.com/kmilodenisglez/drones.restapi/repo/db"
@@ -9,7 +11,7 @@ import (
 )
 
 type Provider interface {
-	GrantIntent(userCredential *dto.UserCredIn, data interface{}) (*dto.GrantIntentResponse, *dto.Problem)
+	GrantIntent(ctx context.Context, userCredential *dto.UserCredIn, data interface{}) (*dto.GrantIntentResponse, *dto.Problem)
 }
 
 // region ======== EVOTE AUTHENTICATION PROVIDER =========================================
@@ -19,18 +21,18 @@ type ProviderDrone struct {
 	repo *db.RepoDrones
 }
 
-func (p *ProviderDrone) GrantIntent(uCred *dto.UserCredIn, options interface{}) (*dto.GrantIntentResponse, *dto.Problem) {
+func (p *ProviderDrone) GrantIntent(ctx context.Context, uCred *dto.UserCredIn, options interface{}) (*dto.GrantIntentResponse, *dto.Problem) {
 	// getting the users
-	user, err := (*p.repo).GetUser(uCred.Username, true)
+	user, err := (*p.repo).GetUser(ctx, uCred.Username, true)
 	if err != nil {
-		return nil, dto.NewProblem(iris.StatusExpectationFailed, schema.ErrBuntdb, err.Error())
+		return nil, dto.NewProblem(iris.StatusInternalServerError, schema.ErrBuntdb, err.Error())
 	}
 	checksum, _ := lib.Checksum("SHA256", []byte(uCred.Password))
 	if user.Passphrase == checksum {
-		return &dto.GrantIntentResponse{Identifier: user.Username, DID: user.Username}, nil
+		return &dto.GrantIntentResponse{Identifier: user.Username, DID: user.Username, Admin: user.Admin}, nil
 	}
 
-	return nil, dto.NewProblem(iris.StatusUnauthorized, schema.ErrFile, schema.ErrCredsNotFound)
+	return nil, dto.NewProblem(iris.StatusUnauthorized, schema.ErrAuth, schema.DetInvalidCredentials)
 }
 
 // endregion =============================================================================
@@ -0,0 +1,322 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/kataras/iris/v12"
+	"github.com/kmilodenisglez/drones.restapi/lib"
+	"github.com/kmilodenisglez/drones.restapi/repo/db"
+	"github.com/kmilodenisglez/drones.restapi/schema"
+	"github.com/kmilodenisglez/drones.restapi/schema/dto"
+	"github.com/kmilodenisglez/drones.restapi/service/tracing"
+	"github.com/kmilodenisglez/drones.restapi/service/utils"
+)
+
+// region ======== SETUP =================================================================
+
+// ISvcBackup Backup, restore, export and import service interface
+type ISvcBackup interface {
+	CreateSnapshotSvc(ctx context.Context) (*dto.Snapshot, *dto.Problem)
+	ListSnapshotsSvc(ctx context.Context) (*[]dto.Snapshot, *dto.Problem)
+	RestoreSnapshotSvc(ctx context.Context, id string) (*dto.Snapshot, *dto.Problem)
+	ExportSvc(ctx context.Context, passphrases bool) (*dto.Dataset, *dto.Problem)
+	ImportSvc(ctx context.Context, dataset *dto.Dataset, replace bool) *dto.Problem
+}
+
+type svcBackupReqs struct {
+	svcConf       *utils.SvcConfig
+	reposDrones   *db.RepoDrones
+	reposEventLog *db.RepoEventLog
+	logger        *utils.SvcLogger
+}
+
+// snapshotIDLayout the snapshot ID is its UTC creation time, so the IDs sort chronologically
+const snapshotIDLayout = "20060102-150405.000"
+
+var snapshotIDRegexp = regexp.MustCompile(`^\d{8}-\d{6}\.\d{3}$`)
+
+// endregion =============================================================================
+
+// NewSvcBackupReqs instantiate the Backup services
+func NewSvcBackupReqs(svcConf *utils.SvcConfig, reposDrones *db.RepoDrones, reposEventLog *db.RepoEventLog, svcLog *utils.SvcLogger) ISvcBackup {
+	return &svcBackupReqs{svcConf, reposDrones, reposEventLog, svcLog}
+}
+
+// region ======== METHODS ======================================================
+
+// CreateSnapshotSvc take a consistent snapshot of the store and event log databases in a new folder of
+// BackupDir, the oldest snapshots beyond BackupRetention are deleted
+func (s *svcBackupReqs) CreateSnapshotSvc(ctx context.Context) (_ *dto.Snapshot, problem *dto.Problem) {
+	ctx, span := tracing.Start(ctx, "ISvcBackup.CreateSnapshotSvc")
+	defer func() { tracing.End(span, problem.Err()) }()
+
+	snapshot, problem := s.createSnapshot(ctx)
+	if problem != nil {
+		return nil, problem
+	}
+	s.pruneSnapshots(ctx)
+	return snapshot, nil
+}
+
+// ListSnapshotsSvc the snapshots of BackupDir, the newest first
+func (s *svcBackupReqs) ListSnapshotsSvc(ctx context.Context) (_ *[]dto.Snapshot, problem *dto.Problem) {
+	_, span := tracing.Start(ctx, "ISvcBackup.ListSnapshotsSvc")
+	defer func() { tracing.End(span, problem.Err()) }()
+
+	ids, err := snapshotIDs(s.svcConf.BackupDir)
+	if err != nil {
+		return nil, dto.NewProblem(iris.StatusInternalServerError, schema.ErrFile, err.Error())
+	}
+	list := make([]dto.Snapshot, 0, len(ids))
+	for i := len(ids) - 1; i >= 0; i-- {
+		snapshot, err := readSnapshot(s.svcConf.BackupDir, ids[i])
+		if err != nil {
+			return nil, dto.NewProblem(iris.StatusInternalServerError, schema.ErrFile, err.Error())
+		}
+		list = append(list, *snapshot)
+	}
+	return &list, nil
+}
+
+// RestoreSnapshotSvc replace the store and event log databases with a snapshot. A snapshot of the
+// current content is taken first and returned, so the restore can be undone
+//
+// - id [string] ~ ID of the snapshot to restore
+func (s *svcBackupReqs) RestoreSnapshotSvc(ctx context.Context, id string) (_ *dto.Snapshot, problem *dto.Problem) {
+	ctx, span := tracing.Start(ctx, "ISvcBackup.RestoreSnapshotSvc")
+	defer func() { tracing.End(span, problem.Err()) }()
+
+	if !snapshotIDRegexp.MatchString(id) {
+		return nil, dto.NewProblem(iris.StatusBadRequest, schema.ErrProcParam, schema.DetInvalidSnapshotID)
+	}
+	dir := filepath.Join(s.svcConf.BackupDir, id)
+	databases := s.databases()
+	for file := range databases {
+		if exist, _ := lib.FileExists(filepath.Join(dir, file)); !exist {
+			return nil, dto.NewProblemf(iris.StatusNotFound, schema.ErrNotFound, schema.DetSnapshotNotFound, id)
+		}
+	}
+
+	// the retention policy is applied after the restore, it could delete the snapshot being restored
+	current, problem := s.createSnapshot(ctx)
+	if problem != nil {
+		return nil, problem
+	}
+	defer s.pruneSnapshots(ctx)
+	for file, snapshot := range databases {
+		f, err := os.Open(filepath.Join(dir, file))
+		if err != nil {
+			return nil, dto.NewProblem(iris.StatusInternalServerError, schema.ErrFile, err.Error())
+		}
+		err = snapshot.restore(ctx, f)
+		_ = f.Close()
+		if err != nil {
+			s.logger.Errorf(ctx, "restore of snapshot '%s' failed, snapshot '%s' has the previous content: %s", id, current.ID, err)
+			return nil, dto.NewProblem(iris.StatusInternalServerError, schema.ErrBuntdb, err.Error())
+		}
+	}
+	// a snapshot taken by an older version is brought to the current schema
+	if _, err := (*s.reposDrones).Migrate(ctx, false); err != nil {
+		s.logger.Errorf(ctx, "snapshot '%s' restored but not migrated, snapshot '%s' has the previous content: %s", id, current.ID, err)
+		return nil, dto.NewProblem(iris.StatusInternalServerError, schema.ErrBuntdb, err.Error())
+	}
+	s.logger.Infof(ctx, "snapshot '%s' restored, the previous content is in snapshot '%s'", id, current.ID)
+	return current, nil
+}
+
+// ExportSvc the whole dataset as versioned JSON: users, drones, medications, payloads and event logs
+//
+// - passphrases [bool] ~ Keep the passphrases of the users, they are left out otherwise
+func (s *svcBackupReqs) ExportSvc(ctx context.Context, passphrases bool) (_ *dto.Dataset, problem *dto.Problem) {
+	ctx, span := tracing.Start(ctx, "ISvcBackup.ExportSvc")
+	defer func() { tracing.End(span, problem.Err()) }()
+
+	dataset, err := (*s.reposDrones).ExportData(ctx)
+	if err != nil {
+		return nil, dto.NewProblem(iris.StatusInternalServerError, schema.ErrBuntdb, err.Error())
+	}
+	logs, err := (*s.reposEventLog).ExportEventLogs(ctx)
+	if err != nil {
+		return nil, dto.NewProblem(iris.StatusInternalServerError, schema.ErrBuntdb, err.Error())
+	}
+	dataset.Logs = *logs
+	if !passphrases {
+		for i := range dataset.Users {
+			dataset.Users[i].Passphrase = ""
+		}
+	}
+	return dataset, nil
+}
+
+// ImportSvc import a dataset created by ExportSvc. A populated database is only overwritten with
+// replace, then the event logs are replaced too; otherwise the event logs are merged. A user without a
+// passphrase keeps the one stored in the database under the same username, the dataset is refused if
+// there is none
+//
+// - dataset [*dto.Dataset] ~ Dataset to import
+//
+// - replace [bool] ~ Overwrite a populated database
+func (s *svcBackupReqs) ImportSvc(ctx context.Context, dataset *dto.Dataset, replace bool) (problem *dto.Problem) {
+	ctx, span := tracing.Start(ctx, "ISvcBackup.ImportSvc")
+	defer func() { tracing.End(span, problem.Err()) }()
+
+	for i := range dataset.Users {
+		user := &dataset.Users[i]
+		if user.Passphrase != "" {
+			continue
+		}
+		stored, err := (*s.reposDrones).GetUser(ctx, user.Username, true)
+		if err != nil {
+			return dto.NewProblem(iris.StatusInternalServerError, schema.ErrBuntdb, err.Error())
+		}
+		if stored.Username != user.Username || stored.Passphrase == "" {
+			return dto.NewProblemf(iris.StatusBadRequest, schema.ErrVal, schema.DetImportUnknownUser, user.Username)
+		}
+		user.Passphrase = stored.Passphrase
+	}
+
+	err := (*s.reposDrones).ImportData(ctx, dataset, replace)
+	switch {
+	case errors.Is(err, schema.ErrDatasetVersion):
+		return dto.NewProblem(iris.StatusBadRequest, schema.ErrVal, err.Error())
+	case err != nil && err.Error() == schema.ErrBuntdbPopulated:
+		return dto.NewProblem(iris.StatusConflict, schema.ErrBuntdbPopulated, schema.DetDatabasePopulatedImport)
+	case errors.Is(err, schema.ErrInvalidDataset):
+		return dto.NewProblem(iris.StatusBadRequest, schema.ErrVal, err.Error())
+	case err != nil:
+		return dto.NewProblem(iris.StatusInternalServerError, schema.ErrBuntdb, err.Error())
+	}
+
+	if err := (*s.reposEventLog).ImportEventLogs(ctx, dataset.Logs, replace); err != nil {
+		return dto.NewProblem(iris.StatusInternalServerError, schema.ErrBuntdb, err.Error())
+	}
+	return nil
+}
+
+// endregion =============================================================================
+
+// region ======== PRIVATE AUX ===========================================================
+
+// createSnapshot take the snapshot of the databases without applying the retention policy
+func (s *svcBackupReqs) createSnapshot(ctx context.Context) (*dto.Snapshot, *dto.Problem) {
+	id := time.Now().UTC().Format(snapshotIDLayout)
+	dir := filepath.Join(s.svcConf.BackupDir, id)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, dto.NewProblem(iris.StatusInternalServerError, schema.ErrFile, err.Error())
+	}
+
+	for file, snapshot := range s.databases() {
+		if err := writeSnapshot(filepath.Join(dir, file), func(f *os.File) error { return snapshot.save(ctx, f) }); err != nil {
+			_ = os.RemoveAll(dir)
+			s.logger.Errorf(ctx, "snapshot '%s' failed: %s", id, err)
+			return nil, dto.NewProblem(iris.StatusInternalServerError, schema.ErrBuntdb, err.Error())
+		}
+	}
+	s.logger.Infof(ctx, "snapshot '%s' created", id)
+
+	snapshot, err := readSnapshot(s.svcConf.BackupDir, id)
+	if err != nil {
+		return nil, dto.NewProblem(iris.StatusInternalServerError, schema.ErrFile, err.Error())
+	}
+	return snapshot, nil
+}
+
+// database the snapshot operations of a repository
+type database struct {
+	save    func(ctx context.Context, f *os.File) error
+	restore func(ctx context.Context, f *os.File) error
+}
+
+// databases the databases of a snapshot by file name
+func (s *svcBackupReqs) databases() map[string]database {
+	return map[string]database{
+		filepath.Base(s.svcConf.StoreDBPath): {
+			save:    func(ctx context.Context, f *os.File) error { return (*s.reposDrones).Snapshot(ctx, f) },
+			restore: func(ctx context.Context, f *os.File) error { return (*s.reposDrones).RestoreSnapshot(ctx, f) },
+		},
+		filepath.Base(s.svcConf.LogDBPath): {
+			save:    func(ctx context.Context, f *os.File) error { return (*s.reposEventLog).Snapshot(ctx, f) },
+			restore: func(ctx context.Context, f *os.File) error { return (*s.reposEventLog).RestoreSnapshot(ctx, f) },
+		},
+	}
+}
+
+// pruneSnapshots delete the oldest snapshots beyond BackupRetention, the failures are only logged
+func (s *svcBackupReqs) pruneSnapshots(ctx context.Context) {
+	ids, err := snapshotIDs(s.svcConf.BackupDir)
+	if err != nil {
+		s.logger.Errorf(ctx, "the old snapshots could not be listed: %s", err)
+		return
+	}
+	for i := 0; i < len(ids)-s.svcConf.BackupRetention; i++ {
+		if err := os.RemoveAll(filepath.Join(s.svcConf.BackupDir, ids[i])); err != nil {
+			s.logger.Errorf(ctx, "snapshot '%s' could not be deleted: %s", ids[i], err)
+			continue
+		}
+		s.logger.Infof(ctx, "snapshot '%s' deleted by the retention policy", ids[i])
+	}
+}
+
+// writeSnapshot write a snapshot file, synced to disk before it is closed
+func writeSnapshot(path string, write func(f *os.File) error) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// snapshotIDs the IDs of the snapshots of the folder, the oldest first
+func snapshotIDs(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() && snapshotIDRegexp.MatchString(entry.Name()) {
+			ids = append(ids, entry.Name())
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func readSnapshot(dir, id string) (*dto.Snapshot, error) {
+	created, err := time.Parse(snapshotIDLayout, id)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, id))
+	if err != nil {
+		return nil, err
+	}
+	snapshot := dto.Snapshot{ID: id, Created: created.Format(time.RFC3339), Files: make([]string, 0, len(entries))}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		snapshot.Files = append(snapshot.Files, entry.Name())
+		snapshot.Size += info.Size()
+	}
+	return &snapshot, nil
+}
+
+// endregion =============================================================================
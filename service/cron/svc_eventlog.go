@@ -1,78 +1,186 @@
 package cron
 
 import (
+	"context"
+	"errors"
+
 	"github.com/go-co-op/gocron"
 	"github.com/kataras/iris/v12"
+	"github.com/kmilodenisglez/drones.restapi/lib"
 	"github.com/kmilodenisglez/drones.restapi/repo/db"
 	"github.com/kmilodenisglez/drones.restapi/schema"
 	"github.com/kmilodenisglez/drones.restapi/schema/dto"
+	"github.com/kmilodenisglez/drones.restapi/service/backup"
+	"github.com/kmilodenisglez/drones.restapi/service/metrics"
+	"github.com/kmilodenisglez/drones.restapi/service/tracing"
 	"github.com/kmilodenisglez/drones.restapi/service/utils"
-	"log"
 	"time"
 )
 
 // ISvcEventLog EventLog request service interface
 type ISvcEventLog interface {
-	GetEventLogs() (*[]dto.LogEvent, *dto.Problem)
+	GetEventLogs(ctx context.Context) (*[]dto.LogEvent, *dto.Problem)
 	MeinerCronJob() error
+	SchedulerStatus() dto.SchedulerStatus
+	StopCronJob(ctx context.Context) error
+	RescheduleCronJob(everyTime int) error
 }
 
+// names of the jobs, used as metrics label
+const (
+	batteryLevelsJob = "battery_levels"
+	backupJob        = "backup"
+)
+
 type svcEventLogReqs struct {
 	svcConf       *utils.SvcConfig
 	reposEventLog *db.RepoEventLog
 	reposDrones   *db.RepoDrones
+	backup        backup.ISvcBackup
+	logger        *utils.SvcLogger
+	scheduler     *gocron.Scheduler
+	job           *gocron.Job
 }
 
 // endregion =============================================================================
 
 // NewSvcRepoEventLog instantiate the Drones request services
-func NewSvcRepoEventLog(svcConf *utils.SvcConfig) ISvcEventLog {
-	reposEventLog := db.NewRepoEventLog(svcConf)
-	reposDrones := db.NewRepoDrones(svcConf)
-	return &svcEventLogReqs{svcConf, &reposEventLog, &reposDrones}
+func NewSvcRepoEventLog(svcConf *utils.SvcConfig, svcLog *utils.SvcLogger) ISvcEventLog {
+	reposEventLog := db.NewRepoEventLog(svcConf, svcLog)
+	reposDrones := db.NewRepoDrones(svcConf, svcLog)
+	svcBackup := backup.NewSvcBackupReqs(svcConf, &reposDrones, &reposEventLog, svcLog)
+	return &svcEventLogReqs{svcConf: svcConf, reposEventLog: &reposEventLog, reposDrones: &reposDrones, backup: svcBackup, logger: svcLog}
 }
 
 // GetEventLogs get event log
-func (e svcEventLogReqs) GetEventLogs() (*[]dto.LogEvent, *dto.Problem) {
-	logs, err := (*e.reposEventLog).GetEventLogs()
+func (e *svcEventLogReqs) GetEventLogs(ctx context.Context) (*[]dto.LogEvent, *dto.Problem) {
+	logs, err := (*e.reposEventLog).GetEventLogs(ctx)
 	if err != nil {
-		return nil, dto.NewProblem(iris.StatusExpectationFailed, schema.ErrBuntdb, err.Error())
+		return nil, dto.NewProblem(iris.StatusInternalServerError, schema.ErrBuntdb, err.Error())
 	}
 	return logs, nil
 }
 
 // MeinerCronJob periodic task to check drones battery levels and create history/audit event log for this
-func (e svcEventLogReqs) MeinerCronJob() error {
+func (e *svcEventLogReqs) MeinerCronJob() error {
 	// cron job is started only if it is active in configuration
 	if e.svcConf.CronEnabled {
-		log.Printf("schedules a new periodic Job with an interval: %d seconds", e.svcConf.EveryTime)
+		everyTime := e.svcConf.Reloadable().EveryTime
+		e.logger.Infof(context.Background(), "schedules a new periodic Job with an interval: %d seconds", everyTime)
 		cron := gocron.NewScheduler(time.UTC)
 
-		_, err := cron.Every(e.svcConf.EveryTime).Seconds().WaitForSchedule().Do(e.doFunc)
+		job, err := cron.Every(everyTime).Seconds().WaitForSchedule().Do(e.doFunc)
 		if err != nil {
 			return err
 		}
+		// the scheduled backups run alongside the battery levels job
+		if e.svcConf.BackupEvery > 0 {
+			e.logger.Infof(context.Background(), "schedules the backups with an interval: %d seconds, keeping %d snapshots", e.svcConf.BackupEvery, e.svcConf.BackupRetention)
+			if _, err := cron.Every(e.svcConf.BackupEvery).Seconds().WaitForSchedule().Do(e.backupFunc); err != nil {
+				return err
+			}
+		}
+		e.scheduler, e.job = cron, job
 		// starts the scheduler asynchronously
 		cron.StartAsync()
 	}
 	return nil
 }
 
-func (e svcEventLogReqs) doFunc() {
-	log.Println("cron job executing")
+// StopCronJob stop the scheduler, no new run is started and a run in progress is waited for. It
+// gives up when the context is done
+func (e *svcEventLogReqs) StopCronJob(ctx context.Context) error {
+	if e.scheduler == nil {
+		return nil
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		e.scheduler.Stop() // waits for the running jobs
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		e.logger.Infof(ctx, "the cron scheduler has been stopped")
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// RescheduleCronJob change the interval of the periodic task, used when the configuration is reloaded.
+// The next run is scheduled from now, a run in progress is not interrupted
+//
+// - everyTime [int] ~ New interval in seconds
+func (e *svcEventLogReqs) RescheduleCronJob(everyTime int) error {
+	if e.scheduler == nil {
+		return nil
+	}
+	if _, err := e.scheduler.Job(e.job).Every(everyTime).Seconds().Update(); err != nil {
+		return err
+	}
+	e.logger.Infof(context.Background(), "the periodic Job has been rescheduled with an interval: %d seconds", everyTime)
+	return nil
+}
+
+// SchedulerStatus state of the cron scheduler, used by the readiness probe
+func (e *svcEventLogReqs) SchedulerStatus() dto.SchedulerStatus {
+	status := dto.SchedulerStatus{Enabled: e.svcConf.CronEnabled}
+	if e.scheduler == nil {
+		return status
+	}
+	status.Running = e.scheduler.IsRunning()
+	if last := e.job.LastRun(); !last.IsZero() {
+		status.LastRun = last.UTC().Format(time.RFC3339)
+	}
+	if next := e.job.NextRun(); !next.IsZero() {
+		status.NextRun = next.UTC().Format(time.RFC3339)
+	}
+	return status
+}
+
+func (e *svcEventLogReqs) doFunc() {
+	// every run gets its own ID, so the log lines of the run can be correlated like a request
+	ctx := lib.WithRequestID(context.Background(), "cron-"+lib.GenerateUUIDStr())
+	ctx, span := tracing.Start(ctx, "cron."+batteryLevelsJob)
+	start := time.Now()
+	err := e.checkBatteryLevels(ctx)
+	metrics.ObserveCronRun(batteryLevelsJob, start, err)
+	tracing.End(span, err)
+	if err != nil {
+		e.logger.Errorf(ctx, "cron job '%s' failed: %s", batteryLevelsJob, err)
+		return
+	}
+	e.logger.Debugf(ctx, "cron job '%s' ended in %s", batteryLevelsJob, time.Since(start))
+}
+
+func (e *svcEventLogReqs) backupFunc() {
+	ctx := lib.WithRequestID(context.Background(), "cron-"+lib.GenerateUUIDStr())
+	ctx, span := tracing.Start(ctx, "cron."+backupJob)
+	start := time.Now()
+	var err error
+	if _, problem := e.backup.CreateSnapshotSvc(ctx); problem != nil {
+		err = errors.New(problem.Detail)
+	}
+	metrics.ObserveCronRun(backupJob, start, err)
+	tracing.End(span, err)
+	if err != nil {
+		e.logger.Errorf(ctx, "cron job '%s' failed: %s", backupJob, err)
+	}
+}
+
+// checkBatteryLevels snapshot the battery levels of the drones in service into the event log
+func (e *svcEventLogReqs) checkBatteryLevels(ctx context.Context) error {
 	// If the drone database has not been populated then the cron is skipped
-	isPopulated := (*e.reposDrones).IsPopulated()
+	isPopulated := (*e.reposDrones).IsPopulated(ctx)
 	if !isPopulated {
-		return
+		return nil
 	}
 	// drones are requested to populate the event log database
-	drones, err := (*e.reposDrones).GetDrones("")
-	if err != nil || drones == nil {
-		return
-	}
-	err = (*e.reposEventLog).CheckBatteryLevelsDrones(drones)
+	drones, err := (*e.reposDrones).GetDrones(ctx, &dto.DroneFilter{SortBy: dto.DroneSortBattery, Desc: true})
 	if err != nil {
-		return
+		return err
 	}
-	log.Println("cron job ending")
+	return (*e.reposEventLog).CheckBatteryLevelsDrones(ctx, &drones.Items)
 }
@@ -0,0 +1,110 @@
+package i18n
+
+import (
+	"embed"
+	"io/fs"
+
+	irisi18n "github.com/kataras/iris/v12/i18n"
+)
+
+// region ======== MESSAGE CATALOGUES ====================================================
+
+// DefaultLanguage language of the messages when the client accepts none of the catalogues, also the
+// language of the logs
+const DefaultLanguage = "en-US"
+
+// Languages the languages with a message catalogue, the first one is the default
+var Languages = []string{DefaultLanguage, "es-ES"}
+
+// key prefixes of the catalogues, see locales/en-US.yml
+const (
+	titlePrefix      = "title."
+	validationPrefix = "validation."
+)
+
+//go:embed locales/*.yml
+var locales embed.FS
+
+var catalogue = mustLoad()
+
+// mustLoad the embedded message catalogues, a malformed catalogue is a bug so it panics
+func mustLoad() *irisi18n.I18n {
+	c := irisi18n.New()
+	// the language is only picked from the Accept-Language header
+	c.URLParameter = ""
+	c.Subdomain = false
+	c.PathRedirect = false
+
+	names := func() []string {
+		files, err := fs.Glob(locales, "locales/*.yml")
+		if err != nil {
+			panic(err)
+		}
+		return files
+	}
+	if err := c.LoadAssets(names, locales.ReadFile, Languages...); err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// Catalogue the i18n instance of the message catalogues, to be registered as the Iris app I18n so the
+// request locale is negotiated from the Accept-Language header
+func Catalogue() *irisi18n.I18n {
+	return catalogue
+}
+
+// Tr the message of a key in the given language, falling back to the default language. Returns an
+// empty string if the key is unknown
+//
+// - lang [string] ~ Language code, e.g. es-ES
+//
+// - key [string] ~ Message key
+//
+// - args [...interface{}] ~ Arguments of the message format
+func Tr(lang, key string, args ...interface{}) string {
+	return catalogue.Tr(lang, key, args...)
+}
+
+// Message the message of a key in the given language, or the text itself if it is not a key of the
+// catalogues (e.g. the error of a library)
+//
+// - lang [string] ~ Language code, e.g. es-ES
+//
+// - keyOrText [string] ~ Message key or free text
+//
+// - args [...interface{}] ~ Arguments of the message format
+func Message(lang, keyOrText string, args ...interface{}) string {
+	if msg := Tr(lang, keyOrText, args...); msg != "" {
+		return msg
+	}
+	return keyOrText
+}
+
+// Title the title of a problem type (error code) in the given language
+//
+// - lang [string] ~ Language code, e.g. es-ES
+//
+// - code [string] ~ Error code (schema.Err* key)
+func Title(lang, code string) string {
+	return Tr(lang, titlePrefix+code)
+}
+
+// Validation the message of a failed validation rule in the given language, the message of the field
+// overrides the one of the rule. Returns an empty string if the rule has no message
+//
+// - lang [string] ~ Language code, e.g. es-ES
+//
+// - field [string] ~ JSON name of the invalid field
+//
+// - rule [string] ~ Name of the validator that failed, e.g. range
+//
+// - args [...interface{}] ~ The invalid value followed by the parameters of the rule
+func Validation(lang, field, rule string, args ...interface{}) string {
+	if msg := Tr(lang, validationPrefix+field+"."+rule, args...); msg != "" {
+		return msg
+	}
+	return Tr(lang, validationPrefix+rule, args...)
+}
+
+// endregion =============================================================================
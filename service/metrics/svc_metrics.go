@@ -0,0 +1,178 @@
+package metrics
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/kataras/iris/v12"
+	"github.com/kmilodenisglez/drones.restapi/schema/dto"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// region ======== COLLECTORS ============================================================
+
+const namespace = "drones"
+
+// names of the repositories, used as label of the database metrics
+const (
+	RepoDrones      = "drones"
+	RepoEventLog    = "event_log"
+	RepoAudit       = "audit"
+	RepoIdempotency = "idempotency"
+)
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of HTTP requests by route, method and status.",
+	}, []string{"route", "method", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of the HTTP requests by route, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	dbDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_operation_duration_seconds",
+		Help:      "Duration of the buntdb operations by repository and operation.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"repo", "operation"})
+
+	cronDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "cron_run_duration_seconds",
+		Help:      "Duration of the cron job runs.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"job"})
+
+	cronFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cron_run_failures_total",
+		Help:      "Number of failed cron job runs.",
+	}, []string{"job"})
+
+	logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logins_total",
+		Help:      "Number of login attempts by result (success or failure).",
+	}, []string{"result"})
+)
+
+// endregion =============================================================================
+
+// region ======== FLEET GAUGES ==========================================================
+
+// FleetSource repository that provides the fleet figures, it is satisfied by db.RepoDrones
+type FleetSource interface {
+	GetFleetStats(ctx context.Context) (*dto.FleetStats, error)
+	GetDrones(ctx context.Context, filter *dto.DroneFilter) (*dto.DronePage, error)
+}
+
+// fleetCollector compute the fleet gauges from the repository on every scrape, so they are never stale
+type fleetCollector struct {
+	source        FleetSource
+	dronesByState *prometheus.Desc
+	droneBattery  *prometheus.Desc
+}
+
+// NewFleetCollector create the collector of the fleet gauges (drones per state and battery per drone)
+//
+// - source [FleetSource] ~ Drones repository instance
+func NewFleetCollector(source FleetSource) prometheus.Collector {
+	return &fleetCollector{
+		source:        source,
+		dronesByState: prometheus.NewDesc(namespace+"_fleet_drones", "Number of drones in service by state.", []string{"state"}, nil),
+		droneBattery:  prometheus.NewDesc(namespace+"_fleet_drone_battery_level", "Battery capacity (%) of every drone in service.", []string{"serial_number"}, nil),
+	}
+}
+
+func (c *fleetCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.dronesByState
+	ch <- c.droneBattery
+}
+
+func (c *fleetCollector) Collect(ch chan<- prometheus.Metric) {
+	stats, err := c.source.GetFleetStats(context.Background())
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.dronesByState, err)
+		return
+	}
+	for state, count := range stats.ByState {
+		ch <- prometheus.MustNewConstMetric(c.dronesByState, prometheus.GaugeValue, float64(count), state)
+	}
+
+	drones, err := c.source.GetDrones(context.Background(), &dto.DroneFilter{SortBy: dto.DroneSortSerialNumber})
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.droneBattery, err)
+		return
+	}
+	for _, drone := range drones.Items {
+		ch <- prometheus.MustNewConstMetric(c.droneBattery, prometheus.GaugeValue, drone.BatteryCapacity, drone.SerialNumber)
+	}
+}
+
+// endregion =============================================================================
+
+// NewRegistry create the registry exposed at /metrics, with the app collectors, the fleet gauges and
+// the Go runtime / process collectors
+//
+// - source [FleetSource] ~ Drones repository instance
+func NewRegistry(source FleetSource) *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		httpRequests, httpDuration, dbDuration, cronDuration, cronFailures, logins,
+		NewFleetCollector(source),
+	)
+	return reg
+}
+
+// region ======== INSTRUMENTATION =======================================================
+
+// NewHTTPMiddleware count and time every request. The route template is used as label instead
+// of the path, so the serial numbers don't blow up the cardinality
+func NewHTTPMiddleware() iris.Handler {
+	return func(ctx iris.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		route := "unmatched"
+		if r := ctx.GetCurrentRoute(); r != nil {
+			route = r.Path()
+		}
+		status := strconv.Itoa(ctx.GetStatusCode())
+		httpRequests.WithLabelValues(route, ctx.Method(), status).Inc()
+		httpDuration.WithLabelValues(route, ctx.Method(), status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// ObserveDBOperation record the duration of a repository operation, it is meant to be deferred
+// at the beginning of the operation: defer metrics.ObserveDBOperation(metrics.RepoDrones, "get_drone", time.Now())
+func ObserveDBOperation(repo, operation string, start time.Time) {
+	dbDuration.WithLabelValues(repo, operation).Observe(time.Since(start).Seconds())
+}
+
+// ObserveCronRun record the duration of a cron job run and count it as failed if err is not nil
+func ObserveCronRun(job string, start time.Time, err error) {
+	cronDuration.WithLabelValues(job).Observe(time.Since(start).Seconds())
+	if err != nil {
+		cronFailures.WithLabelValues(job).Inc()
+	}
+}
+
+// IncLogin count a login attempt
+func IncLogin(success bool) {
+	result := "failure"
+	if success {
+		result = "success"
+	}
+	logins.WithLabelValues(result).Inc()
+}
+
+// endregion =============================================================================
@@ -0,0 +1,136 @@
+package seed
+
+import (
+	"encoding/base64"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/kmilodenisglez/drones.restapi/lib"
+	"github.com/kmilodenisglez/drones.restapi/schema/dto"
+)
+
+// region ======== GENERATED DATA ========================================================
+
+// generatedUsers the users of the generated data, always the same. The first one is an administrator
+func generatedUsers() []dto.User {
+	var users = []dto.User{{
+		Passphrase: "0b14d501a594442a01c6859541bcb3e8164d183d32937b851835442f69d5c94e", // password1
+		Username:   "richard.sargon@meinermail.com",
+		Name:       "Richard Sargon",
+		Admin:      true,
+	}, {
+		Passphrase: "6cf615d5bcaac778352a8f1f3360d23f02f34ec182e259897fd6ce485d7870d4", // password2
+		Username:   "tom.carter@meinermail.com",
+		Name:       "Tom Carter",
+	}}
+	return users
+}
+
+// generatedDrones the drones of the generated data, always the same
+func generatedDrones() []dto.Drone {
+	uuid := "123e4567-e89b-12d3-a456-4266141740"
+	var drones = []dto.Drone{{
+		SerialNumber:    uuid + "01",
+		Model:           dto.Cruiserweight,
+		WeightLimit:     lib.CalculateDroneWeightLimit(dto.Cruiserweight),
+		BatteryCapacity: 45,
+		State:           dto.IDLE,
+	}, {
+		SerialNumber:    uuid + "02",
+		Model:           dto.Middleweight,
+		WeightLimit:     lib.CalculateDroneWeightLimit(dto.Middleweight),
+		BatteryCapacity: 56.4,
+		State:           dto.DELIVERED,
+	}, {
+		SerialNumber:    uuid + "03",
+		Model:           dto.Heavyweight,
+		WeightLimit:     lib.CalculateDroneWeightLimit(dto.Heavyweight),
+		BatteryCapacity: 99.2,
+		State:           dto.LOADING,
+	}, {
+		SerialNumber:    uuid + "04",
+		Model:           dto.Middleweight,
+		WeightLimit:     lib.CalculateDroneWeightLimit(dto.Middleweight),
+		BatteryCapacity: 35.6,
+		State:           dto.RETURNING,
+	}, {
+		SerialNumber:    uuid + "05",
+		Model:           dto.Heavyweight,
+		WeightLimit:     lib.CalculateDroneWeightLimit(dto.Heavyweight),
+		BatteryCapacity: 52.9,
+		State:           dto.DELIVERING,
+	}, {
+		SerialNumber:    uuid + "06",
+		Model:           dto.Lightweight,
+		WeightLimit:     lib.CalculateDroneWeightLimit(dto.Lightweight),
+		BatteryCapacity: 12.9,
+		State:           dto.IDLE,
+	}, {
+		SerialNumber:    uuid + "07",
+		Model:           dto.Cruiserweight,
+		WeightLimit:     lib.CalculateDroneWeightLimit(dto.Cruiserweight),
+		BatteryCapacity: 91.3,
+		State:           dto.LOADED,
+	}, {
+		SerialNumber:    uuid + "08",
+		Model:           dto.Heavyweight,
+		WeightLimit:     lib.CalculateDroneWeightLimit(dto.Heavyweight),
+		BatteryCapacity: 73.6,
+		State:           dto.LOADED,
+	}, {
+		SerialNumber:    uuid + "09",
+		Model:           dto.Lightweight,
+		WeightLimit:     lib.CalculateDroneWeightLimit(dto.Lightweight),
+		BatteryCapacity: 25,
+		State:           dto.IDLE,
+	}, {
+		SerialNumber:    uuid + "10",
+		Model:           dto.Lightweight,
+		WeightLimit:     lib.CalculateDroneWeightLimit(dto.Lightweight),
+		BatteryCapacity: 25,
+		State:           dto.IDLE,
+	}}
+	return drones
+}
+
+// generatedMedications the medications of the generated data, their names and codes depend on the faker seed
+func generatedMedications(faker *gofakeit.Faker) []dto.Medication {
+	var medications = []dto.Medication{{
+		Name:   faker.Password(true, true, true, false, false, 12),
+		Weight: 700,
+		Code:   faker.Password(false, true, true, false, false, 10),
+		Image:  base64.StdEncoding.EncodeToString([]byte("fake_image")),
+	}, {
+		Name:   lib.NormalizeString(faker.Company(), true),
+		Weight: 210,
+		Code:   faker.Password(false, true, true, false, false, 10),
+		Image:  base64.StdEncoding.EncodeToString([]byte("fake_image")),
+	}, {
+		Name:   lib.NormalizeString(faker.Company(), true),
+		Weight: 34,
+		Code:   faker.Password(false, true, true, false, false, 10),
+		Image:  base64.StdEncoding.EncodeToString([]byte("fake_image")),
+	}, {
+		Name:   lib.NormalizeString(faker.Company(), true),
+		Weight: 115,
+		Code:   faker.Password(false, true, true, false, false, 10),
+		Image:  base64.StdEncoding.EncodeToString([]byte("fake_image")),
+	}, {
+		Name:   lib.NormalizeString(faker.Company(), true),
+		Weight: 490,
+		Code:   faker.Password(false, true, true, false, false, 10),
+		Image:  base64.StdEncoding.EncodeToString([]byte("fake_image")),
+	}, {
+		Name:   lib.NormalizeString(faker.Company(), true),
+		Weight: 226,
+		Code:   faker.Password(false, true, true, false, false, 10),
+		Image:  base64.StdEncoding.EncodeToString([]byte("fake_image")),
+	}, {
+		Name:   lib.NormalizeString(faker.Company(), true),
+		Weight: 397,
+		Code:   faker.Password(false, true, true, false, false, 10),
+		Image:  base64.StdEncoding.EncodeToString([]byte("fake_image")),
+	}}
+	return medications
+}
+
+// endregion =============================================================================
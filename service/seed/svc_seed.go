@@ -0,0 +1,212 @@
+package seed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/ghodss/yaml"
+	"github.com/kataras/iris/v12"
+	"github.com/kmilodenisglez/drones.restapi/lib"
+	"github.com/kmilodenisglez/drones.restapi/repo/db"
+	"github.com/kmilodenisglez/drones.restapi/schema"
+	"github.com/kmilodenisglez/drones.restapi/schema/dto"
+	"github.com/kmilodenisglez/drones.restapi/service/tracing"
+	"github.com/kmilodenisglez/drones.restapi/service/utils"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// region ======== SETUP =================================================================
+
+// ISvcSeed Seeding service interface, populate and reset are refused outside development mode
+type ISvcSeed interface {
+	PopulateSvc(ctx context.Context, options *dto.SeedOptions) (*dto.SeedReport, *dto.Problem)
+	ResetSvc(ctx context.Context, options *dto.SeedOptions) (*dto.SeedReport, *dto.Problem)
+}
+
+type svcSeedReqs struct {
+	svcConf       *utils.SvcConfig
+	reposDrones   *db.RepoDrones
+	reposEventLog *db.RepoEventLog
+	logger        *utils.SvcLogger
+}
+
+// endregion =============================================================================
+
+// NewSvcSeedReqs instantiate the Seeding services
+func NewSvcSeedReqs(svcConf *utils.SvcConfig, reposDrones *db.RepoDrones, reposEventLog *db.RepoEventLog, svcLog *utils.SvcLogger) ISvcSeed {
+	return &svcSeedReqs{svcConf, reposDrones, reposEventLog, svcLog}
+}
+
+// region ======== METHODS ======================================================
+
+// PopulateSvc write the seed data only if the database has not been populated yet
+//
+// - options [*dto.SeedOptions] ~ Fixture file or seed, the settings are used for the zero values
+func (s *svcSeedReqs) PopulateSvc(ctx context.Context, options *dto.SeedOptions) (_ *dto.SeedReport, problem *dto.Problem) {
+	ctx, span := tracing.Start(ctx, "ISvcSeed.PopulateSvc")
+	defer func() { tracing.End(span, problem.Err()) }()
+
+	return s.seed(ctx, options, false)
+}
+
+// ResetSvc wipe the store and event log databases and write the seed data again
+//
+// - options [*dto.SeedOptions] ~ Fixture file or seed, the settings are used for the zero values
+func (s *svcSeedReqs) ResetSvc(ctx context.Context, options *dto.SeedOptions) (_ *dto.SeedReport, problem *dto.Problem) {
+	ctx, span := tracing.Start(ctx, "ISvcSeed.ResetSvc")
+	defer func() { tracing.End(span, problem.Err()) }()
+
+	report, problem := s.seed(ctx, options, true)
+	if problem != nil {
+		return nil, problem
+	}
+	if err := (*s.reposEventLog).ImportEventLogs(ctx, nil, true); err != nil {
+		return nil, dto.NewProblem(iris.StatusInternalServerError, schema.ErrBuntdb, err.Error())
+	}
+	return report, nil
+}
+
+// endregion =============================================================================
+
+// region ======== PRIVATE AUX ===========================================================
+
+// seed build the dataset of the options and import it
+//
+// - replace [bool] ~ Overwrite a populated database
+func (s *svcSeedReqs) seed(ctx context.Context, options *dto.SeedOptions, replace bool) (*dto.SeedReport, *dto.Problem) {
+	if !s.svcConf.DevMode {
+		return nil, dto.NewProblem(iris.StatusForbidden, schema.ErrSeedDisabled, schema.DetSeedDisabled)
+	}
+
+	dataset, report, err := s.dataset(options)
+	if err != nil {
+		return nil, dto.NewProblem(iris.StatusBadRequest, schema.ErrVal, err.Error())
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("seed.source", report.Source), attribute.Int64("seed.seed", report.Seed))
+
+	err = (*s.reposDrones).ImportData(ctx, dataset, replace)
+	switch {
+	case err != nil && err.Error() == schema.ErrBuntdbPopulated:
+		return nil, dto.NewProblem(iris.StatusConflict, schema.ErrBuntdbPopulated, schema.DetDatabasePopulated)
+	case errors.Is(err, schema.ErrInvalidDataset):
+		return nil, dto.NewProblem(iris.StatusBadRequest, schema.ErrVal, err.Error())
+	case err != nil:
+		s.logger.Errorf(ctx, "the database could not be seeded: %s", err)
+		return nil, dto.NewProblem(iris.StatusInternalServerError, schema.ErrBuntdb, err.Error())
+	}
+	s.logger.Infof(ctx, "database seeded from %s data (fixture '%s', seed %d), replace: %t", report.Source, report.Fixture, report.Seed, replace)
+	return report, nil
+}
+
+// dataset the data of the fixture file or, without it, the data generated with the seed. A random seed
+// is picked when it is 0, and reported so the data can be generated again
+func (s *svcSeedReqs) dataset(options *dto.SeedOptions) (*dto.Dataset, *dto.SeedReport, error) {
+	fixture, seed := options.Fixture, options.Seed
+	if fixture == "" && seed == 0 {
+		fixture = s.svcConf.SeedFile
+	}
+	if seed == 0 {
+		seed = s.svcConf.Seed
+	}
+
+	if fixture != "" {
+		dataset, err := readFixture(fixture)
+		if err != nil {
+			return nil, nil, err
+		}
+		report := dto.SeedReport{Source: dto.SeedSourceFixture, Fixture: fixture, Users: len(dataset.Users), Drones: len(dataset.Drones), Medications: len(dataset.Medications)}
+		return dataset, &report, nil
+	}
+
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	dataset := dto.Dataset{
+		Version:     dto.DatasetVersion,
+		Users:       generatedUsers(),
+		Drones:      generatedDrones(),
+		Medications: generatedMedications(gofakeit.New(seed)),
+	}
+	for i := range dataset.Drones {
+		dataset.Drones[i].Version = 1
+	}
+	report := dto.SeedReport{Source: dto.SeedSourceGenerated, Seed: seed, Users: len(dataset.Users), Drones: len(dataset.Drones), Medications: len(dataset.Medications)}
+	return &dataset, &report, nil
+}
+
+// readFixture read and validate a YAML or JSON fixture file, the passwords are hashed and the missing
+// weight limits and versions are filled in
+func readFixture(path string) (*dto.Dataset, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", schema.ErrInvalidFixture, err)
+	}
+	fixture := dto.SeedFixture{}
+	// JSON is valid YAML, both are decoded with the json tags
+	if err := yaml.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("%w '%s': %s", schema.ErrInvalidFixture, path, err)
+	}
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w '%s': %s", schema.ErrInvalidFixture, path, fmt.Sprintf(format, args...))
+	}
+
+	dataset := dto.Dataset{Version: dto.DatasetVersion, Drones: fixture.Drones, Medications: fixture.Medications, Payloads: fixture.Payloads}
+	usernames := make(map[string]bool, len(fixture.Users))
+	for _, u := range fixture.Users {
+		if u.Username == "" || usernames[u.Username] {
+			return nil, invalid("user '%s': the username is required and unique", u.Username)
+		}
+		usernames[u.Username] = true
+		passphrase := u.Passphrase
+		if u.Password != "" {
+			if passphrase, err = lib.Checksum(lib.SHA256, []byte(u.Password)); err != nil {
+				return nil, err
+			}
+		}
+		if passphrase == "" {
+			return nil, invalid("user '%s': a password or a passphrase is required", u.Username)
+		}
+		dataset.Users = append(dataset.Users, dto.User{Username: u.Username, Name: u.Name, Passphrase: passphrase, Admin: u.Admin})
+	}
+
+	serialNumbers := make(map[string]bool, len(dataset.Drones))
+	for i := range dataset.Drones {
+		drone := &dataset.Drones[i]
+		if drone.SerialNumber == "" || serialNumbers[drone.SerialNumber] {
+			return nil, invalid("drone '%s': the serial number is required and unique", drone.SerialNumber)
+		}
+		serialNumbers[drone.SerialNumber] = true
+		if drone.WeightLimit == 0 {
+			drone.WeightLimit = lib.CalculateDroneWeightLimit(drone.Model)
+		}
+		if drone.Version == 0 {
+			drone.Version = 1
+		}
+		if _, err := govalidator.ValidateStruct(drone); err != nil {
+			return nil, invalid("drone '%s': %s", drone.SerialNumber, err)
+		}
+	}
+
+	codes := make(map[string]bool, len(dataset.Medications))
+	for _, m := range dataset.Medications {
+		if codes[m.Code] {
+			return nil, invalid("medication '%s': the code is unique", m.Code)
+		}
+		codes[m.Code] = true
+		if m.Weight <= 0 {
+			return nil, invalid("medication '%s': the weight must be positive", m.Code)
+		}
+		if _, err := govalidator.ValidateStruct(m); err != nil {
+			return nil, invalid("medication '%s': %s", m.Code, err)
+		}
+	}
+	return &dataset, nil
+}
+
+// endregion =============================================================================
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+
+	"github.com/kataras/iris/v12"
+	"github.com/kmilodenisglez/drones.restapi/lib"
+	"github.com/kmilodenisglez/drones.restapi/repo/db"
+	"github.com/kmilodenisglez/drones.restapi/schema"
+	"github.com/kmilodenisglez/drones.restapi/schema/dto"
+	"github.com/kmilodenisglez/drones.restapi/service/utils"
+)
+
+// region ======== SETUP =================================================================
+
+// ISvcAudit Audit trail service interface
+type ISvcAudit interface {
+	RecordSvc(ctx context.Context, actor dto.InjectedParam, action, target string, before, after interface{}) *dto.Problem
+	GetAuditEntriesSvc(ctx context.Context, filter *dto.AuditFilter) (*[]dto.AuditEntry, *dto.Problem)
+	VerifyAuditChainSvc(ctx context.Context) (*dto.AuditChainStatus, *dto.Problem)
+}
+
+type svcAuditReqs struct {
+	reposAudit *db.RepoAudit
+	logger     *utils.SvcLogger
+}
+
+// endregion =============================================================================
+
+// NewSvcAuditReqs instantiate the Audit trail services
+func NewSvcAuditReqs(reposAudit *db.RepoAudit, svcLog *utils.SvcLogger) ISvcAudit {
+	return &svcAuditReqs{reposAudit, svcLog}
+}
+
+// region ======== METHODS ======================================================
+
+// RecordSvc append a new entry to the audit trail. The before and after snapshots can be nil
+// (e.g. nothing existed before a creation), the changes between both are computed here
+func (s *svcAuditReqs) RecordSvc(ctx context.Context, actor dto.InjectedParam, action, target string, before, after interface{}) *dto.Problem {
+	if actor.Username == "" && actor.Did == "" {
+		actor.Username = dto.AuditAnonymousActor
+	}
+
+	beforeSnapshot, err := lib.MarshalSnapshot(before)
+	if err != nil {
+		return dto.NewProblem(iris.StatusInternalServerError, schema.ErrGeneric, err.Error())
+	}
+	afterSnapshot, err := lib.MarshalSnapshot(after)
+	if err != nil {
+		return dto.NewProblem(iris.StatusInternalServerError, schema.ErrGeneric, err.Error())
+	}
+
+	entry := dto.AuditEntry{
+		Actor:   actor,
+		Action:  action,
+		Target:  target,
+		Before:  beforeSnapshot,
+		After:   afterSnapshot,
+		Changes: lib.DiffSnapshots(beforeSnapshot, afterSnapshot),
+	}
+	if err := (*s.reposAudit).AppendAuditEntry(ctx, &entry); err != nil {
+		s.logger.Errorf(ctx, "audit entry '%s' for '%s' could not be recorded: %s", action, target, err)
+		return dto.NewProblem(iris.StatusInternalServerError, schema.ErrBuntdb, err.Error())
+	}
+	return nil
+}
+
+func (s *svcAuditReqs) GetAuditEntriesSvc(ctx context.Context, filter *dto.AuditFilter) (*[]dto.AuditEntry, *dto.Problem) {
+	res, err := (*s.reposAudit).GetAuditEntries(ctx, filter)
+	if err != nil {
+		return nil, dto.NewProblem(iris.StatusInternalServerError, schema.ErrBuntdb, err.Error())
+	}
+	return res, nil
+}
+
+func (s *svcAuditReqs) VerifyAuditChainSvc(ctx context.Context) (*dto.AuditChainStatus, *dto.Problem) {
+	res, err := (*s.reposAudit).VerifyAuditChain(ctx)
+	if err != nil {
+		return nil, dto.NewProblem(iris.StatusInternalServerError, schema.ErrBuntdb, err.Error())
+	}
+	return res, nil
+}
@@ -1,185 +1,623 @@
 package service
 
 import (
-	"fmt"
+	"context"
+	"errors"
 
+	"github.com/asaskevich/govalidator"
 	"github.com/kataras/iris/v12"
+	"github.com/kmilodenisglez/drones.restapi/lib"
 	"github.com/kmilodenisglez/drones.restapi/repo/db"
 	"github.com/kmilodenisglez/drones.restapi/schema"
 	"github.com/kmilodenisglez/drones.restapi/schema/dto"
-	"github.com/tidwall/buntdb"
+	"github.com/kmilodenisglez/drones.restapi/service/tracing"
+	"github.com/kmilodenisglez/drones.restapi/service/utils"
 )
 
 // region ======== SETUP =================================================================
 
 // ISvcDrones Drones request service interface
 type ISvcDrones interface {
-	IsPopulateDBSvc() bool
-	PopulateDBSvc() *dto.Problem
+	IsPopulateDBSvc(ctx context.Context) bool
 
 	// user functions
 
-	GetUserSvc(id string, filter bool) (*dto.User, *dto.Problem)
-	GetUsersSvc() (*[]dto.User, *dto.Problem)
+	GetUserSvc(ctx context.Context, id string, filter bool) (*dto.User, *dto.Problem)
+	GetUsersSvc(ctx context.Context) (*[]dto.User, *dto.Problem)
 
 	// drone functions
 
-	GetADroneSvc(serialNumber string) (*dto.Drone, *dto.Problem)
-	GetDronesSvc(filters ...string) (*[]dto.Drone, *dto.Problem)
-	RegisterDroneSvc(drone *dto.Drone) *dto.Problem
-	ExistDroneSvc(serialNumber string) (bool, *dto.Problem)
+	GetADroneSvc(ctx context.Context, serialNumber string) (*dto.Drone, *dto.Problem)
+	GetDronesSvc(ctx context.Context, filter *dto.DroneFilter) (*dto.DronePage, *dto.Problem)
+	RegisterDroneSvc(ctx context.Context, drone *dto.Drone) *dto.Problem
+	UpdateDroneSvc(ctx context.Context, drone *dto.Drone, expectedVersion *uint64) (*dto.Drone, *dto.Problem)
+	PatchDroneSvc(ctx context.Context, serialNumber string, patch *dto.PatchDrone, expectedVersion *uint64) (*dto.Drone, *dto.Drone, *dto.Problem)
+	RetireDroneSvc(ctx context.Context, serialNumber string, expectedVersion *uint64) (*dto.Drone, *dto.Drone, *dto.Problem)
+	ExistDroneSvc(ctx context.Context, serialNumber string) (bool, *dto.Problem)
+	GetFleetStatsSvc(ctx context.Context) (*dto.FleetStats, *dto.Problem)
 
 	// medication functions
 
-	GetMedicationsSvc() (*[]dto.Medication, *dto.Problem)
-	CheckingLoadedMedicationsItemsSvc(serialNumberDrone string) (*[]string, *dto.Problem)
-	LoadMedicationItemsADroneSvc(serialNumberDrone string, medicationItemIDs []interface{}) *dto.Problem
+	GetMedicationsSvc(ctx context.Context) (*[]dto.Medication, *dto.Problem)
+	CheckingLoadedMedicationsItemsSvc(ctx context.Context, serialNumberDrone string) (*[]string, *dto.Problem)
+	LoadMedicationItemsADroneSvc(ctx context.Context, serialNumberDrone string, medicationItemIDs []interface{}) ([]string, *dto.Problem)
+
+	// bulk functions
+
+	RegisterDronesSvc(ctx context.Context, drones []dto.Drone, mode string) (*dto.BulkReport, *dto.Problem)
+	LoadMedicationItemsDronesSvc(ctx context.Context, loads []dto.LoadInstruction, mode string) (*dto.BulkReport, [][]string, *dto.Problem)
+
+	// delivery functions
+
+	AdvanceDroneSvc(ctx context.Context, serialNumber, action string, expectedVersion *uint64) (*dto.Drone, *dto.DroneDelivery, *dto.Problem)
+	GetDeliveriesSvc(ctx context.Context, filter *dto.DeliveryFilter) (*[]dto.Delivery, *dto.Problem)
 }
 
 type svcDronesReqs struct {
+	svcConf     *utils.SvcConfig
 	reposDrones *db.RepoDrones
+	logger      *utils.SvcLogger
 }
 
 // endregion =============================================================================
 
 // NewSvcDronesReqs instantiate the Drones request services
-func NewSvcDronesReqs(reposDrones *db.RepoDrones) ISvcDrones {
-	return &svcDronesReqs{reposDrones}
+func NewSvcDronesReqs(svcConf *utils.SvcConfig, reposDrones *db.RepoDrones, svcLog *utils.SvcLogger) ISvcDrones {
+	return &svcDronesReqs{svcConf, reposDrones, svcLog}
 }
 
 // region ======== METHODS ======================================================
 
-func (s *svcDronesReqs) IsPopulateDBSvc() bool {
-	return (*s.reposDrones).IsPopulated()
-}
+func (s *svcDronesReqs) IsPopulateDBSvc(ctx context.Context) bool {
+	ctx, span := tracing.Start(ctx, "ISvcDrones.IsPopulateDBSvc")
+	defer span.End()
 
-func (s *svcDronesReqs) PopulateDBSvc() *dto.Problem {
-	err := (*s.reposDrones).PopulateDB()
-
-	switch {
-	case err == buntdb.ErrNotFound:
-		return dto.NewProblem(iris.StatusPreconditionFailed, schema.ErrBuntdbItemNotFound, err.Error())
-	case err != nil:
-		if err.Error() == schema.ErrBuntdbPopulated {
-			return dto.NewProblem(iris.StatusInternalServerError, schema.ErrBuntdbPopulated, "the database has already been populated")
-		}
-		return dto.NewProblem(iris.StatusExpectationFailed, schema.ErrBuntdb, err.Error())
-	}
-	return nil
+	return (*s.reposDrones).IsPopulated(ctx)
 }
 
-func (s *svcDronesReqs) GetUserSvc(id string, filter bool) (*dto.User, *dto.Problem) {
-	res, err := (*s.reposDrones).GetUser(id, filter)
+func (s *svcDronesReqs) GetUserSvc(ctx context.Context, id string, filter bool) (_ *dto.User, problem *dto.Problem) {
+	ctx, span := tracing.Start(ctx, "ISvcDrones.GetUserSvc")
+	defer func() { tracing.End(span, problem.Err()) }()
+
+	res, err := (*s.reposDrones).GetUser(ctx, id, filter)
 	if err != nil {
-		return nil, dto.NewProblem(iris.StatusExpectationFailed, schema.ErrBuntdb, err.Error())
+		return nil, dto.NewProblem(iris.StatusInternalServerError, schema.ErrBuntdb, err.Error())
 	}
 	return res, nil
 }
 
-func (s *svcDronesReqs) GetUsersSvc() (*[]dto.User, *dto.Problem) {
-	res, err := (*s.reposDrones).GetUsers()
+func (s *svcDronesReqs) GetUsersSvc(ctx context.Context) (_ *[]dto.User, problem *dto.Problem) {
+	ctx, span := tracing.Start(ctx, "ISvcDrones.GetUsersSvc")
+	defer func() { tracing.End(span, problem.Err()) }()
+
+	res, err := (*s.reposDrones).GetUsers(ctx)
 	if err != nil {
-		return nil, dto.NewProblem(iris.StatusExpectationFailed, schema.ErrBuntdb, err.Error())
+		return nil, dto.NewProblem(iris.StatusInternalServerError, schema.ErrBuntdb, err.Error())
 	}
 	return res, nil
 }
 
 // GetADroneSvc get a specific drone
-func (s *svcDronesReqs) GetADroneSvc(serialNumber string) (*dto.Drone, *dto.Problem) {
-	res, err := (*s.reposDrones).GetDrone(serialNumber)
+func (s *svcDronesReqs) GetADroneSvc(ctx context.Context, serialNumber string) (_ *dto.Drone, problem *dto.Problem) {
+	ctx, span := tracing.Start(ctx, "ISvcDrones.GetADroneSvc")
+	defer func() { tracing.End(span, problem.Err()) }()
+
+	res, err := (*s.reposDrones).GetDrone(ctx, serialNumber)
 	// Getting non-existent values will cause an ErrNotFound error.
-	if err == buntdb.ErrNotFound {
-		return nil, dto.NewProblem(iris.StatusPreconditionFailed, schema.ErrBuntdbItemNotFound, err.Error())
+	if err == db.ErrNotFound {
+		return nil, dto.NewProblemf(iris.StatusNotFound, schema.ErrBuntdbItemNotFound, schema.DetDroneNotFound, serialNumber)
 	} else if err != nil {
-		return nil, dto.NewProblem(iris.StatusExpectationFailed, schema.ErrBuntdb, err.Error())
+		return nil, dto.NewProblem(iris.StatusInternalServerError, schema.ErrBuntdb, err.Error())
 	}
 
 	return res, nil
 }
 
-func (s *svcDronesReqs) GetDronesSvc(filters ...string) (*[]dto.Drone, *dto.Problem) {
-	var filter = ""
-	if len(filters) > 0 {
-		filter = filters[0]
-	}
+// GetDronesSvc get a page of the drones that match the filter
+func (s *svcDronesReqs) GetDronesSvc(ctx context.Context, filter *dto.DroneFilter) (_ *dto.DronePage, problem *dto.Problem) {
+	ctx, span := tracing.Start(ctx, "ISvcDrones.GetDronesSvc")
+	defer func() { tracing.End(span, problem.Err()) }()
 
-	res, err := (*s.reposDrones).GetDrones(filter)
-	if err != nil {
-		return nil, dto.NewProblem(iris.StatusExpectationFailed, schema.ErrBuntdb, err.Error())
+	res, err := (*s.reposDrones).GetDrones(ctx, filter)
+	if err == schema.ErrInvalidCursor {
+		return nil, dto.NewProblem(iris.StatusBadRequest, schema.ErrParamURL, schema.DetInvalidCursor)
+	} else if err != nil {
+		return nil, dto.NewProblem(iris.StatusInternalServerError, schema.ErrBuntdb, err.Error())
 	}
 
 	return res, nil
 }
 
-func (s *svcDronesReqs) RegisterDroneSvc(drone *dto.Drone) *dto.Problem {
-	err := (*s.reposDrones).RegisterDrone(drone)
-	if err != nil {
-		return dto.NewProblem(iris.StatusExpectationFailed, schema.ErrBuntdb, err.Error())
+func (s *svcDronesReqs) RegisterDroneSvc(ctx context.Context, drone *dto.Drone) (problem *dto.Problem) {
+	ctx, span := tracing.Start(ctx, "ISvcDrones.RegisterDroneSvc")
+	defer func() { tracing.End(span, problem.Err()) }()
+
+	if drone.State != dto.IDLE {
+		return stateProblem()
+	}
+	if err := (*s.reposDrones).RegisterDrone(ctx, drone); err != nil {
+		return registerProblem(err)
 	}
 	return nil
 }
 
-func (s *svcDronesReqs) ExistDroneSvc(serialNumber string) (bool, *dto.Problem) {
-	err := (*s.reposDrones).ExistDrone(serialNumber)
+// UpdateDroneSvc full replacement of an existing drone, its state must be the current one. It returns the
+// drone as it was before the update
+func (s *svcDronesReqs) UpdateDroneSvc(ctx context.Context, drone *dto.Drone, expectedVersion *uint64) (_ *dto.Drone, problem *dto.Problem) {
+	ctx, span := tracing.Start(ctx, "ISvcDrones.UpdateDroneSvc")
+	defer func() { tracing.End(span, problem.Err()) }()
+
+	previous, err := (*s.reposDrones).UpdateDrone(ctx, drone, expectedVersion)
+	switch {
+	case err == db.ErrNotFound:
+		return nil, dto.NewProblemf(iris.StatusNotFound, schema.ErrBuntdbItemNotFound, schema.DetDroneNotFound, drone.SerialNumber)
+	case err == schema.ErrDroneVersionMismatch:
+		return nil, dto.NewProblem(iris.StatusPreconditionFailed, schema.ErrDroneVersionMismatchKey, schema.DetDroneVersionMismatch)
+	case err == schema.ErrDroneRetired:
+		return nil, dto.NewProblem(iris.StatusConflict, schema.ErrDroneRetiredKey, schema.DetDroneRetired)
+	case err == schema.ErrDroneStateReadOnly:
+		return nil, stateProblem()
+	case err != nil:
+		return nil, dto.NewProblem(iris.StatusInternalServerError, schema.ErrBuntdb, err.Error())
+	}
+	return previous, nil
+}
+
+// PatchDroneSvc partial update of an existing drone, the omitted fields keep their current value.
+// The write is conditioned to the version that was read, so a concurrent update is never lost. It returns
+// the drone before and after the update
+func (s *svcDronesReqs) PatchDroneSvc(ctx context.Context, serialNumber string, patch *dto.PatchDrone, expectedVersion *uint64) (_, _ *dto.Drone, problem *dto.Problem) {
+	ctx, span := tracing.Start(ctx, "ISvcDrones.PatchDroneSvc")
+	defer func() { tracing.End(span, problem.Err()) }()
+
+	drone, problem := s.GetADroneSvc(ctx, serialNumber)
+	if problem != nil {
+		return nil, nil, problem
+	}
+	if drone.Retired {
+		return nil, nil, dto.NewProblem(iris.StatusConflict, schema.ErrDroneRetiredKey, schema.DetDroneRetired)
+	}
+	if expectedVersion != nil && *expectedVersion != drone.Version {
+		return nil, nil, dto.NewProblem(iris.StatusPreconditionFailed, schema.ErrDroneVersionMismatchKey, schema.DetDroneVersionMismatch)
+	}
+	readVersion := drone.Version
+
+	if patch.Model != nil {
+		drone.Model = *patch.Model
+		drone.WeightLimit = lib.CalculateDroneWeightLimit(drone.Model)
+	}
+	if patch.BatteryCapacity != nil {
+		drone.BatteryCapacity = *patch.BatteryCapacity
+	}
+	if patch.State != nil && *patch.State != drone.State {
+		return nil, nil, stateProblem()
+	}
+
+	// validate drone fields
+	if _, err := govalidator.ValidateStruct(drone); err != nil {
+		return nil, nil, lib.ValidationProblem(err)
+	}
+
+	previous, problem := s.UpdateDroneSvc(ctx, drone, &readVersion)
+	if problem != nil {
+		return nil, nil, problem
+	}
+	return previous, drone, nil
+}
+
+// RetireDroneSvc decommission a drone, it is refused if the drone is mid-delivery or has loaded medications.
+// It returns the drone before and after the retirement
+func (s *svcDronesReqs) RetireDroneSvc(ctx context.Context, serialNumber string, expectedVersion *uint64) (_, _ *dto.Drone, problem *dto.Problem) {
+	ctx, span := tracing.Start(ctx, "ISvcDrones.RetireDroneSvc")
+	defer func() { tracing.End(span, problem.Err()) }()
+
+	previous, drone, err := (*s.reposDrones).RetireDrone(ctx, serialNumber, expectedVersion)
+	switch {
+	case err == db.ErrNotFound:
+		return nil, nil, dto.NewProblemf(iris.StatusNotFound, schema.ErrBuntdbItemNotFound, schema.DetDroneNotFound, serialNumber)
+	case err == schema.ErrDroneVersionMismatch:
+		return nil, nil, dto.NewProblem(iris.StatusPreconditionFailed, schema.ErrDroneVersionMismatchKey, schema.DetDroneVersionMismatch)
+	case err == schema.ErrDroneRetired:
+		return nil, nil, dto.NewProblem(iris.StatusConflict, schema.ErrDroneRetiredKey, schema.DetDroneRetired)
+	case err == schema.ErrDroneNotRetirable:
+		return nil, nil, dto.NewProblem(iris.StatusConflict, schema.ErrDroneNotRetirableKey, schema.DetDroneNotRetirable)
+	case err != nil:
+		return nil, nil, dto.NewProblem(iris.StatusInternalServerError, schema.ErrBuntdb, err.Error())
+	}
+	return previous, drone, nil
+}
+
+func (s *svcDronesReqs) ExistDroneSvc(ctx context.Context, serialNumber string) (_ bool, problem *dto.Problem) {
+	ctx, span := tracing.Start(ctx, "ISvcDrones.ExistDroneSvc")
+	defer func() { tracing.End(span, problem.Err()) }()
+
+	err := (*s.reposDrones).ExistDrone(ctx, serialNumber)
 	// Getting non-existent values will cause an ErrNotFound error.
-	if err == buntdb.ErrNotFound {
+	if err == db.ErrNotFound {
 		return false, nil
 	} else if err != nil {
-		return false, dto.NewProblem(iris.StatusExpectationFailed, schema.ErrBuntdb, err.Error())
+		return false, dto.NewProblem(iris.StatusInternalServerError, schema.ErrBuntdb, err.Error())
 	}
 	return true, nil
 }
 
-func (s *svcDronesReqs) GetMedicationsSvc() (*[]dto.Medication, *dto.Problem) {
-	res, err := (*s.reposDrones).GetMedications()
+// GetFleetStatsSvc summary of the fleet for the dashboards
+func (s *svcDronesReqs) GetFleetStatsSvc(ctx context.Context) (_ *dto.FleetStats, problem *dto.Problem) {
+	ctx, span := tracing.Start(ctx, "ISvcDrones.GetFleetStatsSvc")
+	defer func() { tracing.End(span, problem.Err()) }()
+
+	res, err := (*s.reposDrones).GetFleetStats(ctx)
+	if err != nil {
+		return nil, dto.NewProblem(iris.StatusInternalServerError, schema.ErrBuntdb, err.Error())
+	}
+	return res, nil
+}
+
+func (s *svcDronesReqs) GetMedicationsSvc(ctx context.Context) (_ *[]dto.Medication, problem *dto.Problem) {
+	ctx, span := tracing.Start(ctx, "ISvcDrones.GetMedicationsSvc")
+	defer func() { tracing.End(span, problem.Err()) }()
+
+	res, err := (*s.reposDrones).GetMedications(ctx)
 	if err != nil {
-		return nil, dto.NewProblem(iris.StatusExpectationFailed, schema.ErrBuntdb, err.Error())
+		return nil, dto.NewProblem(iris.StatusInternalServerError, schema.ErrBuntdb, err.Error())
 	}
 	return res, nil
 }
 
-func (s *svcDronesReqs) CheckingLoadedMedicationsItemsSvc(serialNumberDrone string) (*[]string, *dto.Problem) {
+func (s *svcDronesReqs) CheckingLoadedMedicationsItemsSvc(ctx context.Context, serialNumberDrone string) (_ *[]string, problem *dto.Problem) {
+	ctx, span := tracing.Start(ctx, "ISvcDrones.CheckingLoadedMedicationsItemsSvc")
+	defer func() { tracing.End(span, problem.Err()) }()
+
 	// check that the drone exists in the database
-	err := (*s.reposDrones).ExistDrone(serialNumberDrone)
+	err := (*s.reposDrones).ExistDrone(ctx, serialNumberDrone)
 	// Getting non-existent values will cause an ErrNotFound error.
-	if err == buntdb.ErrNotFound {
-		return nil, dto.NewProblem(iris.StatusPreconditionFailed, schema.ErrBuntdbItemNotFound, fmt.Sprintf("the drone with serial number %s does not exist", serialNumberDrone))
+	if err == db.ErrNotFound {
+		return nil, dto.NewProblemf(iris.StatusNotFound, schema.ErrBuntdbItemNotFound, schema.DetDroneNotFound, serialNumberDrone)
 	} else if err != nil {
-		return nil, dto.NewProblem(iris.StatusExpectationFailed, schema.ErrBuntdb, err.Error())
+		return nil, dto.NewProblem(iris.StatusInternalServerError, schema.ErrBuntdb, err.Error())
 	}
 
 	// if the drone exists, then we check if it has medication items associated with it
-	res, err := (*s.reposDrones).CheckingLoadedMedicationsItems(serialNumberDrone)
+	res, err := (*s.reposDrones).CheckingLoadedMedicationsItems(ctx, serialNumberDrone)
 
 	// Getting non-existent values will cause an ErrNotFound error.
 	// if it throws the ErrNotFound error, it is that the drone is not loading medication items
-	if err == buntdb.ErrNotFound {
+	if err == db.ErrNotFound {
 		return &[]string{}, nil
 	} else if err != nil {
-		return nil, dto.NewProblem(iris.StatusExpectationFailed, schema.ErrBuntdb, err.Error())
+		return nil, dto.NewProblem(iris.StatusInternalServerError, schema.ErrBuntdb, err.Error())
 	}
 	return res, nil
 }
 
-func (s *svcDronesReqs) LoadMedicationItemsADroneSvc(serialNumberDrone string, medicationItemIDs []interface{}) *dto.Problem {
+// LoadMedicationItemsADroneSvc replace the medications loaded on a drone, it returns the codes that were
+// loaded before
+func (s *svcDronesReqs) LoadMedicationItemsADroneSvc(ctx context.Context, serialNumberDrone string, medicationItemIDs []interface{}) (_ []string, problem *dto.Problem) {
+	ctx, span := tracing.Start(ctx, "ISvcDrones.LoadMedicationItemsADroneSvc")
+	defer func() { tracing.End(span, problem.Err()) }()
+
+	drone, errP := s.loadableDrone(ctx, serialNumberDrone)
+	if errP != nil {
+		return nil, errP
+	}
+
+	previous, err := (*s.reposDrones).LoadMedicationItemsADrone(ctx, drone, medicationItemIDs)
+	if err != nil {
+		return nil, loadProblem(err)
+	}
+	return previous, nil
+}
+
+// RegisterDronesSvc register a batch of drones. Every drone is validated like a single registration and
+// its serial number must be unique in the batch. In atomic mode the drones are written in a single
+// transaction, or none of them if an item fails. In best-effort mode the valid ones are registered
+// one by one. The problem is only returned if the whole batch fails
+func (s *svcDronesReqs) RegisterDronesSvc(ctx context.Context, drones []dto.Drone, mode string) (_ *dto.BulkReport, problem *dto.Problem) {
+	ctx, span := tracing.Start(ctx, "ISvcDrones.RegisterDronesSvc")
+	defer func() { tracing.End(span, problem.Err()) }()
+
+	report := newBulkReport(mode, len(drones))
+	firstIndex := make(map[string]int, len(drones))
+	valid := make([]int, 0, len(drones))
+	for i := range drones {
+		drone := &drones[i]
+		report.Items[i].SerialNumber = drone.SerialNumber
+		drone.WeightLimit = lib.CalculateDroneWeightLimit(drone.Model)
+
+		if _, err := govalidator.ValidateStruct(drone); err != nil {
+			failBulkItem(report, i, lib.ValidationProblem(err))
+		} else if drone.State != dto.IDLE {
+			failBulkItem(report, i, stateProblem())
+		} else if first, repeated := firstIndex[drone.SerialNumber]; repeated {
+			failBulkItem(report, i, dto.NewProblemf(iris.StatusConflict, schema.ErrDuplicateKey, schema.DetBulkDuplicateItem, first))
+		} else {
+			valid = append(valid, i)
+		}
+		if _, repeated := firstIndex[drone.SerialNumber]; !repeated {
+			firstIndex[drone.SerialNumber] = i
+		}
+	}
+
+	if mode == dto.BulkModeBestEffort {
+		for _, i := range valid {
+			if problem := s.RegisterDroneSvc(ctx, &drones[i]); problem != nil {
+				failBulkItem(report, i, problem)
+			}
+		}
+		settleBulkReport(report, iris.StatusCreated)
+		return report, nil
+	}
+
+	// the serial numbers are checked before the transaction, so all the failures are reported
+	for _, i := range valid {
+		if problem := s.unusedSerialNumber(ctx, drones[i].SerialNumber); problem != nil {
+			failBulkItem(report, i, problem)
+		}
+	}
+	if report.Failed == 0 {
+		err := (*s.reposDrones).RegisterDrones(ctx, drones)
+		var batchErr *db.BatchError
+		if errors.As(err, &batchErr) {
+			for i, itemErr := range batchErr.Errors {
+				failBulkItem(report, i, registerProblem(itemErr))
+			}
+		} else if err != nil {
+			return nil, registerProblem(err)
+		}
+	}
+	settleBulkReport(report, iris.StatusCreated)
+	return report, nil
+}
+
+// LoadMedicationItemsDronesSvc load a batch of drones with medication items. Every instruction is validated
+// like a single loading and its serial number must be unique in the batch. In atomic mode the drones are
+// loaded in a single transaction, or none of them if an item fails. In best-effort mode the valid ones are
+// loaded one by one. The problem is only returned if the whole batch fails. It also returns the codes that
+// were loaded before on the drone of every loaded instruction, by index
+func (s *svcDronesReqs) LoadMedicationItemsDronesSvc(ctx context.Context, loads []dto.LoadInstruction, mode string) (_ *dto.BulkReport, _ [][]string, problem *dto.Problem) {
+	ctx, span := tracing.Start(ctx, "ISvcDrones.LoadMedicationItemsDronesSvc")
+	defer func() { tracing.End(span, problem.Err()) }()
+
+	report := newBulkReport(mode, len(loads))
+	firstIndex := make(map[string]int, len(loads))
+	droneLoads := make([]dto.DroneLoad, len(loads))
+	valid := make([]int, 0, len(loads))
+	for i, load := range loads {
+		report.Items[i].SerialNumber = load.SerialNumber
+		medicationItemIDs := make([]interface{}, 0, len(load.MedicationCodes))
+		for _, code := range load.MedicationCodes {
+			medicationItemIDs = append(medicationItemIDs, code)
+		}
+		droneLoads[i].MedicationItemIDs = medicationItemIDs
+
+		if problem := validLoadInstruction(load.SerialNumber, medicationItemIDs); problem != nil {
+			failBulkItem(report, i, problem)
+		} else if first, repeated := firstIndex[load.SerialNumber]; repeated {
+			failBulkItem(report, i, dto.NewProblemf(iris.StatusConflict, schema.ErrDuplicateKey, schema.DetBulkDuplicateItem, first))
+		} else {
+			valid = append(valid, i)
+		}
+		if _, repeated := firstIndex[load.SerialNumber]; !repeated {
+			firstIndex[load.SerialNumber] = i
+		}
+	}
+
+	previous := make([][]string, len(loads))
+	if mode == dto.BulkModeBestEffort {
+		for _, i := range valid {
+			var problem *dto.Problem
+			if previous[i], problem = s.LoadMedicationItemsADroneSvc(ctx, loads[i].SerialNumber, droneLoads[i].MedicationItemIDs); problem != nil {
+				failBulkItem(report, i, problem)
+			}
+		}
+		settleBulkReport(report, iris.StatusOK)
+		return report, previous, nil
+	}
+
+	// the state of every drone is checked before the transaction, so all the failures are reported, and
+	// again inside it, so a drone that changed in between is not loaded
+	for _, i := range valid {
+		drone, problem := s.loadableDrone(ctx, loads[i].SerialNumber)
+		if problem != nil {
+			failBulkItem(report, i, problem)
+			continue
+		}
+		droneLoads[i].Drone = drone
+	}
+	if report.Failed == 0 {
+		loaded, err := (*s.reposDrones).LoadMedicationItemsDrones(ctx, droneLoads)
+		var batchErr *db.BatchError
+		if errors.As(err, &batchErr) {
+			for i, itemErr := range batchErr.Errors {
+				failBulkItem(report, i, loadProblem(itemErr))
+			}
+		} else if err != nil {
+			return nil, nil, loadProblem(err)
+		} else {
+			previous = loaded
+		}
+	}
+	settleBulkReport(report, iris.StatusOK)
+	return report, previous, nil
+}
+
+// AdvanceDroneSvc apply a lifecycle action to a drone: dispatch, delivered, return, returned or abort
+// (dto.DeliveryAction*). The action must be allowed in the state of the drone
+//
+// - action [string] ~ Lifecycle action
+//
+// - expectedVersion [*uint64] ~ Version of the drone from the If-Match header, nil if it was not sent
+//
+// It returns the drone as it was before the action, and the drone and delivery after it
+func (s *svcDronesReqs) AdvanceDroneSvc(ctx context.Context, serialNumber, action string, expectedVersion *uint64) (_ *dto.Drone, _ *dto.DroneDelivery, problem *dto.Problem) {
+	ctx, span := tracing.Start(ctx, "ISvcDrones.AdvanceDroneSvc")
+	defer func() { tracing.End(span, problem.Err()) }()
+
+	previous, drone, delivery, err := (*s.reposDrones).AdvanceDrone(ctx, serialNumber, action, expectedVersion)
+	var errTransition *db.TransitionError
+	switch {
+	case err == db.ErrNotFound:
+		return nil, nil, dto.NewProblemf(iris.StatusNotFound, schema.ErrBuntdbItemNotFound, schema.DetDroneNotFound, serialNumber)
+	case err == schema.ErrDroneVersionMismatch:
+		return nil, nil, dto.NewProblem(iris.StatusPreconditionFailed, schema.ErrDroneVersionMismatchKey, schema.DetDroneVersionMismatch)
+	case err == schema.ErrDroneRetired:
+		return nil, nil, dto.NewProblem(iris.StatusConflict, schema.ErrDroneRetiredKey, schema.DetDroneRetired)
+	case errors.As(err, &errTransition):
+		return nil, nil, dto.NewProblemf(iris.StatusConflict, schema.ErrDroneInvalidTransitionKey, schema.DetDroneInvalidTransition, action, errTransition.State.String())
+	case err == schema.ErrDroneNotLoaded:
+		return nil, nil, dto.NewProblem(iris.StatusConflict, schema.ErrDroneNotLoadedKey, schema.DetDroneNotLoaded)
+	case err != nil:
+		return nil, nil, dto.NewProblem(iris.StatusInternalServerError, schema.ErrBuntdb, err.Error())
+	}
+	return previous, &dto.DroneDelivery{Drone: *drone, Delivery: delivery}, nil
+}
+
+// GetDeliveriesSvc delivery history of a drone or of a medication, the oldest dispatch first. An unknown
+// drone is not found; the history of a medication is kept after it leaves the catalogue, so an unknown
+// code has no deliveries
+func (s *svcDronesReqs) GetDeliveriesSvc(ctx context.Context, filter *dto.DeliveryFilter) (_ *[]dto.Delivery, problem *dto.Problem) {
+	ctx, span := tracing.Start(ctx, "ISvcDrones.GetDeliveriesSvc")
+	defer func() { tracing.End(span, problem.Err()) }()
+
+	if filter.SerialNumber != "" {
+		err := (*s.reposDrones).ExistDrone(ctx, filter.SerialNumber)
+		if err == db.ErrNotFound {
+			return nil, dto.NewProblemf(iris.StatusNotFound, schema.ErrBuntdbItemNotFound, schema.DetDroneNotFound, filter.SerialNumber)
+		} else if err != nil {
+			return nil, dto.NewProblem(iris.StatusInternalServerError, schema.ErrBuntdb, err.Error())
+		}
+	}
+
+	deliveries, err := (*s.reposDrones).GetDeliveries(ctx, filter)
+	if err != nil {
+		return nil, dto.NewProblem(iris.StatusInternalServerError, schema.ErrBuntdb, err.Error())
+	}
+	return deliveries, nil
+}
+
+// endregion =============================================================================
+
+// region ======== PRIVATE AUX ===========================================================
+
+// loadableDrone the drone of the serial number if it can be loaded: in service, not on a trip and with enough battery
+func (s *svcDronesReqs) loadableDrone(ctx context.Context, serialNumber string) (*dto.Drone, *dto.Problem) {
 	// get drone if exist
-	drone, errP := s.GetADroneSvc(serialNumberDrone)
+	drone, errP := s.GetADroneSvc(ctx, serialNumber)
 	if errP != nil {
-		return errP
+		return nil, errP
+	}
+
+	if drone.Retired {
+		return nil, dto.NewProblem(iris.StatusConflict, schema.ErrDroneRetiredKey, schema.DetDroneRetired)
 	}
 
-	// prevent the drone from being in LOADING state if the battery level is **below 25%**
-	if drone.BatteryCapacity < 25.0 {
-		return dto.NewProblem(iris.StatusPreconditionFailed, schema.ErrDroneVeryLowBatteryKey, schema.ErrDroneVeryLowBattery.Error())
-	} else if drone.State != dto.IDLE {
-		return dto.NewProblem(iris.StatusPreconditionFailed, schema.ErrDroneBusyKey, schema.ErrDroneBusy.Error())
+	// prevent the drone from being in LOADING state if the battery level is below MinBatteryToLoad (25% by default)
+	minBattery := s.svcConf.Reloadable().MinBatteryToLoad
+	if drone.BatteryCapacity < minBattery {
+		s.logger.Warnf(ctx, "drone '%s' can't be loaded, battery level %.2f%% is below %.2f%%", drone.SerialNumber, drone.BatteryCapacity, minBattery)
+		return nil, dto.NewProblemf(iris.StatusPreconditionFailed, schema.ErrDroneVeryLowBatteryKey, schema.DetDroneVeryLowBattery, drone.BatteryCapacity, minBattery)
+	} else if !drone.State.Loadable() {
+		return nil, dto.NewProblem(iris.StatusPreconditionFailed, schema.ErrDroneBusyKey, schema.DetDroneBusy)
 	}
+	return drone, nil
+}
+
+// unusedSerialNumber a problem if the serial number belongs to a drone, even a retired one
+func (s *svcDronesReqs) unusedSerialNumber(ctx context.Context, serialNumber string) *dto.Problem {
+	drone, err := (*s.reposDrones).GetDrone(ctx, serialNumber)
+	switch {
+	case err == db.ErrNotFound:
+		return nil
+	case err != nil:
+		return dto.NewProblem(iris.StatusInternalServerError, schema.ErrBuntdb, err.Error())
+	case drone.Retired:
+		return registerProblem(schema.ErrDroneRetired)
+	default:
+		return registerProblem(schema.ErrDroneAlreadyExists)
+	}
+}
 
-	err := (*s.reposDrones).LoadMedicationItemsADrone(drone, medicationItemIDs)
-	if err == buntdb.ErrNotFound {
+// registerProblem the problem of a failed drone registration
+func registerProblem(err error) *dto.Problem {
+	switch {
+	case errors.Is(err, schema.ErrDroneAlreadyExists):
+		return dto.NewProblem(iris.StatusConflict, schema.ErrDuplicateKey, schema.DetDroneAlreadyExists)
+	case errors.Is(err, schema.ErrDroneRetired):
+		return dto.NewProblem(iris.StatusConflict, schema.ErrDroneRetiredKey, schema.DetDroneRetiredRegistration)
+	default:
+		return dto.NewProblem(iris.StatusInternalServerError, schema.ErrBuntdb, err.Error())
+	}
+}
+
+// stateProblem the problem of a drone registered in a state other than IDLE or updated with a different state,
+// the state only changes by loading the drone and by the lifecycle actions
+func stateProblem() *dto.Problem {
+	return dto.NewProblem(iris.StatusConflict, schema.ErrDroneStateReadOnlyKey, schema.DetDroneStateReadOnly)
+}
+
+// loadProblem the problem of a failed drone loading
+func loadProblem(err error) *dto.Problem {
+	var errBattery *db.BatteryError
+	switch {
+	case err == db.ErrNotFound:
+		return dto.NewProblem(iris.StatusNotFound, schema.ErrNotFound, schema.ErrDetNotFound)
+	case errors.Is(err, schema.ErrMedicationNotFound):
+		return dto.NewProblem(iris.StatusNotFound, schema.ErrNotFound, schema.DetMedicationNotFound)
+	case errors.Is(err, schema.ErrDroneMaximumLoadWeightExceeded):
 		return dto.NewProblem(iris.StatusPreconditionFailed, schema.ErrDroneMaximumLoadWeightExceededKey, err.Error())
-	} else if err != nil {
-		return dto.NewProblem(iris.StatusExpectationFailed, schema.ErrBuntdb, err.Error())
+	case err == schema.ErrDroneRetired:
+		return dto.NewProblem(iris.StatusConflict, schema.ErrDroneRetiredKey, schema.DetDroneRetired)
+	case errors.As(err, &errBattery):
+		return dto.NewProblemf(iris.StatusPreconditionFailed, schema.ErrDroneVeryLowBatteryKey, schema.DetDroneVeryLowBattery, errBattery.Level, errBattery.Min)
+	case err == schema.ErrDroneBusy:
+		return dto.NewProblem(iris.StatusPreconditionFailed, schema.ErrDroneBusyKey, schema.DetDroneBusy)
+	}
+	return dto.NewProblem(iris.StatusInternalServerError, schema.ErrBuntdb, err.Error())
+}
+
+// validLoadInstruction a problem if the serial number of a drone or the format of the medication codes is invalid
+func validLoadInstruction(serialNumber string, medicationItemIDs []interface{}) *dto.Problem {
+	if serialNumber == "" {
+		return dto.NewProblem(iris.StatusBadRequest, schema.ErrProcParam, schema.DetInvalidField)
+	}
+	if !lib.ValidateSerialNumberDrone(serialNumber) {
+		return dto.NewProblemf(iris.StatusBadRequest, schema.ErrValidationField, schema.DetSerialNumberTooLong, dto.MaxSerialNumberLength)
+	}
+	if !lib.ValidateStringCollection(medicationItemIDs, dto.RegexpMedicationCode) {
+		return dto.NewProblem(iris.StatusBadRequest, schema.ErrValidationField, schema.DetInvalidMedicationIDs)
 	}
 	return nil
 }
+
+// newBulkReport the report of a bulk request, its items are pending until they fail or the report is settled
+func newBulkReport(mode string, size int) *dto.BulkReport {
+	return &dto.BulkReport{Mode: mode, Total: size, Items: make([]dto.BulkItem, size)}
+}
+
+// failBulkItem report the problem of an item, with the status of its problem code
+func failBulkItem(report *dto.BulkReport, index int, problem *dto.Problem) {
+	report.Items[index].Index = index
+	report.Items[index].Status = schema.ProblemTypeOf(problem.Code).Status
+	report.Items[index].Problem = problem
+	report.Failed++
+}
+
+// settleBulkReport the pending items succeeded with the status, unless it is an atomic batch with failures,
+// then they were not written (424 Failed Dependency)
+func settleBulkReport(report *dto.BulkReport, status int) {
+	if report.Mode == dto.BulkModeAtomic && report.Failed > 0 {
+		status = iris.StatusFailedDependency
+	}
+	for i := range report.Items {
+		if report.Items[i].Problem != nil {
+			continue
+		}
+		report.Items[i].Index = i
+		report.Items[i].Status = status
+		if status != iris.StatusFailedDependency {
+			report.Succeeded++
+		}
+	}
+}
+
+// endregion =============================================================================
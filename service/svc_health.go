@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/kmilodenisglez/drones.restapi/lib"
+	"github.com/kmilodenisglez/drones.restapi/repo/db"
+	"github.com/kmilodenisglez/drones.restapi/schema"
+	"github.com/kmilodenisglez/drones.restapi/schema/dto"
+	"github.com/kmilodenisglez/drones.restapi/service/cron"
+	"github.com/kmilodenisglez/drones.restapi/service/utils"
+)
+
+// region ======== SETUP =================================================================
+
+// ISvcHealth Health probes service interface
+type ISvcHealth interface {
+	LivenessSvc() *dto.HealthReport
+	ReadinessSvc(ctx context.Context) *dto.HealthReport
+}
+
+type svcHealthReqs struct {
+	svcConf       *utils.SvcConfig
+	reposDrones   *db.RepoDrones
+	reposEventLog *db.RepoEventLog
+	reposAudit    *db.RepoAudit
+	cronJob       *cron.ISvcEventLog
+	logger        *utils.SvcLogger
+}
+
+// endregion =============================================================================
+
+// NewSvcHealthReqs instantiate the Health probes services
+func NewSvcHealthReqs(svcConf *utils.SvcConfig, reposDrones *db.RepoDrones, reposEventLog *db.RepoEventLog, reposAudit *db.RepoAudit, cronJob *cron.ISvcEventLog, svcLog *utils.SvcLogger) ISvcHealth {
+	return &svcHealthReqs{svcConf, reposDrones, reposEventLog, reposAudit, cronJob, svcLog}
+}
+
+// region ======== METHODS ======================================================
+
+// LivenessSvc the process is up and serving requests, nothing else is checked
+func (s *svcHealthReqs) LivenessSvc() *dto.HealthReport {
+	return &dto.HealthReport{Status: dto.HealthOK}
+}
+
+// ReadinessSvc run every readiness check, the report fails if any check fails
+func (s *svcHealthReqs) ReadinessSvc(ctx context.Context) *dto.HealthReport {
+	checks := []struct {
+		name string
+		fn   func(ctx context.Context) (string, string)
+	}{
+		{dto.HealthCheckStoreDB, pingCheck((*s.reposDrones).Ping)},
+		{dto.HealthCheckEventLogDB, pingCheck((*s.reposEventLog).Ping)},
+		{dto.HealthCheckAuditDB, pingCheck((*s.reposAudit).Ping)},
+		{dto.HealthCheckPopulated, s.checkPopulated},
+		{dto.HealthCheckCron, s.checkCron},
+		{dto.HealthCheckDiskSpace, s.checkDiskSpace},
+	}
+
+	report := dto.HealthReport{Status: dto.HealthOK, Checks: make(map[string]dto.HealthCheck, len(checks))}
+	for _, c := range checks {
+		start := time.Now()
+		status, detail := c.fn(ctx)
+		report.Checks[c.name] = dto.HealthCheck{Status: status, Detail: detail, Duration: time.Since(start).String()}
+		if status == dto.HealthFail {
+			report.Status = dto.HealthFail
+			s.logger.Warnf(ctx, "readiness check '%s' failed: %s", c.name, detail)
+		}
+	}
+	return &report
+}
+
+// endregion =============================================================================
+
+// region ======== PRIVATE AUX ===========================================================
+
+// pingCheck readiness check of a database that can be opened and read
+func pingCheck(ping func(ctx context.Context) error) func(ctx context.Context) (string, string) {
+	return func(ctx context.Context) (string, string) {
+		if err := ping(ctx); err != nil {
+			return dto.HealthFail, err.Error()
+		}
+		return dto.HealthOK, ""
+	}
+}
+
+func (s *svcHealthReqs) checkPopulated(ctx context.Context) (string, string) {
+	if !(*s.reposDrones).IsPopulated(ctx) {
+		return dto.HealthFail, "the database has not been populated yet"
+	}
+	return dto.HealthOK, ""
+}
+
+func (s *svcHealthReqs) checkCron(_ context.Context) (string, string) {
+	status := (*s.cronJob).SchedulerStatus()
+	switch {
+	case !status.Enabled:
+		return dto.HealthDisabled, "the cron job is disabled in the configuration"
+	case !status.Running:
+		return dto.HealthFail, "the cron scheduler is not running"
+	}
+	return dto.HealthOK, fmt.Sprintf("next run at %s", status.NextRun)
+}
+
+// checkDiskSpace the databases are written in the folder of the store database
+func (s *svcHealthReqs) checkDiskSpace(_ context.Context) (string, string) {
+	minFree := s.svcConf.Reloadable().MinFreeDiskMB
+
+	free, err := lib.FreeDiskSpace(filepath.Dir(s.svcConf.StoreDBPath))
+	if err == schema.ErrDiskSpaceUnsupported {
+		return dto.HealthSkipped, err.Error()
+	} else if err != nil {
+		return dto.HealthFail, err.Error()
+	}
+
+	freeMB := free / (1 << 20)
+	if freeMB < minFree {
+		return dto.HealthFail, fmt.Sprintf("%d MB free, at least %d MB are required", freeMB, minFree)
+	}
+	return dto.HealthOK, fmt.Sprintf("%d MB free", freeMB)
+}
+
+// endregion =============================================================================
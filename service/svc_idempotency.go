@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+
+	"github.com/kataras/iris/v12"
+	"github.com/kmilodenisglez/drones.restapi/lib"
+	"github.com/kmilodenisglez/drones.restapi/repo/db"
+	"github.com/kmilodenisglez/drones.restapi/schema"
+	"github.com/kmilodenisglez/drones.restapi/schema/dto"
+	"github.com/kmilodenisglez/drones.restapi/service/utils"
+)
+
+// region ======== SETUP =================================================================
+
+// ISvcIdempotency Idempotency-Key service interface
+type ISvcIdempotency interface {
+	BeginSvc(ctx context.Context, username, key, fingerprint string) (*dto.IdempotentResponse, *dto.Problem)
+	CompleteSvc(ctx context.Context, username, key string, response *dto.IdempotentResponse) *dto.Problem
+	ReleaseSvc(ctx context.Context, username, key string) *dto.Problem
+}
+
+type svcIdempotencyReqs struct {
+	reposIdempotency *db.RepoIdempotency
+	logger           *utils.SvcLogger
+}
+
+// endregion =============================================================================
+
+// NewSvcIdempotencyReqs instantiate the Idempotency-Key services
+func NewSvcIdempotencyReqs(reposIdempotency *db.RepoIdempotency, svcLog *utils.SvcLogger) ISvcIdempotency {
+	return &svcIdempotencyReqs{reposIdempotency, svcLog}
+}
+
+// region ======== METHODS ======================================================
+
+// BeginSvc start a request with an Idempotency-Key. It returns the response to replay when the key
+// completed a request with the same fingerprint, nil when the key was reserved for this request, and a
+// problem when the key is invalid, in use by a request in progress or was used by a different request
+//
+// - username [string] ~ Owner of the key
+//
+// - key [string] ~ Idempotency-Key header
+//
+// - fingerprint [string] ~ Fingerprint of the request, see IdempotencyFingerprint
+func (s *svcIdempotencyReqs) BeginSvc(ctx context.Context, username, key, fingerprint string) (*dto.IdempotentResponse, *dto.Problem) {
+	if !validIdempotencyKey(key) {
+		return nil, dto.NewProblemf(iris.StatusBadRequest, schema.ErrProcParam, schema.DetIdempotencyKeyInvalid, dto.MaxIdempotencyKeyLength)
+	}
+
+	stored, err := (*s.reposIdempotency).ReserveIdempotencyKey(ctx, username, key, fingerprint)
+	if err != nil {
+		return nil, dto.NewProblem(iris.StatusInternalServerError, schema.ErrBuntdb, err.Error())
+	}
+	switch {
+	case stored == nil:
+		return nil, nil
+	case stored.Fingerprint != fingerprint:
+		return nil, dto.NewProblem(iris.StatusUnprocessableEntity, schema.ErrIdempotencyKeyReused, schema.DetIdempotencyKeyReused)
+	case !stored.Completed:
+		return nil, dto.NewProblem(iris.StatusConflict, schema.ErrIdempotencyInProgress, schema.DetIdempotencyInProgress)
+	}
+	return stored, nil
+}
+
+// CompleteSvc store the response of the request that reserved the key, it is replayed to the retries
+func (s *svcIdempotencyReqs) CompleteSvc(ctx context.Context, username, key string, response *dto.IdempotentResponse) *dto.Problem {
+	response.Completed = true
+	if err := (*s.reposIdempotency).SaveIdempotentResponse(ctx, username, key, response); err != nil {
+		return dto.NewProblem(iris.StatusInternalServerError, schema.ErrBuntdb, err.Error())
+	}
+	return nil
+}
+
+// ReleaseSvc free the key of a request whose response must not be replayed (e.g. a server error), so
+// the retries are handled again
+func (s *svcIdempotencyReqs) ReleaseSvc(ctx context.Context, username, key string) *dto.Problem {
+	if err := (*s.reposIdempotency).ReleaseIdempotencyKey(ctx, username, key); err != nil {
+		return dto.NewProblem(iris.StatusInternalServerError, schema.ErrBuntdb, err.Error())
+	}
+	return nil
+}
+
+// endregion =============================================================================
+
+// IdempotencyFingerprint the fingerprint of a request, the retries of a request share it and a different
+// request with the same key is detected by it
+//
+// - method [string] ~ HTTP method
+//
+// - path [string] ~ Path of the request
+//
+// - body [[]byte] ~ Body of the request
+func IdempotencyFingerprint(method, path string, body []byte) string {
+	data := make([]byte, 0, len(method)+len(path)+len(body)+2)
+	data = append(append(append(data, method...), '\n'), path...)
+	data = append(append(data, '\n'), body...)
+	fingerprint, _ := lib.Checksum(lib.SHA256, data)
+	return fingerprint
+}
+
+// region ======== PRIVATE AUX ===========================================================
+
+// validIdempotencyKey the key has between 1 and dto.MaxIdempotencyKeyLength printable ASCII characters, without spaces
+func validIdempotencyKey(key string) bool {
+	if key == "" || len(key) > dto.MaxIdempotencyKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] <= ' ' || key[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// endregion =============================================================================
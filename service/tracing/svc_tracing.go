@@ -0,0 +1,180 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/kataras/iris/v12"
+	"github.com/kataras/iris/v12/middleware/requestid"
+	"github.com/kmilodenisglez/drones.restapi/service/utils"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// region ======== SETUP =================================================================
+
+const (
+	serviceName         = "drones"
+	instrumentationName = "github.com/kmilodenisglez/drones.restapi"
+)
+
+// span exporters supported by the TraceExporter configuration
+const (
+	ExporterNone   = "none"   // spans are created (the trace context is propagated) but never exported
+	ExporterStdout = "stdout" // one JSON span per line to the standard output, works offline
+	ExporterFile   = "file"   // one JSON span per line appended to TraceFilePath, works offline
+	ExporterOTLP   = "otlp"   // OTLP over HTTP to OTLPEndpoint (e.g. an OpenTelemetry collector or Jaeger)
+)
+
+// ShutdownFunc flush the pending spans and release the exporter
+type ShutdownFunc func(ctx context.Context) error
+
+// endregion =============================================================================
+
+// NewTracerProvider install the global tracer provider and the W3C trace-context propagator. The
+// exporter is chosen by the TraceExporter configuration, the returned function must be called on
+// shutdown so the buffered spans are not lost
+//
+// - svcConf [*utils.SvcConfig] ~ App conf instance pointer
+func NewTracerProvider(svcConf *utils.SvcConfig) (ShutdownFunc, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	exporter, closer, err := newExporter(svcConf)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(serviceName))),
+	}
+	if exporter != nil {
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+	tp := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(tp)
+
+	if exporter == nil {
+		// nothing to flush, the provider without span processors refuses to shut down
+		return func(ctx context.Context) error { return nil }, nil
+	}
+	return func(ctx context.Context) error {
+		err := tp.Shutdown(ctx)
+		if closer != nil {
+			if errClose := closer.Close(); err == nil {
+				err = errClose
+			}
+		}
+		return err
+	}, nil
+}
+
+// region ======== SPANS =================================================================
+
+// Start create a span as child of the span carried by the context (if any)
+//
+// - ctx [context.Context] ~ Parent context
+//
+// - name [string] ~ Name of the span
+//
+// - attrs [...attribute.KeyValue] ~ Attributes of the span
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// StartDB create the span of a repository operation, named after the same repo and operation labels
+// of the database metrics: db.drones.get_drone
+func StartDB(ctx context.Context, repo, operation string) (context.Context, trace.Span) {
+	return Start(ctx, "db."+repo+"."+operation,
+		attribute.String("db.system", "buntdb"),
+		attribute.String("db.operation", operation),
+	)
+}
+
+// End finish the span, marking it as failed if err is not nil
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// endregion =============================================================================
+
+// region ======== PROPAGATION ===========================================================
+
+// NewHTTPMiddleware start a server span per request. The trace context of the client (traceparent and
+// tracestate headers) is honored, and the span is stored in the request context, so the service and
+// repository spans are nested in it. The span is named after the route template
+func NewHTTPMiddleware() iris.Handler {
+	return func(ctx iris.Context) {
+		req := ctx.Request()
+		parent := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+		spanCtx, span := otel.Tracer(instrumentationName).Start(parent, req.Method, trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPMethodKey.String(req.Method),
+				semconv.HTTPTargetKey.String(req.URL.Path),
+				attribute.String("http.request_id", requestid.Get(ctx)),
+			))
+		defer span.End()
+
+		ctx.ResetRequest(req.WithContext(spanCtx))
+		ctx.Next()
+
+		// the route is only known once the request has been routed
+		if r := ctx.GetCurrentRoute(); r != nil {
+			span.SetName(req.Method + " " + r.Path())
+			span.SetAttributes(semconv.HTTPRouteKey.String(r.Path()))
+		}
+		status := ctx.GetStatusCode()
+		span.SetAttributes(semconv.HTTPStatusCodeKey.Int(status))
+		span.SetStatus(semconv.SpanStatusFromHTTPStatusCodeAndSpanKind(status, trace.SpanKindServer))
+	}
+}
+
+// endregion =============================================================================
+
+// region ======== PRIVATE AUX ===========================================================
+
+// newExporter create the span exporter of the configuration, the closer (if any) is the file of the file exporter
+func newExporter(svcConf *utils.SvcConfig) (sdktrace.SpanExporter, io.Closer, error) {
+	switch svcConf.TraceExporter {
+	case "", ExporterNone:
+		return nil, nil, nil
+	case ExporterStdout:
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		return exporter, nil, err
+	case ExporterFile:
+		f, err := os.OpenFile(svcConf.TraceFilePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, nil, err
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			_ = f.Close()
+			return nil, nil, err
+		}
+		return exporter, f, nil
+	case ExporterOTLP:
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(svcConf.OTLPEndpoint)}
+		if svcConf.OTLPInsecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err := otlptracehttp.New(context.Background(), opts...)
+		return exporter, nil, err
+	default:
+		return nil, nil, fmt.Errorf("unknown trace exporter '%s', use %s, %s, %s or %s", svcConf.TraceExporter, ExporterNone, ExporterStdout, ExporterFile, ExporterOTLP)
+	}
+}
+
+// endregion =============================================================================
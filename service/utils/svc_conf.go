@@ -2,60 +2,457 @@ package utils
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/ghodss/yaml"
 	"github.com/kmilodenisglez/drones.restapi/lib"
 	"github.com/kmilodenisglez/drones.restapi/schema"
-	"github.com/tkanos/gonfig"
+	"github.com/kmilodenisglez/drones.restapi/schema/dto"
 )
 
 // region ======== TYPES =================================================================
 
-// conf unexported configuration schema holder struct
+// conf unexported configuration schema holder struct. Every field can be overridden by the
+// environment variable of its env tag, which takes precedence over the config file
 type conf struct { //nolint:maligned
 	// ENVIRONMENT
-	Debug    bool
-	APIDocIP string
-	DappPort string
+	Debug    bool   `env:"SERVER_DEBUG"`
+	APIDocIP string `env:"SERVER_API_DOC_IP"`
+	DappPort string `env:"SERVER_DAPP_PORT"`
+	GrpcPort string `env:"SERVER_GRPC_PORT"` // port of the gRPC API, empty to disable it
+
+	ShutdownTimeout int `env:"SERVER_SHUTDOWN_TIMEOUT"` // seconds to drain the requests and wait for the cron job on shutdown
+
+	// LOGGING
+	LogLevel  string `env:"SERVER_LOG_LEVEL"`  // debug, info, warn, error or disable
+	LogFormat string `env:"SERVER_LOG_FORMAT"` // json or text
+
+	// TRACING
+	TraceExporter string `env:"SERVER_TRACE_EXPORTER"`  // none, stdout, file or otlp
+	TraceFilePath string `env:"SERVER_TRACE_FILE_PATH"` // file of the file exporter
+	OTLPEndpoint  string `env:"SERVER_OTLP_ENDPOINT"`   // host:port of the OTLP/HTTP receiver
+	OTLPInsecure  bool   `env:"SERVER_OTLP_INSECURE"`   // plain HTTP instead of HTTPS for the OTLP exporter
 
 	// Cryptographic conf
-	JWTSignKey string
-	TkMaxAge   uint8
+	JWTSignKey string `env:"SERVER_JWT_SIGN_KEY"`
+	TkMaxAge   uint8  `env:"SERVER_TK_MAX_AGE"`
 
 	// STORE DB
-	StoreDBPath string
+	StoreBackend string `env:"SERVER_STORE_BACKEND"` // buntdb or postgres, used by the drones and event log repositories
+	StoreDBPath  string `env:"SERVER_STORE_DB_PATH"`
+	PostgresDSN  string `env:"SERVER_POSTGRES_DSN"` // connection string of the postgres backend
+
+	// AUDIT TRAIL
+	AuditDBPath string `env:"SERVER_AUDIT_DB_PATH"`
+
+	// IDEMPOTENCY
+	IdempotencyDBPath string `env:"SERVER_IDEMPOTENCY_DB_PATH"`
+	IdempotencyTTL    int    `env:"SERVER_IDEMPOTENCY_TTL"` // seconds a response is replayed to the retries of its Idempotency-Key
+
+	// GRAPHQL
+	GraphQLMaxDepth      int `env:"SERVER_GRAPHQL_MAX_DEPTH"`      // deepest nesting of fields of a query
+	GraphQLMaxComplexity int `env:"SERVER_GRAPHQL_MAX_COMPLEXITY"` // most fields a query may resolve
+
+	// THRESHOLDS
+	MinFreeDiskMB    uint64  `env:"SERVER_MIN_FREE_DISK_MB"`    // the readiness probe fails below this free space in the databases folder
+	MinBatteryToLoad float64 `env:"SERVER_MIN_BATTERY_TO_LOAD"` // a drone can't be loaded below this battery level (%)
 
 	// CRON JOB
-	CronEnabled bool
-	LogDBPath   string
-	EveryTime   int
+	CronEnabled bool   `env:"SERVER_CRON_ENABLED"`
+	LogDBPath   string `env:"SERVER_LOG_DB_PATH"`
+	EveryTime   int    `env:"SERVER_EVERY_TIME"`
+
+	// BACKUPS
+	BackupDir       string `env:"SERVER_BACKUP_DIR"`       // folder of the snapshots, one subfolder per snapshot
+	BackupEvery     int    `env:"SERVER_BACKUP_EVERY"`     // seconds between the scheduled snapshots, 0 disables them
+	BackupRetention int    `env:"SERVER_BACKUP_RETENTION"` // snapshots kept, the oldest ones are deleted
+
+	// SEEDING
+	DevMode  bool   `env:"SERVER_DEV_MODE"`  // populate and reset are only available in development mode
+	SeedFile string `env:"SERVER_SEED_FILE"` // YAML or JSON fixture of populate and reset, generated data if empty
+	Seed     int64  `env:"SERVER_SEED"`      // seed of the generated data, 0 for a random one
+}
+
+// Reloadable the settings that are applied at runtime when the configuration is reloaded (SIGHUP or
+// a change of the config file). The rest of the settings are structural, they are only read at startup
+type Reloadable struct {
+	Debug            bool
+	LogLevel         string
+	EveryTime        int
+	MinFreeDiskMB    uint64
+	MinBatteryToLoad float64
 }
 
-// SvcConfig exported configuration service struct
+// SvcConfig exported configuration service struct. The embedded conf holds the values read at
+// startup, the reloadable settings must be read with the Reloadable method to see the changes
 type SvcConfig struct {
 	Path string `string:"Path to the config YAML file"`
 	conf `conf:"Configuration object"`
+
+	mu      sync.RWMutex
+	live    Reloadable
+	modTime time.Time
+}
+
+// ConfigError invalid configuration, it lists every problem found so all of them can be fixed at once
+type ConfigError struct {
+	Path     string
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	source := e.Path
+	if source == "" {
+		source = "defaults and environment"
+	}
+	return fmt.Sprintf("invalid configuration (%s):\n  - %s", source, strings.Join(e.Problems, "\n  - "))
 }
 
 // endregion =============================================================================
 
-// NewSvcConfig create a new configuration service.
-func NewSvcConfig() *SvcConfig {
-	c := conf{}
+// NewSvcConfig create a new configuration service. The settings are the defaults, overridden by the
+// config file and then by the environment variables. The config file is the one of the path parameter
+// (e.g. the --config flag), the SERVER_CONFIG environment variable or ./conf/conf.yaml, in that order;
+// without any of them the server runs with the defaults and the environment variables
+//
+// - path [string] ~ Path to the config YAML file, empty to look for it
+func NewSvcConfig(path string) (*SvcConfig, error) {
+	path, err := resolveConfigPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := loadConf(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &SvcConfig{Path: path, conf: c, live: c.reloadable()} // We are using struct composition here. Hence, the anonymous field (https://golangbot.com/inheritance/)
+	s.modTime = fileModTime(path)
+	return s, nil
+}
 
-	var configPath = lib.GetEnvOrError(schema.EnvConfigPath)
-	var jwtSignKey = "secret__sample__with__32__chars_" // lib.GetEnvOrError(schema.EnvJWTSignKey)
+// region ======== METHODS ===============================================================
 
-	exist, err := lib.FileExists(configPath)
+// Reloadable the current value of the reloadable settings
+func (s *SvcConfig) Reloadable() Reloadable {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.live
+}
+
+// Reload read the configuration again and apply the reloadable settings. On error the current
+// settings are kept. It returns the previous reloadable settings, so the caller can apply the
+// changes, and the structural settings that changed and are ignored until the next restart
+func (s *SvcConfig) Reload() (previous Reloadable, ignored []string, err error) {
+	c, err := loadConf(s.Path)
+	if err != nil {
+		return s.Reloadable(), nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	previous = s.live
+	s.live = c.reloadable()
+	s.modTime = fileModTime(s.Path)
+	return previous, structuralChanges(s.conf, c), nil
+}
+
+// FileChanged report if the config file has been modified since it was last read
+func (s *SvcConfig) FileChanged() bool {
+	if s.Path == "" {
+		return false
+	}
+	modTime := fileModTime(s.Path)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return !modTime.IsZero() && !modTime.Equal(s.modTime)
+}
+
+// endregion =============================================================================
+
+// region ======== PRIVATE AUX ===========================================================
+
+// defaultConf the settings used when they are neither in the config file nor in the environment
+func defaultConf() conf {
+	return conf{
+		DappPort:         "7001",
+		GrpcPort:         "7002",
+		ShutdownTimeout:  15,
+		LogLevel:         "info",
+		LogFormat:        "json",
+		TraceExporter:    "none",
+		TraceFilePath:    "./db/traces.json",
+		OTLPEndpoint:     "localhost:4318",
+		OTLPInsecure:     true,
+		JWTSignKey:       "secret__sample__with__32__chars_",
+		TkMaxAge:         180,
+		StoreBackend:     schema.StoreBackendBuntDB,
+		StoreDBPath:      "./db/data.db",
+		AuditDBPath:      "./db/audit.db",
+		MinFreeDiskMB:    100,
+		MinBatteryToLoad: dto.MinBatteryToLoad,
+		CronEnabled:      true,
+		LogDBPath:        "./db/event_log.db",
+		EveryTime:        300,
+		BackupDir:        "./db/backups",
+		BackupEvery:      86400,
+		BackupRetention:  7,
+
+		GraphQLMaxDepth:      8,
+		GraphQLMaxComplexity: 1000,
+
+		IdempotencyDBPath: "./db/idempotency.db",
+		IdempotencyTTL:    86400,
+	}
+}
+
+// resolveConfigPath the config file to read, an explicit path (flag or SERVER_CONFIG) must exist
+func resolveConfigPath(path string) (string, error) {
+	source := "--config flag"
+	if path == "" {
+		path, source = os.Getenv(schema.EnvConfigPath), schema.EnvConfigPath+" environment variable"
+	}
+	if path == "" {
+		if exist, _ := lib.FileExists(schema.DefaultConfigPath); exist {
+			return schema.DefaultConfigPath, nil
+		}
+		return "", nil
+	}
+
+	exist, err := lib.FileExists(path)
 	if err != nil || !exist {
-		panic(fmt.Errorf("server config file not found, check the %s environment variable", schema.EnvConfigPath))
+		return "", fmt.Errorf("server config file '%s' not found, check the %s", path, source)
 	}
+	return path, nil
+}
 
-	err = gonfig.GetConf(configPath, &c) // getting the conf
-	if err != nil {
-		panic(err)
-	} // error check
+// loadConf read the defaults, the config file (if any) and the environment, and validate the result
+func loadConf(path string) (conf, error) {
+	c := defaultConf()
+	problems := make([]string, 0)
 
-	c.JWTSignKey = jwtSignKey // saving the sign key into the configuration object
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return c, err
+		}
+		problems = append(problems, unknownSettings(data)...)
+		if err := yaml.Unmarshal(data, &c); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
 
-	return &SvcConfig{configPath, c} // We are using struct composition here. Hence, the anonymous field (https://golangbot.com/inheritance/)
+	problems = append(problems, applyEnv(&c)...)
+	problems = append(problems, c.validate()...)
+	if len(problems) > 0 {
+		return c, &ConfigError{Path: path, Problems: problems}
+	}
+	return c, nil
+}
+
+// unknownSettings the keys of the config file that are not settings, usually a typo
+func unknownSettings(data []byte) []string {
+	keys := make(map[string]interface{})
+	if err := yaml.Unmarshal(data, &keys); err != nil {
+		return nil // reported by the typed unmarshal
+	}
+
+	t := reflect.TypeOf(conf{})
+	problems := make([]string, 0)
+	for k := range keys {
+		if _, ok := t.FieldByNameFunc(func(name string) bool { return strings.EqualFold(name, k) }); !ok {
+			problems = append(problems, fmt.Sprintf("%s: unknown setting", k))
+		}
+	}
+	return problems
+}
+
+// applyEnv override the settings with the environment variables of the env tags
+func applyEnv(c *conf) []string {
+	problems := make([]string, 0)
+	v := reflect.ValueOf(c).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		env := v.Type().Field(i).Tag.Get("env")
+		value, ok := os.LookupEnv(env)
+		if env == "" || !ok {
+			continue
+		}
+
+		f := v.Field(i)
+		var err error
+		switch f.Kind() {
+		case reflect.String:
+			f.SetString(value)
+		case reflect.Bool:
+			var b bool
+			if b, err = strconv.ParseBool(value); err == nil {
+				f.SetBool(b)
+			}
+		case reflect.Int, reflect.Int64:
+			var n int64
+			if n, err = strconv.ParseInt(value, 10, f.Type().Bits()); err == nil {
+				f.SetInt(n)
+			}
+		case reflect.Uint8, reflect.Uint64:
+			var n uint64
+			if n, err = strconv.ParseUint(value, 10, f.Type().Bits()); err == nil {
+				f.SetUint(n)
+			}
+		case reflect.Float64:
+			var n float64
+			if n, err = strconv.ParseFloat(value, 64); err == nil {
+				f.SetFloat(n)
+			}
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: '%s' is not a valid %s", env, value, f.Kind()))
+		}
+	}
+	return problems
+}
+
+// validate check the settings, all the problems are returned
+func (c *conf) validate() []string {
+	problems := make([]string, 0)
+	add := func(field, format string, args ...interface{}) {
+		problems = append(problems, field+": "+fmt.Sprintf(format, args...))
+	}
+
+	if port, err := strconv.Atoi(c.DappPort); err != nil || port < 1 || port > 65535 {
+		add("DappPort", "must be a port number between 1 and 65535, got '%s'", c.DappPort)
+	}
+	if c.GrpcPort != "" {
+		if port, err := strconv.Atoi(c.GrpcPort); err != nil || port < 1 || port > 65535 {
+			add("GrpcPort", "must be empty or a port number between 1 and 65535, got '%s'", c.GrpcPort)
+		} else if c.GrpcPort == c.DappPort {
+			add("GrpcPort", "can't be the same port of DappPort")
+		}
+	}
+	if c.ShutdownTimeout < 0 {
+		add("ShutdownTimeout", "can't be negative, got %d", c.ShutdownTimeout)
+	}
+	if !oneOf(c.LogLevel, "debug", "info", "warn", "error", "disable") {
+		add("LogLevel", "must be debug, info, warn, error or disable, got '%s'", c.LogLevel)
+	}
+	if !oneOf(c.LogFormat, "json", "text") {
+		add("LogFormat", "must be json or text, got '%s'", c.LogFormat)
+	}
+	switch c.TraceExporter {
+	case "none", "stdout":
+	case "file":
+		if c.TraceFilePath == "" {
+			add("TraceFilePath", "is required by the file exporter")
+		}
+	case "otlp":
+		if c.OTLPEndpoint == "" {
+			add("OTLPEndpoint", "is required by the otlp exporter")
+		}
+	default:
+		add("TraceExporter", "must be none, stdout, file or otlp, got '%s'", c.TraceExporter)
+	}
+	if len(c.JWTSignKey) < 32 {
+		add("JWTSignKey", "must have at least 32 characters")
+	}
+	if c.TkMaxAge == 0 {
+		add("TkMaxAge", "must be at least 1 minute")
+	}
+	switch c.StoreBackend {
+	case schema.StoreBackendBuntDB:
+	case schema.StoreBackendPostgres:
+		if c.PostgresDSN == "" {
+			add("PostgresDSN", "is required by the postgres backend")
+		}
+	default:
+		add("StoreBackend", "must be buntdb or postgres, got '%s'", c.StoreBackend)
+	}
+	for field, path := range map[string]string{"StoreDBPath": c.StoreDBPath, "AuditDBPath": c.AuditDBPath, "IdempotencyDBPath": c.IdempotencyDBPath, "LogDBPath": c.LogDBPath, "BackupDir": c.BackupDir} {
+		if path == "" {
+			add(field, "is required")
+		}
+	}
+	if c.IdempotencyTTL < 1 {
+		add("IdempotencyTTL", "must be at least 1 second, got %d", c.IdempotencyTTL)
+	}
+	if c.GraphQLMaxDepth < 1 {
+		add("GraphQLMaxDepth", "must be at least 1, got %d", c.GraphQLMaxDepth)
+	}
+	if c.GraphQLMaxComplexity < 1 {
+		add("GraphQLMaxComplexity", "must be at least 1, got %d", c.GraphQLMaxComplexity)
+	}
+	if c.MinBatteryToLoad < 0 || c.MinBatteryToLoad > 100 {
+		add("MinBatteryToLoad", "must be a percentage between 0 and 100, got %v", c.MinBatteryToLoad)
+	}
+	if c.CronEnabled && c.EveryTime < 1 {
+		add("EveryTime", "must be at least 1 second when the cron job is enabled, got %d", c.EveryTime)
+	}
+	if c.BackupEvery < 0 {
+		add("BackupEvery", "can't be negative, use 0 to disable the scheduled backups, got %d", c.BackupEvery)
+	}
+	if c.BackupRetention < 1 {
+		add("BackupRetention", "must keep at least 1 snapshot, got %d", c.BackupRetention)
+	}
+	if c.SeedFile != "" {
+		if exist, _ := lib.FileExists(c.SeedFile); !exist {
+			add("SeedFile", "file '%s' not found", c.SeedFile)
+		}
+	}
+	return problems
+}
+
+// reloadable the reloadable settings of the configuration
+func (c *conf) reloadable() Reloadable {
+	return Reloadable{
+		Debug:            c.Debug,
+		LogLevel:         c.LogLevel,
+		EveryTime:        c.EveryTime,
+		MinFreeDiskMB:    c.MinFreeDiskMB,
+		MinBatteryToLoad: c.MinBatteryToLoad,
+	}
+}
+
+// structuralChanges the settings that are not reloadable and differ between both configurations
+func structuralChanges(current, loaded conf) []string {
+	reloadable := reflect.TypeOf(Reloadable{})
+	cv, lv := reflect.ValueOf(current), reflect.ValueOf(loaded)
+	changed := make([]string, 0)
+	for i := 0; i < cv.NumField(); i++ {
+		name := cv.Type().Field(i).Name
+		if _, ok := reloadable.FieldByName(name); ok {
+			continue
+		}
+		if cv.Field(i).Interface() != lv.Field(i).Interface() {
+			changed = append(changed, name)
+		}
+	}
+	return changed
+}
+
+func oneOf(value string, allowed ...string) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+	return false
+}
+
+func fileModTime(path string) time.Time {
+	if path == "" {
+		return time.Time{}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
 }
+
+// endregion =============================================================================
@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/kataras/golog"
+	"github.com/kmilodenisglez/drones.restapi/lib"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SvcLogger structured logger of the app. It wraps the Iris logger (golog) and attaches the
+// request ID carried by the context to every log line
+type SvcLogger struct {
+	logger *golog.Logger
+}
+
+// NewSvcLogger create a logger service instance. Depends on the app configuration instance
+//
+// - appConf [*SvcConfig] ~ App conf instance pointer
+func NewSvcLogger(appConf *SvcConfig) *SvcLogger {
+	l := golog.New().SetOutput(os.Stdout)
+	s := &SvcLogger{logger: l}
+	s.Configure(l, appConf)
+	return s
+}
+
+// Configure apply the level and format of the configuration to a golog logger, it is also used
+// to configure the logger of the Iris app
+//
+// - l [*golog.Logger] ~ Logger to configure
+//
+// - appConf [*SvcConfig] ~ App conf instance pointer
+func (s *SvcLogger) Configure(l *golog.Logger, appConf *SvcConfig) {
+	level := appConf.LogLevel
+	if level == "" {
+		level = "info"
+	}
+	l.SetLevel(level)
+	if appConf.LogFormat != "text" {
+		l.SetFormat("json", "")
+	}
+}
+
+// SetOutput change the destination of the log lines, e.g. the standard error for the command line
+func (s *SvcLogger) SetOutput(w io.Writer) {
+	s.logger.SetOutput(w)
+}
+
+// SetLevel change the level of the logger at runtime (debug, info, warn, error or disable)
+func (s *SvcLogger) SetLevel(level string) {
+	s.logger.SetLevel(level)
+}
+
+func (s *SvcLogger) Debugf(ctx context.Context, format string, args ...interface{}) {
+	s.logger.Debugf(format, append(args, fields(ctx))...)
+}
+
+func (s *SvcLogger) Infof(ctx context.Context, format string, args ...interface{}) {
+	s.logger.Infof(format, append(args, fields(ctx))...)
+}
+
+func (s *SvcLogger) Warnf(ctx context.Context, format string, args ...interface{}) {
+	s.logger.Warnf(format, append(args, fields(ctx))...)
+}
+
+func (s *SvcLogger) Errorf(ctx context.Context, format string, args ...interface{}) {
+	s.logger.Errorf(format, append(args, fields(ctx))...)
+}
+
+// InfoFields log a message with extra structured fields, besides the request ID
+func (s *SvcLogger) InfoFields(ctx context.Context, msg string, extra golog.Fields) {
+	f := fields(ctx)
+	for k, v := range extra {
+		f[k] = v
+	}
+	s.logger.Info(msg, f)
+}
+
+// fields structured fields of a log line taken from the context: the request ID and, if the request
+// is traced, the trace and span IDs
+func fields(ctx context.Context) golog.Fields {
+	f := golog.Fields{}
+	if requestID := lib.RequestIDFromContext(ctx); requestID != "" {
+		f["requestId"] = requestID
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		f["traceId"] = sc.TraceID().String()
+		f["spanId"] = sc.SpanID().String()
+	}
+	return f
+}
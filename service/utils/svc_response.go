@@ -1,10 +1,32 @@
 package utils
 
 import (
+	"bytes"
+	"encoding/csv"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kataras/golog"
 	"github.com/kataras/iris/v12"
+	"github.com/kataras/iris/v12/context"
+	"github.com/kataras/iris/v12/middleware/requestid"
+	"github.com/kmilodenisglez/drones.restapi/schema"
 	"github.com/kmilodenisglez/drones.restapi/schema/dto"
+	"github.com/kmilodenisglez/drones.restapi/service/i18n"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
 )
 
+// ContentCSVHeaderValue media type of the CSV representation of a list
+const ContentCSVHeaderValue = "text/csv"
+
+// ListFormats representations of a list besides JSON and MessagePack, built only when negotiated.
+// A nil function means the list has no such representation
+type ListFormats struct {
+	Protobuf func() proto.Message
+	CSV      func() [][]string // records, the first one is the header
+}
 
 type SvcResponse struct {
 	appConf *SvcConfig
@@ -28,14 +50,11 @@ func NewSvcResponse(appConf *SvcConfig) *SvcResponse {
 //
 // - ctx [*iris.Context] ~ Iris Request context
 func (s SvcResponse) ResWithDataStatus(status int, data interface{}, ctx *iris.Context)  {
-	// TIP: negotiate the response between server's prioritizes
-	// and client's requirements, instead of ctx.JSON:
-	// ctx.Negotiation().JSON().MsgPack().Protobuf()
-	// ctx.Negotiate(books)
+	// TIP: the lists negotiate their representation, see ResOKWithList
+	(*ctx).StatusCode(status) // the status must be set before the body is written
 	if _, err := (*ctx).JSON(data); err != nil {																									// Logging *marshal* json if error occurs (come internally from iris)
 		(*ctx).Application().Logger().Error(err.Error())
 	}
-	(*ctx).StatusCode(status)
 }
 
 // ResOKWithData create response 200 with specified data converted to json in to the context.
@@ -48,6 +67,53 @@ func (s SvcResponse) ResOKWithData(data interface{}, ctx *iris.Context) {
 	(*ctx).StatusCode(iris.StatusOK)
 }
 
+// ResOKWithList create response 200 with the list in the representation negotiated with the Accept header:
+// JSON (the default), MessagePack, and Protobuf or CSV when the list has them. Responds 406 if the client
+// accepts none of them.
+//
+// - data [interface] ~ List to be marshalled in to the context, its JSON and MessagePack representation
+//
+// - formats [ListFormats] ~ Other representations of the list
+//
+// - ctx [*iris.Context] ~ Iris Request context
+func (s SvcResponse) ResOKWithList(data interface{}, formats ListFormats, ctx *iris.Context) {
+	offered := []string{context.ContentJSONHeaderValue, context.ContentMsgPackHeaderValue, context.ContentMsgPack2HeaderValue}
+	if formats.Protobuf != nil {
+		offered = append(offered, context.ContentProtobufHeaderValue)
+	}
+	if formats.CSV != nil {
+		offered = append(offered, ContentCSVHeaderValue)
+	}
+	(*ctx).Header("Vary", "Accept")
+
+	var err error
+	switch contentType := negotiate((*ctx).GetHeader("Accept"), offered); contentType {
+	case "":
+		s.ResErr(dto.NewProblemf(iris.StatusNotAcceptable, schema.ErrNotAcceptable, schema.DetNotAcceptable, strings.Join(offered, ", ")), ctx)
+		return
+	case context.ContentMsgPackHeaderValue, context.ContentMsgPack2HeaderValue:
+		// the keys are the JSON field names, the same as in the JSON representation
+		var buf bytes.Buffer
+		enc := msgpack.NewEncoder(&buf)
+		enc.SetCustomStructTag("json")
+		if err = enc.Encode(data); err == nil {
+			(*ctx).ContentType(contentType)
+			_, err = (*ctx).Write(buf.Bytes())
+		}
+	case context.ContentProtobufHeaderValue:
+		_, err = (*ctx).Protobuf(formats.Protobuf())
+	case ContentCSVHeaderValue:
+		(*ctx).ContentType(ContentCSVHeaderValue)
+		err = csv.NewWriter((*ctx).ResponseWriter()).WriteAll(formats.CSV())
+	default:
+		_, err = (*ctx).JSON(data)
+	}
+	if err != nil {
+		(*ctx).Application().Logger().Error(err.Error())
+	}
+	(*ctx).StatusCode(iris.StatusOK)
+}
+
 // ResOK create a response OK but with an empty content (204)
 //
 // - ctx [*iris.Context] ~ Iris Request context
@@ -69,10 +135,7 @@ func (s SvcResponse) ResCreated(ctx *iris.Context)  {
 //
 // - ctx [*iris.Context] ~ Iris Request context
 func (s SvcResponse) ResCreatedWithData(data interface{}, ctx *iris.Context) {
-	if _, err := (*ctx).JSON(data); err != nil {																									// Logging *marshal* json if error occurs (come internally from iris)
-		(*ctx).Application().Logger().Error(err.Error())
-	}
-	(*ctx).StatusCode(iris.StatusCreated)
+	s.ResWithDataStatus(iris.StatusCreated, data, ctx)
 }
 
 
@@ -88,20 +151,156 @@ func (s SvcResponse) ResDelete(ctx *iris.Context)  {
 // region ======== ERROR RESPONSES =======================================================
 
 // ResErr create and log an 'Error GrantIntentResponse' to the stdout and setup the request context properly.
-// Also set the response status = specific status code, so we can respond the request accordingly (application/problem+json).
-// Ideally, this should be used for client error series (400s) or server error series (500)
+// The status and the type URI come from the problem catalogue of the error code, so a code is always
+// answered the same way (application/problem+json, RFC 7807). The title, the detail and the messages of
+// the invalid fields are translated to the locale of the request (Accept-Language). The details of the
+// internal errors are only sent in debug mode, they are always logged
 //
 // - apiError [*dto.Problem] ~ Error struct
 //
 // - ctx [*iris.Context] ~ Iris Request context
 func (s SvcResponse) ResErr(apiError *dto.Problem, ctx *iris.Context) {
-	d := apiError.Detail
+	completeProblem(apiError, ctx)
+
+	// the details are always logged in the default language, tied to the request by its ID
+	detail := i18n.Message(i18n.DefaultLanguage, apiError.Detail, apiError.DetailArgs...)
+	(*ctx).Application().Logger().Warnf("%s: %s", apiError.Code, detail, golog.Fields{"requestId": apiError.RequestID, "status": apiError.Status})
+
+	lang := requestLanguage(ctx)
+	problem := s.publicProblem(*apiError, lang)
+
+	(*ctx).Header("Content-Language", lang)
+	(*ctx).StopWithStatus(int(problem.Status))
+	if _, err := (*ctx).Problem(problem); err != nil {
+		(*ctx).Application().Logger().Error(err.Error())
+	}
+}
+
+// ResBulkReport create the response of a bulk request with the result of every item: 200 if all of them
+// succeeded, 207 (Multi-Status) if some failed in best-effort mode and 422 if an atomic request was
+// rejected, then nothing was written. The problems of the items are answered like ResErr does
+//
+// - report [*dto.BulkReport] ~ Result of the items
+//
+// - ctx [*iris.Context] ~ Iris Request context
+func (s SvcResponse) ResBulkReport(report *dto.BulkReport, ctx *iris.Context) {
+	status := iris.StatusOK
+	if report.Failed > 0 {
+		status = iris.StatusMultiStatus
+		if report.Mode == dto.BulkModeAtomic {
+			status = iris.StatusUnprocessableEntity
+		}
+
+		lang := requestLanguage(ctx)
+		for i := range report.Items {
+			if report.Items[i].Problem == nil {
+				continue
+			}
+			completeProblem(report.Items[i].Problem, ctx)
+			problem := s.publicProblem(*report.Items[i].Problem, lang)
+			report.Items[i].Problem = &problem
+		}
+		(*ctx).Application().Logger().Warnf("%d of %d items failed", report.Failed, report.Total, golog.Fields{"requestId": requestid.Get(*ctx), "mode": report.Mode})
+		(*ctx).Header("Content-Language", lang)
+	}
+	s.ResWithDataStatus(status, report, ctx)
+}
+
+// Localize a copy of the problem with the title, the detail and the messages of the invalid fields in the
+// given language. The details and messages that are not keys of the catalogues (e.g. the errors of a
+// library) are kept as is
+//
+// - problem [dto.Problem] ~ Problem to translate
+//
+// - lang [string] ~ Language of the catalogues, e.g. es-ES
+func Localize(problem dto.Problem, lang string) dto.Problem {
+	problem.Title = i18n.Title(lang, problem.Code)
+	problem.Detail = i18n.Message(lang, problem.Detail, problem.DetailArgs...)
+	if len(problem.Errors) > 0 {
+		fieldErrors := make([]dto.FieldError, len(problem.Errors))
+		for i, fe := range problem.Errors {
+			if msg := i18n.Validation(lang, lastSegment(fe.Field), fe.Rule, fe.Args...); msg != "" {
+				fe.Message = msg
+			}
+			fieldErrors[i] = fe
+		}
+		problem.Errors = fieldErrors
+	}
+	return problem
+}
+
+// endregion =============================================================================
+
+// region ======== PRIVATE AUX ===========================================================
+
+// completeProblem set the status and the type URI of the problem code, the path and the ID of the request
+func completeProblem(apiError *dto.Problem, ctx *iris.Context) {
+	apiError.Status = uint(schema.ProblemTypeOf(apiError.Code).Status)
+	apiError.Type = schema.ProblemTypeURI(apiError.Code)
+	apiError.Instance = (*ctx).Path()
+	apiError.RequestID = requestid.Get(*ctx)
+}
+
+// publicProblem the problem translated to the language, the details of the internal errors are hidden
+// unless the environment debug config is true
+func (s SvcResponse) publicProblem(apiError dto.Problem, lang string) dto.Problem {
+	problem := Localize(apiError, lang)
+	if schema.ProblemTypeOf(apiError.Code).Internal && !s.appConf.Reloadable().Debug {
+		problem.Detail = ""
+	}
+	return problem
+}
+
+// requestLanguage the language of the request locale (Accept-Language), the default one without locale
+func requestLanguage(ctx *iris.Context) string {
+	if locale := (*ctx).GetLocale(); locale != nil {
+		return locale.Language()
+	}
+	return i18n.DefaultLanguage
+}
 
-	// If the environment debug config isn't true then retrieve no details
-	if !s.appConf.Debug {d = ""}
+// lastSegment the name of a field given its path, e.g. "medications.code" is "code"
+func lastSegment(field string) string {
+	return field[strings.LastIndex(field, ".")+1:]
+}
 
-	(*ctx).StopWithProblem(int(apiError.Status), iris.NewProblem().Title(apiError.Title).Detail(d))
+// negotiate the first offered media type accepted by the client, following the preference (quality value)
+// of the Accept header. The wildcards "*/*" and "type/*" are supported; without Accept header the first
+// offered media type is returned, and an empty string if the client accepts none of them
+func negotiate(accept string, offered []string) string {
+	if strings.TrimSpace(accept) == "" {
+		return offered[0]
+	}
 
-	return
+	type mediaRange struct {
+		mime    string
+		quality float64
+	}
+	ranges := make([]mediaRange, 0)
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		r := mediaRange{mime: strings.ToLower(strings.TrimSpace(params[0])), quality: 1}
+		for _, p := range params[1:] {
+			if kv := strings.SplitN(strings.TrimSpace(p), "=", 2); len(kv) == 2 && kv[0] == "q" {
+				if q, err := strconv.ParseFloat(kv[1], 64); err == nil {
+					r.quality = q
+				}
+			}
+		}
+		if r.mime != "" && r.quality > 0 {
+			ranges = append(ranges, r)
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].quality > ranges[j].quality })
+
+	for _, r := range ranges {
+		for _, mime := range offered {
+			if r.mime == mime || r.mime == "*/*" || (strings.HasSuffix(r.mime, "/*") && strings.HasPrefix(mime, strings.TrimSuffix(r.mime, "*"))) {
+				return mime
+			}
+		}
+	}
+	return ""
 }
+
 // endregion =============================================================================
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/kataras/iris/v12"
+	"github.com/kmilodenisglez/drones.restapi/repo/db"
+)
+
+// defaultShutdownTimeout used when ShutdownTimeout is not configured
+const defaultShutdownTimeout = 15 * time.Second
+
+// gracefulShutdown stop the app in order, sharing the ShutdownTimeout deadline:
+//
+// 1. stop accepting requests and drain the in-flight ones, of the REST and the gRPC servers
+//
+// 2. stop the cron scheduler and wait for a run in progress
+//
+// 3. flush the pending spans
+//
+// 4. refuse new database handles and wait until the open ones are synced and closed
+//
+// - app [*iris.Application] ~ Iris App instance
+//
+// - svc [*appServices] ~ Services created by newApp
+//
+// - sig [os.Signal] ~ The signal that triggered the shutdown
+func gracefulShutdown(app *iris.Application, svc *appServices, sig os.Signal) {
+	timeout := defaultShutdownTimeout
+	if svc.config.ShutdownTimeout > 0 {
+		timeout = time.Duration(svc.config.ShutdownTimeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	svc.logger.Infof(ctx, "%s received, shutting down (timeout %s)", sig, timeout)
+
+	grpcStopped := make(chan struct{})
+	go func() {
+		defer close(grpcStopped)
+		svc.grpcServer.GracefulStop()
+	}()
+	if err := app.Shutdown(ctx); err != nil {
+		svc.logger.Errorf(ctx, "the in-flight requests could not be drained: %s", err)
+	}
+	select {
+	case <-grpcStopped:
+	case <-ctx.Done():
+		// the streams that are still open (e.g. following the event logs) are closed
+		svc.grpcServer.Stop()
+		svc.logger.Errorf(ctx, "the in-flight gRPC calls could not be drained: %s", ctx.Err())
+	}
+	if err := svc.cronJob.StopCronJob(ctx); err != nil {
+		svc.logger.Errorf(ctx, "the cron job could not be stopped: %s", err)
+	}
+	if err := svc.shutdownTracing(ctx); err != nil {
+		svc.logger.Errorf(ctx, "the pending spans could not be exported: %s", err)
+	}
+	if err := db.CloseDatabases(ctx); err != nil {
+		svc.logger.Errorf(ctx, "the databases could not be closed: %s", err)
+		return
+	}
+
+	svc.logger.Infof(ctx, "shutdown completed")
+}